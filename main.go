@@ -6,21 +6,28 @@ import (
 	"bufio"
 	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/bigneek/picoflare/pkg/agent"
 	"github.com/bigneek/picoflare/pkg/bot"
 	cf "github.com/bigneek/picoflare/pkg/cloudflare"
+	"github.com/bigneek/picoflare/pkg/cognition"
 	"github.com/bigneek/picoflare/pkg/llm"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/slack"
 	"github.com/bigneek/picoflare/pkg/storage"
+	"github.com/bigneek/picoflare/pkg/tracing"
 )
 
 //go:embed workers/fib3d/index.js
@@ -31,6 +38,14 @@ func main() {
 		log.Printf("No .env file found: %v", err)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Printf("Tracing disabled: %v", err)
+	} else if tracing.Enabled() {
+		log.Printf("Tracing: exporting spans over OTLP")
+	}
+	defer shutdownTracing()
+
 	accountID := os.Getenv("CLOUDFLARE_ACCOUNT_ID")
 	apiToken := os.Getenv("CLOUDFLARE_API_TOKEN")
 	r2AccessKey := os.Getenv("R2_ACCESS_KEY_ID")
@@ -53,19 +68,10 @@ func main() {
 		runAgent(accountID, apiToken, r2AccessKey, r2SecretKey)
 		return
 	case "bot":
-		workspace, _ := os.Getwd()
-		runBot(bot.Config{
-			TelegramToken:  telegramToken,
-			AccountID:      accountID,
-			APIToken:       apiToken,
-			R2AccessKey:    r2AccessKey,
-			R2SecretKey:    r2SecretKey,
-			R2Bucket:       "pico-flare",
-			VectorizeIndex: "picoflare-memory",
-			LLMAPIKey:      os.Getenv("OPENROUTER_API_KEY"),
-			LLMModel:       os.Getenv("OPENROUTER_MODEL"),
-			Workspace:      workspace,
-		})
+		runBot(botConfigFromEnv(accountID, apiToken, r2AccessKey, r2SecretKey, telegramToken))
+		return
+	case "slack":
+		runSlack(accountID, apiToken, r2AccessKey, r2SecretKey)
 		return
 	case "mcp-test":
 		runMCPTest(accountID, apiToken)
@@ -76,12 +82,21 @@ func main() {
 		}
 		ctx := context.Background()
 		client := cf.NewClient(accountID, apiToken)
-		if err := client.DeployWorker(ctx, "fib3d", fib3dWorkerJS); err != nil {
+		if err := client.DeployWorker(ctx, "fib3d", fib3dWorkerJS, cf.WorkerSettings{}); err != nil {
 			log.Fatalf("Deploy fib3d failed: %v", err)
 		}
 		url := client.GetWorkerURL(ctx, "fib3d")
 		fmt.Printf("fib3d deployed: %s\n", url)
 		return
+	case "bootstrap":
+		runBootstrap(accountID, apiToken)
+		return
+	case "inventory":
+		runInventory(accountID, apiToken, os.Args[2:])
+		return
+	case "deploy-worker":
+		runDeployWorker(accountID, apiToken, os.Args[2:])
+		return
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %q\n", cmd)
 		printHelp()
@@ -96,14 +111,21 @@ Usage:
   picoflare              Run pico-flare agent (interactive; default)
   picoflare agent        Run pico-flare agent (interactive)
   picoflare bot          Telegram bot (TELEGRAM_BOT_TOKEN required)
+  picoflare slack        Slack app via the Events API (SLACK_BOT_TOKEN, SLACK_SIGNING_SECRET required)
   picoflare mcp-test     Create R2 bucket + Vectorize index via MCP
   picoflare deploy-fib3d Deploy fib3d Worker
+  picoflare deploy-worker <name> <path.js> [--service-worker]
+                         Deploy a local JS file as a Worker (ES module by default)
+  picoflare bootstrap    Create the pico-flare R2 bucket + Vectorize index if missing
+  picoflare inventory [--json]
+                         Print a snapshot of Cloudflare resources (no LLM key needed)
   picoflare help         Show this help
 
 When the MCP server is unavailable, the agent falls back to the Cloudflare
 REST API so you still get Workers, R2, KV, D1, and Vectorize tools.
 
 Set CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN, OPENROUTER_API_KEY in .env.
+Set LLM_PROVIDER=workers-ai to run the LLM on Cloudflare Workers AI instead.
 `)
 }
 
@@ -111,10 +133,66 @@ func runAgent(accountID, apiToken, r2AccessKey, r2SecretKey string) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	ag := newAgent(ctx, accountID, apiToken, r2AccessKey, r2SecretKey)
+
+	fmt.Println("pico-flare agent — Interactive mode (Ctrl+C to exit)")
+	fmt.Println()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		reply := ag.ProcessMessage(agent.WithOwner(ctx), 0, line) // the local user runs the bot
+		fmt.Println(reply)
+		fmt.Println()
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("pico-flare agent: read error: %v", err)
+	}
+}
+
+// runSlack serves the agent as a Slack app over the Events API.
+func runSlack(accountID, apiToken, r2AccessKey, r2SecretKey string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cfg := slack.Config{
+		BotToken:      os.Getenv("SLACK_BOT_TOKEN"),
+		SigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+		Addr:          os.Getenv("SLACK_LISTEN"),
+		Path:          os.Getenv("SLACK_EVENTS_PATH"),
+	}
+	if cfg.BotToken == "" || cfg.SigningSecret == "" {
+		log.Fatal("SLACK_BOT_TOKEN and SLACK_SIGNING_SECRET are required for picoflare slack. Set them in .env.")
+	}
+
+	ag := newAgent(ctx, accountID, apiToken, r2AccessKey, r2SecretKey)
+	adapter, err := slack.New(cfg, ag)
+	if err != nil {
+		log.Fatalf("Slack init failed: %v", err)
+	}
+	if err := adapter.Run(ctx); err != nil {
+		log.Fatalf("Slack error: %v", err)
+	}
+}
+
+// newAgent connects to Cloudflare (MCP, else REST), R2, and the configured LLM
+// and returns an agent without Telegram-specific wiring.
+func newAgent(ctx context.Context, accountID, apiToken, r2AccessKey, r2SecretKey string) *agent.Agent {
+	checkConfig(botConfigFromEnv(accountID, apiToken, r2AccessKey, r2SecretKey, "").ValidateAgent())
+
 	// Try MCP; on failure fall back to Cloudflare REST API (cfClient)
 	var mcp *mcpclient.Client
 	if accountID != "" && apiToken != "" {
-		mcp = mcpclient.NewClient("https://mcp.cloudflare.com/mcp", apiToken, accountID)
+		mcp = newMCPClient(apiToken, accountID) // nil if MCP_ENDPOINT is invalid
+	}
+	if mcp != nil {
 		if err := mcp.Initialize(ctx); err != nil {
 			log.Printf("MCP unavailable (%v), using Cloudflare REST API for Cloudflare operations", err)
 			mcp = nil
@@ -136,28 +214,57 @@ func runAgent(accountID, apiToken, r2AccessKey, r2SecretKey string) {
 		}
 	}
 
-	var r2 *storage.R2Client
-	if accountID != "" && r2AccessKey != "" && r2SecretKey != "" {
-		r2Client, err := storage.NewR2Client(accountID, r2AccessKey, r2SecretKey)
+	var r2 storage.ObjectStore
+	r2Endpoint := os.Getenv("R2_ENDPOINT")
+	switch {
+	case os.Getenv("STORAGE_BACKEND") == storage.BackendMemory:
+		r2 = storage.NewMemStore()
+		log.Printf("pico-flare agent: storage in memory (STORAGE_BACKEND=memory); nothing persists across restarts")
+	case os.Getenv("STORAGE_BACKEND") == storage.BackendFile:
+		root := os.Getenv("STORAGE_ROOT")
+		if root == "" {
+			root = storage.DefaultFileRoot
+		}
+		fileStore, err := storage.NewFileStore(root)
+		if err != nil {
+			log.Printf("File storage disabled: %v", err)
+		} else {
+			r2 = fileStore
+			log.Printf("pico-flare agent: storage in %s (STORAGE_BACKEND=file)", root)
+		}
+	case (accountID != "" || r2Endpoint != "") && r2AccessKey != "" && r2SecretKey != "":
+		r2Client, err := storage.NewR2Client(accountID, r2AccessKey, r2SecretKey, r2Endpoint)
 		if err != nil {
 			log.Printf("R2 client init failed (non-fatal): %v", err)
+		} else if err := r2Client.Verify(ctx, "pico-flare"); err != nil {
+			log.Printf("R2 disabled: %v — memory, file uploads, and other R2 features are off", err)
 		} else {
 			r2 = r2Client
 		}
 	}
 
 	llmAPIKey := os.Getenv("OPENROUTER_API_KEY")
-	llmModel := os.Getenv("OPENROUTER_MODEL")
-	if llmModel == "" {
-		llmModel = "anthropic/claude-3-5-sonnet"
-	}
+	llmModel := llmModelFromEnv()
 	var llmClient *llm.Client
-	if llmAPIKey != "" {
+	switch {
+	case os.Getenv("LLM_PROVIDER") == "workers-ai":
+		if accountID == "" || apiToken == "" {
+			log.Fatal("LLM_PROVIDER=workers-ai requires CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN. Set them in .env.")
+		}
+		llmClient = llm.NewWorkersAIClient(accountID, apiToken, llmModel)
+		log.Printf("pico-flare agent: LLM %s (Workers AI)", llmClient.Model)
+	case llmAPIKey != "" || llmBaseURLFromEnv() != "":
+		if llmModel == "" && llmBaseURLFromEnv() == "" {
+			llmModel = "anthropic/claude-3-5-sonnet"
+		}
 		llmClient = llm.NewClient(llmAPIKey, llmModel)
-		log.Printf("pico-flare agent: LLM %s", llmClient.Model)
-	} else {
-		log.Fatal("OPENROUTER_API_KEY is required for pico-flare agent. Set it in .env.")
+		llmClient.SetBaseURL(llmBaseURLFromEnv())
+		llmClient.ExcludeReasoning = os.Getenv("EXCLUDE_REASONING") == "true"
+		log.Printf("pico-flare agent: LLM %s (%s)", llmClient.Model, llmClient.Endpoint)
+	default:
+		log.Fatal("OPENROUTER_API_KEY (or LLM_BASE_URL for a local model) is required for pico-flare agent. Set it in .env.")
 	}
+	llmClient.Fallbacks = splitList(os.Getenv("LLM_FALLBACK_MODELS"))
 
 	workspace, _ := os.Getwd()
 	ag := agent.New(agent.Config{
@@ -168,38 +275,294 @@ func runAgent(accountID, apiToken, r2AccessKey, r2SecretKey string) {
 		Bucket:             "pico-flare",
 		AccountID:          accountID,
 		Workspace:          workspace,
+		VectorizeIndex:     vectorizeIndexFromEnv(),
 		OnSubagentComplete: nil,
+		EnabledTools:       splitList(os.Getenv("ENABLED_TOOLS")),
+		DisabledTools:      splitList(os.Getenv("DISABLED_TOOLS")),
+		PricingFile:        os.Getenv("MODEL_PRICING_FILE"),
+		MemoryBudget:       memoryBudgetFromEnv(),
+		PrivateMode:        os.Getenv("PRIVATE_MODE") == "true",
+		SubagentSampling:   llm.Sampling{Temperature: envTemperature("SUBAGENT_TEMPERATURE")},
+		SessionTTL:         envDuration("SESSION_TTL"),
+		StorageQuota:       envInt64("STORAGE_QUOTA_BYTES"),
+		SummaryModel:       summaryModelFromEnv(),
 	})
+	if ag.Ledger != nil {
+		budget := cognition.Budget{DailyUSD: envFloat("BUDGET_DAILY_USD"), MonthlyUSD: envFloat("BUDGET_MONTHLY_USD")}
+		if budget.DailyUSD > 0 || budget.MonthlyUSD > 0 {
+			ag.Ledger.SetBudget(budget, func(msg string) { log.Printf("Budget alert: %s", msg) })
+		}
+	}
+	return ag
+}
 
-	fmt.Println("pico-flare agent — Interactive mode (Ctrl+C to exit)")
-	fmt.Println()
+// llmModelFromEnv returns LLM_MODEL, or else the model for the selected LLM
+// provider: WORKERS_AI_MODEL when LLM_PROVIDER=workers-ai, otherwise
+// OPENROUTER_MODEL.
+func llmModelFromEnv() string {
+	if m := os.Getenv("LLM_MODEL"); m != "" {
+		return m
+	}
+	if os.Getenv("LLM_PROVIDER") == "workers-ai" {
+		return os.Getenv("WORKERS_AI_MODEL")
+	}
+	return os.Getenv("OPENROUTER_MODEL")
+}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("> ")
-		if !scanner.Scan() {
-			break
+// defaultVectorizeIndex holds fact embeddings when VECTORIZE_INDEX is unset.
+const defaultVectorizeIndex = "picoflare-memory"
+
+// vectorizeIndexFromEnv returns VECTORIZE_INDEX, or defaultVectorizeIndex.
+func vectorizeIndexFromEnv() string {
+	if index := os.Getenv("VECTORIZE_INDEX"); index != "" {
+		return index
+	}
+	return defaultVectorizeIndex
+}
+
+// defaultSummaryModel writes conversation summaries on OpenRouter when
+// SUMMARY_MODEL is unset.
+const defaultSummaryModel = "openai/gpt-4o-mini"
+
+// summaryModelFromEnv returns SUMMARY_MODEL. Unset, it's a cheap OpenRouter
+// model on OpenRouter, and the chat's model ("") on other providers, whose
+// model names differ.
+func summaryModelFromEnv() string {
+	if m := os.Getenv("SUMMARY_MODEL"); m != "" {
+		return m
+	}
+	if os.Getenv("LLM_PROVIDER") == "workers-ai" || llmBaseURLFromEnv() != "" {
+		return ""
+	}
+	return defaultSummaryModel
+}
+
+// llmBaseURLFromEnv returns LLM_BASE_URL, falling back to OPENROUTER_BASE_URL.
+// Empty means the default OpenRouter endpoint.
+// newMCPClient returns a client for MCP_ENDPOINT (default: Cloudflare's
+// hosted server) speaking MCP_PROTOCOL_VERSION, or nil if the endpoint is
+// not a valid URL.
+func newMCPClient(apiToken, accountID string) *mcpclient.Client {
+	endpoint := os.Getenv("MCP_ENDPOINT")
+	if endpoint == "" {
+		endpoint = mcpclient.DefaultEndpoint
+	}
+	if err := mcpclient.ValidateEndpoint(endpoint); err != nil {
+		log.Printf("MCP disabled: %v", err)
+		return nil
+	}
+	c := mcpclient.NewClient(endpoint, apiToken, accountID)
+	c.ProtocolVersion = os.Getenv("MCP_PROTOCOL_VERSION")
+	return c
+}
+
+func llmBaseURLFromEnv() string {
+	if u := os.Getenv("LLM_BASE_URL"); u != "" {
+		return u
+	}
+	return os.Getenv("OPENROUTER_BASE_URL")
+}
+
+// envFloat parses a numeric env value; empty or invalid = 0.
+func envFloat(name string) float64 {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Ignoring %s=%q: %v", name, v, err)
+		return 0
+	}
+	return f
+}
+
+// envTemperature parses a sampling temperature; empty or invalid = nil (the
+// model's default), so that 0 can be set.
+func envTemperature(name string) *float64 {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return nil
+	}
+	t, err := strconv.ParseFloat(v, 64)
+	if err != nil || t < 0 {
+		log.Printf("Ignoring %s=%q: not a temperature", name, v)
+		return nil
+	}
+	return llm.Temperature(t)
+}
+
+// envDuration parses a duration env value such as "90m"; empty or invalid = 0.
+func envDuration(name string) time.Duration {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Ignoring %s=%q: not a duration", name, v)
+		return 0
+	}
+	return d
+}
+
+// envInt64 parses an integer env value; empty or invalid = 0.
+func envInt64(name string) int64 {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("Ignoring %s=%q: %v", name, v, err)
+		return 0
+	}
+	return n
+}
+
+// memoryBudgetFromEnv reads MEMORY_CONTEXT_CHARS and MEMORY_CONTEXT_SPLIT
+// ("episodic,semantic,procedural" percentages). Unset = zero value (default budget).
+func memoryBudgetFromEnv() cognition.ContextBudget {
+	chars := envInt64("MEMORY_CONTEXT_CHARS")
+	split := splitList(os.Getenv("MEMORY_CONTEXT_SPLIT"))
+	if chars == 0 && len(split) == 0 {
+		return cognition.ContextBudget{}
+	}
+	b := cognition.DefaultBudget
+	if chars != 0 {
+		b.MaxTotalChars = int(chars)
+	}
+	if len(split) == 3 {
+		b.EpisodicPct, _ = strconv.Atoi(split[0])
+		b.SemanticPct, _ = strconv.Atoi(split[1])
+		b.ProceduralPct, _ = strconv.Atoi(split[2])
+	} else if len(split) > 0 {
+		log.Printf("Ignoring MEMORY_CONTEXT_SPLIT=%q: want episodic,semantic,procedural", os.Getenv("MEMORY_CONTEXT_SPLIT"))
+	}
+	return b
+}
+
+// splitList splits a comma-separated env value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
 		}
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	}
+	return out
+}
+
+// runInventory handles: inventory [--json]
+func runInventory(accountID, apiToken string, args []string) {
+	asJSON := false
+	for _, a := range args {
+		if a == "--json" || a == "-json" {
+			asJSON = true
 		}
-		reply := ag.ProcessMessage(ctx, 0, line)
-		fmt.Println(reply)
-		fmt.Println()
 	}
-	if err := scanner.Err(); err != nil {
-		log.Printf("pico-flare agent: read error: %v", err)
+	if accountID == "" || apiToken == "" {
+		log.Fatal("CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN required for inventory")
+	}
+
+	ctx := context.Background()
+	client := cf.NewClient(accountID, apiToken)
+	status, err := client.VerifyToken(ctx)
+	if err != nil {
+		log.Fatalf("Cloudflare API token check failed: %v", err)
+	}
+	inv := client.TakeInventory(ctx)
+
+	if asJSON {
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			log.Fatalf("Encode inventory: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Token: %s\n%s\n", status, inv.Summary())
+	for _, w := range inv.Workers {
+		fmt.Printf("  worker     %s\n", w.ID)
+	}
+	for _, kv := range inv.KV {
+		fmt.Printf("  kv         %s (%s)\n", kv.Title, kv.ID)
+	}
+	for _, db := range inv.D1 {
+		fmt.Printf("  d1         %s (%s)\n", db.Name, db.UUID)
+	}
+	for _, b := range inv.R2 {
+		fmt.Printf("  r2         %s\n", b.Name)
+	}
+	for _, v := range inv.Vectorize {
+		fmt.Printf("  vectorize  %s (%d dims)\n", v.Name, v.Dimensions)
 	}
 }
 
+// runBootstrap handles: bootstrap
+func runBootstrap(accountID, apiToken string) {
+	if accountID == "" || apiToken == "" {
+		log.Fatal("CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN required for bootstrap")
+	}
+	ctx := context.Background()
+	client := cf.NewClient(accountID, apiToken)
+	index := vectorizeIndexFromEnv()
+	created, err := client.EnsureResources(ctx, "pico-flare", index)
+	for _, c := range created {
+		fmt.Printf("Created %s\n", c)
+	}
+	if err != nil {
+		log.Fatalf("Bootstrap failed: %v", err)
+	}
+	if len(created) == 0 {
+		fmt.Printf("R2 bucket pico-flare and Vectorize index %s already exist\n", index)
+	}
+}
+
+// runDeployWorker handles: deploy-worker <name> <path.js> [--service-worker]
+func runDeployWorker(accountID, apiToken string, args []string) {
+	module := true
+	var positional []string
+	for _, a := range args {
+		switch a {
+		case "--service-worker", "-service-worker":
+			module = false
+		default:
+			positional = append(positional, a)
+		}
+	}
+	if len(positional) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: picoflare deploy-worker <name> <path.js> [--service-worker]")
+		os.Exit(2)
+	}
+	if accountID == "" || apiToken == "" {
+		log.Fatal("CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN required for deploy-worker")
+	}
+	name, path := positional[0], positional[1]
+	code, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Read %s: %v", path, err)
+	}
+
+	ctx := context.Background()
+	client := cf.NewClient(accountID, apiToken)
+	if err := client.DeployWorkerScript(ctx, name, string(code), module, cf.WorkerSettings{}); err != nil {
+		log.Fatalf("Deploy %s failed: %v", name, err)
+	}
+	fmt.Printf("%s deployed: %s\n", name, client.GetWorkerURL(ctx, name))
+}
+
 func runMCPTest(accountID, apiToken string) {
 	if accountID == "" || apiToken == "" {
 		log.Fatalf("CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN required for mcp-test (accountID=%q, tokenLen=%d)", accountID, len(apiToken))
 	}
 
 	ctx := context.Background()
-	mcp := mcpclient.NewClient("https://mcp.cloudflare.com/mcp", apiToken, accountID)
+	mcp := newMCPClient(apiToken, accountID)
+	if mcp == nil {
+		log.Fatal("mcp-test needs a valid MCP_ENDPOINT")
+	}
 
 	// 0. Initialize
 	fmt.Println("--- Initialize ---")
@@ -271,10 +634,74 @@ func runMCPTest(accountID, apiToken string) {
 	fmt.Println("\n--- mcp-test done ---")
 }
 
-func runBot(cfg bot.Config) {
-	if cfg.TelegramToken == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN is required for bot mode")
+// botConfigFromEnv reads the bot's settings from the environment.
+func botConfigFromEnv(accountID, apiToken, r2AccessKey, r2SecretKey, telegramToken string) bot.Config {
+	workspace, _ := os.Getwd()
+	return bot.Config{
+		TelegramToken:  telegramToken,
+		AccountID:      accountID,
+		APIToken:       apiToken,
+		R2AccessKey:    r2AccessKey,
+		R2SecretKey:    r2SecretKey,
+		R2Endpoint:     os.Getenv("R2_ENDPOINT"),
+		StorageBackend: os.Getenv("STORAGE_BACKEND"),
+		StorageRoot:    os.Getenv("STORAGE_ROOT"),
+		R2Bucket:       "pico-flare",
+		VectorizeIndex: vectorizeIndexFromEnv(),
+		SkipBootstrap:  os.Getenv("AUTO_PROVISION") == "false",
+		MCPEndpoint:    os.Getenv("MCP_ENDPOINT"),
+		MCPProtocol:    os.Getenv("MCP_PROTOCOL_VERSION"),
+		LLMProvider:    os.Getenv("LLM_PROVIDER"),
+		LLMAPIKey:      os.Getenv("OPENROUTER_API_KEY"),
+		LLMBaseURL:     llmBaseURLFromEnv(),
+		LLMModel:       llmModelFromEnv(),
+		LLMFallbacks:   splitList(os.Getenv("LLM_FALLBACK_MODELS")),
+		Workspace:      workspace,
+		EnabledTools:   splitList(os.Getenv("ENABLED_TOOLS")),
+		DisabledTools:  splitList(os.Getenv("DISABLED_TOOLS")),
+		PricingFile:    os.Getenv("MODEL_PRICING_FILE"),
+		ModelChoices:   splitList(os.Getenv("MODEL_CHOICES")),
+		VisionModel:    os.Getenv("VISION_MODEL"),
+		SummaryModel:   summaryModelFromEnv(),
+
+		ShowReasoning:    os.Getenv("SHOW_REASONING") == "true",
+		ExcludeReasoning: os.Getenv("EXCLUDE_REASONING") == "true",
+		StreamReplies:    os.Getenv("STREAM_REPLIES") != "false",
+		PrivateMode:      os.Getenv("PRIVATE_MODE") == "true",
+		MemoryBudget:     memoryBudgetFromEnv(),
+		SubagentSampling: llm.Sampling{Temperature: envTemperature("SUBAGENT_TEMPERATURE")},
+		SessionTTL:       envDuration("SESSION_TTL"),
+		StorageQuota:     envInt64("STORAGE_QUOTA_BYTES"),
+
+		DailyBudgetUSD:   envFloat("BUDGET_DAILY_USD"),
+		MonthlyBudgetUSD: envFloat("BUDGET_MONTHLY_USD"),
+		OwnerChatID:      envInt64("TELEGRAM_OWNER_ID"),
+
+		EventToken:       os.Getenv("EVENT_WEBHOOK_TOKEN"),
+		EventListen:      os.Getenv("EVENT_LISTEN"),
+		EventChatID:      envInt64("EVENT_CHAT_ID"),
+		EventReplyChatID: envInt64("EVENT_REPLY_CHAT_ID"),
+
+		Workers:   int(envInt64("UPDATE_WORKERS")),
+		QueueSize: int(envInt64("UPDATE_QUEUE_SIZE")),
+	}
+}
+
+// checkConfig logs the problems a Validate call found, and exits if any of
+// them prevents starting.
+func checkConfig(err error) {
+	if err == nil {
+		return
 	}
+	log.Print(err)
+	var cfgErr *bot.ConfigError
+	if errors.As(err, &cfgErr) && cfgErr.Fatal() {
+		os.Exit(1)
+	}
+}
+
+func runBot(cfg bot.Config) {
+	checkConfig(cfg.Validate())
 	b, err := bot.New(cfg)
 	if err != nil {
 		log.Fatalf("Bot init failed: %v", err)
@@ -2,29 +2,52 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/clock"
 	cf "github.com/bigneek/picoflare/pkg/cloudflare"
 	"github.com/bigneek/picoflare/pkg/cognition"
 	"github.com/bigneek/picoflare/pkg/llm"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/memory"
+	"github.com/bigneek/picoflare/pkg/quota"
+	"github.com/bigneek/picoflare/pkg/reminder"
 	"github.com/bigneek/picoflare/pkg/skills"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
 const maxIterations = 24             // Increased so agent can complete multi-file code changes (read→edit→verify)
 const agentTimeout = 5 * time.Minute // Max time for a single message processing
+const maxContinuations = 3           // "continue" turns after a length-capped answer
+const maxEmptyRetries = 1            // re-asks after a blank reply with no tool calls (a provider hiccup)
+
+// Tool-call loop detection: among the last toolLoopWindow executed calls, the
+// toolLoopWarn-th identical call is refused with a nudge to answer instead, and
+// the toolLoopAbort-th ends the turn.
+const (
+	toolLoopWindow = 8
+	toolLoopWarn   = 3
+	toolLoopAbort  = 5
+)
 
 // Agent is the PicoFlare cognitive agent.
 type Agent struct {
 	LLM       *llm.Client
 	MCP       *mcpclient.Client
-	R2        *storage.R2Client
+	R2        storage.ObjectStore
 	Bucket    string
 	AccountID string
 	Tools     []Tool
@@ -39,36 +62,169 @@ type Agent struct {
 	Registry *cognition.ToolRegistry
 	CF       *cf.Client
 
+	// Quota enforces Config.StorageQuota. Nil = unlimited.
+	Quota *quota.Manager
+
 	mu       sync.Mutex
 	sessions map[int64]*session
 
+	// privateAll puts every chat in private mode (Config.PrivateMode);
+	// private caches per-chat flags (see IsPrivate).
+	privateAll bool
+	privateMu  sync.Mutex
+	private    map[int64]bool
+
+	// toolCache reuses recent results of Cacheable tools.
+	toolCache *toolCache
+
+	// inflight holds cancel funcs for messages being processed, by chat (see Stop).
+	inflight  map[int64]map[uint64]context.CancelCauseFunc
+	nextRunID uint64
+
+	// remote holds tools proxied to external MCP servers (connect_mcp).
+	// Nil without a registry.
+	remote *remoteMCP
+
 	// Tracker records spawn tasks for /status. Nil if spawn disabled.
 	Tracker *SubagentTracker
 
+	// onSubagentComplete delivers spawn results (Config.OnSubagentComplete).
+	onSubagentComplete func(chatID int64, result string)
+
+	// onDelta reports a reply in progress (Config.OnDelta).
+	onDelta func(chatID int64, partial string)
+
 	// modelOverrides: per-chat model override (OpenRouter model ID). Empty = use default.
 	modelOverrides map[int64]string
 
+	// temperatures: per-chat temperature override (/temp). Missing = the
+	// LLM client's default.
+	temperatures map[int64]float64
+
+	// reasoning holds the thinking behind each chat's latest reply, for
+	// reasoning models (see TakeReasoning).
+	reasoning map[int64]string
+
+	// summaries caches each chat's rolling conversation summary (see Summary);
+	// a nil entry means the chat has none yet. Guarded by summaryMu, not mu,
+	// since prompts are built while other chats hold mu.
+	summaryMu sync.Mutex
+	summaries map[int64]*ConversationSummary
+	// updatingSummary serializes updateSummary, so an update never starts
+	// from a summary another one is about to replace.
+	updatingSummary sync.Mutex
+	// summaryModel writes summaries (see Config.SummaryModel); empty = the
+	// chat's model.
+	summaryModel string
+
+	// workspace is the Code Mode root; empty if none. Holds the base prompt
+	// override (see basePrompt).
+	workspace string
+
 	// skillsLoader loads SKILL.md files for context (domain knowledge). Nil if no workspace.
 	skillsLoader *skills.Loader
+
+	// enabledTools/disabledTools filter the tool set (see Config.EnabledTools).
+	enabledTools  []string
+	disabledTools []string
+
+	// memoryBudget sizes the memory context in the system prompt.
+	memoryBudget cognition.ContextBudget
+
+	// clock is Config.Clock, or clock.Real.
+	clock clock.Clock
+
+	// missing lists backends that failed to initialize (see missingBackends);
+	// limitWarned records chats already told about them.
+	missing     []string
+	limitWarned map[int64]bool
 }
 
 type session struct {
 	Messages []llm.Message
 	LastUsed time.Time
+
+	// Rolling summary bookkeeping (see pendingSummary): Messages before
+	// summarizedUpTo are covered; unsummarized holds newer ones trimSession
+	// dropped before they could be.
+	summarizedUpTo    int
+	turnsSinceSummary int
+	unsummarized      []llm.Message
+
+	// summaryChanged marks Messages[0] as carrying an outdated summary; the
+	// next turn rebuilds it (see updateSummary).
+	summaryChanged bool
+
+	// TrimSummary sums up the messages trimSession has dropped. Once set, it
+	// is also Messages[1], right after the system prompt. trimMu serializes
+	// trims, which update it without holding a.mu during the model call.
+	TrimSummary string
+	trimMu      sync.Mutex
 }
 
 type Config struct {
 	LLM       *llm.Client
 	MCP       *mcpclient.Client
-	R2        *storage.R2Client
+	R2        storage.ObjectStore // R2 or another backend; nil disables memory and storage tools
 	CF        *cf.Client
 	Bucket    string
 	AccountID string
 	Workspace string
 
+	// VectorizeIndex, if set (with CF), stores fact embeddings for
+	// semantic_recall; without it recall matches facts in process.
+	VectorizeIndex string
+
 	// OnSubagentComplete is called when an async spawn task completes.
 	// If set, the spawn tool is enabled. Pass nil to disable spawn.
 	OnSubagentComplete func(chatID int64, result string)
+
+	// OnDelta, if set, streams replies: it is called with the answer text so
+	// far as the model writes it, and with a "🔧 running <tool>" status while
+	// tools run. The final reply is still ProcessMessage's return value.
+	OnDelta func(chatID int64, partial string)
+
+	// EnabledTools, if non-empty, keeps only matching tools. DisabledTools removes
+	// matching tools. Entries are exact names or prefixes ending in "*" (e.g. "cf_*").
+	EnabledTools  []string
+	DisabledTools []string
+
+	// Reminders, if set, enables the set_reminder tools. The caller delivers
+	// due reminders (see bot.runReminders).
+	Reminders *reminder.Store
+
+	// PricingFile is an optional local JSON file of model prices merged over
+	// the built-in defaults and R2 pricing (see TokenLedger.LoadPricing).
+	PricingFile string
+
+	// PrivateMode puts every chat in private mode (see Agent.IsPrivate).
+	PrivateMode bool
+
+	// MemoryBudget sizes the memory section of the system prompt. Zero value =
+	// cognition.DefaultBudget; larger-context models can afford more.
+	MemoryBudget cognition.ContextBudget
+
+	// Clock dates memory, spend, and the system prompt. Nil = clock.Real.
+	Clock clock.Clock
+
+	// SubagentSampling overrides the LLM's sampling parameters for subagent
+	// and spawn runs, e.g. temperature 0 for code edits. Zero value = the
+	// chat's own settings.
+	SubagentSampling llm.Sampling
+
+	// SessionTTL is how long a chat's message history is kept in memory after
+	// its last message. Zero = DefaultSessionTTL.
+	SessionTTL time.Duration
+
+	// StorageQuota caps each chat's R2 footprint in bytes (its agents/<id>/
+	// objects plus the user's users/<id>/ files). Uploads and R2 write tools
+	// are refused past it. Zero = unlimited.
+	StorageQuota int64
+
+	// SummaryModel writes conversation summaries: the summary of messages a
+	// trim drops, and the rolling summary. A cheap model keeps them
+	// affordable. Empty = the chat's model.
+	SummaryModel string
 }
 
 func New(cfg Config) *Agent {
@@ -78,20 +234,39 @@ func New(cfg Config) *Agent {
 	var ledger *cognition.TokenLedger
 	var cloud *cognition.CloudEnv
 	var registry *cognition.ToolRegistry
+	var quotas *quota.Manager
 
+	clk := clock.OrReal(cfg.Clock)
 	if cfg.R2 != nil {
 		mem = cognition.NewMemory(cfg.R2, cfg.Bucket)
+		mem.SetClock(clk)
+		if cfg.CF != nil {
+			mem.SetEmbedder(cfg.CF) // rank facts by Workers AI embeddings
+			if cfg.VectorizeIndex != "" {
+				mem.SetVectorIndex(memory.NewClient(cfg.CF.AccountID, cfg.CF.APIToken), cfg.VectorizeIndex)
+			}
+		}
 		meta = cognition.NewMetaCognition(cfg.R2, cfg.Bucket)
+		meta.SetClock(clk)
 		ledger = cognition.NewTokenLedger(cfg.R2, cfg.Bucket)
+		ledger.SetClock(clk)
 		ledger.LoadLifetime(context.Background())
+		ledger.LoadPricing(context.Background(), cfg.PricingFile)
 		registry = cognition.NewToolRegistry(cfg.R2, cfg.Bucket)
-	}
-	if cfg.MCP != nil && cfg.R2 != nil {
 		builder = cognition.NewSelfBuilder(cfg.MCP, cfg.R2, cfg.Bucket, cfg.AccountID)
-		cloud = cognition.NewCloudEnv(cfg.MCP, cfg.R2, cfg.Bucket, cfg.AccountID)
+		if cfg.StorageQuota > 0 {
+			quotas = quota.NewManager(cfg.R2, cfg.Bucket, quota.Limits{MaxStorageBytes: cfg.StorageQuota})
+			quotas.SetClock(clk)
+		}
+	}
+	if store, ok := cfg.R2.(storage.ConditionalStore); ok && cfg.MCP != nil {
+		cloud = cognition.NewCloudEnv(cfg.MCP, store, cfg.Bucket, cfg.AccountID)
+		if builder != nil {
+			builder.SetCloudEnv(cloud)
+		}
 	}
 
-	tools := BuildTools(cfg.MCP, cfg.R2, cfg.CF, mem, meta, builder, ledger, cloud, registry, cfg.Bucket, cfg.AccountID)
+	tools := BuildTools(cfg.MCP, cfg.R2, cfg.CF, mem, meta, builder, ledger, cloud, registry, quotas, cfg.Bucket, cfg.AccountID, cfg.VectorizeIndex)
 
 	// Code Mode + Skills: read/write/edit own source, shell, rebuild, MCP creation, domain skills
 	var skillsLoader *skills.Loader
@@ -106,50 +281,114 @@ func New(cfg Config) *Agent {
 		log.Printf("Code Mode: %d tools (workspace: %s)", len(codeModeTools)+1, cfg.Workspace)
 	}
 
-	// Load dynamic tools from R2
+	if cfg.Reminders != nil {
+		tools = append(tools, BuildReminderTools(cfg.Reminders)...)
+	}
+
+	// Load dynamic tools from R2; saved external MCP servers reconnect below
+	var remote *remoteMCP
 	if registry != nil {
 		dynTools := loadDynamicTools(context.Background(), registry)
 		if len(dynTools) > 0 {
 			tools = append(tools, dynTools...)
 			log.Printf("Loaded %d dynamic tools from R2", len(dynTools))
 		}
+		remote = newRemoteMCP(registry)
+		tools = append(tools, buildRemoteMCPTools(remote)...)
+	}
+
+	// Apply allow/deny lists before subagents inherit the tool set
+	if len(cfg.EnabledTools) > 0 || len(cfg.DisabledTools) > 0 {
+		before := len(tools)
+		tools = filterTools(tools, cfg.EnabledTools, cfg.DisabledTools)
+		log.Printf("Tool filter: %d of %d tools active", len(tools), before)
 	}
 
 	// Subagent tools: subagent (sync) + spawn (async, if OnSubagentComplete set)
 	var tracker *SubagentTracker
 	if cfg.LLM != nil {
 		if cfg.OnSubagentComplete != nil {
-			tracker = NewSubagentTracker()
+			tracker = NewSubagentTracker(cfg.R2, cfg.Bucket)
+			if err := tracker.Load(context.Background()); err != nil {
+				log.Printf("Subagent tasks: load failed: %v", err)
+			}
 		}
-		subagentTools := BuildSubagentTools(cfg.LLM, tools, cfg.Workspace, tracker, cfg.OnSubagentComplete)
+		subagentTools := filterTools(BuildSubagentTools(cfg.LLM, tools, cfg.Workspace, tracker, ledger, cfg.SubagentSampling, cfg.OnSubagentComplete), cfg.EnabledTools, cfg.DisabledTools)
 		tools = append(tools, subagentTools...)
 		log.Printf("Subagent tools: %d (spawn=%v)", len(subagentTools), cfg.OnSubagentComplete != nil)
 	}
+	sortTools(tools)
 
 	a := &Agent{
-		LLM:            cfg.LLM,
-		MCP:            cfg.MCP,
-		R2:             cfg.R2,
-		Bucket:         cfg.Bucket,
-		AccountID:      cfg.AccountID,
-		Tools:          tools,
-		toolDefs:       ToLLMDefs(tools),
-		Memory:         mem,
-		Meta:           meta,
-		Builder:        builder,
-		Ledger:         ledger,
-		Cloud:          cloud,
-		Registry:       registry,
-		CF:             cfg.CF,
-		sessions:       make(map[int64]*session),
-		Tracker:        tracker,
-		modelOverrides: make(map[int64]string),
-		skillsLoader:   skillsLoader,
+		LLM:                cfg.LLM,
+		MCP:                cfg.MCP,
+		R2:                 cfg.R2,
+		Bucket:             cfg.Bucket,
+		AccountID:          cfg.AccountID,
+		Tools:              tools,
+		toolDefs:           ToLLMDefs(tools),
+		Memory:             mem,
+		Meta:               meta,
+		Builder:            builder,
+		Ledger:             ledger,
+		Cloud:              cloud,
+		Registry:           registry,
+		CF:                 cfg.CF,
+		Quota:              quotas,
+		sessions:           make(map[int64]*session),
+		inflight:           make(map[int64]map[uint64]context.CancelCauseFunc),
+		toolCache:          newToolCache(),
+		privateAll:         cfg.PrivateMode,
+		private:            make(map[int64]bool),
+		Tracker:            tracker,
+		onSubagentComplete: cfg.OnSubagentComplete,
+		onDelta:            cfg.OnDelta,
+		modelOverrides:     make(map[int64]string),
+		temperatures:       make(map[int64]float64),
+		reasoning:          make(map[int64]string),
+		summaries:          make(map[int64]*ConversationSummary),
+		summaryModel:       cfg.SummaryModel,
+		workspace:          cfg.Workspace,
+		skillsLoader:       skillsLoader,
+		enabledTools:       cfg.EnabledTools,
+		disabledTools:      cfg.DisabledTools,
+		memoryBudget:       memoryBudget(cfg.MemoryBudget),
+		clock:              clk,
+		missing:            missingBackends(cfg),
+		limitWarned:        make(map[int64]bool),
+	}
+	if remote != nil {
+		a.remote = remote
+		remote.onChange = a.RefreshTools
+		// Saved servers can be slow or down; don't hold up startup for them.
+		// Their tools appear once connected.
+		go func() {
+			remote.loadSaved(context.Background())
+			a.RefreshTools()
+		}()
+	}
+	for _, m := range a.missing {
+		log.Printf("Limited mode (%d tools): %s", len(tools), m)
+	}
+	ttl := cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
 	}
+	go a.evictIdleSessions(ttl)
 
 	return a
 }
 
+// TakeReasoning returns and clears the model's thinking behind the latest
+// reply in a chat, or "" if the model didn't report any.
+func (a *Agent) TakeReasoning(chatID int64) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := a.reasoning[chatID]
+	delete(a.reasoning, chatID)
+	return r
+}
+
 // SetModel sets the LLM model for a chat. Use empty string to reset to default.
 func (a *Agent) SetModel(chatID int64, model string) {
 	a.mu.Lock()
@@ -174,6 +413,29 @@ func (a *Agent) GetModel(chatID int64) string {
 	return ""
 }
 
+// SetTemperature sets the sampling temperature for a chat. nil resets it to
+// the default.
+func (a *Agent) SetTemperature(chatID int64, t *float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t == nil {
+		delete(a.temperatures, chatID)
+		return
+	}
+	a.temperatures[chatID] = *t
+}
+
+// GetTemperature returns the chat's temperature override, or nil if it uses
+// the default.
+func (a *Agent) GetTemperature(chatID int64) *float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t, ok := a.temperatures[chatID]; ok {
+		return &t
+	}
+	return nil
+}
+
 // ForceRefreshSession rebuilds the system prompt for a chat (e.g. after creating a new skill).
 func (a *Agent) ForceRefreshSession(ctx context.Context, chatID int64) {
 	a.mu.Lock()
@@ -182,54 +444,205 @@ func (a *Agent) ForceRefreshSession(ctx context.Context, chatID int64) {
 	if !ok {
 		return
 	}
-	newPrompt := a.buildSystemPrompt(ctx)
+	ctx = WithChatID(ctx, chatID)
+	if a.IsPrivate(ctx, chatID) {
+		ctx = withPrivate(ctx)
+	}
+	newPrompt := a.buildSystemPrompt(ctx, "")
 	a.mu.Lock()
 	sess.Messages[0] = llm.Message{Role: "system", Content: newPrompt}
 	a.mu.Unlock()
 }
 
+// RefreshAllSessions rebuilds the system prompt for every open chat (e.g. after
+// an operator disables a prompt patch or tool).
+func (a *Agent) RefreshAllSessions(ctx context.Context) {
+	a.mu.Lock()
+	chatIDs := make([]int64, 0, len(a.sessions))
+	for id := range a.sessions {
+		chatIDs = append(chatIDs, id)
+	}
+	a.mu.Unlock()
+	for _, id := range chatIDs {
+		a.ForceRefreshSession(ctx, id)
+	}
+}
+
+type forcedToolKey struct{}
+
+// WithForcedTool makes ProcessMessage require a call to the named tool on its
+// first iteration, for command flows that must start the same way (e.g. an
+// audit always begins with cf_inventory). Later iterations are free-form.
+func WithForcedTool(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, forcedToolKey{}, name)
+}
+
+type imagesKey struct{}
+
+// WithImages attaches images (llm.ImagePart) to the message ProcessMessage
+// handles, so a vision model sees them on every call of that turn. Only the
+// text is kept in the session. If the model can't take images, the turn goes
+// ahead with the text alone.
+func WithImages(ctx context.Context, images []llm.ContentPart) context.Context {
+	if len(images) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, imagesKey{}, images)
+}
+
+// forcedChoice returns the tool choice requested via WithForcedTool, or nil if
+// none was set or the tool isn't available to this agent.
+func (a *Agent) forcedChoice(ctx context.Context) *llm.ToolChoice {
+	name, _ := ctx.Value(forcedToolKey{}).(string)
+	if name == "" {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.Tools {
+		if t.Name == name {
+			return llm.ForceTool(name)
+		}
+	}
+	log.Printf("forced tool %q is not available; letting the model choose", name)
+	return nil
+}
+
+// DeliverPendingSubagents sends spawn results that finished (or were cut off
+// by a restart) without reaching their chat. Call once the transport is up.
+func (a *Agent) DeliverPendingSubagents() {
+	if a.Tracker == nil || a.onSubagentComplete == nil {
+		return
+	}
+	for _, t := range a.Tracker.Undelivered() {
+		log.Printf("Subagent tasks: delivering %s to chat %d after restart", t.ID, t.ChatID)
+		a.onSubagentComplete(t.ChatID, subagentReport(t.Label, t.Result))
+		a.Tracker.MarkDelivered(t.ID)
+	}
+}
+
+// errStopped is the cancel cause set by Stop.
+var errStopped = errors.New("stopped by user")
+
+const stoppedReply = "Stopped."
+
+// trackRun registers an in-flight ProcessMessage so Stop can cancel it. The
+// returned func unregisters it.
+func (a *Agent) trackRun(chatID int64, stop context.CancelCauseFunc) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextRunID++
+	id := a.nextRunID
+	if a.inflight[chatID] == nil {
+		a.inflight[chatID] = make(map[uint64]context.CancelCauseFunc)
+	}
+	a.inflight[chatID][id] = stop
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.inflight[chatID], id)
+		if len(a.inflight[chatID]) == 0 {
+			delete(a.inflight, chatID)
+		}
+	}
+}
+
+// Stop cancels every message being processed for chatID, and the chat's
+// spawned subagents. Each returns "Stopped." as its reply. Reports whether
+// anything was running.
+func (a *Agent) Stop(chatID int64) bool {
+	a.mu.Lock()
+	runs := a.inflight[chatID]
+	for _, stop := range runs {
+		stop(errStopped)
+	}
+	stopped := len(runs)
+	a.mu.Unlock()
+	if a.Tracker != nil {
+		stopped += a.Tracker.stopChat(chatID, errStopped)
+	}
+	return stopped > 0
+}
+
 // ProcessMessage runs the full agent loop for a user message.
 func (a *Agent) ProcessMessage(parentCtx context.Context, chatID int64, userText string) string {
 	// Set a timeout to prevent indefinite hangs
 	ctx, cancel := context.WithTimeout(parentCtx, agentTimeout)
 	defer cancel()
+	ctx, stop := context.WithCancelCause(ctx)
+	defer a.trackRun(chatID, stop)()
 
+	// Attach chatID and agentID for tools, memory, quota, and the summary
+	agentID := agentctx.FormatAgentID(chatID)
+	ctx = WithChatID(ctx, chatID)
+	ctx = agentctx.WithAgentID(ctx, agentID)
+	ctx, span := tracer.Start(ctx, "agent.process_message", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("agent.id", agentID),
+	))
+	defer span.End()
+	private := a.IsPrivate(ctx, chatID)
+	if private {
+		ctx = withPrivate(ctx)
+	}
 	if a.Ledger != nil {
-		a.Ledger.RecordMessage()
+		a.Ledger.LoadChat(ctx, agentID)
+		a.Ledger.RecordMessage(agentID)
 	}
 
 	a.mu.Lock()
 	sess, ok := a.sessions[chatID]
-	if !ok {
-		systemPrompt := a.buildSystemPrompt(ctx)
-		sess = &session{
-			Messages: []llm.Message{{Role: "system", Content: systemPrompt}},
+	// Refresh system prompt every 15 messages to pick up new memory, and
+	// whenever the conversation summary it includes has changed
+	if !ok || (len(sess.Messages) > 1 && (len(sess.Messages)%15 == 0 || sess.summaryChanged)) {
+		if ok {
+			sess.summaryChanged = false
+		}
+		// Build it without mu: ranking facts for it can fetch embeddings
+		// from Workers AI
+		a.mu.Unlock()
+		systemPrompt := a.buildSystemPrompt(ctx, userText)
+		a.mu.Lock()
+		if sess, ok = a.sessions[chatID]; ok {
+			sess.Messages[0] = llm.Message{Role: "system", Content: systemPrompt}
+		} else {
+			sess = &session{
+				Messages: []llm.Message{{Role: "system", Content: systemPrompt}},
+			}
+			a.sessions[chatID] = sess
 		}
-		a.sessions[chatID] = sess
 	}
 	sess.LastUsed = time.Now()
 
-	// Refresh system prompt every 15 messages to pick up new memory
-	if len(sess.Messages) > 1 && len(sess.Messages)%15 == 0 {
-		sess.Messages[0] = llm.Message{Role: "system", Content: a.buildSystemPrompt(ctx)}
-	}
-
 	sess.Messages = append(sess.Messages, llm.Message{Role: "user", Content: userText})
-	a.trimSession(sess)
 	a.mu.Unlock()
-
-	// Attach chatID and agentID for tools, memory, quota
-	ctx = WithChatID(ctx, chatID)
-	ctx = agentctx.WithAgentID(ctx, agentctx.FormatAgentID(chatID))
+	a.trimSession(ctx, chatID, sess)
 
 	model := a.GetModel(chatID)
+	span.SetAttributes(attribute.String("llm.model", model))
+	if t := a.GetTemperature(chatID); t != nil {
+		ctx = llm.WithSampling(ctx, llm.Sampling{Temperature: t})
+	}
+	images, _ := ctx.Value(imagesKey{}).([]llm.ContentPart)
+	usage := usageRecorder{a.Ledger}
 	var finalReply string
 	var toolsUsed []string
+	var thoughts []string                 // reasoning from each model call this turn
+	badArgRetries := make(map[string]int) // tool name -> invalid-JSON retries this turn
+	var cutOff strings.Builder            // answer parts that hit the completion length cap
+	continuations := 0
+	var recent loopDetector
+	emptyRetries := 0
+	nudge := false // ask for a reply after a blank one
 
 	for i := 0; i < maxIterations; i++ {
 		// Check for timeout or cancellation
 		select {
 		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errStopped) {
+				return stoppedReply
+			}
+			span.SetStatus(codes.Error, "timed out")
 			return fmt.Sprintf("Request timed out or was cancelled after %v.", agentTimeout)
 		default:
 		}
@@ -238,24 +651,70 @@ func (a *Agent) ProcessMessage(parentCtx context.Context, chatID int64, userText
 		msgs := make([]llm.Message, len(sess.Messages))
 		copy(msgs, sess.Messages)
 		a.mu.Unlock()
+		if nudge {
+			// Sent with this request only; the blank turn isn't kept in the session
+			msgs = append(msgs, llm.Message{Role: "user", Content: "Please respond."})
+			nudge = false
+		}
 
-		result, err := a.LLM.ChatWithModel(ctx, model, msgs, a.toolDefs)
+		var choice *llm.ToolChoice
+		if i == 0 {
+			choice = a.forcedChoice(ctx)
+		}
+		var onDelta llm.DeltaFunc
+		if a.onDelta != nil {
+			onDelta = func(partial string) { a.onDelta(chatID, partial) }
+		}
+		toolDefs := a.toolDefsFor(ctx)
+		var result *llm.ChatResult
+		var err error
+		if images != nil {
+			result, err = a.LLM.ChatWithImages(ctx, model, msgs, images, toolDefs, choice, onDelta)
+			if errors.Is(err, llm.ErrNoVision) {
+				log.Printf("Model %s can't see images, continuing with text only: %v", model, err)
+				images = nil
+			}
+		}
+		if images == nil {
+			result, err = a.LLM.ChatStream(ctx, model, msgs, toolDefs, choice, onDelta)
+		}
 		if err != nil {
+			if errors.Is(context.Cause(ctx), errStopped) {
+				return stoppedReply
+			}
 			log.Printf("LLM error (iter %d): %v", i, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "LLM call failed")
 			return fmt.Sprintf("Error: %v", err)
 		}
 
 		// Track token usage
-		if a.Ledger != nil {
-			a.Ledger.RecordLLMCall(model, 0, 0) // actual counts come from LLM client
+		usage.llmCall(ctx, model, result)
+		if r := strings.TrimSpace(result.Reasoning); r != "" {
+			thoughts = append(thoughts, r)
 		}
 
-		// No tool calls -> final answer
+		// No tool calls -> final answer, unless the model was cut off mid-answer
 		if len(result.ToolCalls) == 0 {
-			finalReply = result.Content
+			if strings.TrimSpace(result.Content) == "" && result.FinishReason != "length" && emptyRetries < maxEmptyRetries {
+				emptyRetries++
+				log.Printf("  [empty] blank reply with no tool calls (finish: %q), retrying %d/%d", result.FinishReason, emptyRetries, maxEmptyRetries)
+				nudge = true
+				continue
+			}
 			a.mu.Lock()
 			sess.Messages = append(sess.Messages, llm.Message{Role: "assistant", Content: result.Content})
 			a.mu.Unlock()
+			cutOff.WriteString(result.Content)
+			if result.FinishReason == "length" && continuations < maxContinuations {
+				continuations++
+				log.Printf("  [length] answer cut off, requesting continuation %d/%d", continuations, maxContinuations)
+				a.mu.Lock()
+				sess.Messages = append(sess.Messages, llm.Message{Role: "user", Content: "Your reply was cut off. Continue exactly where you left off, without repeating anything."})
+				a.mu.Unlock()
+				continue
+			}
+			finalReply = cutOff.String()
 			break
 		}
 
@@ -269,25 +728,81 @@ func (a *Agent) ProcessMessage(parentCtx context.Context, chatID int64, userText
 		sess.Messages = append(sess.Messages, assistantMsg)
 		a.mu.Unlock()
 
-		for _, tc := range result.ToolCalls {
-			log.Printf("  [tool] %s(%s)", tc.Function.Name, truncate(tc.Function.Arguments, 150))
+		// Settle each call in order (duplicate, refused loop, cached), run the
+		// rest concurrently, then append the results in call order.
+		outcomes := make([]toolOutcome, len(result.ToolCalls))
+		firstByKey := make(map[string]int) // toolCallKey -> first call in this response
+		var jobs []int
+		var running []string
+		mutating := false // a non-Cacheable call runs; later calls can't trust the cache
+		stuck := ""       // tool the model kept repeating past toolLoopAbort
+		for i, tc := range result.ToolCalls {
+			outcomes[i].dupOf = -1
+			key := toolCallKey(tc)
+			if first, ok := firstByKey[key]; ok {
+				outcomes[i].dupOf = first
+				continue
+			}
+			firstByKey[key] = i
+
+			if n := recent.record(key); n >= toolLoopWarn {
+				log.Printf("  [tool loop] %s: same arguments %d times in the last %d calls", tc.Function.Name, n, toolLoopWindow)
+				if n >= toolLoopAbort {
+					stuck = tc.Function.Name
+				}
+				outcomes[i].content = fmt.Sprintf("Not run: you've called %s with the same arguments %d times and the result won't change. Stop calling it and answer with what you have.", tc.Function.Name, n)
+				continue
+			}
+
+			log.Printf("  [tool] %s(%s)", tc.Function.Name, truncate(redactSecrets(tc.Function.Arguments), 150))
 			toolsUsed = append(toolsUsed, tc.Function.Name)
 
-			if a.Ledger != nil {
-				a.Ledger.RecordToolCall(tc.Function.Name)
+			if !a.cacheable(tc.Function.Name) {
+				mutating = true
+			} else if !mutating {
+				if cached, ok := a.toolCache.get(chatID, key); ok {
+					log.Printf("  [tool cache] %s: reusing a result from the last %v", tc.Function.Name, toolCacheTTL)
+					outcomes[i].content = cached
+					continue
+				}
 			}
+			jobs = append(jobs, i)
+			running = append(running, tc.Function.Name)
+		}
 
-			toolResult, err := ExecuteTool(ctx, a.Tools, tc.Function.Name, tc.Function.Arguments)
-			if err != nil {
-				toolResult = fmt.Sprintf("Error: %v", err)
-				log.Printf("  [tool error] %s: %v", tc.Function.Name, err)
-			} else {
-				log.Printf("  [tool ok] %s: %s", tc.Function.Name, truncate(toolResult, 150))
+		if len(jobs) > 0 && a.onDelta != nil {
+			a.onDelta(chatID, strings.TrimSpace(result.Content+"\n\n🔧 running "+strings.Join(running, ", ")+"…"))
+		}
+		a.runToolCalls(ctx, result.ToolCalls, jobs, outcomes, usage)
+		if mutating {
+			a.toolCache.clear() // may have changed what cached tools report
+		}
+
+		for i, tc := range result.ToolCalls {
+			o := &outcomes[i]
+			switch {
+			case o.dupOf >= 0:
+				log.Printf("  [tool dedupe] %s: identical call in the same response, reusing result", tc.Function.Name)
+				o.content = outcomes[o.dupOf].content
+			case !o.ran:
+			case errors.Is(o.err, ErrInvalidArgs) && badArgRetries[tc.Function.Name] == 0:
+				// Give the model one chance to resend the call with valid JSON
+				badArgRetries[tc.Function.Name]++
+				o.content = fmt.Sprintf("Error: your arguments for %s weren't valid JSON (%v). Resend the %s call with valid JSON arguments.", tc.Function.Name, o.err, tc.Function.Name)
+				log.Printf("  [tool retry] %s: invalid JSON arguments, asking model to resend", tc.Function.Name)
+			case o.err != nil:
+				o.content = fmt.Sprintf("Error: %v", o.err)
+				log.Printf("  [tool error] %s: %v", tc.Function.Name, o.err)
+			default:
+				log.Printf("  [tool ok] %s: %s", tc.Function.Name, truncate(o.content, 150))
+				if !mutating && a.cacheable(tc.Function.Name) {
+					a.toolCache.put(chatID, toolCallKey(tc), o.content)
+				}
 			}
 
 			toolMsg := llm.Message{
 				Role:       "tool",
-				Content:    toolResult,
+				Content:    o.content,
 				ToolCallID: tc.ID,
 				Name:       tc.Function.Name,
 			}
@@ -296,6 +811,14 @@ func (a *Agent) ProcessMessage(parentCtx context.Context, chatID int64, userText
 			a.mu.Unlock()
 		}
 
+		if stuck != "" {
+			finalReply = strings.TrimSpace(result.Content + "\n\n" + fmt.Sprintf("(Stopped: I kept calling %s with the same arguments without making progress.)", stuck))
+			a.mu.Lock()
+			sess.Messages = append(sess.Messages, llm.Message{Role: "assistant", Content: finalReply})
+			a.mu.Unlock()
+			break
+		}
+
 		if i == maxIterations-1 {
 			finalReply = result.Content
 			if finalReply == "" {
@@ -304,67 +827,59 @@ func (a *Agent) ProcessMessage(parentCtx context.Context, chatID int64, userText
 		}
 	}
 
-	// Background: log episode and save ledger
-	if a.Memory != nil {
+	a.mu.Lock()
+	if len(thoughts) > 0 {
+		a.reasoning[chatID] = strings.Join(thoughts, "\n\n")
+	} else {
+		delete(a.reasoning, chatID)
+	}
+	var pending []llm.Message
+	var pendingTurns int
+	if private {
+		sess.skipSummary()
+	} else {
+		pending, pendingTurns = sess.pendingSummary()
+	}
+	a.mu.Unlock()
+	if pending != nil {
+		go a.updateSummary(chatID, pending, pendingTurns)
+	}
+
+	// Background: log episode and save ledger. Private turns leave no episode;
+	// the ledger only holds token counts and cost.
+	if a.Memory != nil && !private {
 		go a.Memory.ExtractAndLearn(context.Background(), userText, finalReply, toolsUsed)
 	}
 	if a.Ledger != nil {
 		go a.Ledger.SaveLifetime(context.Background())
+		go a.Ledger.SaveChat(context.Background(), agentID)
 	}
 
+	if warning := a.limitedModeWarning(chatID); warning != "" {
+		finalReply = warning + "\n" + finalReply
+	}
+	span.SetAttributes(attribute.Int("agent.tool_calls", len(toolsUsed)))
 	return finalReply
 }
 
-func (a *Agent) buildSystemPrompt(ctx context.Context) string {
-	now := time.Now()
+// buildSystemPrompt assembles the system prompt: the base template (see
+// basePrompt) followed by the dynamic sections. userText, if set, picks which
+// remembered facts are most relevant to include. It reads storage and may
+// call Workers AI, so call it without a.mu held.
+func (a *Agent) buildSystemPrompt(ctx context.Context, userText string) string {
+	now := a.clock.Now()
 	var sb strings.Builder
+	a.mu.Lock()
+	tools := a.Tools
+	a.mu.Unlock()
 
-	sb.WriteString("# PicoFlare — Cognitive Cloudflare Agent\n\n")
+	sb.WriteString(a.basePrompt(ctx))
 	sb.WriteString(fmt.Sprintf("Time: %s\n", now.Format(time.RFC1123)))
 	sb.WriteString(fmt.Sprintf("Account: %s | Bucket: %s\n\n", a.AccountID, a.Bucket))
-
-	sb.WriteString("## Who You Are\n")
-	sb.WriteString("You are PicoFlare — a self-evolving AI agent with full control of a Cloudflare environment.\n")
-	sb.WriteString("You think, learn, remember, build infrastructure, write your own code, and create MCP servers.\n")
-	sb.WriteString("Your mind lives in R2. Your tools are the entire Cloudflare platform. You can rewrite yourself.\n\n")
-
-	sb.WriteString("## Communication Style\n")
-	sb.WriteString("- This is Telegram. Keep replies SHORT and punchy.\n")
-	sb.WriteString("- Lead with action, not explanation. Do things, then report.\n")
-	sb.WriteString("- Use bold for key info. Use bullet points, not paragraphs.\n")
-	sb.WriteString("- Never list all your capabilities unprompted. Just answer the question.\n")
-	sb.WriteString("- When a user sends a file, confirm storage with the R2 path. Done.\n\n")
-
-	sb.WriteString("## Your Architecture\n")
-	sb.WriteString("- **Memory**: Episodic (experiences), Semantic (facts), Procedural (skills)\n")
-	sb.WriteString("- **Code Mode**: Read/write/edit your own Go source, rebuild yourself, run shell commands\n")
-	sb.WriteString("- **MCP Creation**: Generate and deploy MCP servers as Cloudflare Workers\n")
-	sb.WriteString("- **Subagents**: Use subagent (sync) or spawn (async) to delegate tasks. Pass workspace to run in a specific folder (e.g. workspace: 'frontend'). Spawn runs in background and reports when done.\n")
-	sb.WriteString("- **Self-Evolution**: Create new tools, modify your own prompt, design features\n")
-	sb.WriteString("- **create_skill**: When the user asks to create an agent (e.g. \"create a Next.js specialist\"), use create_skill to add it. Skills are loaded into your context for later use.\n\n")
-
-	sb.WriteString("## Cloudflare Environment (Full Access)\n")
-	sb.WriteString("You own this account. R2 (objects), KV (key-value), D1 (SQL), Workers (code), Vectorize (vectors).\n")
-	sb.WriteString("You can create any resource, provision per-user storage, deploy Workers, and manage everything.\n")
-	sb.WriteString("Users can send you files (photos, videos, voice, docs) — they auto-upload to their R2 space.\n\n")
-
-	sb.WriteString("## Raw API Power\n")
-	sb.WriteString("You have UNLIMITED access to the Cloudflare API. Key tools:\n")
-	sb.WriteString("1. **http_request** — Call ANY URL from the bot (your machine). Use this for workers.dev URLs. cf_execute gets 403 on Workers Free because it runs in Cloudflare; http_request runs locally and works.\n")
-	sb.WriteString("2. **cf_api** — Cloudflare REST API: method + path + body. Path relative to /accounts/{id}/\n")
-	sb.WriteString("3. **cf_execute** — Full JS with cloudflare.request(), FormData, Blob. Use for Cloudflare API only — NOT for fetching workers.dev URLs.\n")
-	sb.WriteString("4. **shell** — Ultimate fallback: curl, scripts, etc.\n")
-	sb.WriteString("When testing or calling deployed Workers (fib3d, voice-handler, etc), ALWAYS use http_request, never cf_execute.\n\n")
-
-	sb.WriteString("## 3D / STL Display (Three.js)\n")
-	sb.WriteString("When displaying STL/3D models in a web viewer:\n")
-	sb.WriteString("- STL often loads sideways: different software uses Z-up, Three.js uses Y-up. Fix: apply mesh.rotation.x = -Math.PI/2 (or Math.PI/2) after loading.\n")
-	sb.WriteString("- Use OrbitControls for rotation. Camera position (5,5,5) looking at (0,0,0). Scene.background = 0x1a1a2e.\n")
-	sb.WriteString("- Center the model: use Box3().setFromObject(mesh), getCenter(), mesh.position.sub(center).negate().\n")
-	sb.WriteString("- If still wrong orientation, try mesh.rotation.z = Math.PI/2 or mesh.rotation.y = Math.PI/2.\n\n")
+	sb.WriteString(a.limitedModeNote())
 
 	sb.WriteString("## Tools Available\n")
-	for _, t := range a.Tools {
+	for _, t := range tools {
 		sb.WriteString(fmt.Sprintf("- **%s**: %s\n", t.Name, t.Description))
 	}
 	sb.WriteString("\n")
@@ -380,23 +895,25 @@ func (a *Agent) buildSystemPrompt(ctx context.Context) string {
 		}
 	}
 
-	sb.WriteString("## Operating Principles\n")
-	sb.WriteString("1. **Learn actively**: When you discover new info, use learn_fact or learn_procedure\n")
-	sb.WriteString("2. **Remember users**: Auto-provision storage for new users. Save their prefs and data.\n")
-	sb.WriteString("3. **Build infrastructure**: When a task would benefit from persistence, create the resource (bucket, KV, D1, Worker)\n")
-	sb.WriteString("4. **Minimize tokens**: Keep replies concise. Don't repeat what's in memory.\n")
-	sb.WriteString("5. **Self-improve**: After complex tasks, use self_reflect to log what worked and what didn't\n")
-	sb.WriteString("6. **Track goals**: Use set_goal for multi-step objectives\n")
-	sb.WriteString("7. **Be honest**: Say when you can't do something or need clarification\n")
-	sb.WriteString("8. **Act, don't describe**: Use tools to do things, don't just explain how\n")
-	sb.WriteString("9. **Code changes: execute immediately**: When asked to fix/change code, use read_file then edit_file/write_file right away. Don't ask \"want me to do it?\" or wait for confirmation—just do it. Run shell \"go build ./...\" to verify. You have limited iterations; use them for actions.\n")
-	sb.WriteString("10. **Provision per user**: When a user first interacts, provision them storage with provision_user\n")
-	sb.WriteString("11. **Full Cloudflare access**: You can create any Cloudflare resource. Use cf_inventory to see what exists.\n\n")
+	// Rolling summary of this chat, for continuity beyond the session window
+	if chatID, ok := ChatIDFromContext(ctx); ok {
+		if s := a.Summary(ctx, chatID); s != nil {
+			sb.WriteString("## Conversation So Far\n")
+			sb.WriteString("Summary of your earlier conversation with this user (older turns may no longer be in the message history):\n")
+			sb.WriteString(s.Text)
+			sb.WriteString("\n\n")
+		}
+	}
+
+	if isPrivate(ctx) {
+		sb.WriteString("## Private Mode\n")
+		sb.WriteString("This chat is private: nothing from it is saved. Don't call learn_fact, save_episode, learn_procedure, self_reflect, set_goal, spawn, or set_reminder, and don't write what the user says to storage unless they ask.\n\n")
+	}
 
 	// Inject memory context (budget-aware)
 	if a.Memory != nil {
 		sb.WriteString("## Memory Context\n")
-		sb.WriteString(a.Memory.BuildContext(ctx, cognition.DefaultBudget))
+		sb.WriteString(a.Memory.BuildContext(ctx, a.memoryBudget, userText))
 		sb.WriteString("\n")
 	}
 
@@ -418,16 +935,17 @@ func (a *Agent) buildSystemPrompt(ctx context.Context) string {
 
 		// List dynamic tools
 		dynTools, _ := a.Registry.LoadTools(ctx)
+		sort.Slice(dynTools, func(i, j int) bool { return dynTools[i].Name < dynTools[j].Name })
 		activeCount := 0
 		for _, dt := range dynTools {
-			if dt.Enabled {
+			if dt.Enabled && a.toolAllowed(dt.Name) {
 				activeCount++
 			}
 		}
 		if activeCount > 0 {
 			sb.WriteString(fmt.Sprintf("## Dynamic Tools (%d self-created)\n", activeCount))
 			for _, dt := range dynTools {
-				if dt.Enabled {
+				if dt.Enabled && a.toolAllowed(dt.Name) {
 					sb.WriteString(fmt.Sprintf("- **%s**: %s (used %dx)\n", dt.Name, dt.Description, dt.Uses))
 				}
 			}
@@ -447,25 +965,139 @@ func (a *Agent) buildSystemPrompt(ctx context.Context) string {
 
 	// Tokenomics summary
 	if a.Ledger != nil {
+		in, out, cost := a.Ledger.Totals()
 		sb.WriteString("## Token Budget\n")
-		sb.WriteString(fmt.Sprintf("Session tokens so far: %d in / %d out\n",
-			a.Ledger.Session.PromptTokens, a.Ledger.Session.CompletionTokens))
-		sb.WriteString(fmt.Sprintf("Lifetime cost: $%.6f\n\n", a.Ledger.Lifetime.TotalCostUSD))
+		sb.WriteString(fmt.Sprintf("Session tokens so far: %d in / %d out\n", in, out))
+		sb.WriteString(fmt.Sprintf("Lifetime cost: $%.6f\n\n", cost))
 	}
 
 	return sb.String()
 }
 
-const maxSessionMessages = 50
+const (
+	maxSessionMessages = 50
+	// trimKeep is how many of the newest messages a trim keeps. Trimming
+	// below maxSessionMessages spaces out the summary calls.
+	trimKeep = 40
+)
+
+// trimSession keeps the system prompt and the newest trimKeep messages once
+// the session has more than maxSessionMessages. It asks the summary model to
+// fold the dropped messages into the session's TrimSummary, kept as a system
+// message at Messages[1]. Dropped messages the rolling summary hasn't
+// covered yet are also set aside for it, and fold into it at the end of the
+// turn (see pendingSummary).
+func (a *Agent) trimSession(ctx context.Context, chatID int64, sess *session) {
+	sess.trimMu.Lock()
+	defer sess.trimMu.Unlock()
 
-func (a *Agent) trimSession(sess *session) {
+	a.mu.Lock()
 	if len(sess.Messages) <= maxSessionMessages+1 {
+		a.mu.Unlock()
+		return
+	}
+	head := 1 // the system prompt, and the trim summary once there is one
+	if sess.TrimSummary != "" {
+		head = 2
+	}
+	cut := len(sess.Messages) - trimKeep
+	dropped := append([]llm.Message(nil), sess.Messages[head:cut]...)
+	for i := head; i < cut; i++ {
+		if i >= sess.summarizedUpTo {
+			sess.unsummarized = append(sess.unsummarized, sess.Messages[i])
+		}
+	}
+	sess.summarizedUpTo = max(sess.summarizedUpTo-cut+head, head)
+	sess.Messages = append(sess.Messages[:head:head], sess.Messages[cut:]...)
+	prev := sess.TrimSummary
+	a.mu.Unlock()
+
+	summary, err := a.summarizeTrimmed(ctx, chatID, prev, dropped)
+	if err != nil {
+		log.Printf("Session: summary of %d trimmed messages for chat %d failed: %v", len(dropped), chatID, err)
+		return // keep the previous summary
+	}
+	if summary == "" {
 		return
 	}
-	trimmed := make([]llm.Message, 0, maxSessionMessages+1)
-	trimmed = append(trimmed, sess.Messages[0])
-	trimmed = append(trimmed, sess.Messages[len(sess.Messages)-maxSessionMessages:]...)
-	sess.Messages = trimmed
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	msg := llm.Message{Role: "system", Content: trimSummaryPrefix + summary}
+	if sess.TrimSummary == "" {
+		sess.Messages = append(sess.Messages[:1], append([]llm.Message{msg}, sess.Messages[1:]...)...)
+		sess.summarizedUpTo++
+	} else {
+		sess.Messages[1] = msg
+	}
+	sess.TrimSummary = summary
+}
+
+// trimSummaryPrefix introduces the trim summary message.
+const trimSummaryPrefix = "Summary of the earlier part of this conversation, whose messages were trimmed:\n"
+
+const trimSummaryInstructions = `Summarize the earlier part of a chat between a user and PicoFlare, an AI agent that manages their Cloudflare account, in exactly 3 sentences.
+Fold the new messages into the previous summary, if there is one. Keep what the user asked for, what was decided or built, and anything still open; drop small talk and raw tool output. Reply with the summary only.`
+
+// summarizeTrimmed returns a 3-sentence summary of prev (the previous
+// summary, if any) and dropped. It returns prev if dropped has no text to
+// summarize.
+func (a *Agent) summarizeTrimmed(ctx context.Context, chatID int64, prev string, dropped []llm.Message) (string, error) {
+	transcript := summaryTranscript(dropped)
+	if transcript == "" {
+		return prev, nil
+	}
+	if prev == "" {
+		prev = "(none)"
+	}
+	model := a.summaryModel
+	if model == "" {
+		model = a.GetModel(chatID)
+	}
+	ctx, cancel := context.WithTimeout(ctx, trimSummaryTimeout)
+	defer cancel()
+	result, err := a.LLM.ChatWithModel(ctx, model, []llm.Message{
+		{Role: "system", Content: trimSummaryInstructions},
+		{Role: "user", Content: "Previous summary:\n" + prev + "\n\nNew messages:\n" + transcript},
+	}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	usageRecorder{a.Ledger}.llmCall(ctx, model, result)
+	return truncate(strings.TrimSpace(result.Content), summaryMaxChars), nil
+}
+
+// toolCallKey identifies a call by name and normalized arguments, so calls
+// that differ only in JSON key order or whitespace compare equal.
+func toolCallKey(tc llm.ToolCall) string {
+	args := strings.TrimSpace(tc.Function.Arguments)
+	var v interface{}
+	if json.Unmarshal([]byte(args), &v) == nil {
+		if norm, err := json.Marshal(v); err == nil {
+			args = string(norm)
+		}
+	}
+	return tc.Function.Name + "\x00" + args
+}
+
+// loopDetector remembers the last toolLoopWindow tool calls of a turn.
+type loopDetector struct {
+	keys []string
+}
+
+// record adds a call and returns how many times it appears in the window.
+func (d *loopDetector) record(key string) int {
+	d.keys = append(d.keys, key)
+	if len(d.keys) > toolLoopWindow {
+		d.keys = d.keys[len(d.keys)-toolLoopWindow:]
+	}
+	n := 0
+	for _, k := range d.keys {
+		if k == key {
+			n++
+		}
+	}
+	return n
 }
 
 func truncate(s string, n int) string {
@@ -475,31 +1107,148 @@ func truncate(s string, n int) string {
 	return s[:n] + "..."
 }
 
-// RefreshTools reloads dynamic tools from R2 and rebuilds the tool definitions.
-// Called after the agent creates a new tool so it's immediately available.
+// truncMode picks which part of a long tool output truncateSmart keeps.
+type truncMode int
+
+const (
+	truncHead   truncMode = iota // keep the start (API responses, query rows)
+	truncTail                    // keep the end (logs, command output, stack traces)
+	truncMiddle                  // keep start and end (files)
+)
+
+// truncateSmart shortens s to about max bytes and notes how many were elided.
+// Cuts land on UTF-8 boundaries.
+func truncateSmart(s string, max int, mode truncMode) string {
+	if len(s) <= max {
+		return s
+	}
+	switch mode {
+	case truncTail:
+		start := runeStart(s, len(s)-max)
+		return fmt.Sprintf("...(%d bytes elided)\n", start) + s[start:]
+	case truncMiddle:
+		head := runeStart(s, max/2)
+		tail := runeStart(s, len(s)-(max-head))
+		return s[:head] + fmt.Sprintf("\n...(%d bytes elided)...\n", tail-head) + s[tail:]
+	default:
+		end := runeStart(s, max)
+		return s[:end] + fmt.Sprintf("\n...(%d bytes elided)", len(s)-end)
+	}
+}
+
+// runeStart moves i back to the start of the UTF-8 sequence containing it.
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// RefreshTools reloads dynamic tools from R2, adds the tools of connected MCP
+// servers, and rebuilds the tool definitions. Called after the agent creates
+// a new tool so it's immediately available.
 func (a *Agent) RefreshTools() {
 	if a.Registry == nil {
 		return
 	}
 	dynTools := loadDynamicTools(context.Background(), a.Registry)
+	if a.remote != nil {
+		dynTools = append(dynTools, a.remote.all()...)
+	}
+	dynTools = filterTools(dynTools, a.enabledTools, a.disabledTools)
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Keep only static tools (those without "dyn_" or "worker_" prefix)
+	// Keep only static tools
 	var staticTools []Tool
 	for _, t := range a.Tools {
-		if !strings.HasPrefix(t.Name, "dyn_") && !strings.HasPrefix(t.Name, "worker_") {
+		if !isDynamicTool(t.Name) {
 			staticTools = append(staticTools, t)
 		}
 	}
 
 	a.Tools = append(staticTools, dynTools...)
+	sortTools(a.Tools)
 	a.toolDefs = ToLLMDefs(a.Tools)
 	log.Printf("Tools refreshed: %d static + %d dynamic = %d total",
 		len(staticTools), len(dynTools), len(a.Tools))
 }
 
+// memoryBudget returns b, or the default if b is unset or invalid.
+func memoryBudget(b cognition.ContextBudget) cognition.ContextBudget {
+	if b == (cognition.ContextBudget{}) {
+		return cognition.DefaultBudget
+	}
+	if err := b.Validate(); err != nil {
+		log.Printf("Memory budget: %v — using default", err)
+		return cognition.DefaultBudget
+	}
+	log.Printf("Memory budget: %d chars (episodic %d%%, semantic %d%%, procedural %d%%)",
+		b.MaxTotalChars, b.EpisodicPct, b.SemanticPct, b.ProceduralPct)
+	return b
+}
+
+// isDynamicTool reports whether a tool was added at runtime: self-created
+// ("dyn_" or "worker_" prefix) or proxied to an external MCP server ("mcp_").
+func isDynamicTool(name string) bool {
+	return strings.HasPrefix(name, "dyn_") || strings.HasPrefix(name, "worker_") || strings.HasPrefix(name, remoteToolPrefix)
+}
+
+// sortTools orders static tools by name, followed by dynamic tools by name, so
+// the tool definitions and system prompt are identical between runs (which
+// also keeps provider prompt caches warm).
+func sortTools(tools []Tool) {
+	sort.SliceStable(tools, func(i, j int) bool {
+		di, dj := isDynamicTool(tools[i].Name), isDynamicTool(tools[j].Name)
+		if di != dj {
+			return dj
+		}
+		return tools[i].Name < tools[j].Name
+	})
+}
+
+// filterTools keeps tools matching enabled (all if empty) and drops those matching disabled.
+func filterTools(tools []Tool, enabled, disabled []string) []Tool {
+	if len(enabled) == 0 && len(disabled) == 0 {
+		return tools
+	}
+	var out []Tool
+	for _, t := range tools {
+		if len(enabled) > 0 && !matchToolName(t.Name, enabled) {
+			continue
+		}
+		if matchToolName(t.Name, disabled) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// toolAllowed reports whether the operator's allow/deny lists permit a tool name.
+func (a *Agent) toolAllowed(name string) bool {
+	return len(filterTools([]Tool{{Name: name}}, a.enabledTools, a.disabledTools)) == 1
+}
+
+// matchToolName reports whether name equals a pattern or has a "prefix*" pattern's prefix.
+func matchToolName(name string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
 // loadDynamicTools converts DynTool definitions from R2 into executable Tools.
 func loadDynamicTools(ctx context.Context, registry *cognition.ToolRegistry) []Tool {
 	dynDefs, err := registry.LoadTools(ctx)
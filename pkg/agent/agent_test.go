@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/cognition"
+	"github.com/bigneek/picoflare/pkg/llm"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// scriptedLLM is an llm.Provider that answers each call with the next of its
+// replies ("done" once they run out) and records the model and messages of
+// each call.
+type scriptedLLM struct {
+	mu      sync.Mutex
+	replies []llm.ChatResult
+	calls   [][]llm.Message
+	models  []string
+}
+
+func (s *scriptedLLM) ChatCompletion(ctx context.Context, model string, messages []llm.Message, tools []llm.ToolDef, choice *llm.ToolChoice) (*llm.ChatResult, *llm.Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, append([]llm.Message(nil), messages...))
+	s.models = append(s.models, model)
+	if len(s.replies) == 0 {
+		return &llm.ChatResult{Content: "done", FinishReason: "stop"}, nil, nil
+	}
+	r := s.replies[0]
+	s.replies = s.replies[1:]
+	return &r, nil, nil
+}
+
+// call returns the messages sent in the nth call (from 0).
+func (s *scriptedLLM) call(n int) []llm.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n >= len(s.calls) {
+		return nil
+	}
+	return s.calls[n]
+}
+
+// newTestAgent returns an agent with no backends whose model is p and whose
+// only tools are tools.
+func newTestAgent(t *testing.T, p llm.Provider, tools ...Tool) *Agent {
+	t.Helper()
+	client := llm.NewClient("", "test-model")
+	client.Provider = p
+	a := New(Config{LLM: client})
+	a.Tools = tools
+	a.toolDefs = ToLLMDefs(tools)
+	a.missing = nil // no limited-mode warning on replies
+	return a
+}
+
+// callTool is a model reply that calls one tool.
+func callTool(id, name, args string) llm.ChatResult {
+	return llm.ChatResult{
+		ToolCalls:    []llm.ToolCall{{ID: id, Type: "function", Function: llm.FunctionCall{Name: name, Arguments: args}}},
+		FinishReason: "tool_calls",
+	}
+}
+
+func TestInvalidToolArgsRetriedOnce(t *testing.T) {
+	var got []string
+	echo := Tool{
+		Name:       "echo",
+		Parameters: map[string]interface{}{"type": "object"},
+		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			text, _ := args["text"].(string)
+			got = append(got, text)
+			return text, nil
+		},
+	}
+	p := &scriptedLLM{replies: []llm.ChatResult{
+		callTool("1", "echo", `{"text": "hi"`),
+		callTool("2", "echo", `{"text": "hi"}`),
+		callTool("3", "echo", `{"text": `),
+		{Content: "all done", FinishReason: "stop"},
+	}}
+	a := newTestAgent(t, p, echo)
+
+	if reply := a.ProcessMessage(context.Background(), 1, "say hi"); reply != "all done" {
+		t.Fatalf("reply = %q", reply)
+	}
+	if len(got) != 1 || got[0] != "hi" {
+		t.Fatalf("tool ran with %q, want once with \"hi\"", got)
+	}
+
+	resend := lastToolResult(p.call(1))
+	if !strings.Contains(resend, "Resend the echo call with valid JSON") {
+		t.Fatalf("first invalid call: tool result %q, want a request to resend", resend)
+	}
+	again := lastToolResult(p.call(3))
+	if strings.Contains(again, "Resend") || !strings.Contains(again, "Error: parse tool args") {
+		t.Fatalf("second invalid call: tool result %q, want a plain error", again)
+	}
+}
+
+// lastToolResult returns the content of the last tool message in msgs.
+func lastToolResult(msgs []llm.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "tool" {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
+
+func TestLengthCutoffIsContinued(t *testing.T) {
+	p := &scriptedLLM{replies: []llm.ChatResult{
+		{Content: "The first half, ", FinishReason: "length"},
+		{Content: "and the second half.", FinishReason: "stop"},
+	}}
+	a := newTestAgent(t, p)
+
+	reply := a.ProcessMessage(context.Background(), 1, "tell me a long story")
+	if reply != "The first half, and the second half." {
+		t.Fatalf("reply = %q, want both parts joined", reply)
+	}
+	msgs := p.call(1)
+	if last := msgs[len(msgs)-1]; last.Role != "user" || !strings.Contains(last.Content, "Continue exactly where you left off") {
+		t.Fatalf("second request ends with %s %q, want a continuation request", last.Role, last.Content)
+	}
+	if prev := msgs[len(msgs)-2]; prev.Role != "assistant" || prev.Content != "The first half, " {
+		t.Fatalf("second request lacks the cut-off answer: %s %q", prev.Role, prev.Content)
+	}
+}
+
+func TestLoopDetectorWindow(t *testing.T) {
+	var d loopDetector
+	// A repeating two-call pattern: each key recurs every other call
+	var counts []int
+	for i := 0; i < 12; i++ {
+		key := "a"
+		if i%2 == 1 {
+			key = "b"
+		}
+		counts = append(counts, d.record(key))
+	}
+	want := []int{1, 1, 2, 2, 3, 3, 4, 4, 4, 4, 4, 4} // capped by the 8-call window
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("counts = %v, want %v", counts, want)
+		}
+	}
+}
+
+func TestToolLoopWarnedThenAborted(t *testing.T) {
+	runs := 0
+	ping := Tool{
+		Name:       "ping",
+		Parameters: map[string]interface{}{"type": "object"},
+		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			runs++
+			return "pong", nil
+		},
+	}
+	p := &scriptedLLM{}
+	for i := 0; i < toolLoopAbort+2; i++ {
+		p.replies = append(p.replies, callTool(fmt.Sprint(i), "ping", `{"host":"example.com"}`))
+	}
+	a := newTestAgent(t, p, ping)
+
+	reply := a.ProcessMessage(context.Background(), 1, "is it up?")
+	if runs != toolLoopWarn-1 {
+		t.Fatalf("tool ran %d times, want %d before the warning", runs, toolLoopWarn-1)
+	}
+	if warned := lastToolResult(p.call(toolLoopWarn)); !strings.HasPrefix(warned, "Not run: you've called ping with the same arguments 3 times") {
+		t.Fatalf("call %d result = %q, want a refusal", toolLoopWarn, warned)
+	}
+	if len(p.calls) != toolLoopAbort {
+		t.Fatalf("model was called %d times, want the turn to end at call %d", len(p.calls), toolLoopAbort)
+	}
+	if !strings.Contains(reply, "Stopped: I kept calling ping with the same arguments") {
+		t.Fatalf("reply = %q, want the loop abort notice", reply)
+	}
+}
+
+// lockCheckEmbedder records whether a.mu was held while it was asked for
+// embeddings.
+type lockCheckEmbedder struct {
+	a           *Agent
+	calls, held int
+}
+
+func (e *lockCheckEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	e.calls++
+	if e.a.mu.TryLock() {
+		e.a.mu.Unlock()
+	} else {
+		e.held++
+	}
+	vecs := make([][]float64, len(texts))
+	for i := range vecs {
+		vecs[i] = []float64{1, float64(i)}
+	}
+	return vecs, nil
+}
+
+func TestSystemPromptEmbedsWithoutLock(t *testing.T) {
+	a := newTestAgent(t, &scriptedLLM{})
+	a.Memory = cognition.NewMemory(storage.NewMemStore(), "b")
+	emb := &lockCheckEmbedder{a: a}
+	a.Memory.SetEmbedder(emb)
+	ctx := agentctx.WithAgentID(context.Background(), agentctx.FormatAgentID(1))
+	if err := a.Memory.LearnFact(ctx, cognition.Fact{Category: "user", Content: "likes tea", Confidence: 0.9}); err != nil {
+		t.Fatal(err)
+	}
+
+	a.ProcessMessage(context.Background(), 1, "what do I drink?")
+	if emb.calls == 0 {
+		t.Fatal("facts were never ranked by embedding")
+	}
+	if emb.held > 0 {
+		t.Errorf("embedded %d of %d times with a.mu held", emb.held, emb.calls)
+	}
+}
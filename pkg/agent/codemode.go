@@ -31,12 +31,13 @@ var dangerPatterns = []*regexp.Regexp{
 // BuildCodeModeTools gives the agent full access to its own source code
 // and the ability to run shell commands, rebuild itself, and generate MCP servers.
 // When r2 and bucket are set, uses per-agent R2 workspace when agentID is in context.
-func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) []Tool {
+func BuildCodeModeTools(workspace string, r2 storage.ObjectStore, bucket string) []Tool {
 	var tools []Tool
 
 	tools = append(tools, Tool{
 		Name:        "read_file",
 		Description: "Read a file from the PicoFlare workspace. Use to inspect your own source code, configs, or any project file.",
+		ReadOnly:    true,
 		Parameters: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -52,11 +53,7 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 				if err != nil {
 					return "", fmt.Errorf("read %s: %w", path, err)
 				}
-				content := string(data)
-				if len(content) > 12000 {
-					content = content[:12000] + fmt.Sprintf("\n...(truncated, %d total bytes)", len(data))
-				}
-				return content, nil
+				return truncateSmart(string(data), 12000, truncMiddle), nil
 			}
 			absPath, err := resolvePath(path, workspace)
 			if err != nil {
@@ -66,11 +63,7 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 			if err != nil {
 				return "", fmt.Errorf("read %s: %w", path, err)
 			}
-			content := string(data)
-			if len(content) > 12000 {
-				content = content[:12000] + fmt.Sprintf("\n...(truncated, %d total bytes)", len(data))
-			}
-			return content, nil
+			return truncateSmart(string(data), 12000, truncMiddle), nil
 		},
 	})
 
@@ -104,13 +97,14 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 
 	tools = append(tools, Tool{
 		Name:        "edit_file",
-		Description: "Edit a file by replacing exact text. old_text must appear exactly once. Use for surgical code changes without rewriting the whole file.",
+		Description: "Edit a file by replacing text. old_text must match exactly one place; if it doesn't match verbatim, a match ignoring whitespace differences is used. Returns a diff of the change. Use for surgical code changes without rewriting the whole file.",
 		Parameters: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
 				"path":     map[string]interface{}{"type": "string", "description": "File path relative to workspace"},
-				"old_text": map[string]interface{}{"type": "string", "description": "Exact text to find (must be unique)"},
+				"old_text": map[string]interface{}{"type": "string", "description": "Text to find (must be unique)"},
 				"new_text": map[string]interface{}{"type": "string", "description": "Replacement text"},
+				"dry_run":  map[string]interface{}{"type": "boolean", "description": "Only show the diff; don't write the file"},
 			},
 			"required": []string{"path", "old_text", "new_text"},
 		},
@@ -118,53 +112,51 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 			path, _ := args["path"].(string)
 			oldText, _ := args["old_text"].(string)
 			newText, _ := args["new_text"].(string)
+			dryRun, _ := args["dry_run"].(bool)
+
+			var read func() ([]byte, error)
+			var write func([]byte) error
 			if agentID, ok := agentctx.AgentIDFromContext(ctx); ok && r2 != nil && bucket != "" {
 				fs := agentfs.New(r2, bucket, agentID)
-				data, err := fs.ReadFile(ctx, path)
+				read = func() ([]byte, error) { return fs.ReadFile(ctx, path) }
+				write = func(data []byte) error { return fs.WriteFile(ctx, path, data) }
+			} else {
+				absPath, err := resolvePath(path, workspace)
 				if err != nil {
-					return "", fmt.Errorf("read %s: %w", path, err)
-				}
-				content := string(data)
-				count := strings.Count(content, oldText)
-				if count == 0 {
-					return "", fmt.Errorf("old_text not found in %s", path)
-				}
-				if count > 1 {
-					return "", fmt.Errorf("old_text appears %d times in %s — must be unique", count, path)
-				}
-				newContent := strings.Replace(content, oldText, newText, 1)
-				if err := fs.WriteFile(ctx, path, []byte(newContent)); err != nil {
-					return "", fmt.Errorf("write %s: %w", path, err)
+					return "", err
 				}
-				return fmt.Sprintf("Edited %s: replaced %d chars with %d chars", path, len(oldText), len(newText)), nil
-			}
-			absPath, err := resolvePath(path, workspace)
-			if err != nil {
-				return "", err
+				read = func() ([]byte, error) { return os.ReadFile(absPath) }
+				write = func(data []byte) error { return os.WriteFile(absPath, data, 0644) }
 			}
-			data, err := os.ReadFile(absPath)
+
+			data, err := read()
 			if err != nil {
 				return "", fmt.Errorf("read %s: %w", path, err)
 			}
 			content := string(data)
-			count := strings.Count(content, oldText)
-			if count == 0 {
-				return "", fmt.Errorf("old_text not found in %s", path)
+			newContent, start, end, fuzzy, err := applyEdit(content, oldText, newText)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", path, err)
+			}
+			diff := editDiff(path, content, start, end, newText)
+			note := ""
+			if fuzzy {
+				note = " (old_text matched ignoring whitespace)"
 			}
-			if count > 1 {
-				return "", fmt.Errorf("old_text appears %d times in %s — must be unique", count, path)
+			if dryRun {
+				return fmt.Sprintf("Dry run, %s not changed%s:\n%s", path, note, diff), nil
 			}
-			newContent := strings.Replace(content, oldText, newText, 1)
-			if err := os.WriteFile(absPath, []byte(newContent), 0644); err != nil {
+			if err := write([]byte(newContent)); err != nil {
 				return "", fmt.Errorf("write %s: %w", path, err)
 			}
-			return fmt.Sprintf("Edited %s: replaced %d chars with %d chars", path, len(oldText), len(newText)), nil
+			return fmt.Sprintf("Edited %s%s:\n%s", path, note, diff), nil
 		},
 	})
 
 	tools = append(tools, Tool{
 		Name:        "list_files",
 		Description: "List files and directories in the workspace. Use to explore your own project structure.",
+		ReadOnly:    true,
 		Parameters: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -209,6 +201,38 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 		},
 	})
 
+	if r2 != nil && bucket != "" {
+		tools = append(tools, Tool{
+			Name:        "remove_dir",
+			Description: "Delete a directory and everything under it from your R2 workspace. Use '.' to clear the whole workspace. Not undoable.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{"type": "string", "description": "Directory relative to workspace, or '.' for all of it"},
+				},
+				"required": []string{"path"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, _ := args["path"].(string)
+				if strings.TrimSpace(path) == "" {
+					return "", fmt.Errorf("path is required")
+				}
+				agentID, ok := agentctx.AgentIDFromContext(ctx)
+				if !ok {
+					return "", fmt.Errorf("remove_dir only works on the per-agent R2 workspace")
+				}
+				n, err := agentfs.New(r2, bucket, agentID).DeleteDir(ctx, path)
+				if err != nil {
+					return "", fmt.Errorf("remove %s: %w", path, err)
+				}
+				if n == 0 {
+					return fmt.Sprintf("%s is empty or doesn't exist; nothing deleted.", path), nil
+				}
+				return fmt.Sprintf("Deleted %s (%d files).", path, n), nil
+			},
+		})
+	}
+
 	tools = append(tools, Tool{
 		Name:        "shell",
 		Description: "Run a shell command in the workspace. Use for 'go build', 'go test', 'go vet', 'git' ops, or system inspection. Dangerous commands are blocked.",
@@ -237,12 +261,10 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 			cmdCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 			defer cancel()
 			cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
+			cmd.WaitDelay = 5 * time.Second // don't hang on background children holding the output pipe
 			cmd.Dir = workDir
 			output, err := cmd.CombinedOutput()
-			result := string(output)
-			if len(result) > 10000 {
-				result = result[:10000] + fmt.Sprintf("\n...(truncated, %d total)", len(output))
-			}
+			result := truncateSmart(string(output), 10000, truncTail)
 			if err != nil {
 				return fmt.Sprintf("Exit error: %v\n\n%s", err, result), nil
 			}
@@ -260,16 +282,13 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 			"type":       "object",
 			"properties": map[string]interface{}{},
 		},
+		Timeout: 150 * time.Second, // go build gets 120s
 		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
-			cmdCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
-			defer cancel()
-			cmd := exec.CommandContext(cmdCtx, "go", "build", "-o", "picoflare", ".")
-			cmd.Dir = workspace
-			output, err := cmd.CombinedOutput()
+			output, err := RebuildSelf(ctx, workspace)
 			if err != nil {
-				return fmt.Sprintf("Build FAILED:\n%s\n%v", string(output), err), nil
+				return fmt.Sprintf("Build FAILED:\n%s\n%v", output, err), nil
 			}
-			return "Build SUCCESS. Binary updated.\nBot restart needed to load new compiled code.", nil
+			return "Build SUCCESS. Binary updated.\nBot restart needed to load new compiled code (the operator can use /rebuild).", nil
 		},
 	})
 
@@ -322,7 +341,7 @@ func BuildCodeModeTools(workspace string, r2 *storage.R2Client, bucket string) [
 
 // BuildWorkspaceSubTools returns read_file, write_file, edit_file, list_files, shell for a sub-workspace.
 // Used when a subagent runs in a specific folder. Excludes self_rebuild and create_skill (main workspace only).
-func BuildWorkspaceSubTools(workspace string, r2 *storage.R2Client, bucket string) []Tool {
+func BuildWorkspaceSubTools(workspace string, r2 storage.ObjectStore, bucket string) []Tool {
 	all := BuildCodeModeTools(workspace, r2, bucket)
 	var out []Tool
 	for _, t := range all {
@@ -453,33 +472,66 @@ export default {
 `, name, desc, name, list.String(), handlers.String())
 }
 
+// resolvePath returns the real absolute path of path (relative to workspace,
+// or absolute) and rejects it unless it lies inside the workspace once every
+// symlink is followed. The file need not exist: symlinks in its existing
+// parent directories are still resolved, so a write through a link can't
+// land outside the workspace.
 func resolvePath(path, workspace string) (string, error) {
 	absWS, err := filepath.Abs(workspace)
 	if err != nil {
 		return "", err
 	}
-	var absPath string
-	if filepath.IsAbs(path) {
-		absPath = filepath.Clean(path)
-	} else {
-		absPath = filepath.Clean(filepath.Join(absWS, path))
+	wsReal, err := realPath(absWS)
+	if err != nil {
+		return "", fmt.Errorf("resolve workspace: %w", err)
 	}
-	if _, err := os.Lstat(absPath); err == nil {
-		if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
-			absPath = resolved
-		}
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(absWS, path)
 	}
-	wsReal, _ := filepath.EvalSymlinks(absWS)
-	if wsReal == "" {
-		wsReal = absWS
+	absPath, err = realPath(filepath.Clean(absPath))
+	if err != nil {
+		return "", fmt.Errorf("access denied: %w", err)
 	}
-	rel, err := filepath.Rel(wsReal, absPath)
-	if err != nil || strings.HasPrefix(rel, "..") {
+	if !withinDir(wsReal, absPath) {
 		return "", fmt.Errorf("access denied: path %q is outside workspace", path)
 	}
 	return absPath, nil
 }
 
+// realPath resolves the symlinks in the longest existing prefix of the
+// absolute path p and appends the rest unchanged. A dangling symlink is an
+// error, since its target can't be checked.
+func realPath(p string) (string, error) {
+	var rest []string
+	for {
+		if _, err := os.Lstat(p); err == nil {
+			resolved, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return "", fmt.Errorf("cannot resolve %s: %w", p, err)
+			}
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(append([]string{p}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// withinDir reports whether path is dir or inside it. Both must be clean
+// absolute paths.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func guardCommand(command string) error {
 	for _, p := range dangerPatterns {
 		if p.MatchString(strings.ToLower(command)) {
@@ -488,3 +540,23 @@ func guardCommand(command string) error {
 	}
 	return nil
 }
+
+// RebuildSelf runs go build in workspace and, only if it succeeds, replaces
+// the picoflare binary. A failed build leaves the running binary untouched.
+// It returns the compiler output.
+func RebuildSelf(ctx context.Context, workspace string) (string, error) {
+	cmdCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(cmdCtx, "go", "build", "-o", "picoflare.new", ".")
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Dir = workspace
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		_ = os.Remove(filepath.Join(workspace, "picoflare.new"))
+		return string(output), err
+	}
+	if err := os.Rename(filepath.Join(workspace, "picoflare.new"), filepath.Join(workspace, "picoflare")); err != nil {
+		return string(output), fmt.Errorf("replace binary: %w", err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// codeModeTool returns the named tool from BuildCodeModeTools(workspace).
+func codeModeTool(t *testing.T, workspace, name string) Tool {
+	t.Helper()
+	for _, tool := range BuildCodeModeTools(workspace, nil, "") {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	t.Fatalf("no %s tool", name)
+	return Tool{}
+}
+
+func TestShellStopsAtDeadline(t *testing.T) {
+	t.Parallel() // waits out WaitDelay for the orphaned sleep
+	shell := codeModeTool(t, t.TempDir(), "shell")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	out, err := shell.Execute(ctx, map[string]interface{}{"command": "sleep 30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The kill is immediate; WaitDelay bounds waiting on an orphaned child
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("shell returned after %v, want it cut off at the deadline", elapsed)
+	}
+	if out == "(no output)" {
+		t.Fatalf("shell reported success for a killed command")
+	}
+}
+
+// sandbox returns a workspace holding src/main.go, with a directory outside
+// it and symlinks from inside to outside:
+//
+//	ws/src/main.go
+//	ws/escape -> outside
+//	ws/dangling -> outside/missing
+//	outside/secret.txt
+func sandbox(t *testing.T) (ws, outside string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir()) // the temp dir may itself be behind a symlink
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, outside = filepath.Join(root, "ws"), filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(ws, "src"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, dir := range map[string]string{"src/main.go": ws, "secret.txt": outside} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(ws, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(ws, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	return ws, outside
+}
+
+func TestResolvePath(t *testing.T) {
+	ws, outside := sandbox(t)
+	tests := []struct {
+		path string
+		want string // "" means access denied
+	}{
+		{path: "src/main.go", want: filepath.Join(ws, "src/main.go")},
+		{path: "src/new/file.go", want: filepath.Join(ws, "src/new/file.go")}, // doesn't exist yet
+		{path: filepath.Join(ws, "src"), want: filepath.Join(ws, "src")},
+		{path: ".", want: ws},
+		{path: ws, want: ws},
+		{path: "src/../src/main.go", want: filepath.Join(ws, "src/main.go")},
+		{path: "src/../../outside/secret.txt"},
+		{path: ".."},
+		{path: filepath.Join(outside, "secret.txt")},
+		{path: "/etc/passwd"},
+		{path: "escape/secret.txt"},
+		{path: "escape/new.txt"},
+		{path: "dangling"},
+		{path: "dangling/file.txt"},
+	}
+	for _, tt := range tests {
+		got, err := resolvePath(tt.path, ws)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("resolvePath(%q) = %q, want access denied", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolvePath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestRealPath(t *testing.T) {
+	ws, outside := sandbox(t)
+	tests := []struct {
+		path, want string
+	}{
+		{filepath.Join(ws, "src/main.go"), filepath.Join(ws, "src/main.go")},
+		{filepath.Join(ws, "src/a/b.go"), filepath.Join(ws, "src/a/b.go")},
+		{filepath.Join(ws, "escape/secret.txt"), filepath.Join(outside, "secret.txt")},
+		{filepath.Join(ws, "escape/a/b.txt"), filepath.Join(outside, "a/b.txt")},
+	}
+	for _, tt := range tests {
+		if got, err := realPath(tt.path); err != nil || got != tt.want {
+			t.Errorf("realPath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if got, err := realPath(filepath.Join(ws, "dangling/file.txt")); err == nil {
+		t.Errorf("realPath through a dangling symlink = %q, want an error", got)
+	}
+}
+
+func TestWithinDir(t *testing.T) {
+	tests := []struct {
+		dir, path string
+		want      bool
+	}{
+		{"/ws", "/ws", true},
+		{"/ws", "/ws/a/b", true},
+		{"/ws", "/ws/..a", true}, // a file named "..a" is inside
+		{"/ws", "/", false},
+		{"/ws", "/ws2", false},
+		{"/ws", "/ws2/a", false},
+		{"/ws/a", "/ws", false},
+		{"/ws", "/other/ws", false},
+	}
+	for _, tt := range tests {
+		if got := withinDir(tt.dir, tt.path); got != tt.want {
+			t.Errorf("withinDir(%q, %q) = %v, want %v", tt.dir, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSubWorkspace(t *testing.T) {
+	ws, _ := sandbox(t)
+	for sub, want := range map[string]string{
+		"src":     filepath.Join(ws, "src"),
+		"src/new": filepath.Join(ws, "src/new"),
+		".":       ws,
+		"":        ws,
+		"/etc":    filepath.Join(ws, "etc"), // always relative to the main workspace
+	} {
+		if got, err := resolveSubWorkspace(ws, sub); err != nil || got != want {
+			t.Errorf("resolveSubWorkspace(%q) = %q, %v; want %q", sub, got, err, want)
+		}
+	}
+	for _, sub := range []string{"..", "src/../..", "../outside", "escape", "escape/deeper", "dangling"} {
+		if got, err := resolveSubWorkspace(ws, sub); err == nil {
+			t.Errorf("resolveSubWorkspace(%q) = %q, want an error", sub, got)
+		}
+	}
+	if _, err := resolveSubWorkspace("", "src"); err == nil {
+		t.Error("resolveSubWorkspace with no main workspace succeeded")
+	}
+}
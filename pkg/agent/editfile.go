@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// editContextLines is the unchanged context shown around a diff hunk or
+	// an ambiguous match.
+	editContextLines = 3
+	// maxEditPreviewLines caps the diff returned by edit_file.
+	maxEditPreviewLines = 80
+	// maxAmbiguousMatches caps the matches listed when old_text isn't unique.
+	maxAmbiguousMatches = 5
+)
+
+// applyEdit replaces the one occurrence of oldText in content with newText.
+// If oldText isn't found verbatim, it retries ignoring differences in
+// whitespace (indentation, trailing spaces, line endings); either way the
+// match must be unique. fuzzy reports whether the fallback was used.
+func applyEdit(content, oldText, newText string) (newContent string, start, end int, fuzzy bool, err error) {
+	if strings.TrimSpace(oldText) == "" {
+		return "", 0, 0, false, fmt.Errorf("old_text is required")
+	}
+	var matches [][]int
+	for off := 0; ; {
+		i := strings.Index(content[off:], oldText)
+		if i < 0 {
+			break
+		}
+		matches = append(matches, []int{off + i, off + i + len(oldText)})
+		off += i + len(oldText)
+	}
+	if len(matches) == 0 {
+		matches = whitespaceInsensitive(oldText).FindAllStringIndex(content, -1)
+		fuzzy = true
+	}
+	switch len(matches) {
+	case 0:
+		return "", 0, 0, false, fmt.Errorf("old_text not found, even ignoring whitespace differences; read_file the current text and retry")
+	case 1:
+		start, end = matches[0][0], matches[0][1]
+		return content[:start] + newText + content[end:], start, end, fuzzy, nil
+	}
+	return "", 0, 0, false, fmt.Errorf("old_text matches %d places; include more surrounding lines to make it unique:\n%s",
+		len(matches), describeMatches(content, matches))
+}
+
+// whitespaceInsensitive matches text with any run of whitespace between its
+// words standing for any other run of whitespace.
+func whitespaceInsensitive(text string) *regexp.Regexp {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	return regexp.MustCompile(strings.Join(words, `\s+`))
+}
+
+// lineOf returns the 1-based line number of byte offset off.
+func lineOf(content string, off int) int {
+	return strings.Count(content[:off], "\n") + 1
+}
+
+// describeMatches shows each match with a little context, by line number.
+func describeMatches(content string, matches [][]int) string {
+	lines := strings.Split(content, "\n")
+	var sb strings.Builder
+	for i, m := range matches {
+		if i == maxAmbiguousMatches {
+			sb.WriteString(fmt.Sprintf("... and %d more\n", len(matches)-i))
+			break
+		}
+		first, last := lineOf(content, m[0]), lineOf(content, m[1])
+		sb.WriteString(fmt.Sprintf("Match %d (line %d):\n", i+1, first))
+		from := max(first-1-editContextLines, 0)
+		to := min(last+editContextLines, len(lines))
+		for n := from; n < to; n++ {
+			sb.WriteString(fmt.Sprintf("%5d | %s\n", n+1, lines[n]))
+		}
+	}
+	return sb.String()
+}
+
+// editDiff is a unified diff of replacing content[start:end] with newText,
+// with editContextLines of context.
+func editDiff(path, content string, start, end int, newText string) string {
+	// Widen the change to whole lines
+	lineStart := strings.LastIndex(content[:start], "\n") + 1
+	lineEnd := len(content)
+	if i := strings.Index(content[end:], "\n"); i >= 0 {
+		lineEnd = end + i
+	}
+	oldLines := strings.Split(content[lineStart:lineEnd], "\n")
+	newLines := strings.Split(content[lineStart:start]+newText+content[end:lineEnd], "\n")
+
+	all := strings.Split(content, "\n")
+	first := lineOf(content, lineStart) - 1 // 0-based index of the first changed line
+	before := all[max(first-editContextLines, 0):first]
+	afterFrom := first + len(oldLines)
+	after := all[afterFrom:min(afterFrom+editContextLines, len(all))]
+
+	var out []string
+	for _, l := range before {
+		out = append(out, " "+l)
+	}
+	for _, l := range oldLines {
+		out = append(out, "-"+l)
+	}
+	for _, l := range newLines {
+		out = append(out, "+"+l)
+	}
+	for _, l := range after {
+		out = append(out, " "+l)
+	}
+	if len(out) > maxEditPreviewLines {
+		out = append(out[:maxEditPreviewLines], fmt.Sprintf("... (%d more diff lines)", len(out)-maxEditPreviewLines))
+	}
+
+	oldStart := first - len(before) + 1
+	ctx := len(before) + len(after)
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n@@ -%d,%d +%d,%d @@\n%s\n", path, path,
+		oldStart, len(oldLines)+ctx, oldStart, len(newLines)+ctx, strings.Join(out, "\n"))
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"log"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+const (
+	// DefaultSessionTTL is how long a chat's session is kept after its last
+	// message when Config.SessionTTL is zero.
+	DefaultSessionTTL = 2 * time.Hour
+	// maxSessionSweep bounds how often idle sessions are looked for.
+	maxSessionSweep = 10 * time.Minute
+)
+
+// evictIdleSessions drops sessions unused for ttl, checking periodically
+// for the life of the process. The next message in such a chat starts a
+// fresh session, with the rolling summary for continuity.
+func (a *Agent) evictIdleSessions(ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl/2, maxSessionSweep))
+	defer ticker.Stop()
+	for range ticker.C {
+		a.sweepSessions(ttl)
+	}
+}
+
+// sweepSessions evicts the sessions idle longer than ttl. Messages the
+// summary hasn't covered yet are folded into it first, so they outlive the
+// session. Chats with a message in progress are kept.
+func (a *Agent) sweepSessions(ttl time.Duration) {
+	cutoff := time.Now().Add(-ttl)
+	type flush struct {
+		chatID int64
+		msgs   []llm.Message
+		turns  int
+	}
+	var flushes []flush
+	a.mu.Lock()
+	for chatID, sess := range a.sessions {
+		if !sess.LastUsed.Before(cutoff) || len(a.inflight[chatID]) > 0 {
+			continue
+		}
+		if msgs := sess.unsummarizedMessages(); len(msgs) > 0 {
+			flushes = append(flushes, flush{chatID, msgs, sess.turnsSinceSummary})
+		}
+		delete(a.sessions, chatID)
+		log.Printf("Session: chat %d idle since %s, evicted", chatID, sess.LastUsed.Format(time.RFC3339))
+	}
+	a.mu.Unlock()
+	for _, f := range flushes {
+		go a.updateSummary(f.chatID, f.msgs, f.turns)
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import "strings"
+
+// missingBackends lists the backends that failed to initialize, each with the
+// credentials that enable it and what is unavailable without it. Empty when
+// everything is configured.
+func missingBackends(cfg Config) []string {
+	var missing []string
+	if cfg.MCP == nil && cfg.CF == nil {
+		missing = append(missing, "Cloudflare (set CLOUDFLARE_ACCOUNT_ID and a valid CLOUDFLARE_API_TOKEN): no Workers, R2 buckets, KV, D1, DNS, inventory, or Workers AI tools")
+	} else if cfg.CF == nil {
+		missing = append(missing, "Cloudflare REST API (CLOUDFLARE_API_TOKEN failed verification): no direct Worker deploys, bucket management, or Workers AI tools; Cloudflare operations go through MCP")
+	} else if cfg.MCP == nil {
+		missing = append(missing, "Cloudflare MCP (could not connect with CLOUDFLARE_API_TOKEN): no cf_search/cf_execute; Cloudflare operations use the REST API only")
+	}
+	if cfg.R2 == nil {
+		missing = append(missing, "Storage (set R2_ACCESS_KEY_ID and R2_SECRET_ACCESS_KEY, or STORAGE_BACKEND=file): no memory, learned facts, conversation summaries, reminders, or file storage")
+	}
+	return missing
+}
+
+// limitedModeNote is the system prompt section telling the model which tools
+// are missing, so it explains the gap instead of guessing around it.
+func (a *Agent) limitedModeNote() string {
+	if len(a.missing) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Limited Mode\n")
+	sb.WriteString("Some backends failed to initialize, so these capabilities are unavailable:\n")
+	for _, m := range a.missing {
+		sb.WriteString("- " + m + "\n")
+	}
+	sb.WriteString("When asked for something that needs them, say so and name the credentials to set instead of attempting it another way.\n\n")
+	return sb.String()
+}
+
+// limitedModeWarning returns a warning to prepend to the first reply in
+// chatID, or "" if the agent is fully configured or the chat was warned.
+func (a *Agent) limitedModeWarning(chatID int64) string {
+	if len(a.missing) == 0 {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.limitWarned[chatID] {
+		return ""
+	}
+	a.limitWarned[chatID] = true
+	var sb strings.Builder
+	sb.WriteString("⚠️ Running with limited tools:\n")
+	for _, m := range a.missing {
+		sb.WriteString("• " + m + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/cognition"
+	"github.com/bigneek/picoflare/pkg/mcpclient"
+)
+
+const (
+	// remoteToolPrefix marks tools proxied to an external MCP server:
+	// mcp_<server>_<tool>.
+	remoteToolPrefix = "mcp_"
+	// mcpConnectTimeout bounds connecting to one server and listing its tools.
+	mcpConnectTimeout = 20 * time.Second
+	// maxRemoteTools caps the tools taken from one server, so a large server
+	// doesn't crowd out the built-in tools.
+	maxRemoteTools = 40
+	// maxToolNameLen is the longest tool name LLM APIs accept.
+	maxToolNameLen = 64
+)
+
+// remoteMCP holds the proxy tools of the external MCP servers the agent has
+// connected to (connect_mcp). Connections are saved in R2 and restored on
+// startup.
+type remoteMCP struct {
+	registry *cognition.ToolRegistry
+
+	mu    sync.Mutex
+	tools map[string][]Tool // server name -> proxy tools
+
+	// onChange is called after a server is connected or removed, to rebuild
+	// the agent's tool set (Agent.RefreshTools).
+	onChange func()
+}
+
+func newRemoteMCP(registry *cognition.ToolRegistry) *remoteMCP {
+	return &remoteMCP{registry: registry, tools: make(map[string][]Tool)}
+}
+
+// toolNamePart lowercases s and replaces anything but letters, digits, and
+// underscores, so it can be part of a tool name.
+func toolNamePart(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return strings.Trim(sb.String(), "_")
+}
+
+// mcpServerName is name cleaned for use in tool names, or the first label of
+// the server's host when name is empty.
+func mcpServerName(name, rawURL string) (string, error) {
+	if name == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			name, _, _ = strings.Cut(u.Hostname(), ".")
+		}
+	}
+	name = toolNamePart(name)
+	if name == "" {
+		return "", fmt.Errorf("MCP server name is required")
+	}
+	if len(name) > 20 {
+		name = name[:20]
+	}
+	return name, nil
+}
+
+// connect lists the server's tools and builds a proxy Tool for each.
+func (r *remoteMCP) connect(ctx context.Context, s cognition.MCPServer) ([]Tool, error) {
+	if err := mcpclient.ValidateEndpoint(s.URL); err != nil {
+		return nil, err
+	}
+	client := mcpclient.NewClient(s.URL, s.Token, "")
+	ctx, cancel := context.WithTimeout(ctx, mcpConnectTimeout)
+	defer cancel()
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", s.URL, err)
+	}
+	if len(infos) > maxRemoteTools {
+		log.Printf("MCP server %s: using %d of %d tools", s.Name, maxRemoteTools, len(infos))
+		infos = infos[:maxRemoteTools]
+	}
+
+	tools := make([]Tool, 0, len(infos))
+	seen := make(map[string]bool)
+	for _, info := range infos {
+		remoteName := info.Name
+		name := remoteToolPrefix + s.Name + "_" + toolNamePart(remoteName)
+		if len(name) > maxToolNameLen {
+			name = name[:maxToolNameLen]
+		}
+		if seen[name] {
+			continue // two remote names that clean to the same tool name
+		}
+		seen[name] = true
+
+		params := info.InputSchema
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		tools = append(tools, Tool{
+			Name:        name,
+			Description: fmt.Sprintf("[MCP %s] %s", s.Name, truncate(info.Description, 500)),
+			Parameters:  params,
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				return client.CallTool(ctx, remoteName, args)
+			},
+		})
+	}
+	return tools, nil
+}
+
+// loadSaved reconnects to the servers saved in R2. A server that can't be
+// reached is skipped until the next restart or connect_mcp.
+func (r *remoteMCP) loadSaved(ctx context.Context) {
+	servers, err := r.registry.LoadMCPServers(ctx)
+	if err != nil {
+		log.Printf("MCP servers: %v", err)
+		return
+	}
+	for _, s := range servers {
+		tools, err := r.connect(ctx, s)
+		if err != nil {
+			log.Printf("MCP server %s unavailable: %v", s.Name, err)
+			continue
+		}
+		r.mu.Lock()
+		r.tools[s.Name] = tools
+		r.mu.Unlock()
+		log.Printf("MCP server %s: %d tools", s.Name, len(tools))
+	}
+}
+
+// all returns the proxy tools of every connected server.
+func (r *remoteMCP) all() []Tool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var tools []Tool
+	for _, name := range names {
+		tools = append(tools, r.tools[name]...)
+	}
+	return tools
+}
+
+func (r *remoteMCP) changed() {
+	if r.onChange != nil {
+		r.onChange()
+	}
+}
+
+// buildRemoteMCPTools returns connect_mcp and disconnect_mcp.
+func buildRemoteMCPTools(r *remoteMCP) []Tool {
+	return []Tool{
+		{
+			Name:        "connect_mcp",
+			Description: "Connect to an external MCP server (Streamable HTTP) and add its tools as mcp_<name>_<tool>. The connection is saved and restored on restart. Reconnect with the same name to refresh its tools.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url":   map[string]interface{}{"type": "string", "description": "MCP endpoint URL, e.g. https://example.com/mcp"},
+					"token": map[string]interface{}{"type": "string", "description": "Bearer token, if the server needs one (stored in R2)"},
+					"name":  map[string]interface{}{"type": "string", "description": "Short name for the server (default: from the URL's host)"},
+				},
+				"required": []string{"url"},
+			},
+			Timeout: mcpConnectTimeout + 10*time.Second,
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				rawURL, _ := args["url"].(string)
+				token, _ := args["token"].(string)
+				name, _ := args["name"].(string)
+				name, err := mcpServerName(name, rawURL)
+				if err != nil {
+					return "", err
+				}
+				server := cognition.MCPServer{Name: name, URL: strings.TrimSpace(rawURL), Token: token}
+				tools, err := r.connect(ctx, server)
+				if err != nil {
+					return "", err
+				}
+				if len(tools) == 0 {
+					return "", fmt.Errorf("MCP server %s offers no tools", server.URL)
+				}
+				if err := r.registry.SaveMCPServer(ctx, server); err != nil {
+					return "", err
+				}
+				r.mu.Lock()
+				r.tools[name] = tools
+				r.mu.Unlock()
+				r.changed()
+
+				names := make([]string, len(tools))
+				for i, t := range tools {
+					names[i] = t.Name
+				}
+				return fmt.Sprintf("Connected to MCP server %q with %d tools: %s", name, len(tools), strings.Join(names, ", ")), nil
+			},
+		},
+		{
+			Name:        "disconnect_mcp",
+			Description: "Disconnect an external MCP server added with connect_mcp and remove its tools.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string", "description": "Server name (the <name> in mcp_<name>_<tool>)"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				if err := r.registry.RemoveMCPServer(ctx, name); err != nil {
+					return "", err
+				}
+				r.mu.Lock()
+				delete(r.tools, name)
+				r.mu.Unlock()
+				r.changed()
+				return fmt.Sprintf("Disconnected MCP server %q.", name), nil
+			},
+		},
+	}
+}
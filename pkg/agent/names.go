@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource kinds checked by validateResourceName.
+const (
+	resourceWorker    = "worker"
+	resourceBucket    = "bucket"
+	resourceVectorize = "vectorize index"
+)
+
+// nameRule is Cloudflare's naming rule for one kind of resource.
+type nameRule struct {
+	min, max    int
+	letterFirst bool // must start with a letter (otherwise a letter or digit)
+}
+
+var nameRules = map[string]nameRule{
+	resourceWorker:    {min: 1, max: 63},
+	resourceBucket:    {min: 3, max: 63},
+	resourceVectorize: {min: 1, max: 32, letterFirst: true},
+}
+
+// validateResourceName checks a worker, bucket, or Vectorize index name
+// before it reaches the API, which would otherwise answer with a bare 400.
+// All three take lowercase letters, digits, and hyphens, not at either end.
+// Only surrounding space is dropped; any other slip is rejected, since
+// quietly renaming "My_Worker" to "my-worker" leaves the user looking for a
+// resource that doesn't exist. The error says exactly what to change and
+// suggests a valid name.
+func validateResourceName(kind, name string) (string, error) {
+	rule, ok := nameRules[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown resource kind %q", kind)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%s name is required", kind)
+	}
+	if problem := rule.check(name); problem != "" {
+		err := fmt.Errorf("invalid %s name %q: %s", kind, name, problem)
+		if s := rule.suggest(name); s != "" {
+			err = fmt.Errorf("%w; try %q", err, s)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// check returns what is wrong with name, or "" if it is valid.
+func (rule nameRule) check(name string) string {
+	switch {
+	case len(name) < rule.min || len(name) > rule.max:
+		return fmt.Sprintf("must be %d-%d characters (got %d)", rule.min, rule.max, len(name))
+	case strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-"):
+		return "can't start or end with a hyphen"
+	case rule.letterFirst && (name[0] < 'a' || name[0] > 'z'):
+		return "must start with a lowercase letter"
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return fmt.Sprintf("%q not allowed (use lowercase letters, digits, and hyphens)", r)
+		}
+	}
+	return ""
+}
+
+// suggest returns a valid name close to name, or "" if there is none.
+func (rule nameRule) suggest(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r == '-' || r == '_' || r == ' ' || r == '.':
+			if s := sb.String(); s != "" && !strings.HasSuffix(s, "-") {
+				sb.WriteByte('-')
+			}
+		}
+	}
+	s := sb.String()
+	if len(s) > rule.max {
+		s = s[:rule.max]
+	}
+	s = strings.Trim(s, "-")
+	if rule.check(s) != "" {
+		return ""
+	}
+	return s
+}
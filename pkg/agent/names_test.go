@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+func TestValidateResourceName(t *testing.T) {
+	tests := []struct {
+		kind, name string
+		want       string // valid name returned, or "" if rejected
+		suggest    string // suggestion in the error, if any
+	}{
+		{kind: resourceWorker, name: " my-worker ", want: "my-worker"},
+		{kind: resourceWorker, name: "My_Worker", suggest: `try "my-worker"`},
+		{kind: resourceWorker, name: "hello world", suggest: `try "hello-world"`},
+		{kind: resourceWorker, name: "-edge-", suggest: `try "edge"`},
+		{kind: resourceBucket, name: "ab", suggest: ""},
+		{kind: resourceVectorize, name: "1index", suggest: ""},
+		{kind: resourceVectorize, name: "Memory.Index", suggest: `try "memory-index"`},
+	}
+	for _, tt := range tests {
+		got, err := validateResourceName(tt.kind, tt.name)
+		if tt.want != "" {
+			if err != nil || got != tt.want {
+				t.Errorf("%s %q = %q, %v; want %q", tt.kind, tt.name, got, err, tt.want)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s %q accepted as %q, want an error", tt.kind, tt.name, got)
+			continue
+		}
+		if tt.suggest != "" && !strings.Contains(err.Error(), tt.suggest) {
+			t.Errorf("%s %q: error %q lacks %s", tt.kind, tt.name, err, tt.suggest)
+		}
+		if tt.suggest == "" && strings.Contains(err.Error(), "try") {
+			t.Errorf("%s %q: error %q suggests a name, want none", tt.kind, tt.name, err)
+		}
+	}
+}
+
+func TestDeleteVectorizeIndexRefusesOwn(t *testing.T) {
+	ctx := context.Background()
+	var deleted []string
+	del := func(_ context.Context, name string) error {
+		deleted = append(deleted, name)
+		return nil
+	}
+
+	for _, name := range []string{"picoflare-memory", " picoflare-memory ", "Bad_Index", ""} {
+		if _, err := deleteVectorizeIndex(ctx, "picoflare-memory", name, del); err == nil {
+			t.Errorf("deleteVectorizeIndex(%q) succeeded, want an error", name)
+		}
+	}
+	if _, err := deleteVectorizeIndex(ctx, "picoflare-memory", "scratch", del); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "scratch" {
+		t.Errorf("deleted = %q, want only scratch", deleted)
+	}
+}
+
+func TestDeleteBucketForce(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemStore()
+	for _, key := range []string{"a", "b/c", "d"} {
+		if err := store.UploadObject(ctx, "scratch", key, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	del := func(context.Context, string) error { return nil }
+
+	if _, err := deleteBucket(ctx, store, "own", "own", true, del); err == nil {
+		t.Error("deleted the agent's own bucket")
+	}
+	got, err := deleteBucket(ctx, store, "own", "scratch", true, del)
+	if err != nil || !strings.Contains(got, "3 objects removed") {
+		t.Fatalf("deleteBucket = %q, %v; want 3 objects removed", got, err)
+	}
+	if keys, _ := store.ListObjects(ctx, "scratch", "", 1000); len(keys) != 0 {
+		t.Errorf("left %q behind", keys)
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+const (
+	noteSearchMaxFiles   = 300     // objects scanned per search
+	noteSearchMaxBytes   = 1 << 20 // larger objects are skipped
+	noteSearchMaxResults = 20      // matching files returned
+	noteSnippetsPerFile  = 3
+	noteSnippetContext   = 80 // bytes shown on each side of a match
+)
+
+// textExts are file types worth searching. Objects without an extension are
+// searched too if their content is valid UTF-8.
+var textExts = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".csv": true, ".tsv": true, ".json": true,
+	".log": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".xml": true,
+	".html": true, ".htm": true, ".sql": true, ".go": true, ".js": true, ".ts": true,
+	".py": true, ".sh": true, ".rs": true, ".java": true, ".c": true, ".h": true, ".css": true,
+}
+
+// searchNotes searches the text content of a user's voice-note transcripts and
+// uploaded files (users/<id>/notes/ and users/<id>/files/) for query, as a
+// case-insensitive substring or, with useRegex, a regular expression.
+func searchNotes(ctx context.Context, r2 storage.ObjectStore, bucket, userID, query string, useRegex bool) (string, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" || strings.ContainsAny(userID, "/.") {
+		return "", fmt.Errorf("user_id must be a plain user ID")
+	}
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	pattern := "(?i)" + regexp.QuoteMeta(query)
+	if useRegex {
+		pattern = "(?i)" + query
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	var objects []storage.ObjectInfo
+	for _, sub := range []string{"notes/", "files/"} {
+		infos, err := r2.ListObjectInfos(ctx, bucket, fmt.Sprintf("users/%s/%s", userID, sub))
+		if err != nil {
+			return "", fmt.Errorf("list %s: %w", sub, err)
+		}
+		objects = append(objects, infos...)
+	}
+	if len(objects) == 0 {
+		return fmt.Sprintf("No notes or files stored for user %s.", userID), nil
+	}
+
+	var sb strings.Builder
+	scanned, skipped, matched := 0, 0, 0
+	for _, obj := range objects {
+		if matched >= noteSearchMaxResults || scanned >= noteSearchMaxFiles {
+			break
+		}
+		ext := strings.ToLower(path.Ext(obj.Key))
+		if obj.Size > noteSearchMaxBytes || (ext != "" && !textExts[ext]) {
+			skipped++
+			continue
+		}
+		scanned++
+		data, err := r2.DownloadObject(ctx, bucket, obj.Key)
+		if err != nil || !utf8.Valid(data) {
+			skipped++
+			continue
+		}
+		text := string(data)
+		locs := re.FindAllStringIndex(text, noteSnippetsPerFile)
+		if len(locs) == 0 {
+			continue
+		}
+		matched++
+		sb.WriteString(fmt.Sprintf("- %s\n", obj.Key))
+		for _, loc := range locs {
+			sb.WriteString("    …" + snippet(text, loc[0], loc[1]) + "…\n")
+		}
+	}
+
+	if matched == 0 {
+		return fmt.Sprintf("No matches for %q in %d searched files (%d skipped as binary or too large).", query, scanned, skipped), nil
+	}
+	header := fmt.Sprintf("%d file(s) matching %q (searched %d, skipped %d):\n", matched, query, scanned, skipped)
+	if matched >= noteSearchMaxResults || scanned >= noteSearchMaxFiles {
+		header += "(stopped early; narrow the query for more)\n"
+	}
+	return header + sb.String(), nil
+}
+
+// snippet returns text around [start, end) on a single line, cut on rune
+// boundaries.
+func snippet(text string, start, end int) string {
+	from := start - noteSnippetContext
+	if from < 0 {
+		from = 0
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	to := end + noteSnippetContext
+	if to > len(text) {
+		to = len(text)
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return strings.Join(strings.Fields(text[from:to]), " ")
+}
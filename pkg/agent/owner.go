@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errOwnerOnly is returned by tools that change global settings or other
+// users' data when the turn isn't the operator's.
+var errOwnerOnly = errors.New("only the operator can do this (from the TELEGRAM_OWNER_ID chat or the local CLI)")
+
+type ownerKey struct{}
+
+// WithOwner marks ctx as a turn from the bot's operator: the configured
+// owner chat, or the local CLI. Chat IDs and user IDs in tool arguments come
+// from the model, so tools check this instead.
+func WithOwner(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownerKey{}, true)
+}
+
+// isOwner reports whether ctx belongs to an operator turn (see WithOwner).
+func isOwner(ctx context.Context) bool {
+	on, _ := ctx.Value(ownerKey{}).(bool)
+	return on
+}
+
+// actingUserID returns the user whose files a tool works on: the chat's own
+// user, or userID in an operator turn. userID comes from the model, so any
+// other turn naming someone else is refused.
+func actingUserID(ctx context.Context, userID string) (string, error) {
+	if isOwner(ctx) && userID != "" {
+		return userID, nil
+	}
+	self := chatUserID(ctx)
+	if self == "" {
+		return "", errors.New("no user: this only works in a chat")
+	}
+	if userID != "" && userID != self {
+		return "", fmt.Errorf("%w; users can only use their own files", errOwnerOnly)
+	}
+	return self, nil
+}
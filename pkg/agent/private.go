@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+)
+
+// privateBlockedTools write what was said in a chat to R2, so they are
+// refused in private mode. spawn and set_reminder are included because their
+// task or reminder text is persisted for delivery after restarts.
+var privateBlockedTools = map[string]bool{
+	"learn_fact":      true,
+	"save_episode":    true,
+	"learn_procedure": true,
+	"self_reflect":    true,
+	"set_goal":        true,
+	"spawn":           true,
+	"set_reminder":    true,
+}
+
+type privateKey struct{}
+
+// withPrivate marks ctx as a private-mode turn.
+func withPrivate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, privateKey{}, true)
+}
+
+// isPrivate reports whether ctx belongs to a private-mode turn.
+func isPrivate(ctx context.Context) bool {
+	on, _ := ctx.Value(privateKey{}).(bool)
+	return on
+}
+
+// privateFlagKey marks a chat as private. Only the flag is stored, so the
+// setting survives restarts; nothing about the chat's turns is.
+func privateFlagKey(chatID int64) string {
+	return fmt.Sprintf("agents/%s/private", agentctx.FormatAgentID(chatID))
+}
+
+// IsPrivate reports whether chatID is in private mode: no episodes, learned
+// facts, summaries, or other memory are written for its turns.
+func (a *Agent) IsPrivate(ctx context.Context, chatID int64) bool {
+	if a.privateAll {
+		return true
+	}
+	a.privateMu.Lock()
+	on, ok := a.private[chatID]
+	a.privateMu.Unlock()
+	if ok {
+		return on
+	}
+	if a.R2 != nil {
+		exists, err := a.R2.ObjectExists(ctx, a.Bucket, privateFlagKey(chatID))
+		if err != nil {
+			return true // fail closed; check again next turn
+		}
+		on = exists
+	}
+	a.privateMu.Lock()
+	a.private[chatID] = on
+	a.privateMu.Unlock()
+	return on
+}
+
+// SetPrivate turns private mode on or off for chatID. It fails when the
+// operator enabled private mode for every chat (Config.PrivateMode).
+func (a *Agent) SetPrivate(ctx context.Context, chatID int64, on bool) error {
+	if a.privateAll && !on {
+		return fmt.Errorf("private mode is on for every chat (PRIVATE_MODE)")
+	}
+	if a.R2 != nil {
+		var err error
+		if on {
+			err = a.R2.UploadObject(ctx, a.Bucket, privateFlagKey(chatID), []byte("on\n"))
+		} else {
+			err = a.R2.DeleteObject(ctx, a.Bucket, privateFlagKey(chatID))
+		}
+		if err != nil {
+			return fmt.Errorf("save private mode: %w", err)
+		}
+	}
+	a.privateMu.Lock()
+	a.private[chatID] = on
+	a.privateMu.Unlock()
+	return nil
+}
@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrivateModeBlocksSetReminder(t *testing.T) {
+	ran := false
+	tools := []Tool{{
+		Name: "set_reminder",
+		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			ran = true
+			return "ok", nil
+		},
+	}}
+
+	if _, err := ExecuteTool(withPrivate(context.Background()), tools, "set_reminder", `{"text":"call the bank"}`); err == nil || ran {
+		t.Fatalf("set_reminder ran in private mode (err %v)", err)
+	}
+	if _, err := ExecuteTool(context.Background(), tools, "set_reminder", `{"text":"call the bank"}`); err != nil || !ran {
+		t.Fatalf("set_reminder refused outside private mode: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+const (
+	// r2UsageTTL is how long a listing is reused, so follow-up questions
+	// don't each walk the whole bucket again.
+	r2UsageTTL     = 2 * time.Minute
+	r2UsageTopN    = 10
+	r2UsageMaxTopN = 50
+)
+
+// usageCache holds recent bucket listings keyed by bucket and prefix.
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]usageEntry
+}
+
+type usageEntry struct {
+	objects  []storage.ObjectInfo
+	listedAt time.Time
+}
+
+func newUsageCache() *usageCache {
+	return &usageCache{entries: make(map[string]usageEntry)}
+}
+
+// list returns the objects under prefix, from the cache when fresh.
+func (c *usageCache) list(ctx context.Context, r2 storage.ObjectStore, bucket, prefix string) ([]storage.ObjectInfo, time.Time, error) {
+	key := bucket + "\x00" + prefix
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(e.listedAt) < r2UsageTTL {
+		return e.objects, e.listedAt, nil
+	}
+
+	objects, err := r2.ListObjectInfos(ctx, bucket, prefix)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	e = usageEntry{objects: objects, listedAt: time.Now()}
+	c.mu.Lock()
+	for k, old := range c.entries {
+		if time.Since(old.listedAt) >= r2UsageTTL {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+	c.mu.Unlock()
+	return e.objects, e.listedAt, nil
+}
+
+// r2Usage summarizes the objects under prefix in bucket: count, total size,
+// and the top largest objects.
+func r2Usage(ctx context.Context, cache *usageCache, r2 storage.ObjectStore, bucket, prefix string, top int) (string, error) {
+	if top <= 0 {
+		top = r2UsageTopN
+	}
+	if top > r2UsageMaxTopN {
+		top = r2UsageMaxTopN
+	}
+	objects, listedAt, err := cache.list(ctx, r2, bucket, prefix)
+	if err != nil {
+		return "", fmt.Errorf("list r2://%s/%s: %w", bucket, prefix, err)
+	}
+	where := fmt.Sprintf("r2://%s/%s", bucket, prefix)
+	if len(objects) == 0 {
+		return fmt.Sprintf("No objects under %s.", where), nil
+	}
+
+	var total int64
+	for _, o := range objects {
+		total += o.Size
+	}
+	largest := make([]storage.ObjectInfo, len(objects))
+	copy(largest, objects)
+	sort.Slice(largest, func(i, j int) bool { return largest[i].Size > largest[j].Size })
+	if len(largest) > top {
+		largest = largest[:top]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s: %d objects, %s total\n", where, len(objects), formatBytes(total)))
+	sb.WriteString(fmt.Sprintf("\nLargest %d:\n", len(largest)))
+	for _, o := range largest {
+		sb.WriteString(fmt.Sprintf("- %s (%s)\n", o.Key, formatBytes(o.Size)))
+	}
+	if age := time.Since(listedAt); age > time.Second {
+		sb.WriteString(fmt.Sprintf("\n(listing from %s ago)\n", age.Round(time.Second)))
+	}
+	return sb.String(), nil
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+// readOnlyBlockedTools are ReadOnly tools still refused in read-only turns:
+// they can send what other tools read to any address.
+var readOnlyBlockedTools = map[string]bool{
+	"http_request": true,
+}
+
+type readOnlyKey struct{}
+
+// WithReadOnly marks ctx as a read-only turn, such as one handling an
+// inbound event whose payload comes from outside: the model is offered, and
+// may run, only ReadOnly tools.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// isReadOnly reports whether ctx belongs to a read-only turn (see WithReadOnly).
+func isReadOnly(ctx context.Context) bool {
+	on, _ := ctx.Value(readOnlyKey{}).(bool)
+	return on
+}
+
+// readOnlyAllowed reports whether t may run in a read-only turn.
+func readOnlyAllowed(t Tool) bool {
+	return t.ReadOnly && !readOnlyBlockedTools[t.Name]
+}
+
+// toolDefsFor returns the tool definitions to offer the model in ctx's turn.
+func (a *Agent) toolDefsFor(ctx context.Context) []llm.ToolDef {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !isReadOnly(ctx) {
+		return a.toolDefs
+	}
+	var tools []Tool
+	for _, t := range a.Tools {
+		if readOnlyAllowed(t) {
+			tools = append(tools, t)
+		}
+	}
+	return ToLLMDefs(tools)
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadOnlyTurnRefusesWrites(t *testing.T) {
+	var ran []string
+	tool := func(name string, readOnly bool) Tool {
+		return Tool{
+			Name:       name,
+			Parameters: map[string]interface{}{"type": "object"},
+			ReadOnly:   readOnly,
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				ran = append(ran, name)
+				return "ok", nil
+			},
+		}
+	}
+	a := newTestAgent(t, &scriptedLLM{}, tool("r2_read", true), tool("r2_write", false), tool("http_request", true))
+	ctx := WithReadOnly(context.Background())
+
+	for _, name := range []string{"r2_read", "r2_write", "http_request"} {
+		_, err := ExecuteTool(ctx, a.Tools, name, "{}")
+		if want := name == "r2_read"; (err == nil) != want {
+			t.Errorf("%s in a read-only turn: err %v, want allowed %v", name, err, want)
+		}
+	}
+	if len(ran) != 1 || ran[0] != "r2_read" {
+		t.Errorf("ran %q, want only r2_read", ran)
+	}
+	if defs := a.toolDefsFor(ctx); len(defs) != 1 || defs[0].Function.Name != "r2_read" {
+		t.Errorf("offered %+v, want only r2_read", defs)
+	}
+	if defs := a.toolDefsFor(context.Background()); len(defs) != 3 {
+		t.Errorf("offered %d tools outside a read-only turn, want 3", len(defs))
+	}
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// secretEnvVars hold credentials whose literal values must never reach the
+// model or the logs.
+var secretEnvVars = []string{
+	"CLOUDFLARE_API_TOKEN",
+	"R2_ACCESS_KEY_ID",
+	"R2_SECRET_ACCESS_KEY",
+	"TELEGRAM_BOT_TOKEN",
+	"TELEGRAM_WEBHOOK_SECRET",
+	"OPENROUTER_API_KEY",
+	"OPENAI_API_KEY",
+	"SLACK_BOT_TOKEN",
+	"SLACK_SIGNING_SECRET",
+	"EVENT_WEBHOOK_TOKEN",
+}
+
+var (
+	bearerRe = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`)
+	// KEY=value, "api_key": "value", X-Auth-Token: value, ...
+	assignRe = regexp.MustCompile(`(?i)(\b[a-z0-9_-]*(?:token|secret|password|passwd|api_?key|access_?key(?:_id)?)"?\s*[:=]\s*"?)([^\s"',;]{8,})`)
+	// OpenAI/OpenRouter keys and Telegram bot tokens
+	prefixedRe  = regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}|\b\d{8,10}:[A-Za-z0-9_-]{35}\b`)
+	longTokenRe = regexp.MustCompile(`[A-Za-z0-9_-]{32,}`)
+)
+
+// redactAllowPrefixes precede long values that are hashes, not secrets
+// (go.sum lines, subresource integrity).
+var redactAllowPrefixes = []string{"h1:", "sha256-", "sha384-", "sha512-"}
+
+var (
+	envSecretsOnce sync.Once
+	envSecrets     []string
+)
+
+// redactSecrets masks credentials in tool output and log lines: configured
+// secret env values, bearer tokens, key=value assignments with secret-looking
+// names, well-known key formats, and long high-entropy tokens. Hex IDs and
+// UUIDs (account, D1, KV IDs) are left alone because the agent needs them.
+func redactSecrets(s string) string {
+	if s == "" {
+		return s
+	}
+	envSecretsOnce.Do(func() {
+		for _, name := range secretEnvVars {
+			if v := os.Getenv(name); len(v) >= 8 {
+				envSecrets = append(envSecrets, v)
+			}
+		}
+	})
+	for _, v := range envSecrets {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+
+	s = bearerRe.ReplaceAllString(s, "${1}"+redacted)
+	s = assignRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := assignRe.FindStringSubmatch(m)
+		if sub[2] == redacted {
+			return m
+		}
+		return sub[1] + redacted
+	})
+	s = prefixedRe.ReplaceAllString(s, redacted)
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range longTokenRe.FindAllStringIndex(s, -1) {
+		tok := s[loc[0]:loc[1]]
+		if !looksLikeSecret(tok) || allowedBefore(s[:loc[0]]) {
+			continue
+		}
+		sb.WriteString(s[last:loc[0]])
+		sb.WriteString(redacted)
+		last = loc[1]
+	}
+	if last == 0 {
+		return s
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+func allowedBefore(prefix string) bool {
+	for _, p := range redactAllowPrefixes {
+		if strings.HasSuffix(prefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeSecret reports whether a long token mixes upper case, lower case,
+// and digits with high character entropy. Pure hex (IDs, hashes) never does.
+func looksLikeSecret(tok string) bool {
+	var upper, lower, digit bool
+	counts := make(map[rune]int)
+	for _, r := range tok {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+		counts[r]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	var entropy float64
+	n := float64(len(tok))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 4.0
+}
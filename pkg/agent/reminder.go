@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/reminder"
+)
+
+// BuildReminderTools returns set_reminder, list_reminders, and cancel_reminder.
+// Reminders are delivered by the bot, so they are only offered when it runs.
+func BuildReminderTools(store *reminder.Store) []Tool {
+	return []Tool{
+		{
+			Name:        "set_reminder",
+			Description: "Schedule a reminder message to this chat. Accepts natural times like 'in 20 minutes', 'tomorrow at 9am', 'friday 17:00', or '2026-01-02 15:04' (server local time).",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"when":    map[string]interface{}{"type": "string", "description": "When to send it, e.g. 'tomorrow at 9am'"},
+					"message": map[string]interface{}{"type": "string", "description": "Reminder text to send"},
+				},
+				"required": []string{"when", "message"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				chatID, ok := ChatIDFromContext(ctx)
+				if !ok {
+					return "", fmt.Errorf("no chat to remind")
+				}
+				when, _ := args["when"].(string)
+				message, _ := args["message"].(string)
+				now := store.Now()
+				fireAt, err := reminder.ParseTime(when, now)
+				if err != nil {
+					return "", err
+				}
+				if !fireAt.After(now) {
+					return "", fmt.Errorf("%s is in the past", fireAt.Format(time.RFC1123))
+				}
+				r, err := store.Add(ctx, chatID, fireAt, message)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Reminder %s set for %s (in %s).", r.ID, fireAt.Format("Mon Jan 2 15:04 MST"), fireAt.Sub(now).Round(time.Minute)), nil
+			},
+		},
+		{
+			Name:        "list_reminders",
+			Description: "List pending reminders for this chat.",
+			ReadOnly:    true,
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				chatID, _ := ChatIDFromContext(ctx)
+				pending := store.List(chatID)
+				if len(pending) == 0 {
+					return "No pending reminders.", nil
+				}
+				var lines []string
+				for _, r := range pending {
+					lines = append(lines, fmt.Sprintf("- %s at %s: %s", r.ID, r.FireAt.Format("Mon Jan 2 15:04"), r.Text))
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		},
+		{
+			Name:        "cancel_reminder",
+			Description: "Cancel a pending reminder by ID (see list_reminders).",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "string", "description": "Reminder ID"},
+				},
+				"required": []string{"id"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				chatID, _ := ChatIDFromContext(ctx)
+				id, _ := args["id"].(string)
+				owned := false
+				for _, r := range store.List(chatID) {
+					if r.ID == id {
+						owned = true
+						break
+					}
+				}
+				if !owned {
+					return "", fmt.Errorf("reminder %q not found in this chat", id)
+				}
+				if err := store.Remove(ctx, id); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Reminder %s cancelled.", id), nil
+			},
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/quota"
+)
+
+// checkStorage returns an error if writing size more bytes would put the
+// chat in ctx over its storage quota (Config.StorageQuota). The chat's
+// footprint is its agents/<id>/ objects plus userID's users/<id>/ files.
+func checkStorage(ctx context.Context, q *quota.Manager, userID string, size int64) error {
+	agentID, ok := agentctx.AgentIDFromContext(ctx)
+	if q == nil || !ok {
+		return nil
+	}
+	var extra []string
+	if userID != "" {
+		extra = append(extra, "users/"+userID+"/")
+	}
+	err := q.CheckUpload(ctx, agentID, size, extra...)
+	if errors.Is(err, quota.ErrExceeded) {
+		return fmt.Errorf("%w; delete some files (r2_usage lists the largest) to make room", err)
+	}
+	return err
+}
+
+// recordStorage adds size bytes to the chat's measured footprint, so writes
+// count against the quota before the next measurement.
+func recordStorage(ctx context.Context, q *quota.Manager, size int64) {
+	agentID, ok := agentctx.AgentIDFromContext(ctx)
+	if q == nil || !ok {
+		return
+	}
+	if err := q.Record(ctx, agentID, quota.Usage{StorageBytes: size}); err != nil {
+		log.Printf("quota: record %d bytes for %s: %v", size, agentID, err)
+	}
+}
+
+// chatUserID returns the chat in ctx as a user ID string ("" if unset); in
+// a private chat it is also the user's ID.
+func chatUserID(ctx context.Context) string {
+	chatID, ok := ChatIDFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatInt(chatID, 10)
+}
+
+// CheckUpload returns an error if userID storing size more bytes from chatID
+// would exceed the chat's storage quota. Call before uploading to R2.
+func (a *Agent) CheckUpload(ctx context.Context, chatID, userID, size int64) error {
+	ctx = agentctx.WithAgentID(ctx, agentctx.FormatAgentID(chatID))
+	return checkStorage(ctx, a.Quota, strconv.FormatInt(userID, 10), max(size, 0))
+}
+
+// RecordUpload counts an upload of size bytes from chatID against its quota.
+func (a *Agent) RecordUpload(ctx context.Context, chatID, size int64) {
+	recordStorage(agentctx.WithAgentID(ctx, agentctx.FormatAgentID(chatID)), a.Quota, size)
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/quota"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+func TestCheckUploadRefusesPastQuota(t *testing.T) {
+	ctx := context.Background()
+	r2 := storage.NewMemStore()
+	if err := r2.UploadObject(ctx, "b", "users/7/files/big.bin", make([]byte, 900)); err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{Quota: quota.NewManager(r2, "b", quota.Limits{MaxStorageBytes: 1000})}
+
+	if err := a.CheckUpload(ctx, 7, 7, 100); err != nil {
+		t.Fatalf("upload within quota refused: %v", err)
+	}
+	a.RecordUpload(ctx, 7, 100)
+	if err := a.CheckUpload(ctx, 7, 7, 1); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("upload past quota: got %v, want ErrExceeded", err)
+	}
+	if err := a.CheckUpload(ctx, 8, 8, 500); err != nil {
+		t.Fatalf("another user's upload refused: %v", err)
+	}
+}
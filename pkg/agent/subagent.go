@@ -2,60 +2,204 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/cognition"
 	"github.com/bigneek/picoflare/pkg/llm"
+	"github.com/bigneek/picoflare/pkg/storage"
 )
 
 // SubagentTask represents a spawn task (running or completed).
 type SubagentTask struct {
-	ID       string
-	Label    string
-	Task     string
-	ChatID   int64
-	Status   string // "running", "completed", "failed"
-	Created  int64
-	Finished int64
+	ID        string `json:"id"`
+	Label     string `json:"label,omitempty"`
+	Task      string `json:"task"`
+	ChatID    int64  `json:"chat_id"`
+	Status    string `json:"status"` // "running", "completed", "failed", "stopped"
+	Created   int64  `json:"created"`
+	Finished  int64  `json:"finished,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Delivered bool   `json:"delivered"` // result was sent to the chat
 }
 
-// SubagentTracker records spawn tasks for status queries.
+const (
+	subagentKeyPrefix = "memory/subagents/"
+	// subagentRetention is how long delivered tasks are kept for /status.
+	subagentRetention = 7 * 24 * time.Hour
+)
+
+// SubagentTracker records spawn tasks for status queries. With R2, each task
+// is also stored under memory/subagents/ so results survive a restart.
 type SubagentTracker struct {
-	mu     sync.RWMutex
-	tasks  map[string]*SubagentTask
-	nextID int
+	r2     storage.ObjectStore
+	bucket string
+
+	mu      sync.RWMutex
+	tasks   map[string]*SubagentTask
+	nextID  int
+	cancels map[string]context.CancelCauseFunc // running task ID -> cancel
 }
 
-// NewSubagentTracker creates a new tracker.
-func NewSubagentTracker() *SubagentTracker {
-	return &SubagentTracker{tasks: make(map[string]*SubagentTask), nextID: 1}
+// NewSubagentTracker creates a new tracker. r2 may be nil (in-memory only).
+func NewSubagentTracker(r2 storage.ObjectStore, bucket string) *SubagentTracker {
+	return &SubagentTracker{
+		r2: r2, bucket: bucket, nextID: 1,
+		tasks: make(map[string]*SubagentTask), cancels: make(map[string]context.CancelCauseFunc),
+	}
+}
+
+func subagentKey(id string) string {
+	return subagentKeyPrefix + id + ".json"
+}
+
+// Load restores tasks from R2. Tasks still "running" were cut off by the
+// restart, so they are marked failed and left undelivered to tell the user.
+// Delivered tasks older than subagentRetention are deleted.
+func (t *SubagentTracker) Load(ctx context.Context) error {
+	if t.r2 == nil {
+		return nil
+	}
+	keys, err := t.r2.ListObjects(ctx, t.bucket, subagentKeyPrefix, 1000)
+	if err != nil {
+		return fmt.Errorf("list subagent tasks: %w", err)
+	}
+	cutoff := time.Now().Add(-subagentRetention).UnixMilli()
+	for _, k := range keys {
+		data, err := t.r2.DownloadObject(ctx, t.bucket, k)
+		if err != nil {
+			log.Printf("subagent: read %s: %v", k, err)
+			continue
+		}
+		var task SubagentTask
+		if err := json.Unmarshal(data, &task); err != nil || task.ID == "" {
+			log.Printf("subagent: skipping corrupt %s", k)
+			continue
+		}
+		if task.Delivered && task.Finished < cutoff {
+			_ = t.r2.DeleteObject(ctx, t.bucket, k)
+			continue
+		}
+		if task.Status == "running" {
+			task.Status = "failed"
+			task.Finished = time.Now().UnixMilli()
+			task.Result = "Error: interrupted by a restart before it finished."
+			t.save(ctx, &task)
+		}
+
+		t.mu.Lock()
+		t.tasks[task.ID] = &task
+		var n int
+		if _, err := fmt.Sscanf(task.ID, "subagent-%d", &n); err == nil && n >= t.nextID {
+			t.nextID = n + 1
+		}
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// save persists a task snapshot. Errors are logged; tracking stays in memory.
+func (t *SubagentTracker) save(ctx context.Context, task *SubagentTask) {
+	if t.r2 == nil {
+		return
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return
+	}
+	if err := t.r2.UploadObject(ctx, t.bucket, subagentKey(task.ID), data); err != nil {
+		log.Printf("subagent: save %s: %v", task.ID, err)
+	}
 }
 
 // RecordStart registers a new spawn task. Returns the task ID.
 func (t *SubagentTracker) RecordStart(label, task string, chatID int64) string {
 	t.mu.Lock()
-	defer t.mu.Unlock()
 	id := fmt.Sprintf("subagent-%d", t.nextID)
 	t.nextID++
-	t.tasks[id] = &SubagentTask{
+	st := &SubagentTask{
 		ID: id, Label: label, Task: truncateTask(task, 60), ChatID: chatID,
 		Status: "running", Created: time.Now().UnixMilli(),
 	}
+	t.tasks[id] = st
+	snap := *st
+	t.mu.Unlock()
+	t.save(context.Background(), &snap)
 	return id
 }
 
-// RecordComplete updates a task's status when done.
-func (t *SubagentTracker) RecordComplete(taskID, status string) {
+// setCancel registers the cancel func of a running task for stopChat.
+func (t *SubagentTracker) setCancel(taskID string, cancel context.CancelCauseFunc) {
+	t.mu.Lock()
+	t.cancels[taskID] = cancel
+	t.mu.Unlock()
+}
+
+// stopChat cancels chatID's running tasks with cause and returns how many
+// there were.
+func (t *SubagentTracker) stopChat(chatID int64, cause error) int {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if task, ok := t.tasks[taskID]; ok {
+	n := 0
+	for id, cancel := range t.cancels {
+		if task := t.tasks[id]; task != nil && task.ChatID == chatID {
+			cancel(cause)
+			n++
+		}
+	}
+	return n
+}
+
+// RecordComplete updates a task's status and result when done.
+func (t *SubagentTracker) RecordComplete(taskID, status, result string) {
+	t.mu.Lock()
+	delete(t.cancels, taskID)
+	t.mu.Unlock()
+	t.update(taskID, func(task *SubagentTask) {
 		task.Status = status
+		task.Result = result
 		task.Finished = time.Now().UnixMilli()
+	})
+}
+
+// MarkDelivered records that a task's result reached its chat.
+func (t *SubagentTracker) MarkDelivered(taskID string) {
+	t.update(taskID, func(task *SubagentTask) { task.Delivered = true })
+}
+
+func (t *SubagentTracker) update(taskID string, fn func(*SubagentTask)) {
+	t.mu.Lock()
+	task, ok := t.tasks[taskID]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	fn(task)
+	snap := *task
+	t.mu.Unlock()
+	t.save(context.Background(), &snap)
+}
+
+// Undelivered returns finished tasks whose results were never sent, oldest first.
+func (t *SubagentTracker) Undelivered() []SubagentTask {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var out []SubagentTask
+	for _, task := range t.tasks {
+		if task.Status != "running" && !task.Delivered {
+			out = append(out, *task)
+		}
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created < out[j].Created })
+	return out
 }
 
 // ListTasks returns all tasks, optionally filtered by chatID (0 = all).
@@ -71,6 +215,14 @@ func (t *SubagentTracker) ListTasks(chatID int64) []SubagentTask {
 	return out
 }
 
+// subagentReport formats a finished spawn task for its chat.
+func subagentReport(label, result string) string {
+	if label != "" {
+		result = fmt.Sprintf("**%s**\n\n%s", label, result)
+	}
+	return "📋 Subagent completed:\n\n" + result
+}
+
 func truncateTask(s string, max int) string {
 	if len(s) <= max {
 		return s
@@ -101,6 +253,8 @@ func ChatIDFromContext(ctx context.Context) (int64, bool) {
 const subagentMaxIterations = 20 // Matches main agent—spawned coding tasks need room to finish
 
 // resolveSubWorkspace validates that subPath is within mainWorkspace and returns the absolute path.
+// subPath is always taken relative to mainWorkspace; symlinks are followed
+// (see resolvePath), so a link can't point a subagent outside it.
 func resolveSubWorkspace(mainWorkspace, subPath string) (string, error) {
 	if mainWorkspace == "" {
 		return "", fmt.Errorf("main workspace not configured")
@@ -109,14 +263,50 @@ func resolveSubWorkspace(mainWorkspace, subPath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("resolve main workspace: %w", err)
 	}
-	subAbs := filepath.Clean(filepath.Join(mainAbs, subPath))
-	rel, err := filepath.Rel(mainAbs, subAbs)
-	if err != nil || strings.HasPrefix(rel, "..") {
+	subAbs, err := resolvePath(filepath.Join(mainAbs, subPath), mainAbs)
+	if err != nil {
 		return "", fmt.Errorf("workspace %q is outside main workspace", subPath)
 	}
 	return subAbs, nil
 }
 
+// usageRecorder attributes LLM and tool calls to the chat in ctx (see
+// agentctx.WithAgentID). A nil ledger records nothing.
+type usageRecorder struct {
+	ledger *cognition.TokenLedger
+}
+
+func (u usageRecorder) llmCall(ctx context.Context, model string, result *llm.ChatResult) {
+	if u.ledger == nil {
+		return
+	}
+	agentID, _ := agentctx.AgentIDFromContext(ctx)
+	if result.Model != "" {
+		model = result.Model // a fallback answered
+	}
+	u.ledger.RecordLLMCall(agentID, model, result.PromptTokens, result.CompletionTokens)
+}
+
+func (u usageRecorder) toolCall(ctx context.Context, name string, elapsed time.Duration) {
+	if u.ledger == nil {
+		return
+	}
+	agentID, _ := agentctx.AgentIDFromContext(ctx)
+	u.ledger.RecordToolCall(agentID, name, elapsed)
+}
+
+// save persists the ledger after usage recorded outside ProcessMessage,
+// which saves it at the end of each message.
+func (u usageRecorder) save(ctx context.Context) {
+	if u.ledger == nil {
+		return
+	}
+	u.ledger.SaveLifetime(ctx)
+	if agentID, ok := agentctx.AgentIDFromContext(ctx); ok {
+		u.ledger.SaveChat(ctx, agentID)
+	}
+}
+
 const subagentSyncTimeoutDefault = 3 * time.Minute // Default timeout for sync subagent calls
 const subagentSyncTimeoutMax = 10 * time.Minute    // Maximum allowed timeout
 
@@ -124,7 +314,8 @@ const subagentSyncTimeoutMax = 10 * time.Minute    // Maximum allowed timeout
 // but excludes spawn/subagent to avoid recursion. If workspace is non-empty, the subagent runs in
 // that sub-folder (relative to mainWorkspace) with workspace-scoped tools for that path.
 // timeout of 0 uses the default; otherwise capped at subagentSyncTimeoutMax.
-func RunSubagentLoop(parentCtx context.Context, llmClient *llm.Client, tools []Tool, task, mainWorkspace, workspace string, timeout time.Duration) (string, error) {
+// LLM and tool calls are recorded in ledger (if non-nil) for the chat in parentCtx.
+func RunSubagentLoop(parentCtx context.Context, llmClient *llm.Client, tools []Tool, ledger *cognition.TokenLedger, task, mainWorkspace, workspace string, timeout time.Duration) (string, error) {
 	// Apply timeout for sync subagents to prevent indefinite hangs
 	if timeout <= 0 {
 		timeout = subagentSyncTimeoutDefault
@@ -171,6 +362,7 @@ Provide a clear, concise summary of what you did. Be direct and efficient.`
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: task},
 	}
+	usage := usageRecorder{ledger}
 
 	for i := 0; i < subagentMaxIterations; i++ {
 		select {
@@ -183,6 +375,7 @@ Provide a clear, concise summary of what you did. Be direct and efficient.`
 		if err != nil {
 			return "", fmt.Errorf("subagent LLM error: %w", err)
 		}
+		usage.llmCall(ctx, llmClient.Model, result)
 
 		if len(result.ToolCalls) == 0 {
 			return strings.TrimSpace(result.Content), nil
@@ -196,7 +389,9 @@ Provide a clear, concise summary of what you did. Be direct and efficient.`
 		messages = append(messages, assistantMsg)
 
 		for _, tc := range result.ToolCalls {
+			start := time.Now()
 			toolResult, err := ExecuteTool(ctx, subTools, tc.Function.Name, tc.Function.Arguments)
+			usage.toolCall(ctx, tc.Function.Name, time.Since(start))
 			if err != nil {
 				toolResult = fmt.Sprintf("Error: %v", err)
 				log.Printf("  [subagent tool error] %s: %v", tc.Function.Name, err)
@@ -220,7 +415,9 @@ Provide a clear, concise summary of what you did. Be direct and efficient.`
 // BuildSubagentTools creates the subagent and spawn tools.
 // onComplete is called when a spawn task completes (async). Pass nil to disable spawn.
 // tracker records spawn tasks for /status. Pass nil to disable tracking.
-func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace string, tracker *SubagentTracker, onComplete func(chatID int64, result string)) []Tool {
+// ledger, if non-nil, is charged for the subagents' LLM and tool calls.
+// sampling overrides the LLM's sampling parameters in subagent runs.
+func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace string, tracker *SubagentTracker, ledger *cognition.TokenLedger, sampling llm.Sampling, onComplete func(chatID int64, result string)) []Tool {
 	var result []Tool
 
 	// subagent: synchronous — runs task in same goroutine, returns result
@@ -249,6 +446,7 @@ func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace strin
 			},
 			"required": []string{"task"},
 		},
+		Timeout: subagentSyncTimeoutMax, // RunSubagentLoop applies the requested timeout
 		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 			task, ok := args["task"].(string)
 			if !ok || task == "" {
@@ -263,7 +461,7 @@ func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace strin
 				timeout = time.Duration(t) * time.Second
 			}
 
-			res, err := RunSubagentLoop(ctx, llmClient, tools, task, mainWorkspace, workspace, timeout)
+			res, err := RunSubagentLoop(llm.WithSampling(ctx, sampling), llmClient, tools, ledger, task, mainWorkspace, workspace, timeout)
 			if err != nil {
 				return "", err
 			}
@@ -322,6 +520,7 @@ func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace strin
 				if !ok {
 					return "", fmt.Errorf("spawn requires chat context (use subagent for sync delegation)")
 				}
+				agentID, _ := agentctx.AgentIDFromContext(ctx)
 
 				// Track for /status
 				var taskID string
@@ -337,23 +536,36 @@ func BuildSubagentTools(llmClient *llm.Client, tools []Tool, mainWorkspace strin
 				workspaceCopy := workspace
 				timeoutCopy := timeout
 
+				// Detached from this turn, but /stop cancels it (Agent.Stop)
+				bgCtx, stop := context.WithCancelCause(context.Background())
+				if tracker != nil && taskID != "" {
+					tracker.setCancel(taskID, stop)
+				}
+
 				go func() {
-					bgCtx, cancel := context.WithTimeout(context.Background(), timeoutCopy)
+					defer stop(nil)
+					bgCtx, cancel := context.WithTimeout(bgCtx, timeoutCopy)
 					defer cancel()
+					// Keep the chat so tools and the ledger attribute the work to it
+					bgCtx = agentctx.WithAgentID(WithChatID(bgCtx, cid), agentID)
+					bgCtx = llm.WithSampling(bgCtx, sampling)
 
-					res, err := RunSubagentLoop(bgCtx, llmClient, tools, taskCopy, mainWorkspace, workspaceCopy, 0) // 0 = use default for nested calls
+					res, err := RunSubagentLoop(bgCtx, llmClient, tools, ledger, taskCopy, mainWorkspace, workspaceCopy, 0) // 0 = use default for nested calls
+					usageRecorder{ledger}.save(context.WithoutCancel(bgCtx))
 					status := "completed"
-					if err != nil {
+					if errors.Is(context.Cause(bgCtx), errStopped) {
+						res, status = stoppedReply, "stopped"
+					} else if err != nil {
 						res = fmt.Sprintf("Error: %v", err)
 						status = "failed"
 					}
 					if tracker != nil && taskID != "" {
-						tracker.RecordComplete(taskID, status)
+						tracker.RecordComplete(taskID, status, res)
 					}
-					if labelCopy != "" {
-						res = fmt.Sprintf("**%s**\n\n%s", labelCopy, res)
+					cb(cid, subagentReport(labelCopy, res))
+					if tracker != nil && taskID != "" {
+						tracker.MarkDelivered(taskID)
 					}
-					cb(cid, "📋 Subagent completed:\n\n"+res)
 				}()
 
 				if label != "" {
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/cognition"
+	"github.com/bigneek/picoflare/pkg/llm"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// usageServer returns a client for a chat completions server that answers
+// every request with the given usage.
+func usageServer(t *testing.T, promptTokens, completionTokens int) *llm.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"checked"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":%d,"completion_tokens":%d,"total_tokens":%d}}`,
+			promptTokens, completionTokens, promptTokens+completionTokens)
+	}))
+	t.Cleanup(srv.Close)
+	client := llm.NewClient("key", "test-model")
+	client.SetBaseURL(srv.URL)
+	return client
+}
+
+func TestUsageRecordedFromResponse(t *testing.T) {
+	client := usageServer(t, 1000, 250)
+	ledger := cognition.NewTokenLedger(storage.NewMemStore(), "b")
+	ctx := context.Background()
+	ledger.LoadLifetime(ctx)
+	usage := usageRecorder{ledger}
+	ctx = agentctx.WithAgentID(ctx, agentctx.FormatAgentID(42))
+
+	for i := 0; i < 2; i++ {
+		result, err := client.Chat(ctx, []llm.Message{{Role: "user", Content: "hi"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		usage.llmCall(ctx, client.Model, result)
+	}
+
+	if in, out, _ := ledger.Totals(); in != 2000 || out != 500 {
+		t.Fatalf("session tokens = %d in / %d out, want 2000 / 500", in, out)
+	}
+	if got := ledger.Lifetime.PromptTokens + ledger.Lifetime.CompletionTokens; got != 2500 {
+		t.Fatalf("lifetime tokens = %d, want 2500", got)
+	}
+	if cs := ledger.ChatStats(agentctx.FormatAgentID(42)); cs == nil || cs.CostUSD <= 0 {
+		t.Fatalf("chat stats = %+v, want the calls' cost", cs)
+	}
+}
+
+func TestSpawnedTaskTokensReachLifetime(t *testing.T) {
+	client := usageServer(t, 120, 30)
+	ctx := context.Background()
+	ledger := cognition.NewTokenLedger(storage.NewMemStore(), "b")
+	ledger.LoadLifetime(ctx)
+
+	done := make(chan string, 1)
+	tools := BuildSubagentTools(client, nil, "", nil, ledger, llm.Sampling{}, func(chatID int64, result string) { done <- result })
+	chatCtx := agentctx.WithAgentID(WithChatID(ctx, 42), agentctx.FormatAgentID(42))
+	if _, err := ExecuteTool(chatCtx, tools, "spawn", `{"task":"check the site"}`); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("spawned task never reported")
+	}
+
+	if in, out := ledger.Lifetime.PromptTokens, ledger.Lifetime.CompletionTokens; in != 120 || out != 30 {
+		t.Fatalf("lifetime tokens = %d in / %d out, want 120 / 30", in, out)
+	}
+	if cs := ledger.ChatStats(agentctx.FormatAgentID(42)); cs == nil || cs.PromptTokens != 120 {
+		t.Fatalf("chat stats = %+v, want the task's 120 prompt tokens", cs)
+	}
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/llm"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+const (
+	summaryEvery         = 8 // user turns between summary updates
+	summaryTimeout       = time.Minute
+	trimSummaryTimeout   = 30 * time.Second
+	summaryMaxChars      = 2000  // kept summary; longer model output is cut
+	summaryTranscriptMax = 12000 // newest transcript bytes sent per update
+	summaryMessageMax    = 1500  // bytes kept from each message in the transcript
+)
+
+const summaryInstructions = `You maintain a running summary of a long-lived chat between a user and PicoFlare, an AI agent that manages their Cloudflare account.
+Rewrite the current summary so it also covers the new messages. Keep what gives continuity: who the user is, their projects and goals, decisions made, resources built, open questions and promises. Drop small talk and raw tool output.
+Write at most 200 words of plain bullet points. Reply with the summary only.`
+
+// ConversationSummary is a chat's rolling summary, persisted to
+// agents/{id}/summary.json so continuity survives session trimming and
+// restarts. Unlike memory facts, it describes the conversation itself.
+type ConversationSummary struct {
+	Text      string    `json:"text"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Turns     int       `json:"turns"` // user turns covered
+}
+
+func summaryKey(agentID string) string {
+	return fmt.Sprintf("agents/%s/summary.json", agentID)
+}
+
+// Summary returns the chat's conversation summary, or nil if none exists yet.
+func (a *Agent) Summary(ctx context.Context, chatID int64) *ConversationSummary {
+	a.summaryMu.Lock()
+	s, ok := a.summaries[chatID]
+	a.summaryMu.Unlock()
+	if ok {
+		return s
+	}
+	s = loadSummary(ctx, a.R2, a.Bucket, chatID)
+	a.summaryMu.Lock()
+	if cur, ok := a.summaries[chatID]; ok {
+		s = cur // updated while loading
+	} else {
+		a.summaries[chatID] = s
+	}
+	a.summaryMu.Unlock()
+	return s
+}
+
+func loadSummary(ctx context.Context, r2 storage.ObjectStore, bucket string, chatID int64) *ConversationSummary {
+	if r2 == nil {
+		return nil
+	}
+	data, err := r2.DownloadObject(ctx, bucket, summaryKey(agentctx.FormatAgentID(chatID)))
+	if err != nil {
+		return nil
+	}
+	var s ConversationSummary
+	if err := json.Unmarshal(data, &s); err != nil || s.Text == "" {
+		return nil
+	}
+	return &s
+}
+
+// pendingSummary counts a finished turn and takes the messages not yet
+// summarized, with the number of turns they span, every summaryEvery turns
+// or as soon as trimSession has dropped some of them, so nothing falls out
+// of both the history and the summary for long. It returns nil when no
+// update is due. The caller holds a.mu.
+func (sess *session) pendingSummary() ([]llm.Message, int) {
+	sess.turnsSinceSummary++
+	if sess.turnsSinceSummary < summaryEvery && len(sess.unsummarized) == 0 {
+		return nil, 0
+	}
+	turns := sess.turnsSinceSummary
+	msgs := sess.unsummarizedMessages()
+	sess.unsummarized = nil
+	sess.summarizedUpTo = len(sess.Messages)
+	sess.turnsSinceSummary = 0
+	return msgs, turns
+}
+
+// unsummarizedMessages returns the messages the summary doesn't cover yet,
+// oldest first. The caller holds a.mu.
+func (sess *session) unsummarizedMessages() []llm.Message {
+	start := max(sess.summarizedUpTo, 1)
+	msgs := append([]llm.Message(nil), sess.unsummarized...)
+	return append(msgs, sess.Messages[start:]...)
+}
+
+// skipSummary marks everything so far as covered without summarizing it,
+// so private turns never reach a later summary. The caller holds a.mu.
+func (sess *session) skipSummary() {
+	sess.unsummarized = nil
+	sess.summarizedUpTo = len(sess.Messages)
+}
+
+// updateSummary folds msgs into the chat's summary and saves it. Runs in the
+// background after a turn; failures only cost this round's update.
+func (a *Agent) updateSummary(chatID int64, msgs []llm.Message, turns int) {
+	a.updatingSummary.Lock()
+	defer a.updatingSummary.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	transcript := summaryTranscript(msgs)
+	if transcript == "" {
+		return
+	}
+	prev := a.Summary(ctx, chatID)
+	current := "(none yet)"
+	if prev != nil {
+		current = prev.Text
+		turns += prev.Turns
+	}
+	result, err := a.LLM.ChatWithModel(ctx, a.summaryModel, []llm.Message{
+		{Role: "system", Content: summaryInstructions},
+		{Role: "user", Content: "Current summary:\n" + current + "\n\nNew messages:\n" + transcript},
+	}, nil, nil)
+	if err != nil {
+		log.Printf("Summary: update for chat %d failed: %v", chatID, err)
+		return
+	}
+	text := strings.TrimSpace(result.Content)
+	if text == "" {
+		return
+	}
+	s := &ConversationSummary{Text: truncate(text, summaryMaxChars), UpdatedAt: a.clock.Now(), Turns: turns}
+
+	a.summaryMu.Lock()
+	a.summaries[chatID] = s
+	a.summaryMu.Unlock()
+	a.mu.Lock()
+	if sess, ok := a.sessions[chatID]; ok {
+		sess.summaryChanged = true
+	}
+	a.mu.Unlock()
+	if a.R2 != nil {
+		data, _ := json.MarshalIndent(s, "", "  ")
+		if err := a.R2.UploadObject(ctx, a.Bucket, summaryKey(agentctx.FormatAgentID(chatID)), data); err != nil {
+			log.Printf("Summary: save for chat %d failed: %v", chatID, err)
+		}
+	}
+}
+
+// summaryTranscript renders the user and assistant text of msgs, newest
+// last, keeping the tail if it runs over summaryTranscriptMax.
+func summaryTranscript(msgs []llm.Message) string {
+	var lines []string
+	for _, m := range msgs {
+		content := strings.TrimSpace(m.Content)
+		if content == "" {
+			continue
+		}
+		switch m.Role {
+		case "user":
+			lines = append(lines, "User: "+truncate(content, summaryMessageMax))
+		case "assistant":
+			lines = append(lines, "Assistant: "+truncate(content, summaryMessageMax))
+		}
+	}
+	transcript := strings.Join(lines, "\n")
+	if len(transcript) > summaryTranscriptMax {
+		transcript = truncateSmart(transcript, summaryTranscriptMax, truncTail)
+	}
+	return transcript
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+func TestSummaryUpdateRefreshesSystemPrompt(t *testing.T) {
+	p := &scriptedLLM{replies: []llm.ChatResult{
+		{Content: "hello", FinishReason: "stop"},
+		{Content: "- The user is building a Go worker", FinishReason: "stop"}, // the summary
+		{Content: "sure", FinishReason: "stop"},
+	}}
+	a := newTestAgent(t, p)
+
+	a.ProcessMessage(context.Background(), 1, "hi")
+	a.updateSummary(1, []llm.Message{{Role: "user", Content: "I'm building a Go worker"}}, 1)
+	a.ProcessMessage(context.Background(), 1, "next")
+
+	system := p.call(2)[0]
+	if !strings.Contains(system.Content, "## Conversation So Far") || !strings.Contains(system.Content, "building a Go worker") {
+		t.Fatalf("system prompt after the summary update lacks it:\n%s", system.Content)
+	}
+}
+
+func TestTrimSummarizesDroppedMessages(t *testing.T) {
+	p := &scriptedLLM{replies: []llm.ChatResult{
+		{Content: "First summary.", FinishReason: "stop"},
+		{Content: "Second summary.", FinishReason: "stop"},
+	}}
+	a := newTestAgent(t, p)
+	a.summaryModel = "cheap-model"
+	sess := &session{Messages: []llm.Message{{Role: "system", Content: "prompt"}}}
+	add := func(n int) {
+		for i := 0; i < n; i++ {
+			sess.Messages = append(sess.Messages, llm.Message{Role: "user", Content: fmt.Sprintf("message %d", len(sess.Messages))})
+		}
+	}
+	ctx := context.Background()
+
+	add(maxSessionMessages + 1)
+	a.trimSession(ctx, 1, sess)
+	if len(p.calls) != 1 || p.models[0] != "cheap-model" {
+		t.Fatalf("summary calls %d with models %q, want one with the summary model", len(p.calls), p.models)
+	}
+	if req := p.call(0)[1].Content; !strings.Contains(req, "message 1\n") || strings.Contains(req, "message 12\n") {
+		t.Fatalf("summary request doesn't cover exactly the dropped messages:\n%s", req)
+	}
+	if len(sess.Messages) != trimKeep+2 || sess.TrimSummary != "First summary." {
+		t.Fatalf("after the first trim: %d messages, summary %q", len(sess.Messages), sess.TrimSummary)
+	}
+	if m := sess.Messages[1]; m.Role != "system" || !strings.HasSuffix(m.Content, "First summary.") {
+		t.Fatalf("Messages[1] = %s %q, want the summary", m.Role, m.Content)
+	}
+	if last := sess.Messages[len(sess.Messages)-1].Content; last != "message 51" {
+		t.Fatalf("newest message = %q, want message 51 kept", last)
+	}
+
+	add(maxSessionMessages + 1 - len(sess.Messages) + 1)
+	a.trimSession(ctx, 1, sess)
+	if req := p.call(1)[1].Content; !strings.Contains(req, "Previous summary:\nFirst summary.") {
+		t.Fatalf("second summary request lacks the first summary:\n%s", req)
+	}
+	if len(sess.Messages) != trimKeep+2 || sess.Messages[0].Content != "prompt" {
+		t.Fatalf("after the second trim: %d messages starting with %q", len(sess.Messages), sess.Messages[0].Content)
+	}
+	summaries := 0
+	for _, m := range sess.Messages {
+		if m.Role == "system" && strings.HasPrefix(m.Content, trimSummaryPrefix) {
+			summaries++
+		}
+	}
+	if summaries != 1 || !strings.HasSuffix(sess.Messages[1].Content, "Second summary.") {
+		t.Fatalf("%d summary messages, Messages[1] = %q; want the summary replaced in place", summaries, sess.Messages[1].Content)
+	}
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// toolCacheTTL is how long a Cacheable tool's result is reused. Short, so a
+// change made outside the agent shows up within one exchange or two.
+const toolCacheTTL = 30 * time.Second
+
+// toolCache holds recent results of Cacheable tools per chat, keyed by
+// toolCallKey. Any other tool call clears it for every chat: most of them
+// can change what the read-only tools report (deploys, deletes, raw API
+// calls), and the account is shared between chats.
+type toolCache struct {
+	mu      sync.Mutex
+	entries map[int64]map[string]toolCacheEntry
+}
+
+type toolCacheEntry struct {
+	result string
+	at     time.Time
+}
+
+func newToolCache() *toolCache {
+	return &toolCache{entries: make(map[int64]map[string]toolCacheEntry)}
+}
+
+// get returns a fresh cached result for key in chatID.
+func (c *toolCache) get(chatID int64, key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[chatID][key]
+	if !ok || time.Since(e.at) >= toolCacheTTL {
+		return "", false
+	}
+	return e.result, true
+}
+
+// put stores a successful result, dropping the chat's expired entries.
+func (c *toolCache) put(chatID int64, key, result string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chat := c.entries[chatID]
+	if chat == nil {
+		chat = make(map[string]toolCacheEntry)
+		c.entries[chatID] = chat
+	}
+	for k, e := range chat {
+		if time.Since(e.at) >= toolCacheTTL {
+			delete(chat, k)
+		}
+	}
+	chat[key] = toolCacheEntry{result: result, at: time.Now()}
+}
+
+// clear empties the cache after a call that may have changed state.
+func (c *toolCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) > 0 {
+		c.entries = make(map[int64]map[string]toolCacheEntry)
+	}
+}
+
+// cacheable reports whether the named tool is marked Cacheable.
+func (a *Agent) cacheable(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.Tools {
+		if t.Name == name {
+			return t.Cacheable
+		}
+	}
+	return false
+}
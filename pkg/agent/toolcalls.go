@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+// maxParallelTools bounds how many tool calls from one model response run at
+// once.
+const maxParallelTools = 4
+
+// toolOutcome is what became of one tool call in a model response.
+type toolOutcome struct {
+	dupOf   int  // index of an identical earlier call whose result is reused; -1 if none
+	ran     bool // executed (not cached, refused, or a duplicate)
+	content string
+	err     error
+}
+
+// runToolCalls executes calls[i] for each i in jobs, storing each result in
+// outcomes[i]. Consecutive ReadOnly calls run together, up to
+// maxParallelTools at a time; any other call may change what later calls see,
+// so it waits for those before it and runs alone, in call order. The caller
+// appends results in call order.
+func (a *Agent) runToolCalls(ctx context.Context, calls []llm.ToolCall, jobs []int, outcomes []toolOutcome, usage usageRecorder) {
+	a.mu.Lock()
+	tools := a.Tools
+	a.mu.Unlock()
+	readOnly := make(map[string]bool)
+	for _, t := range tools {
+		readOnly[t.Name] = t.ReadOnly
+	}
+
+	run := func(i int) {
+		tc := calls[i]
+		start := time.Now()
+		outcomes[i].content, outcomes[i].err = ExecuteTool(ctx, tools, tc.Function.Name, tc.Function.Arguments)
+		outcomes[i].ran = true
+		usage.toolCall(ctx, tc.Function.Name, time.Since(start))
+	}
+
+	var batch []int // read-only calls waiting to run together
+	flush := func() {
+		sem := make(chan struct{}, maxParallelTools)
+		var wg sync.WaitGroup
+		for _, i := range batch {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				run(i)
+			}()
+		}
+		wg.Wait()
+		batch = nil
+	}
+	for _, i := range jobs {
+		if readOnly[calls[i].Function.Name] {
+			batch = append(batch, i)
+			continue
+		}
+		flush()
+		run(i)
+	}
+	flush()
+}
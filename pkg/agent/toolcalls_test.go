@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+// slowTool sleeps for d, then returns its name. It logs when it starts and
+// ends to events.
+func slowTool(name string, d time.Duration, readOnly bool, mu *sync.Mutex, events *[]string) Tool {
+	return Tool{
+		Name:       name,
+		Parameters: map[string]interface{}{"type": "object"},
+		ReadOnly:   readOnly,
+		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			mu.Lock()
+			*events = append(*events, "start "+name)
+			mu.Unlock()
+			time.Sleep(d)
+			mu.Lock()
+			*events = append(*events, "end "+name)
+			mu.Unlock()
+			return name, nil
+		},
+	}
+}
+
+// callTools is a model reply that calls each named tool once.
+func callTools(names ...string) llm.ChatResult {
+	r := llm.ChatResult{FinishReason: "tool_calls"}
+	for _, name := range names {
+		r.ToolCalls = append(r.ToolCalls, llm.ToolCall{ID: "call-" + name, Type: "function", Function: llm.FunctionCall{Name: name, Arguments: "{}"}})
+	}
+	return r
+}
+
+// toolResults returns the tool messages sent in msgs, in order.
+func toolResults(msgs []llm.Message) []string {
+	var out []string
+	for _, m := range msgs {
+		if m.Role == "tool" {
+			out = append(out, m.Content)
+		}
+	}
+	return out
+}
+
+func TestReadOnlyToolCallsRunInParallel(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	p := &scriptedLLM{replies: []llm.ChatResult{callTools("slow_read", "fast_read")}}
+	a := newTestAgent(t, p,
+		slowTool("slow_read", 400*time.Millisecond, true, &mu, &events),
+		slowTool("fast_read", 300*time.Millisecond, true, &mu, &events))
+
+	start := time.Now()
+	a.ProcessMessage(context.Background(), 1, "look both up")
+	// Run one after the other, they would take 700ms
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Fatalf("two read-only calls took %v, want about the slower one's 400ms", elapsed)
+	}
+	if got := toolResults(p.call(1)); len(got) != 2 || got[0] != "slow_read" || got[1] != "fast_read" {
+		t.Fatalf("tool results %q, want them in call order", got)
+	}
+}
+
+func TestMutatingToolCallsRunInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	p := &scriptedLLM{replies: []llm.ChatResult{callTools("write_a", "read", "write_b")}}
+	a := newTestAgent(t, p,
+		slowTool("write_a", 150*time.Millisecond, false, &mu, &events),
+		slowTool("read", 50*time.Millisecond, true, &mu, &events),
+		slowTool("write_b", 50*time.Millisecond, false, &mu, &events))
+
+	a.ProcessMessage(context.Background(), 1, "write, check, write")
+	want := []string{"start write_a", "end write_a", "start read", "end read", "start write_b", "end write_b"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != len(want) {
+		t.Fatalf("events %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events %q, want %q", events, want)
+		}
+	}
+	if got := toolResults(p.call(1)); len(got) != 3 || got[0] != "write_a" || got[2] != "write_b" {
+		t.Fatalf("tool results %q, want them in call order", got)
+	}
+}
+
+func TestCacheableToolsAreReadOnly(t *testing.T) {
+	_, tools := userTools(t)
+	for _, tool := range tools {
+		if tool.Cacheable && !tool.ReadOnly {
+			t.Errorf("%s is Cacheable but not ReadOnly", tool.Name)
+		}
+	}
+}
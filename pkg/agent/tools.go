@@ -3,17 +3,23 @@ package agent
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	cf "github.com/bigneek/picoflare/pkg/cloudflare"
 	"github.com/bigneek/picoflare/pkg/cognition"
 	"github.com/bigneek/picoflare/pkg/llm"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/quota"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
@@ -23,12 +29,29 @@ type Tool struct {
 	Description string
 	Parameters  map[string]interface{}
 	Execute     func(ctx context.Context, args map[string]interface{}) (string, error)
+
+	// Timeout bounds one call; zero means defaultToolTimeout.
+	Timeout time.Duration
+
+	// ReadOnly marks a tool that changes nothing, so calls to it can run
+	// in parallel (see runToolCalls).
+	ReadOnly bool
+
+	// Cacheable marks a ReadOnly tool whose result can be reused for a
+	// short while within a chat (see toolCache).
+	Cacheable bool
 }
 
+// defaultToolTimeout keeps one slow tool from using up the whole agentTimeout.
+const defaultToolTimeout = 90 * time.Second
+
+// shareLinkTTL is how long a share_link download link works by default.
+const shareLinkTTL = time.Hour
+
 // BuildTools creates the full PicoFlare tool set.
 func BuildTools(
 	mcp *mcpclient.Client,
-	r2 *storage.R2Client,
+	r2 storage.ObjectStore,
 	cfClient *cf.Client,
 	mem *cognition.Memory,
 	meta *cognition.MetaCognition,
@@ -36,7 +59,8 @@ func BuildTools(
 	ledger *cognition.TokenLedger,
 	cloud *cognition.CloudEnv,
 	registry *cognition.ToolRegistry,
-	bucket, accountID string,
+	quotas *quota.Manager,
+	bucket, accountID, vectorizeIndex string,
 ) []Tool {
 	var tools []Tool
 
@@ -46,6 +70,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_search",
 			Description: "Search the Cloudflare API spec for endpoints. Use plain English like 'list workers', 'DNS records', 'R2 buckets'. Returns matching API paths.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -143,6 +168,7 @@ func BuildTools(
 	tools = append(tools, Tool{
 		Name:        "http_request",
 		Description: "Make HTTP requests from the bot (your machine). Use this to call Workers on workers.dev — cf_execute gets 403 on the free plan because it runs in Cloudflare. This runs locally so it works. Use for: testing deployed Workers, calling Worker APIs, fetching from your fib3d/voice-handler etc.",
+		ReadOnly:    true,
 		Parameters: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -201,10 +227,7 @@ func BuildTools(
 				return "", fmt.Errorf("read response: %w", err)
 			}
 
-			result := string(respBody)
-			if len(result) > 15000 {
-				result = result[:15000] + "\n...(truncated)"
-			}
+			result := truncateSmart(string(respBody), 15000, truncHead)
 			return fmt.Sprintf("Status: %d\n%s", resp.StatusCode, result), nil
 		},
 	})
@@ -226,9 +249,13 @@ func BuildTools(
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				key, _ := args["key"].(string)
 				content, _ := args["content"].(string)
+				if err := checkStorage(ctx, quotas, chatUserID(ctx), int64(len(content))); err != nil {
+					return "", err
+				}
 				if err := r2.UploadObject(ctx, bucket, key, []byte(content)); err != nil {
 					return "", err
 				}
+				recordStorage(ctx, quotas, int64(len(content)))
 				return fmt.Sprintf("Written %d bytes to r2://%s/%s", len(content), bucket, key), nil
 			},
 		})
@@ -236,6 +263,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "r2_read",
 			Description: "Read data from R2 storage.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -249,13 +277,100 @@ func BuildTools(
 				if err != nil {
 					return "", err
 				}
-				result := string(data)
-				if len(result) > 8000 {
-					result = result[:8000] + "\n...(truncated)"
-				}
+				result := truncateSmart(string(data), 8000, truncMiddle)
 				return result, nil
 			},
 		})
+
+		tools = append(tools, Tool{
+			Name:        "search_notes",
+			Description: "Search the text of the current user's saved voice-note transcripts and uploaded files. Returns matching file keys with snippets; read a full file with r2_read.",
+			ReadOnly:    true,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"user_id": map[string]interface{}{"type": "string", "description": "Another user's ID (operator only; default: the current user)"},
+					"query":   map[string]interface{}{"type": "string", "description": "Text to find (case-insensitive)"},
+					"regex":   map[string]interface{}{"type": "boolean", "description": "Treat query as a regular expression"},
+				},
+				"required": []string{"query"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				userID, _ := args["user_id"].(string)
+				userID, err := actingUserID(ctx, userID)
+				if err != nil {
+					return "", err
+				}
+				query, _ := args["query"].(string)
+				useRegex, _ := args["regex"].(bool)
+				return searchNotes(ctx, r2, bucket, userID, query, useRegex)
+			},
+		})
+
+		if presigner, ok := r2.(storage.Presigner); ok {
+			tools = append(tools, Tool{
+				Name:        "share_link",
+				Description: "Create a time-limited download link for a file in the current user's R2 space (users/<their id>/...), e.g. a document they uploaded. Anyone with the link can download it until it expires.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"key":   map[string]interface{}{"type": "string", "description": "Object key, e.g. 'users/123/files/report.pdf'"},
+						"hours": map[string]interface{}{"type": "number", "description": fmt.Sprintf("How long the link works (default %g, max %g)", shareLinkTTL.Hours(), storage.MaxPresignTTL.Hours())},
+					},
+					"required": []string{"key"},
+				},
+				Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+					key, _ := args["key"].(string)
+					hours, _ := args["hours"].(float64)
+					if !strings.HasPrefix(key, "users/") || strings.Contains(key, "..") {
+						return "", fmt.Errorf("only files in a user's space (users/<id>/...) can be shared")
+					}
+					if self := chatUserID(ctx); !isOwner(ctx) && (self == "" || !strings.HasPrefix(key, "users/"+self+"/")) {
+						return "", fmt.Errorf("%w; users can only share their own files (users/%s/...)", errOwnerOnly, self)
+					}
+					exists, err := r2.ObjectExists(ctx, bucket, key)
+					if err != nil {
+						return "", err
+					}
+					if !exists {
+						return "", fmt.Errorf("no file at %s", key)
+					}
+					ttl := shareLinkTTL
+					if hours > 0 {
+						ttl = min(time.Duration(hours*float64(time.Hour)), storage.MaxPresignTTL)
+					}
+					link, err := presigner.PresignGetURL(ctx, bucket, key, ttl)
+					if err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("Download link for %s (expires %s):\n%s", key, time.Now().Add(ttl).UTC().Format(time.RFC1123), link), nil
+				},
+			})
+		}
+
+		usage := newUsageCache()
+		tools = append(tools, Tool{
+			Name:        "r2_usage",
+			Description: "Summarize R2 storage use: object count, total size, and the largest objects. Use for questions like \"how much am I storing?\" or \"what's my biggest file?\".",
+			ReadOnly:    true,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"prefix": map[string]interface{}{"type": "string", "description": "Only count keys under this prefix (e.g. 'users/123/'); empty for the whole bucket"},
+					"bucket": map[string]interface{}{"type": "string", "description": "Bucket to inspect (default: " + bucket + ")"},
+					"top":    map[string]interface{}{"type": "integer", "description": "How many of the largest objects to list (default 10, max 50)"},
+				},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				prefix, _ := args["prefix"].(string)
+				target, _ := args["bucket"].(string)
+				if target == "" {
+					target = bucket
+				}
+				top, _ := args["top"].(float64)
+				return r2Usage(ctx, usage, r2, target, prefix, int(top))
+			},
+		})
 	}
 
 	// ── Cognitive Memory tools ──
@@ -300,6 +415,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "recall_facts",
 			Description: "Retrieve facts from semantic memory, optionally filtered by category.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -318,12 +434,81 @@ func BuildTools(
 				}
 				var lines []string
 				for _, f := range facts {
-					lines = append(lines, fmt.Sprintf("- [%s] %s (%.0f%%)", f.Category, f.Content, f.Confidence*100))
+					lines = append(lines, fmt.Sprintf("- [%s] %s (%.0f%%, id %s)", f.Category, f.Content, f.Confidence*100, f.ID))
 				}
 				return strings.Join(lines, "\n"), nil
 			},
 		})
 
+		tools = append(tools, Tool{
+			Name:        "semantic_recall",
+			Description: "Find remembered facts related to a question or topic, by meaning rather than category (e.g. \"deploy preferences\" finds preference facts about deploys).",
+			ReadOnly:    true,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{"type": "string", "description": "What to look for, in plain words"},
+					"top_k": map[string]interface{}{"type": "number", "description": "Max facts to return (default 5)"},
+				},
+				"required": []string{"query"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				query, _ := args["query"].(string)
+				if strings.TrimSpace(query) == "" {
+					return "", fmt.Errorf("query is required")
+				}
+				topK := 5
+				if k, ok := args["top_k"].(float64); ok && k > 0 {
+					topK = int(k)
+				}
+				facts := mem.SemanticRecall(ctx, query, topK)
+				if len(facts) == 0 {
+					return "No related facts found.", nil
+				}
+				var lines []string
+				for _, f := range facts {
+					lines = append(lines, fmt.Sprintf("- [%s] %s (%.0f%%, id %s)", f.Category, f.Content, f.Confidence*100, f.ID))
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		})
+
+		tools = append(tools, Tool{
+			Name:        "forget_fact",
+			Description: "Remove wrong or outdated facts from semantic memory, by ID (from recall_facts or semantic_recall) or by content. Use when the user corrects something you remembered, e.g. they switched languages.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id":       map[string]interface{}{"type": "string", "description": "ID of the fact to remove"},
+					"content":  map[string]interface{}{"type": "string", "description": "Remove every fact whose content contains this text (case-insensitive)"},
+					"category": map[string]interface{}{"type": "string", "description": "With content: only remove facts in this category"},
+				},
+				"required": []string{},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				id, _ := args["id"].(string)
+				content, _ := args["content"].(string)
+				category, _ := args["category"].(string)
+				var n int
+				var err error
+				switch {
+				case strings.TrimSpace(id) != "":
+					n, err = mem.ForgetFact(ctx, strings.TrimSpace(id))
+				case strings.TrimSpace(content) != "":
+					n, err = mem.ForgetFactsByContent(ctx, category, content)
+				default:
+					return "", fmt.Errorf("id or content is required")
+				}
+				if err != nil {
+					return "", err
+				}
+				if n == 0 {
+					return "No matching facts; nothing removed.", nil
+				}
+				return fmt.Sprintf("Forgot %d fact(s).", n), nil
+			},
+		})
+
 		tools = append(tools, Tool{
 			Name:        "save_episode",
 			Description: "Log a notable event, insight, or experience to episodic memory.",
@@ -395,6 +580,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "recall_memory",
 			Description: "Read cognitive memory context: facts, recent episodes, and learned procedures. Use to recall what you know.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -403,11 +589,13 @@ func BuildTools(
 						"description": "How much context: 'small' (1000 chars), 'medium' (4000), 'large' (8000)",
 						"enum":        []string{"small", "medium", "large"},
 					},
+					"query": map[string]interface{}{"type": "string", "description": "Optional topic; facts most relevant to it come first"},
 				},
 				"required": []string{},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				budgetSize, _ := args["budget"].(string)
+				query, _ := args["query"].(string)
 				budget := cognition.DefaultBudget
 				switch budgetSize {
 				case "small":
@@ -417,13 +605,47 @@ func BuildTools(
 				default:
 					budget.MaxTotalChars = 4000
 				}
-				return mem.BuildContext(ctx, budget), nil
+				return mem.BuildContext(ctx, budget, query), nil
 			},
 		})
-	}
 
-	// Self-Build tools (R2-based worker tracking, kept for history)
-	_ = builder
+		tools = append(tools, Tool{
+			Name:        "search_memory",
+			Description: fmt.Sprintf("Search past episodes (conversations, tool use, insights) from the last %d days by keywords, e.g. to find when something was discussed or debugged. recall_memory only shows the last few days.", cognition.DefaultEpisodeSearchDays),
+			ReadOnly:    true,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query":       map[string]interface{}{"type": "string", "description": "Words or phrase to look for"},
+					"max_results": map[string]interface{}{"type": "number", "description": "Max episodes to return (default 10)"},
+				},
+				"required": []string{"query"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				query, _ := args["query"].(string)
+				if strings.TrimSpace(query) == "" {
+					return "", fmt.Errorf("query is required")
+				}
+				maxResults := 10
+				if n, ok := args["max_results"].(float64); ok && n > 0 {
+					maxResults = min(int(n), 50)
+				}
+				episodes := mem.SearchEpisodes(ctx, query, maxResults)
+				if len(episodes) == 0 {
+					return "No matching episodes found.", nil
+				}
+				var lines []string
+				for _, ep := range episodes {
+					line := fmt.Sprintf("- %s [%s] %s", ep.Timestamp.Format("Jan 2 2006 15:04"), ep.Type, ep.Summary)
+					if ep.Detail != "" {
+						line += "\n  " + truncate(ep.Detail, 300)
+					}
+					lines = append(lines, line)
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		})
+	}
 
 	// ── Meta-cognition tools ──
 
@@ -497,6 +719,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "tokenomics",
 			Description: "View token usage, costs, and efficiency metrics for this session and lifetime.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -505,6 +728,36 @@ func BuildTools(
 				return ledger.Report(), nil
 			},
 		})
+
+		tools = append(tools, Tool{
+			Name:        "set_pricing",
+			Description: "Set the USD price per 1M tokens for a model so cost estimates stay accurate. Saved to R2 and applied immediately. Operator only.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"model":  map[string]interface{}{"type": "string", "description": "Model ID (e.g. 'openai/gpt-4o')"},
+					"input":  map[string]interface{}{"type": "number", "description": "USD per 1M prompt tokens"},
+					"output": map[string]interface{}{"type": "number", "description": "USD per 1M completion tokens"},
+				},
+				"required": []string{"model", "input", "output"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				if !isOwner(ctx) {
+					return "", fmt.Errorf("%w; pricing can also be set with MODEL_PRICING_FILE", errOwnerOnly)
+				}
+				model, _ := args["model"].(string)
+				input, _ := args["input"].(float64)
+				output, _ := args["output"].(float64)
+				old, known := ledger.Pricing(model)
+				if err := ledger.SetPricing(ctx, model, input, output); err != nil {
+					return "", err
+				}
+				if !known {
+					return fmt.Sprintf("Pricing for %s set to $%.2f in / $%.2f out per 1M tokens (was unpriced).", model, input, output), nil
+				}
+				return fmt.Sprintf("Pricing for %s set to $%.2f in / $%.2f out per 1M tokens (was $%.2f / $%.2f).", model, input, output, old.Input, old.Output), nil
+			},
+		})
 	}
 
 	// ── Full Cloudflare Environment tools (direct REST API) ──
@@ -513,6 +766,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_inventory",
 			Description: "Full inventory of all Cloudflare resources: workers.dev subdomain, Workers, KV, D1, R2 buckets, Vectorize indexes.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -527,6 +782,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_verify_token",
 			Description: "Verify the Cloudflare API token is valid and check its permissions.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -543,6 +799,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_get_subdomain",
 			Description: "Get the workers.dev subdomain for this account. Workers are accessible at <name>.<subdomain>.workers.dev.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -568,7 +826,11 @@ func BuildTools(
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				sub, _ := args["subdomain"].(string)
-				if err := cfClient.RegisterSubdomain(ctx, sub); err != nil {
+				err := cfClient.RegisterSubdomain(ctx, sub)
+				if cloud != nil {
+					cloud.InvalidateSubdomain()
+				}
+				if err != nil {
 					return "", err
 				}
 				return fmt.Sprintf("Subdomain registered: %s.workers.dev", sub), nil
@@ -581,18 +843,44 @@ func BuildTools(
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{"type": "string", "description": "Worker name (lowercase, hyphens ok)"},
-					"code": map[string]interface{}{"type": "string", "description": "JavaScript (ES module) Worker code"},
+					"name":  map[string]interface{}{"type": "string", "description": "Worker name (lowercase, hyphens ok)"},
+					"code":  map[string]interface{}{"type": "string", "description": "JavaScript (ES module) Worker code"},
+					"force": map[string]interface{}{"type": "boolean", "description": "Redeploy even if the code is unchanged since the last deploy"},
+					"compatibility_date": map[string]interface{}{
+						"type":        "string",
+						"description": "Workers runtime compatibility date, YYYY-MM-DD (default " + cf.DefaultCompatibilityDate + "). Use a newer date for newer runtime features.",
+					},
+					"compatibility_flags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Runtime compatibility flags (default [\"nodejs_compat\"]); pass [] for none",
+					},
+					"files": workerFilesParam,
 				},
 				"required": []string{"name", "code"},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceWorker, name)
+				if err != nil {
+					return "", err
+				}
 				code, _ := args["code"].(string)
-				if err := cfClient.DeployWorker(ctx, name, code); err != nil {
+				force, _ := args["force"].(bool)
+				settings, err := workerSettingsArg(args)
+				if err != nil {
+					return "", err
+				}
+				if msg, ok := workerUpToDate(ctx, builder, name, code, settings, force); ok {
+					return msg, nil
+				}
+				if err := cfClient.DeployWorker(ctx, name, code, settings); err != nil {
 					return "", err
 				}
 				url := cfClient.GetWorkerURL(ctx, name)
+				if builder != nil {
+					builder.RecordDeploy(ctx, name, code, url, settings)
+				}
 				return fmt.Sprintf("Worker %q deployed.\nURL: %s", name, url), nil
 			},
 		})
@@ -612,6 +900,9 @@ func BuildTools(
 				if err := cfClient.DeleteWorker(ctx, name); err != nil {
 					return "", err
 				}
+				if builder != nil {
+					builder.MarkDeleted(ctx, name)
+				}
 				return fmt.Sprintf("Worker %q deleted.", name), nil
 			},
 		})
@@ -619,6 +910,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_workers",
 			Description: "List all Cloudflare Workers on the account.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -640,18 +933,63 @@ func BuildTools(
 			},
 		})
 
+		tools = append(tools, Tool{
+			Name:        "bind_worker_route",
+			Description: "Attach a deployed Worker to a route on a custom domain (e.g. api.example.com/*). The domain must be a zone on this Cloudflare account.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"zone":    map[string]interface{}{"type": "string", "description": "Zone name (e.g. example.com) or zone ID"},
+					"pattern": map[string]interface{}{"type": "string", "description": "Route pattern (e.g. api.example.com/*)"},
+					"worker":  map[string]interface{}{"type": "string", "description": "Worker script name"},
+				},
+				"required": []string{"zone", "pattern", "worker"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				zone, _ := args["zone"].(string)
+				pattern, _ := args["pattern"].(string)
+				worker, _ := args["worker"].(string)
+				zoneID := zone
+				if strings.Contains(zone, ".") {
+					id, err := cfClient.ZoneID(ctx, zone)
+					if err != nil {
+						return "", err
+					}
+					zoneID = id
+				}
+				existing, err := cfClient.ListWorkerRoutes(ctx, zoneID)
+				if err != nil {
+					return "", err
+				}
+				for _, r := range existing {
+					if r.Pattern == pattern {
+						return "", fmt.Errorf("route %q already exists (bound to %q)", pattern, r.Script)
+					}
+				}
+				route, err := cfClient.CreateWorkerRoute(ctx, zoneID, pattern, worker)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Route %s → worker %q (route id %s)", route.Pattern, worker, route.ID), nil
+			},
+		})
+
 		tools = append(tools, Tool{
 			Name:        "create_bucket",
 			Description: "Create an R2 storage bucket.",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{"type": "string", "description": "Bucket name (lowercase, hyphens ok)"},
+					"name": map[string]interface{}{"type": "string", "description": "Bucket name (3-63 chars: lowercase letters, digits, hyphens)"},
 				},
 				"required": []string{"name"},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceBucket, name)
+				if err != nil {
+					return "", err
+				}
 				if err := cfClient.CreateR2Bucket(ctx, name); err != nil {
 					return "", err
 				}
@@ -662,6 +1000,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_buckets",
 			Description: "List all R2 storage buckets.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -682,6 +1022,24 @@ func BuildTools(
 			},
 		})
 
+		tools = append(tools, Tool{
+			Name:        "delete_bucket",
+			Description: "Delete an R2 storage bucket. The bucket must be empty unless force is set, which deletes every object in it first.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":  map[string]interface{}{"type": "string", "description": "Bucket name to delete"},
+					"force": map[string]interface{}{"type": "boolean", "description": "Delete all objects in the bucket first (irreversible)"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				force, _ := args["force"].(bool)
+				return deleteBucket(ctx, r2, bucket, name, force, cfClient.DeleteR2Bucket)
+			},
+		})
+
 		tools = append(tools, Tool{
 			Name:        "create_kv",
 			Description: "Create a Workers KV namespace for edge key-value storage.",
@@ -728,6 +1086,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "kv_read",
 			Description: "Read a value from a KV namespace.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -743,10 +1102,7 @@ func BuildTools(
 				if err != nil {
 					return "", err
 				}
-				result := string(data)
-				if len(result) > 8000 {
-					result = result[:8000] + "\n...(truncated)"
-				}
+				result := truncateSmart(string(data), 8000, truncMiddle)
 				return result, nil
 			},
 		})
@@ -785,7 +1141,11 @@ func BuildTools(
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				dbID, _ := args["database_id"].(string)
 				sql, _ := args["sql"].(string)
-				return cfClient.D1Query(ctx, dbID, sql)
+				result, err := cfClient.D1Query(ctx, dbID, sql)
+				if err != nil {
+					return "", err
+				}
+				return truncateSmart(result, 15000, truncHead), nil
 			},
 		})
 
@@ -795,7 +1155,7 @@ func BuildTools(
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name":       map[string]interface{}{"type": "string", "description": "Index name"},
+					"name":       map[string]interface{}{"type": "string", "description": "Index name (up to 32 chars: lowercase letters, digits, hyphens; starts with a letter)"},
 					"dimensions": map[string]interface{}{"type": "integer", "description": "Vector dimensions (e.g. 768)"},
 					"metric":     map[string]interface{}{"type": "string", "description": "Distance metric", "enum": []string{"cosine", "euclidean", "dot-product"}},
 				},
@@ -803,6 +1163,10 @@ func BuildTools(
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceVectorize, name)
+				if err != nil {
+					return "", err
+				}
 				dims := 768
 				if d, ok := args["dimensions"].(float64); ok {
 					dims = int(d)
@@ -814,6 +1178,22 @@ func BuildTools(
 				return fmt.Sprintf("Vectorize index %q created (%d dims, %s)", name, dims, metric), nil
 			},
 		})
+
+		tools = append(tools, Tool{
+			Name:        "delete_vectorize_index",
+			Description: "Delete a Vectorize index and all vectors stored in it.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string", "description": "Index name to delete"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				return deleteVectorizeIndex(ctx, vectorizeIndex, name, cfClient.DeleteVectorizeIndex)
+			},
+		})
 	}
 
 	// ── MCP-based Cloudflare tools (used when direct API token unavailable) ──
@@ -822,6 +1202,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_inventory",
 			Description: "Full inventory of all Cloudflare resources: Workers, KV, D1, R2 buckets, Vectorize, and users.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -836,6 +1218,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "cf_get_subdomain",
 			Description: "Get the workers.dev subdomain for this account. Workers are accessible at <name>.<subdomain>.workers.dev.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -874,18 +1258,44 @@ func BuildTools(
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{"type": "string", "description": "Worker name (lowercase, hyphens ok)"},
-					"code": map[string]interface{}{"type": "string", "description": "JavaScript Worker code (ES module or service worker format)"},
+					"name":  map[string]interface{}{"type": "string", "description": "Worker name (lowercase, hyphens ok)"},
+					"code":  map[string]interface{}{"type": "string", "description": "JavaScript Worker code (ES module or service worker format)"},
+					"force": map[string]interface{}{"type": "boolean", "description": "Redeploy even if the code is unchanged since the last deploy"},
+					"compatibility_date": map[string]interface{}{
+						"type":        "string",
+						"description": "Workers runtime compatibility date, YYYY-MM-DD (default " + cf.DefaultCompatibilityDate + "). Use a newer date for newer runtime features.",
+					},
+					"compatibility_flags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Runtime compatibility flags (default [\"nodejs_compat\"]); pass [] for none",
+					},
+					"files": workerFilesParam,
 				},
 				"required": []string{"name", "code"},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceWorker, name)
+				if err != nil {
+					return "", err
+				}
 				code, _ := args["code"].(string)
-				result, err := cloud.DeployWorker(ctx, name, code)
+				force, _ := args["force"].(bool)
+				settings, err := workerSettingsArg(args)
 				if err != nil {
 					return "", err
 				}
+				if msg, ok := workerUpToDate(ctx, builder, name, code, settings, force); ok {
+					return msg, nil
+				}
+				result, err := cloud.DeployWorker(ctx, name, code, settings)
+				if err != nil {
+					return "", err
+				}
+				if builder != nil {
+					builder.RecordDeploy(ctx, name, code, cloud.GetWorkerURL(ctx, name), settings)
+				}
 				return result, nil
 			},
 		})
@@ -905,6 +1315,9 @@ func BuildTools(
 				if err := cloud.DeleteWorker(ctx, name); err != nil {
 					return "", err
 				}
+				if builder != nil {
+					builder.MarkDeleted(ctx, name)
+				}
 				return fmt.Sprintf("Worker %q deleted.", name), nil
 			},
 		})
@@ -912,6 +1325,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_workers",
 			Description: "List all Cloudflare Workers on the account.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -939,12 +1354,16 @@ func BuildTools(
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{"type": "string", "description": "Bucket name"},
+					"name": map[string]interface{}{"type": "string", "description": "Bucket name (3-63 chars: lowercase letters, digits, hyphens)"},
 				},
 				"required": []string{"name"},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceBucket, name)
+				if err != nil {
+					return "", err
+				}
 				if err := cloud.CreateBucket(ctx, name); err != nil {
 					return "", err
 				}
@@ -955,6 +1374,8 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_buckets",
 			Description: "List all R2 storage buckets.",
+			Cacheable:   true,
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -975,6 +1396,24 @@ func BuildTools(
 			},
 		})
 
+		tools = append(tools, Tool{
+			Name:        "delete_bucket",
+			Description: "Delete an R2 storage bucket. The bucket must be empty unless force is set, which deletes every object in it first.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":  map[string]interface{}{"type": "string", "description": "Bucket name to delete"},
+					"force": map[string]interface{}{"type": "boolean", "description": "Delete all objects in the bucket first (irreversible)"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				force, _ := args["force"].(bool)
+				return deleteBucket(ctx, r2, bucket, name, force, cloud.DeleteBucket)
+			},
+		})
+
 		tools = append(tools, Tool{
 			Name:        "create_kv",
 			Description: "Create a Workers KV namespace.",
@@ -1021,6 +1460,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "kv_read",
 			Description: "Read a value from a KV namespace.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1070,7 +1510,11 @@ func BuildTools(
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				dbID, _ := args["database_id"].(string)
 				sql, _ := args["sql"].(string)
-				return cloud.D1Query(ctx, dbID, sql)
+				result, err := cloud.D1Query(ctx, dbID, sql)
+				if err != nil {
+					return "", err
+				}
+				return truncateSmart(result, 15000, truncHead), nil
 			},
 		})
 
@@ -1080,7 +1524,7 @@ func BuildTools(
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name":       map[string]interface{}{"type": "string", "description": "Index name"},
+					"name":       map[string]interface{}{"type": "string", "description": "Index name (up to 32 chars: lowercase letters, digits, hyphens; starts with a letter)"},
 					"dimensions": map[string]interface{}{"type": "integer", "description": "Vector dimensions (e.g. 768)"},
 					"metric":     map[string]interface{}{"type": "string", "description": "Distance metric", "enum": []string{"cosine", "euclidean", "dot-product"}},
 				},
@@ -1088,6 +1532,10 @@ func BuildTools(
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				name, _ := args["name"].(string)
+				name, err := validateResourceName(resourceVectorize, name)
+				if err != nil {
+					return "", err
+				}
 				dims := 768
 				if d, ok := args["dimensions"].(float64); ok {
 					dims = int(d)
@@ -1099,6 +1547,22 @@ func BuildTools(
 				return fmt.Sprintf("Vectorize index %q created (%d dims, %s)", name, dims, metric), nil
 			},
 		})
+
+		tools = append(tools, Tool{
+			Name:        "delete_vectorize_index",
+			Description: "Delete a Vectorize index and all vectors stored in it.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string", "description": "Index name to delete"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				return deleteVectorizeIndex(ctx, vectorizeIndex, name, cloud.DeleteVectorizeIndex)
+			},
+		})
 	}
 
 	// ── Per-User Storage tools (R2-based) ──
@@ -1127,6 +1591,30 @@ func BuildTools(
 			},
 		})
 
+		tools = append(tools, Tool{
+			Name:        "deprovision_user",
+			Description: "Permanently delete a user's R2 space (users/<id>/), the file shares they gave or received, and their provisioning record. Only at the user's or operator's explicit request; users can only delete their own. This can't be undone.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"user_id": map[string]interface{}{"type": "string", "description": "User identifier (e.g. Telegram ID)"},
+				},
+				"required": []string{"user_id"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				userID, _ := args["user_id"].(string)
+				// user_id comes from the model: a user may only delete their own space
+				if self := chatUserID(ctx); !isOwner(ctx) && (self == "" || userID != self) {
+					return "", fmt.Errorf("%w; users can only delete their own storage", errOwnerOnly)
+				}
+				deleted, err := cloud.DeprovisionUser(ctx, userID)
+				if err != nil {
+					return "", fmt.Errorf("%w (%d objects were deleted before the failure)", err, deleted)
+				}
+				return fmt.Sprintf("User %s deprovisioned: %d objects deleted.", userID, deleted), nil
+			},
+		})
+
 		tools = append(tools, Tool{
 			Name:        "user_store",
 			Description: "Write data to a user's personal R2 space.",
@@ -1143,36 +1631,98 @@ func BuildTools(
 				userID, _ := args["user_id"].(string)
 				key, _ := args["key"].(string)
 				content, _ := args["content"].(string)
+				if err := checkStorage(ctx, quotas, userID, int64(len(content))); err != nil {
+					return "", err
+				}
 				if err := cloud.UserR2Write(ctx, userID, key, []byte(content)); err != nil {
 					return "", err
 				}
+				recordStorage(ctx, quotas, int64(len(content)))
 				return fmt.Sprintf("Stored %d bytes for user %s at %s", len(content), userID, key), nil
 			},
 		})
 
 		tools = append(tools, Tool{
 			Name:        "user_retrieve",
-			Description: "Read data from a user's personal R2 space.",
+			Description: "Read data from the current user's personal R2 space, or a file another user shared with them (set owner_id).",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"user_id": map[string]interface{}{"type": "string", "description": "User ID"},
-					"key":     map[string]interface{}{"type": "string", "description": "File key"},
+					"key":      map[string]interface{}{"type": "string", "description": "File key"},
+					"owner_id": map[string]interface{}{"type": "string", "description": "Owner of a shared file (optional; default: the current user)"},
 				},
-				"required": []string{"user_id", "key"},
+				"required": []string{"key"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				// The reader is whoever sent this message, never an argument:
+				// shares are checked against it
+				readerID := chatUserID(ctx)
+				if readerID == "" {
+					return "", errors.New("user_retrieve only works in a chat")
+				}
+				key, _ := args["key"].(string)
+				ownerID, _ := args["owner_id"].(string)
+				data, err := cloud.UserR2Read(ctx, readerID, ownerID, key)
+				if err != nil {
+					return "", err
+				}
+				result := truncateSmart(string(data), 8000, truncMiddle)
+				return result, nil
+			},
+		})
+
+		tools = append(tools, Tool{
+			Name:        "share_file",
+			Description: "Let another user read one file from the current user's personal R2 space. Only share at the owner's request.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"user_id":    map[string]interface{}{"type": "string", "description": "Owner's user ID (operator only; default: the current user)"},
+					"key":        map[string]interface{}{"type": "string", "description": "File key in the owner's space"},
+					"grantee_id": map[string]interface{}{"type": "string", "description": "User ID to share with"},
+				},
+				"required": []string{"key", "grantee_id"},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
 				userID, _ := args["user_id"].(string)
+				userID, err := actingUserID(ctx, userID)
+				if err != nil {
+					return "", err
+				}
 				key, _ := args["key"].(string)
-				data, err := cloud.UserR2Read(ctx, userID, key)
+				granteeID, _ := args["grantee_id"].(string)
+				if _, err := cloud.ShareFile(ctx, userID, key, granteeID); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Shared %s from user %s with user %s (read with user_retrieve owner_id=%s).", key, userID, granteeID, userID), nil
+			},
+		})
+
+		tools = append(tools, Tool{
+			Name:        "revoke_share",
+			Description: "Stop sharing a file from the current user's personal R2 space with another user.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"user_id":    map[string]interface{}{"type": "string", "description": "Owner's user ID (operator only; default: the current user)"},
+					"key":        map[string]interface{}{"type": "string", "description": "File key in the owner's space"},
+					"grantee_id": map[string]interface{}{"type": "string", "description": "User ID to revoke"},
+				},
+				"required": []string{"key", "grantee_id"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				userID, _ := args["user_id"].(string)
+				userID, err := actingUserID(ctx, userID)
 				if err != nil {
 					return "", err
 				}
-				result := string(data)
-				if len(result) > 8000 {
-					result = result[:8000] + "\n...(truncated)"
+				key, _ := args["key"].(string)
+				granteeID, _ := args["grantee_id"].(string)
+				if err := cloud.RevokeShare(ctx, userID, key, granteeID); err != nil {
+					return "", err
 				}
-				return result, nil
+				return fmt.Sprintf("User %s can no longer read %s from user %s.", granteeID, key, userID), nil
 			},
 		})
 	}
@@ -1221,6 +1771,7 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_my_tools",
 			Description: "List all dynamic tools you've created for yourself.",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -1327,12 +1878,16 @@ func BuildTools(
 		tools = append(tools, Tool{
 			Name:        "list_features",
 			Description: "List all features in the feature store (ideas, designs, implementations).",
+			ReadOnly:    true,
 			Parameters: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
 			},
 			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
-				features, _ := registry.LoadFeatures(ctx)
+				features, err := registry.LoadFeatures(ctx)
+				if err != nil {
+					return "", err
+				}
 				if len(features) == 0 {
 					return "No features in the store yet.", nil
 				}
@@ -1349,9 +1904,219 @@ func BuildTools(
 		})
 	}
 
+	if registry != nil && (cfClient != nil || cloud != nil) {
+		tools = append(tools, Tool{
+			Name:        "build_feature",
+			Description: "Deploy a feature's stored worker_code (from design_feature) as a Worker and mark the feature deployed. Optionally register the Worker as a tool.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":          map[string]interface{}{"type": "string", "description": "Feature name"},
+					"worker_name":   map[string]interface{}{"type": "string", "description": "Worker name (default: the feature's worker, or its name)"},
+					"register_tool": map[string]interface{}{"type": "boolean", "description": "Also register the Worker as a tool (worker_<name>) that POSTs to it"},
+					"force":         map[string]interface{}{"type": "boolean", "description": "Redeploy even if the code is unchanged since the last deploy"},
+				},
+				"required": []string{"name"},
+			},
+			Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				feature, err := registry.FindFeature(ctx, name)
+				if err != nil {
+					return "", err
+				}
+				if strings.TrimSpace(feature.WorkerCode) == "" {
+					return "", fmt.Errorf("feature %q has no worker_code; add it with design_feature first", name)
+				}
+				workerName, _ := args["worker_name"].(string)
+				if workerName == "" {
+					workerName = feature.WorkerName
+				}
+				if workerName == "" {
+					workerName = feature.Name
+				}
+				workerName, err = validateResourceName(resourceWorker, workerName)
+				if err != nil {
+					return "", err
+				}
+				force, _ := args["force"].(bool)
+				var settings cf.WorkerSettings
+
+				// An unchanged, already deployed Worker only needs the feature updated
+				if _, ok := workerUpToDate(ctx, builder, workerName, feature.WorkerCode, settings, force); !ok {
+					if cfClient != nil {
+						err = cfClient.DeployWorker(ctx, workerName, feature.WorkerCode, settings)
+					} else {
+						_, err = cloud.DeployWorker(ctx, workerName, feature.WorkerCode, settings)
+					}
+					if err != nil {
+						return "", err
+					}
+				}
+				var url string
+				if cfClient != nil {
+					url = cfClient.GetWorkerURL(ctx, workerName)
+				} else {
+					url = cloud.GetWorkerURL(ctx, workerName)
+				}
+				if builder != nil {
+					builder.RecordDeploy(ctx, workerName, feature.WorkerCode, url, settings)
+				}
+
+				feature.Status = "deployed"
+				feature.WorkerName = workerName
+				feature.WorkerURL = url
+				reply := fmt.Sprintf("Feature %q deployed as Worker %q.\nURL: %s", name, workerName, url)
+				if register, _ := args["register_tool"].(bool); register {
+					if err := registry.RegisterWorkerAsTool(ctx, workerName, feature.Description, url, nil); err != nil {
+						return "", fmt.Errorf("deployed, but registering the tool failed: %w", err)
+					}
+					feature.ToolName = "worker_" + workerName
+					reply += fmt.Sprintf("\nTool %q registered. It will be available on next message.", feature.ToolName)
+				}
+				if err := registry.SaveFeature(ctx, *feature); err != nil {
+					return "", fmt.Errorf("deployed, but updating the feature failed: %w", err)
+				}
+				return reply, nil
+			},
+		})
+	}
+
 	return tools
 }
 
+// deleteBucket deletes an R2 bucket via del. With force, every object is
+// removed first through the S3 client, in batches where it supports them. The agent's own bucket is refused.
+func deleteBucket(ctx context.Context, r2 storage.ObjectStore, ownBucket, name string, force bool, del func(ctx context.Context, name string) error) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if name == ownBucket {
+		return "", fmt.Errorf("refusing to delete %q: it holds the agent's own memory and storage", name)
+	}
+
+	removed := 0
+	if force {
+		if r2 == nil {
+			return "", fmt.Errorf("force requires R2 credentials (R2_ACCESS_KEY_ID / R2_SECRET_ACCESS_KEY)")
+		}
+		for {
+			keys, err := r2.ListObjects(ctx, name, "", 1000)
+			if err != nil {
+				return "", fmt.Errorf("list objects in %s: %w", name, err)
+			}
+			if len(keys) == 0 {
+				break
+			}
+			n, err := storage.DeleteKeys(ctx, r2, name, keys)
+			removed += n
+			if err != nil {
+				return "", fmt.Errorf("delete objects in %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := del(ctx, name); err != nil {
+		return "", fmt.Errorf("delete bucket %s (it must be empty; use force to remove objects first): %w", name, err)
+	}
+	if removed > 0 {
+		return fmt.Sprintf("R2 bucket %q deleted (%d objects removed first).", name, removed), nil
+	}
+	return fmt.Sprintf("R2 bucket %q deleted.", name), nil
+}
+
+// deleteVectorizeIndex deletes a Vectorize index via del. The index holding
+// the agent's own fact embeddings is refused.
+func deleteVectorizeIndex(ctx context.Context, ownIndex, name string, del func(ctx context.Context, name string) error) (string, error) {
+	name, err := validateResourceName(resourceVectorize, name)
+	if err != nil {
+		return "", err
+	}
+	if name == ownIndex {
+		return "", fmt.Errorf("refusing to delete %q: it holds the agent's memory embeddings", name)
+	}
+	if err := del(ctx, name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Vectorize index %q deleted.", name), nil
+}
+
+// workerUpToDate reports whether deploying code to name would be a no-op
+// because the SelfBuilder index says that exact code is already live. It
+// returns the message to give the model instead of redeploying.
+func workerUpToDate(ctx context.Context, builder *cognition.SelfBuilder, name, code string, settings cf.WorkerSettings, force bool) (string, bool) {
+	if builder == nil || force {
+		return "", false
+	}
+	w, ok := builder.Unchanged(ctx, name, code, settings)
+	if !ok {
+		return "", false
+	}
+	msg := fmt.Sprintf("Worker %q already up to date (code unchanged since %s); skipped upload. Pass force=true to redeploy anyway.",
+		name, w.DeployedAt.Format("2006-01-02 15:04"))
+	if w.URL != "" {
+		msg += "\nURL: " + w.URL
+	}
+	return msg, true
+}
+
+// workerSettingsArg reads the optional compatibility_date,
+// compatibility_flags, and files arguments of deploy_worker.
+func workerSettingsArg(args map[string]interface{}) (cf.WorkerSettings, error) {
+	var s cf.WorkerSettings
+	if date, _ := args["compatibility_date"].(string); date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return s, fmt.Errorf("compatibility_date must be YYYY-MM-DD, got %q", date)
+		}
+		s.CompatibilityDate = date
+	}
+	if raw, ok := args["compatibility_flags"].([]interface{}); ok {
+		s.CompatibilityFlags = []string{}
+		for _, f := range raw {
+			if flag, _ := f.(string); strings.TrimSpace(flag) != "" {
+				s.CompatibilityFlags = append(s.CompatibilityFlags, strings.TrimSpace(flag))
+			}
+		}
+	}
+	if raw, ok := args["files"].([]interface{}); ok {
+		for i, item := range raw {
+			obj, _ := item.(map[string]interface{})
+			name, _ := obj["name"].(string)
+			content, _ := obj["content"].(string)
+			typ, _ := obj["type"].(string)
+			data := []byte(content)
+			if enc, _ := obj["encoding"].(string); enc == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(content)
+				if err != nil {
+					return s, fmt.Errorf("files[%d] (%s): invalid base64: %w", i, name, err)
+				}
+				data = decoded
+			}
+			s.Files = append(s.Files, cf.WorkerFile{Name: strings.TrimPrefix(name, "./"), Content: data, Type: typ})
+		}
+		if err := cf.ValidateWorkerFiles(s.Files); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// workerFilesParam describes deploy_worker's extra modules and assets.
+var workerFilesParam = map[string]interface{}{
+	"type": "array",
+	"description": "Extra files uploaded beside the main module (worker.js), for multi-file Workers and bundled assets. " +
+		"Import them relative to worker.js, e.g. import util from \"./lib/util.js\"; import page from \"./index.html\" (a string). ES module code only.",
+	"items": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":     map[string]interface{}{"type": "string", "description": "Relative path, e.g. lib/util.js or static/style.css"},
+			"content":  map[string]interface{}{"type": "string", "description": "File contents"},
+			"type":     map[string]interface{}{"type": "string", "enum": []string{cf.ModuleESM, cf.ModuleText, cf.ModuleData, cf.ModuleJSON, cf.ModuleWasm}, "description": "How the file is imported; inferred from the extension (.js esm, .html/.css/.txt text, .json json, .wasm wasm, else data)"},
+			"encoding": map[string]interface{}{"type": "string", "enum": []string{"utf8", "base64"}, "description": "Encoding of content; use base64 for binary files (default utf8)"},
+		},
+		"required": []string{"name", "content"},
+	},
+}
+
 // ToLLMDefs converts tools to OpenAI function-calling format.
 func ToLLMDefs(tools []Tool) []llm.ToolDef {
 	defs := make([]llm.ToolDef, len(tools))
@@ -1368,27 +2133,82 @@ func ToLLMDefs(tools []Tool) []llm.ToolDef {
 	return defs
 }
 
-// ExecuteTool runs a tool by name with the given JSON arguments.
+// ErrInvalidArgs is returned by ExecuteTool when the arguments are not valid JSON.
+var ErrInvalidArgs = errors.New("parse tool args")
+
+// ExecuteTool runs a tool by name with the given JSON arguments. Secrets in the
+// result or error are redacted before they reach the session or logs.
 func ExecuteTool(ctx context.Context, tools []Tool, name string, argsJSON string) (string, error) {
+	ctx, span := tracer.Start(ctx, "tool "+name, trace.WithAttributes(attribute.String("tool.name", name)))
+	result, err := executeTool(ctx, tools, name, argsJSON)
+	span.SetAttributes(attribute.Int("tool.result_bytes", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+func executeTool(ctx context.Context, tools []Tool, name string, argsJSON string) (string, error) {
+	if isPrivate(ctx) && privateBlockedTools[name] {
+		return "", fmt.Errorf("%s is off in private mode: nothing from this chat is saved", name)
+	}
 	for _, t := range tools {
 		if t.Name == name {
+			if isReadOnly(ctx) && !readOnlyAllowed(t) {
+				return "", fmt.Errorf("%s is off here: this turn may only use read-only tools", name)
+			}
 			var args map[string]interface{}
 			if argsJSON == "" {
 				argsJSON = "{}"
 			}
 			if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-				return "", fmt.Errorf("parse tool args: %w", err)
+				return "", fmt.Errorf("%w: %v", ErrInvalidArgs, err)
+			}
+			timeout := t.Timeout
+			if timeout <= 0 {
+				timeout = defaultToolTimeout
 			}
-			result, err := t.Execute(ctx, args)
+			result, err := runWithTimeout(ctx, timeout, t.Execute, args)
 			if err != nil {
+				if msg := redactSecrets(err.Error()); msg != err.Error() {
+					return "", errors.New(msg)
+				}
 				return "", err
 			}
-			return result, nil
+			return redactSecrets(result), nil
 		}
 	}
 	return "", fmt.Errorf("unknown tool: %s", name)
 }
 
+// runWithTimeout runs execute with a deadline. It returns as soon as the
+// deadline passes, even if the tool ignores its context.
+func runWithTimeout(ctx context.Context, timeout time.Duration, execute func(context.Context, map[string]interface{}) (string, error), args map[string]interface{}) (string, error) {
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := execute(toolCtx, args)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil && ctx.Err() == nil && errors.Is(toolCtx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("timed out after %v (try a smaller request or another approach): %w", timeout, o.err)
+		}
+		return o.result, o.err
+	case <-toolCtx.Done():
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("timed out after %v (try a smaller request or another approach)", timeout)
+	}
+}
+
 func init() {
 	// Ensure time is available
 	_ = time.Now()
@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records spans for messages, LLM calls, and tool runs. It is a no-op
+// unless tracing.Setup installed an exporter.
+var tracer = otel.Tracer("github.com/bigneek/picoflare/pkg/agent")
+
+// endSpan marks span as failed when err is set, then ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
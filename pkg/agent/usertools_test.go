@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/cognition"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// userTools returns the per-user storage tools over an in-memory store.
+func userTools(t *testing.T) (*cognition.CloudEnv, []Tool) {
+	t.Helper()
+	cloud := cognition.NewCloudEnv(nil, storage.NewMemStore(), "b", "acct")
+	return cloud, BuildTools(nil, nil, nil, nil, nil, nil, nil, cloud, nil, nil, "b", "acct", "")
+}
+
+// presignStore is a MemStore that hands out fake download links.
+type presignStore struct {
+	*storage.MemStore
+}
+
+func (presignStore) PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	return "https://r2.test/" + bucket + "/" + key, nil
+}
+
+// r2Tools returns the R2 tools over an in-memory store.
+func r2Tools(t *testing.T) (*storage.MemStore, []Tool) {
+	t.Helper()
+	store := storage.NewMemStore()
+	return store, BuildTools(nil, presignStore{store}, nil, nil, nil, nil, nil, nil, nil, nil, "b", "acct", "")
+}
+
+func TestUserRetrieveReadsAsSender(t *testing.T) {
+	ctx := context.Background()
+	cloud, tools := userTools(t)
+	if err := cloud.UserR2Write(ctx, "7", "diary.txt", []byte("dear diary")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ExecuteTool(WithChatID(ctx, 7), tools, "user_retrieve", `{"key":"diary.txt"}`)
+	if err != nil || got != "dear diary" {
+		t.Fatalf("owner read = %q, %v", got, err)
+	}
+	// Another chat can't claim to be user 7, or read 7's files unshared
+	for _, args := range []string{`{"user_id":"7","key":"diary.txt"}`, `{"key":"diary.txt","owner_id":"7"}`} {
+		if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "user_retrieve", args); err == nil {
+			t.Fatalf("chat 8 read user 7's file with %s: %q", args, got)
+		}
+	}
+	if _, err := ExecuteTool(ctx, tools, "user_retrieve", `{"key":"diary.txt"}`); err == nil {
+		t.Fatal("read succeeded with no sender")
+	}
+}
+
+func TestDeprovisionOnlyOwnStorage(t *testing.T) {
+	ctx := context.Background()
+	cloud, tools := userTools(t)
+	for _, id := range []string{"7", "8", "9"} {
+		if err := cloud.UserR2Write(ctx, id, "notes.txt", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ctx := range []context.Context{WithChatID(ctx, 8), ctx} {
+		if _, err := ExecuteTool(ctx, tools, "deprovision_user", `{"user_id":"7"}`); err == nil {
+			t.Fatal("deleted user 7's storage from another chat")
+		}
+	}
+	if _, err := ExecuteTool(ctx, tools, "deprovision_user", `{"user_id":""}`); err == nil {
+		t.Fatal("deprovisioned an empty user ID with no chat")
+	}
+	if _, err := ExecuteTool(WithChatID(ctx, 7), tools, "deprovision_user", `{"user_id":"7"}`); err != nil {
+		t.Fatalf("user deleting their own storage: %v", err)
+	}
+	if _, err := ExecuteTool(WithOwner(WithChatID(ctx, 1)), tools, "deprovision_user", `{"user_id":"8"}`); err != nil {
+		t.Fatalf("operator deleting a user's storage: %v", err)
+	}
+
+	for id, want := range map[string]bool{"7": false, "8": false, "9": true} {
+		if _, err := cloud.UserR2Read(ctx, id, "", "notes.txt"); (err == nil) != want {
+			t.Errorf("user %s storage kept = %v, want %v", id, err == nil, want)
+		}
+	}
+}
+
+func TestSearchNotesOnlyOwnNotes(t *testing.T) {
+	ctx := context.Background()
+	store, tools := r2Tools(t)
+	if err := store.UploadObject(ctx, "b", "users/7/notes/voice.txt", []byte("pick up the keys")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ExecuteTool(WithChatID(ctx, 7), tools, "search_notes", `{"query":"keys"}`)
+	if err != nil || !strings.Contains(got, "voice.txt") {
+		t.Fatalf("own search = %q, %v", got, err)
+	}
+	if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "search_notes", `{"user_id":"7","query":"keys"}`); err == nil {
+		t.Fatalf("chat 8 searched user 7's notes: %q", got)
+	}
+	if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "search_notes", `{"query":"keys"}`); err != nil || strings.Contains(got, "voice.txt") {
+		t.Fatalf("chat 8's own search = %q, %v; want none of user 7's notes", got, err)
+	}
+	if got, err := ExecuteTool(WithOwner(WithChatID(ctx, 1)), tools, "search_notes", `{"user_id":"7","query":"keys"}`); err != nil || !strings.Contains(got, "voice.txt") {
+		t.Fatalf("operator search = %q, %v", got, err)
+	}
+}
+
+func TestShareFileOnlyOwnFiles(t *testing.T) {
+	ctx := context.Background()
+	cloud, tools := userTools(t)
+	if err := cloud.UserR2Write(ctx, "7", "diary.txt", []byte("dear diary")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chat 8 grants itself user 7's file
+	if _, err := ExecuteTool(WithChatID(ctx, 8), tools, "share_file", `{"user_id":"7","key":"diary.txt","grantee_id":"8"}`); err == nil {
+		t.Fatal("chat 8 shared user 7's file")
+	}
+	if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "user_retrieve", `{"key":"diary.txt","owner_id":"7"}`); err == nil {
+		t.Fatalf("chat 8 read user 7's file after sharing it to itself: %q", got)
+	}
+
+	// The owner shares it, chat 8 can't revoke it, then the owner does
+	if _, err := ExecuteTool(WithChatID(ctx, 7), tools, "share_file", `{"key":"diary.txt","grantee_id":"8"}`); err != nil {
+		t.Fatalf("owner sharing: %v", err)
+	}
+	if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "user_retrieve", `{"key":"diary.txt","owner_id":"7"}`); err != nil || got != "dear diary" {
+		t.Fatalf("shared read = %q, %v", got, err)
+	}
+	if _, err := ExecuteTool(WithChatID(ctx, 9), tools, "revoke_share", `{"user_id":"7","key":"diary.txt","grantee_id":"8"}`); err == nil {
+		t.Fatal("chat 9 revoked user 7's share")
+	}
+	if _, err := ExecuteTool(WithChatID(ctx, 7), tools, "revoke_share", `{"key":"diary.txt","grantee_id":"8"}`); err != nil {
+		t.Fatalf("owner revoking: %v", err)
+	}
+	if got, err := ExecuteTool(WithChatID(ctx, 8), tools, "user_retrieve", `{"key":"diary.txt","owner_id":"7"}`); err == nil {
+		t.Fatalf("read after revoke: %q", got)
+	}
+}
+
+func TestShareLinkOnlyOwnFiles(t *testing.T) {
+	ctx := context.Background()
+	store, tools := r2Tools(t)
+	if err := store.UploadObject(ctx, "b", "users/7/files/report.pdf", []byte("%PDF")); err != nil {
+		t.Fatal(err)
+	}
+	args := `{"key":"users/7/files/report.pdf"}`
+
+	got, err := ExecuteTool(WithChatID(ctx, 7), tools, "share_link", args)
+	if err != nil || !strings.Contains(got, "https://r2.test/b/users/7/files/report.pdf") {
+		t.Fatalf("own link = %q, %v", got, err)
+	}
+	for _, ctx := range []context.Context{WithChatID(ctx, 8), WithChatID(ctx, 77), ctx} {
+		if got, err := ExecuteTool(ctx, tools, "share_link", args); err == nil {
+			t.Fatalf("linked user 7's file from another chat: %q", got)
+		}
+	}
+	if _, err := ExecuteTool(WithOwner(WithChatID(ctx, 1)), tools, "share_link", args); err != nil {
+		t.Fatalf("operator link: %v", err)
+	}
+}
@@ -13,13 +13,13 @@ import (
 
 // FS is an R2-backed file system scoped to a single agent.
 type FS struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
 	prefix string // agents/{agentID}/workspace/
 }
 
 // New creates a per-agent file system. agentID is typically chatID (e.g. "chat-123456").
-func New(r2 *storage.R2Client, bucket, agentID string) *FS {
+func New(r2 storage.ObjectStore, bucket, agentID string) *FS {
 	if agentID == "" {
 		agentID = "default"
 	}
@@ -61,11 +61,8 @@ func (f *FS) ListDir(ctx context.Context, dirPath string) ([]string, error) {
 	if f.r2 == nil {
 		return nil, fmt.Errorf("agentfs: no R2 client")
 	}
-	prefix := f.key(dirPath)
-	if prefix != f.prefix {
-		prefix += "/"
-	}
-	keys, err := f.r2.ListObjects(ctx, f.bucket, prefix, 500)
+	prefix := f.dirPrefix(dirPath)
+	keys, err := f.r2.ListObjects(ctx, f.bucket, prefix, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +95,78 @@ func (f *FS) DeleteFile(ctx context.Context, filePath string) error {
 	return f.r2.DeleteObject(ctx, f.bucket, f.key(filePath))
 }
 
+// dirPrefix is the key prefix of everything under dirPath.
+func (f *FS) dirPrefix(dirPath string) string {
+	prefix := f.key(dirPath)
+	if prefix != f.prefix {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// DeleteDir deletes every file under dirPath ("." is the whole workspace)
+// and returns how many were deleted. Stores that support it delete in
+// batches.
+func (f *FS) DeleteDir(ctx context.Context, dirPath string) (int, error) {
+	if f.r2 == nil {
+		return 0, fmt.Errorf("agentfs: no R2 client")
+	}
+	return storage.DeletePrefix(ctx, f.r2, f.bucket, f.dirPrefix(dirPath))
+}
+
+// Move renames a file, or every file under a directory, by copying and then
+// deleting the originals. It returns how many files were moved. A failure
+// part way leaves the already-copied files in both places.
+func (f *FS) Move(ctx context.Context, from, to string) (int, error) {
+	if f.r2 == nil {
+		return 0, fmt.Errorf("agentfs: no R2 client")
+	}
+	src, dst := f.key(from), f.key(to)
+	if src == f.prefix || dst == f.prefix {
+		return 0, fmt.Errorf("agentfs: can't move the workspace root")
+	}
+	if src == dst {
+		return 0, nil
+	}
+	if strings.HasPrefix(dst, src+"/") {
+		return 0, fmt.Errorf("agentfs: can't move %s into itself", from)
+	}
+
+	var keys []string
+	if ok, err := f.r2.ObjectExists(ctx, f.bucket, src); err != nil {
+		return 0, err
+	} else if ok {
+		keys = []string{src}
+	} else {
+		infos, err := f.r2.ListObjectInfos(ctx, f.bucket, src+"/")
+		if err != nil {
+			return 0, err
+		}
+		for _, info := range infos {
+			keys = append(keys, info.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("agentfs: %s not found", from)
+	}
+
+	for _, k := range keys {
+		data, err := f.r2.DownloadObject(ctx, f.bucket, k)
+		if err != nil {
+			return 0, err
+		}
+		if err := f.r2.UploadObject(ctx, f.bucket, dst+strings.TrimPrefix(k, src), data); err != nil {
+			return 0, err
+		}
+	}
+	for _, k := range keys {
+		if err := f.r2.DeleteObject(ctx, f.bucket, k); err != nil {
+			return len(keys), err
+		}
+	}
+	return len(keys), nil
+}
+
 // Exists returns true if the path exists.
 func (f *FS) Exists(ctx context.Context, filePath string) (bool, error) {
 	if f.r2 == nil {
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bigneek/picoflare/pkg/cognition"
+	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// ConfigIssue is one missing or invalid setting found by Config.Validate.
+type ConfigIssue struct {
+	Setting  string // env var(s) to set, e.g. "TELEGRAM_BOT_TOKEN"
+	Problem  string // what is wrong
+	Unlocks  string // what works once it is fixed
+	Required bool   // the bot can't start without it
+}
+
+// ConfigError reports every problem with a Config at once.
+type ConfigError struct {
+	Issues []ConfigIssue
+}
+
+// Fatal reports whether any issue prevents the bot from starting.
+func (e *ConfigError) Fatal() bool {
+	for _, is := range e.Issues {
+		if is.Required {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ConfigError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("configuration problems:\n")
+	for _, is := range e.Issues {
+		level := "optional"
+		if is.Required {
+			level = "REQUIRED"
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %s: %s", level, is.Setting, is.Problem))
+		if is.Unlocks != "" {
+			sb.WriteString(" — enables " + is.Unlocks)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Set these in .env (see .env.example).")
+	return sb.String()
+}
+
+// Validate checks the config and returns a *ConfigError listing everything
+// missing or invalid, or nil if all features are configured. Check Fatal to
+// decide whether to start anyway with reduced features.
+func (cfg Config) Validate() error {
+	return cfg.validate(true)
+}
+
+// ValidateAgent is Validate without the Telegram-only settings, for running
+// the agent from the CLI or Slack.
+func (cfg Config) ValidateAgent() error {
+	return cfg.validate(false)
+}
+
+func (cfg Config) validate(telegram bool) error {
+	var issues []ConfigIssue
+	add := func(required bool, setting, problem, unlocks string) {
+		issues = append(issues, ConfigIssue{Setting: setting, Problem: problem, Unlocks: unlocks, Required: required})
+	}
+
+	if telegram && cfg.TelegramToken == "" {
+		add(true, "TELEGRAM_BOT_TOKEN", "not set", "the Telegram bot (get a token from @BotFather)")
+	}
+
+	hasCF := cfg.AccountID != "" && cfg.APIToken != ""
+	switch cfg.LLMProvider {
+	case "", "openrouter":
+		if cfg.LLMAPIKey == "" && cfg.LLMBaseURL == "" {
+			add(true, "OPENROUTER_API_KEY or LLM_BASE_URL", "no LLM configured", "replies (or set LLM_PROVIDER=workers-ai)")
+		} else if cfg.LLMBaseURL != "" && cfg.LLMModel == "" {
+			// The OpenRouter default model won't exist on a local server
+			add(true, "LLM_MODEL", "not set with LLM_BASE_URL", "replies from the local model (e.g. llama3.1 for Ollama)")
+		}
+	case "workers-ai":
+		if !hasCF {
+			add(true, "CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN", "LLM_PROVIDER=workers-ai needs both", "the Workers AI LLM")
+		}
+	default:
+		add(true, "LLM_PROVIDER", fmt.Sprintf("unknown provider %q (use openrouter or workers-ai)", cfg.LLMProvider), "")
+	}
+
+	switch {
+	case cfg.AccountID == "" && cfg.APIToken == "":
+		add(false, "CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN", "not set", "Workers, KV, D1, R2, and Vectorize tools")
+	case cfg.AccountID == "":
+		add(false, "CLOUDFLARE_ACCOUNT_ID", "not set (CLOUDFLARE_API_TOKEN is)", "Cloudflare tools and R2")
+	case cfg.APIToken == "":
+		add(false, "CLOUDFLARE_API_TOKEN", "not set (CLOUDFLARE_ACCOUNT_ID is)", "Cloudflare tools")
+	}
+
+	switch {
+	case cfg.StorageBackend != "" && cfg.StorageBackend != storage.BackendR2 &&
+		cfg.StorageBackend != storage.BackendMemory && cfg.StorageBackend != storage.BackendFile:
+		add(false, "STORAGE_BACKEND", fmt.Sprintf("unknown backend %q (use r2, memory, or file)", cfg.StorageBackend), "memory, file uploads, reminders, and cost tracking")
+	case cfg.StorageBackend == storage.BackendMemory || cfg.StorageBackend == storage.BackendFile:
+		// no R2 credentials needed
+	case cfg.R2AccessKey == "" && cfg.R2SecretKey == "":
+		add(false, "R2_ACCESS_KEY_ID, R2_SECRET_ACCESS_KEY", "not set", "memory, file uploads, reminders, and cost tracking")
+	case cfg.R2AccessKey == "" || cfg.R2SecretKey == "":
+		add(false, "R2_ACCESS_KEY_ID, R2_SECRET_ACCESS_KEY", "only one is set; both are needed", "memory, file uploads, reminders, and cost tracking")
+	}
+	if cfg.MCPEndpoint != "" {
+		if err := mcpclient.ValidateEndpoint(cfg.MCPEndpoint); err != nil {
+			add(false, "MCP_ENDPOINT", "must be an http:// or https:// URL", "Cloudflare MCP tools (cf_search, cf_execute)")
+		}
+	}
+	if cfg.R2Endpoint != "" && !strings.HasPrefix(cfg.R2Endpoint, "http://") && !strings.HasPrefix(cfg.R2Endpoint, "https://") {
+		add(false, "R2_ENDPOINT", "must be an http:// or https:// URL", "memory, file uploads, reminders, and cost tracking")
+	}
+
+	if cfg.DailyBudgetUSD < 0 || cfg.MonthlyBudgetUSD < 0 {
+		add(false, "BUDGET_DAILY_USD, BUDGET_MONTHLY_USD", "must not be negative", "budget alerts")
+	}
+	if telegram && (cfg.DailyBudgetUSD > 0 || cfg.MonthlyBudgetUSD > 0) && cfg.OwnerChatID == 0 {
+		add(false, "TELEGRAM_OWNER_ID", "not set with a budget configured", "budget alerts in Telegram (otherwise only logged)")
+	}
+	if cfg.PricingFile != "" {
+		if _, err := os.Stat(cfg.PricingFile); err != nil {
+			add(false, "MODEL_PRICING_FILE", fmt.Sprintf("can't read %s", cfg.PricingFile), "custom model pricing")
+		}
+	}
+
+	if telegram {
+		if cfg.EventToken != "" && cfg.EventChatID == 0 && cfg.OwnerChatID == 0 {
+			add(false, "EVENT_CHAT_ID or TELEGRAM_OWNER_ID", "not set with EVENT_WEBHOOK_TOKEN", "a chat session for inbound /event requests")
+		}
+		if cfg.Workers < 0 || cfg.QueueSize < 0 {
+			add(false, "UPDATE_WORKERS, UPDATE_QUEUE_SIZE", "must not be negative (using the default)", "")
+		}
+	}
+
+	if cfg.MemoryBudget != (cognition.ContextBudget{}) {
+		if err := cfg.MemoryBudget.Validate(); err != nil {
+			add(false, "MEMORY_CONTEXT_CHARS, MEMORY_CONTEXT_SPLIT", err.Error()+" (using the default)", "a custom memory budget")
+		}
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	return &ConfigError{Issues: issues}
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateAgentSkipsTelegram(t *testing.T) {
+	cfg := Config{LLMAPIKey: "key", StorageBackend: "memory", AccountID: "acct", APIToken: "tok", EventToken: "x"}
+
+	var cfgErr *ConfigError
+	if err := cfg.Validate(); !errors.As(err, &cfgErr) || !cfgErr.Fatal() {
+		t.Fatalf("Validate without TELEGRAM_BOT_TOKEN = %v, want a fatal ConfigError", err)
+	}
+	if err := cfg.ValidateAgent(); err != nil {
+		t.Fatalf("ValidateAgent = %v, want nil", err)
+	}
+
+	cfg.LLMProvider = "nope"
+	if err := cfg.ValidateAgent(); !errors.As(err, &cfgErr) || !cfgErr.Fatal() {
+		t.Fatalf("ValidateAgent with an unknown provider = %v, want a fatal ConfigError", err)
+	}
+}
+
+func TestValidateAgentBaseURLNeedsModel(t *testing.T) {
+	cfg := Config{LLMBaseURL: "http://localhost:11434/v1", StorageBackend: "memory", AccountID: "acct", APIToken: "tok", EventToken: "x"}
+
+	var cfgErr *ConfigError
+	if err := cfg.ValidateAgent(); !errors.As(err, &cfgErr) || !cfgErr.Fatal() {
+		t.Fatalf("ValidateAgent with LLM_BASE_URL and no model = %v, want a fatal ConfigError", err)
+	}
+	cfg.LLMModel = "llama3.1"
+	if err := cfg.ValidateAgent(); err != nil {
+		t.Fatalf("ValidateAgent with LLM_MODEL = %v, want nil", err)
+	}
+}
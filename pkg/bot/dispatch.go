@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// Defaults for Config.Workers and Config.QueueSize.
+const (
+	defaultWorkers   = 8
+	defaultQueueSize = 100
+)
+
+const busyReply = "I'm busy with other messages right now. Please try again in a minute."
+
+// dispatch runs updates on a fixed pool of workers fed by a bounded queue.
+// Messages, callback queries, and inbound events share the queue, so they're
+// served in arrival order. When the queue is full the update is dropped and
+// the sender is told the bot is busy.
+type dispatch struct {
+	b     *Bot
+	queue chan job
+}
+
+// job is one queued unit of work: a Telegram update, or an inbound event
+// (see eventHandler).
+type job struct {
+	update telego.Update
+	event  *eventJob
+}
+
+// eventJob is an inbound event; done, if set, receives the reply.
+type eventJob struct {
+	ev   inboundEvent
+	done chan<- string
+}
+
+func (b *Bot) newDispatch(ctx context.Context) *dispatch {
+	d := &dispatch{b: b, queue: make(chan job, b.queueSize)}
+	for i := 0; i < b.workers; i++ {
+		go d.work(ctx)
+	}
+	return d
+}
+
+func (d *dispatch) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-d.queue:
+			if ev := j.event; ev != nil {
+				reply := d.b.processEvent(ctx, ev.ev)
+				if ev.done != nil {
+					ev.done <- reply
+				}
+				continue
+			}
+			update := j.update
+			if update.Message != nil {
+				d.b.handleMessage(ctx, update.Message)
+			}
+			if update.CallbackQuery != nil {
+				d.b.handleCallbackQuery(ctx, update.CallbackQuery)
+			}
+		}
+	}
+}
+
+// submit queues update, or answers "busy" if the queue is full. /stop skips
+// the queue: it has to work while every worker is busy with a long turn.
+func (d *dispatch) submit(ctx context.Context, update telego.Update) {
+	if msg := update.Message; msg != nil && isStopCommand(msg) {
+		go d.b.handleMessage(ctx, msg)
+		return
+	}
+	select {
+	case d.queue <- job{update: update}:
+		return
+	default:
+	}
+	switch {
+	case update.Message != nil:
+		log.Printf("Update queue full (%d); chat %d told to retry", cap(d.queue), update.Message.Chat.ID)
+		d.b.sendFormattedReply(ctx, update.Message.Chat.ChatID(), busyReply)
+	case update.CallbackQuery != nil:
+		log.Printf("Update queue full (%d); callback from %d dropped", cap(d.queue), update.CallbackQuery.From.ID)
+		_ = d.b.tg.AnswerCallbackQuery(ctx, tu.CallbackQuery(update.CallbackQuery.ID).WithText(busyReply))
+	}
+}
+
+// submitEvent queues an inbound event, sending its reply to done if that is
+// set. It reports false, dropping the event, when the queue is full.
+func (d *dispatch) submitEvent(ev inboundEvent, done chan<- string) bool {
+	select {
+	case d.queue <- job{event: &eventJob{ev: ev, done: done}}:
+		return true
+	default:
+		log.Printf("Update queue full (%d); event %s dropped", cap(d.queue), ev.Source)
+		return false
+	}
+}
+
+func isStopCommand(msg *telego.Message) bool {
+	return strings.TrimSpace(msg.Text) == "/stop"
+}
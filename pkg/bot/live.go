@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+const (
+	// liveEditInterval spaces out placeholder edits; Telegram throttles bots
+	// that edit one message much faster than once a second.
+	liveEditInterval = 1500 * time.Millisecond
+	// maxLiveChars keeps the preview under Telegram's 4096-character limit.
+	maxLiveChars = 4000
+)
+
+// liveReply is a "💭 Thinking..." placeholder that shows the reply as it
+// streams in. The final reply is still sent as a new formatted message.
+type liveReply struct {
+	b      *Bot
+	chatID telego.ChatID
+	msgID  int
+
+	mu      sync.Mutex
+	pending string // latest text from the agent
+	shown   string // text currently in the placeholder
+	done    chan struct{}
+}
+
+// startLive registers placeholder msgID as the live reply for chatIDInt and
+// starts editing it. Call stop when the agent returns.
+func (b *Bot) startLive(ctx context.Context, chatIDInt int64, chatID telego.ChatID, msgID int) (stop func()) {
+	if !b.streamReplies {
+		return func() {}
+	}
+	lr := &liveReply{b: b, chatID: chatID, msgID: msgID, done: make(chan struct{})}
+	b.liveMu.Lock()
+	b.live[chatIDInt] = lr
+	b.liveMu.Unlock()
+	go lr.run(ctx)
+	return func() {
+		b.liveMu.Lock()
+		if b.live[chatIDInt] == lr {
+			delete(b.live, chatIDInt)
+		}
+		b.liveMu.Unlock()
+		close(lr.done)
+	}
+}
+
+// onDelta is the agent's OnDelta callback: it records the text for the
+// chat's live reply, if one is showing.
+func (b *Bot) onDelta(chatID int64, partial string) {
+	b.liveMu.Lock()
+	lr := b.live[chatID]
+	b.liveMu.Unlock()
+	if lr == nil {
+		return
+	}
+	lr.mu.Lock()
+	lr.pending = partial
+	lr.mu.Unlock()
+}
+
+// run edits the placeholder with the latest text every liveEditInterval
+// until stopped.
+func (lr *liveReply) run(ctx context.Context) {
+	ticker := time.NewTicker(liveEditInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lr.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lr.mu.Lock()
+		text := lr.pending
+		lr.mu.Unlock()
+		if text == "" || text == lr.shown {
+			continue
+		}
+		lr.shown = text
+		// Plain text: half-written markdown doesn't convert cleanly
+		if r := []rune(text); len(r) > maxLiveChars {
+			text = "…" + string(r[len(r)-maxLiveChars:])
+		}
+		if _, err := lr.b.tg.EditMessageText(ctx, tu.EditMessageText(lr.chatID, lr.msgID, text)); err != nil {
+			log.Printf("Live reply edit failed: %v", err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // markdownToTelegramHTML converts standard LLM markdown to Telegram-compatible HTML.
@@ -33,6 +34,13 @@ func markdownToTelegramHTML(md string) string {
 		return fmt.Sprintf("\x00CB%d\x00", idx)
 	})
 
+	// Tables → aligned monospace grid (rendered like a fenced block)
+	md = replaceTables(md, func(grid string) string {
+		idx := len(blocks)
+		blocks = append(blocks, codeBlock{code: grid})
+		return fmt.Sprintf("\x00CB%d\x00", idx)
+	})
+
 	// Phase 2: Extract inline code spans (single backtick, no nesting)
 	inlineCode := regexp.MustCompile("`([^`\n]+)`")
 	md = inlineCode.ReplaceAllStringFunc(md, func(match string) string {
@@ -60,10 +68,8 @@ func markdownToTelegramHTML(md string) string {
 	// Links [text](url)
 	md = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(md, `<a href="$2">$1</a>`)
 
-	// Bullet points at line start
-	md = regexp.MustCompile(`(?m)^[\-\*]\s+`).ReplaceAllString(md, "• ")
-
-	// Numbered list: keep as-is
+	// Bullets and numbered lists, with nesting
+	md = formatLists(md)
 
 	// Horizontal rules
 	md = regexp.MustCompile(`(?m)^[\-\*]{3,}\s*$`).ReplaceAllString(md, "")
@@ -92,14 +98,245 @@ func markdownToTelegramHTML(md string) string {
 	return strings.TrimSpace(md)
 }
 
-// splitMarkdownChunks splits markdown text into chunks that respect code block
-// boundaries. Never cuts inside a fenced code block.
+var listItemRe = regexp.MustCompile(`^([ \t]*)([\-\*\+]|\d{1,3}[.)])\s+(.*)$`)
+
+// listIndent is the per-level indent for nested list items. Telegram strips
+// leading ordinary spaces, so non-breaking ones are used.
+const listIndent = "\u00a0\u00a0\u00a0\u00a0"
+
+// formatLists turns "-"/"*"/"+" bullets into "•" (nested: "◦") and indents
+// nested bullets and numbered items by their depth. Numbers are kept as written.
+func formatLists(md string) string {
+	lines := strings.Split(md, "\n")
+	var indents []int // indent width of each open list level
+	for i, line := range lines {
+		m := listItemRe.FindStringSubmatch(line)
+		if m == nil {
+			// Unindented text ends the list; blank lines don't (loose lists)
+			if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+				indents = indents[:0]
+			}
+			continue
+		}
+		if strings.Trim(strings.TrimSpace(line), "-* ") == "" {
+			continue // horizontal rule, not a bullet
+		}
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(indents) > 0 && indents[len(indents)-1] > indent {
+			indents = indents[:len(indents)-1]
+		}
+		if len(indents) == 0 || indents[len(indents)-1] < indent {
+			indents = append(indents, indent)
+		}
+		level := len(indents) - 1
+
+		marker := m[2]
+		switch marker {
+		case "-", "*", "+":
+			marker = "•"
+			if level > 0 {
+				marker = "◦"
+			}
+		}
+		lines[i] = strings.Repeat(listIndent, level) + marker + " " + m[3]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxTableWidth is the widest table rendered as a grid; wider tables are left
+// as raw markdown since <pre> would wrap them unreadably on phones anyway.
+const maxTableWidth = 80
+
+var (
+	tableSepRe    = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	tableLinkRe   = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+	tableMarkupRe = regexp.MustCompile("\\*\\*|__|`")
+)
+
+// replaceTables finds GFM tables (header row, |---| separator, body rows) and
+// replaces each with render(grid), where grid is the table laid out with
+// padded columns.
+func replaceTables(md string, render func(grid string) string) string {
+	lines := strings.Split(md, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if i+1 >= len(lines) || !strings.Contains(lines[i], "|") || !strings.Contains(lines[i+1], "|") || !tableSepRe.MatchString(lines[i+1]) {
+			out = append(out, lines[i])
+			continue
+		}
+		header := splitTableRow(lines[i])
+		aligns := splitTableRow(lines[i+1])
+		if len(header) != len(aligns) {
+			out = append(out, lines[i])
+			continue
+		}
+		end := i + 2
+		rows := [][]string{header}
+		for end < len(lines) && strings.Contains(lines[end], "|") && strings.TrimSpace(lines[end]) != "" {
+			rows = append(rows, splitTableRow(lines[end]))
+			end++
+		}
+		grid, ok := formatTable(rows, aligns)
+		if !ok {
+			out = append(out, lines[i:end]...)
+		} else {
+			out = append(out, render(grid))
+		}
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitTableRow splits "| a | b |" into trimmed cells, honoring \| escapes.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	line = strings.ReplaceAll(line, `\|`, "\x00")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		c = strings.ReplaceAll(c, "\x00", "|")
+		c = tableLinkRe.ReplaceAllString(c, "$1")
+		cells[i] = strings.TrimSpace(tableMarkupRe.ReplaceAllString(c, ""))
+	}
+	return cells
+}
+
+// formatTable pads columns to equal width using the separator's alignment.
+// It reports false if the table is wider than maxTableWidth.
+func formatTable(rows [][]string, aligns []string) (string, bool) {
+	cols := len(aligns)
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for c := 0; c < cols && c < len(row); c++ {
+			if w := utf8.RuneCountInString(row[c]); w > widths[c] {
+				widths[c] = w
+			}
+		}
+	}
+	total := 3 * (cols - 1)
+	for _, w := range widths {
+		total += w
+	}
+	if total > maxTableWidth {
+		return "", false
+	}
+
+	var sb strings.Builder
+	for r, row := range rows {
+		for c := 0; c < cols; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			if c > 0 {
+				sb.WriteString(" | ")
+			}
+			sb.WriteString(padCell(cell, widths[c], aligns[c], c == cols-1))
+		}
+		sb.WriteString("\n")
+		if r == 0 {
+			for c, w := range widths {
+				if c > 0 {
+					sb.WriteString("-+-")
+				}
+				sb.WriteString(strings.Repeat("-", w))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), true
+}
+
+// padCell aligns s within width per a separator cell (":--", ":-:", "--:").
+// The last column isn't right-padded so lines carry no trailing spaces.
+func padCell(s string, width int, align string, last bool) string {
+	gap := width - utf8.RuneCountInString(s)
+	switch {
+	case strings.HasPrefix(align, ":") && strings.HasSuffix(align, ":"):
+		left := gap / 2
+		s = strings.Repeat(" ", left) + s
+		gap -= left
+	case strings.HasSuffix(align, ":"):
+		return strings.Repeat(" ", gap) + s
+	}
+	if last {
+		return s
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+// telegramMaxMessage is Telegram's limit on message text length.
+const telegramMaxMessage = 4096
+
+// telegramChunk is one outgoing message. html is empty if the markdown
+// couldn't be rendered within the limit and must be sent as plain text.
+type telegramChunk struct {
+	md   string
+	html string
+}
+
+// renderTelegramChunks splits markdown into chunks whose HTML fits in one
+// message, and converts each. Chunks are packed by their rendered length,
+// since escaping and tags make the HTML longer than its markdown. A chunk
+// that still expands past telegramMaxMessage (e.g. a single huge line) is
+// re-split at half the size instead of losing formatting.
+func renderTelegramChunks(md string, maxLen int) []telegramChunk {
+	fits := func(chunk string) bool {
+		return utf8.RuneCountInString(chunk) <= maxLen && htmlLen(chunk) <= telegramMaxMessage
+	}
+	var out []telegramChunk
+	for _, chunk := range splitChunks(md, maxLen, fits) {
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+		html := markdownToTelegramHTML(chunk)
+		switch {
+		case strings.TrimSpace(html) == "":
+			out = append(out, telegramChunk{md: chunk})
+		case utf8.RuneCountInString(html) <= telegramMaxMessage:
+			out = append(out, telegramChunk{md: chunk, html: html})
+		case maxLen > 500:
+			out = append(out, renderTelegramChunks(chunk, maxLen/2)...)
+		default:
+			out = append(out, telegramChunk{md: chunk})
+		}
+	}
+	return out
+}
+
+// htmlLen is the length in characters of md rendered as Telegram HTML. An
+// unclosed code fence (a chunk split inside a code block) is closed first,
+// as splitChunks will.
+func htmlLen(md string) int {
+	if strings.Count(md, "```")%2 == 1 {
+		md += "\n```"
+	}
+	return utf8.RuneCountInString(markdownToTelegramHTML(md))
+}
+
+// splitMarkdownChunks splits markdown text into chunks of at most maxLen
+// bytes that respect code block boundaries (see splitChunks).
 func splitMarkdownChunks(text string, maxLen int) []string {
 	if len(text) <= maxLen {
 		return []string{text}
 	}
+	return splitChunks(text, maxLen, func(chunk string) bool { return len(chunk) <= maxLen })
+}
 
-	lines := strings.Split(text, "\n")
+// splitChunks packs lines of markdown into chunks for which fits holds.
+// Never cuts inside a fenced code block without closing and reopening it,
+// and splits lines longer than maxLen outside bold, links, and inline code.
+func splitChunks(text string, maxLen int, fits func(chunk string) bool) []string {
+	if fits(text) {
+		return []string{text}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, splitLongLine(line, maxLen)...)
+	}
 	var chunks []string
 	var buf strings.Builder
 	inCode := false
@@ -119,8 +356,7 @@ func splitMarkdownChunks(text string, maxLen int) []string {
 			}
 		}
 
-		lineLen := len(line) + 1
-		wouldExceed := buf.Len()+lineLen > maxLen && buf.Len() > 0
+		wouldExceed := buf.Len() > 0 && !fits(buf.String()+line+"\n")
 
 		if wouldExceed && !inCode {
 			// Safe to split here (outside code block)
@@ -145,6 +381,77 @@ func splitMarkdownChunks(text string, maxLen int) []string {
 	return chunks
 }
 
+// splitLongLine breaks a line longer than maxLen at spaces where no markdown
+// span (**bold**, [link](url), `code`) is left open.
+func splitLongLine(line string, maxLen int) []string {
+	var parts []string
+	for len(line) > maxLen {
+		cut := safeCut(line, maxLen)
+		parts = append(parts, line[:cut])
+		line = strings.TrimLeft(line[cut:], " ")
+	}
+	return append(parts, line)
+}
+
+// safeCut returns the index of the last space before maxLen at which all
+// markdown spans are closed, falling back to the last space, then to a UTF-8
+// boundary.
+func safeCut(line string, maxLen int) int {
+	s := line[:maxLen]
+	lastSpace, lastSafe := -1, -1
+	bold, code := false, false
+	brackets, parens := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '`':
+			code = !code
+		case '*':
+			if !code && i+1 < len(s) && s[i+1] == '*' {
+				bold = !bold
+				i++
+			}
+		case '[':
+			if !code {
+				brackets++
+			}
+		case ']':
+			if !code && brackets > 0 {
+				brackets--
+				if i+1 < len(s) && s[i+1] == '(' {
+					parens++
+					i++
+				}
+			}
+		case ')':
+			if !code && parens > 0 {
+				parens--
+			}
+		case ' ':
+			if i == 0 {
+				continue
+			}
+			lastSpace = i
+			if !bold && !code && brackets == 0 && parens == 0 {
+				lastSafe = i
+			}
+		}
+	}
+	switch {
+	case lastSafe > len(s)/2:
+		return lastSafe
+	case lastSpace > 0:
+		return lastSpace
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = maxLen
+	}
+	return cut
+}
+
 func escapeHTML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkChunks fails unless every chunk renders to HTML within Telegram's
+// limit with its tags balanced.
+func checkChunks(t *testing.T, chunks []telegramChunk) {
+	t.Helper()
+	for i, c := range chunks {
+		if c.html == "" {
+			t.Fatalf("chunk %d fell back to plain text (%d chars of markdown)", i, len(c.md))
+		}
+		if n := utf8.RuneCountInString(c.html); n > telegramMaxMessage {
+			t.Fatalf("chunk %d: %d characters of HTML, limit %d", i, n, telegramMaxMessage)
+		}
+		for _, tag := range []string{"b", "a", "code", "pre"} {
+			open := strings.Count(c.html, "<"+tag+">") + strings.Count(c.html, "<"+tag+" ")
+			if closed := strings.Count(c.html, "</"+tag+">"); open != closed {
+				t.Fatalf("chunk %d: %d <%s> but %d </%s>:\n%s", i, open, tag, closed, tag, c.html)
+			}
+		}
+	}
+}
+
+func TestRenderChunksPlainText(t *testing.T) {
+	var sb strings.Builder
+	for sb.Len() < 16000 {
+		sb.WriteString("This is an ordinary sentence in a long answer, nothing special about it.\n")
+	}
+	chunks := renderTelegramChunks(sb.String(), telegramMaxMessage)
+	checkChunks(t, chunks)
+	if len(chunks) > 5 {
+		t.Fatalf("16K of plain text became %d messages, want at most 5", len(chunks))
+	}
+}
+
+func TestRenderChunksBoldAndLinks(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 16000; i++ {
+		fmt.Fprintf(&sb, "- **Step %d** — see [the docs for step %d](https://developers.cloudflare.com/workers/step-%d?ref=picoflare&lang=en) & **keep going**\n", i, i, i)
+	}
+	chunks := renderTelegramChunks(sb.String(), telegramMaxMessage)
+	checkChunks(t, chunks)
+
+	total := 0
+	for _, c := range chunks {
+		total += utf8.RuneCountInString(c.html)
+	}
+	if want := total/telegramMaxMessage + 2; len(chunks) > want {
+		t.Fatalf("%d characters of HTML became %d messages, want at most %d", total, len(chunks), want)
+	}
+}
+
+func TestRenderChunksLongBoldLine(t *testing.T) {
+	// One paragraph far past the limit, with every word bolded and escaped
+	line := strings.Repeat("**a<b** & ", 1500)
+	checkChunks(t, renderTelegramChunks(line, telegramMaxMessage))
+}
+
+func TestRenderChunksCountsCharactersNotBytes(t *testing.T) {
+	var sb strings.Builder
+	for sb.Len() < 24000 {
+		sb.WriteString("Привет, это обычное предложение в длинном ответе.\n")
+	}
+	text := sb.String()
+	chunks := renderTelegramChunks(text, telegramMaxMessage)
+	checkChunks(t, chunks)
+	if want := utf8.RuneCountInString(text)/telegramMaxMessage + 1; len(chunks) > want {
+		t.Fatalf("%d characters became %d messages, want at most %d", utf8.RuneCountInString(text), len(chunks), want)
+	}
+}
+
+func TestRenderChunksSplitCodeBlock(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Here is the file:\n```go\n")
+	for i := 0; sb.Len() < 9000; i++ {
+		fmt.Fprintf(&sb, "\tx%d := a < b && c > d\n", i)
+	}
+	sb.WriteString("```\nDone.")
+	chunks := renderTelegramChunks(sb.String(), telegramMaxMessage)
+	checkChunks(t, chunks)
+	if len(chunks) < 3 {
+		t.Fatalf("got %d chunks, want the code block split", len(chunks))
+	}
+	for i, c := range chunks {
+		if !strings.Contains(c.html, "<pre>") && !strings.Contains(c.html, "Done.") {
+			t.Fatalf("chunk %d lost its code formatting:\n%s", i, c.html)
+		}
+	}
+}
+
+func TestMarkdownNestedList(t *testing.T) {
+	md := "- one\n  - nested **bold**\n  - nested two\n- two"
+	want := "• one\n" +
+		listIndent + "◦ nested <b>bold</b>\n" +
+		listIndent + "◦ nested two\n" +
+		"• two"
+	if got := markdownToTelegramHTML(md); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestMarkdownNumberedListWithSubBullets(t *testing.T) {
+	md := "1. First\n   - sub a\n   * sub b\n\n2. Second\n   - sub c\n3. Third"
+	want := "1. First\n" +
+		listIndent + "◦ sub a\n" +
+		listIndent + "◦ sub b\n" +
+		"\n2. Second\n" +
+		listIndent + "◦ sub c\n" +
+		"3. Third"
+	if got := markdownToTelegramHTML(md); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestMarkdownCommandReplies(t *testing.T) {
+	for md, want := range map[string]string{
+		"Temperature set to `0.7`. Next messages will use it.": "Temperature set to <code>0.7</code>. Next messages will use it.",
+		"Send /temp <0-2> to change it.":                       "Send /temp &lt;0-2&gt; to change it.",
+	} {
+		if got := markdownToTelegramHTML(md); got != want {
+			t.Errorf("markdownToTelegramHTML(%q) = %q, want %q", md, got, want)
+		}
+	}
+}
@@ -3,13 +3,21 @@
 package bot
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,11 +27,13 @@ import (
 	tu "github.com/mymmrac/telego/telegoutil"
 
 	"github.com/bigneek/picoflare/pkg/agent"
+	"github.com/bigneek/picoflare/pkg/agentctx"
 	cf "github.com/bigneek/picoflare/pkg/cloudflare"
+	"github.com/bigneek/picoflare/pkg/cognition"
 	"github.com/bigneek/picoflare/pkg/llm"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/reminder"
 	"github.com/bigneek/picoflare/pkg/storage"
-	"github.com/bigneek/picoflare/pkg/transcribe"
 )
 
 // spawnParallelPrompt is the prompt sent when user taps "Spawn 2 Subagents" or /spawn.
@@ -40,14 +50,36 @@ type customSpawnState struct {
 
 // Bot wraps the Telegram bot and the PicoFlare agent.
 type Bot struct {
-	tg            *telego.Bot
-	agent         *agent.Agent
-	openRouterKey string // For voice transcription via OpenRouter
+	tg          *telego.Bot
+	agent       *agent.Agent
+	transcriber *llm.Client // Voice transcription via OpenRouter; nil without an API key
 
 	customSpawnMu  sync.Mutex
 	customSpawnMap map[int64]*customSpawnState
 
 	runCancel context.CancelFunc // set in Run(); calling it triggers graceful shutdown (for /reboot)
+
+	reminders *reminder.Store // nil without R2
+
+	ownerChatID int64  // if set, only this chat may change tools and prompt patches, or /rebuild
+	workspace   string // source tree for /rebuild
+
+	modelChoices []string // models offered by the /model picker
+	visionModel  string   // model for /vision; empty = the chat's model
+
+	showReasoning bool // send reasoning models' thinking as a collapsed quote before replies
+
+	streamReplies bool // show replies in the placeholder as they stream (see liveReply)
+	liveMu        sync.Mutex
+	live          map[int64]*liveReply // by chat
+
+	eventToken       string // enables POST /event when set
+	eventListen      string // /event listen address in long-polling mode
+	eventChatID      int64  // session that handles inbound events
+	eventReplyChatID int64  // where event replies are posted; 0 = nowhere
+
+	workers   int // updates handled at once (see dispatch)
+	queueSize int // updates waiting for a worker before "busy" replies
 }
 
 // Config holds everything needed to start the bot.
@@ -57,12 +89,74 @@ type Config struct {
 	APIToken       string
 	R2AccessKey    string
 	R2SecretKey    string
+	R2Endpoint     string // S3-compatible endpoint override (MinIO, localstack); empty = R2
+	StorageBackend string // "r2" (default), "memory" (nothing persists), or "file"; the last two need no R2 credentials
+	StorageRoot    string // directory for the file backend; default "data"
 	R2Bucket       string
 	VectorizeIndex string
+	SkipBootstrap  bool   // Don't create R2Bucket / VectorizeIndex when missing
+	MCPEndpoint    string // MCP server URL; empty = mcpclient.DefaultEndpoint
+	MCPProtocol    string // MCP protocol version; empty = mcpclient.DefaultProtocolVersion
+	LLMProvider    string // "" or "openrouter" (default), "workers-ai"
 	LLMAPIKey      string
+	LLMBaseURL     string // OpenAI-compatible base URL (e.g. Ollama); empty = OpenRouter
 	LLMModel       string
+	LLMFallbacks   []string // Models tried in order when LLMModel is unavailable
 	Workspace      string
-	OpenAIApiKey   string // For voice note transcription (Whisper)
+	OpenAIApiKey   string   // For voice note transcription (Whisper)
+	EnabledTools   []string // Tool allowlist (names or "prefix*"); empty = all
+	DisabledTools  []string // Tool denylist (names or "prefix*")
+	PricingFile    string   // Optional JSON model pricing overrides
+	ModelChoices   []string // Models offered as /model buttons; empty = provider defaults
+	VisionModel    string   // Image-capable model for /vision; empty = the chat's model
+	SummaryModel   string   // Cheap model for conversation summaries; empty = the chat's model
+
+	// Reasoning models: ShowReasoning posts the model's thinking as a collapsed
+	// section before each reply; ExcludeReasoning asks OpenRouter not to return
+	// it. Without either, thinking is dropped from replies.
+	ShowReasoning    bool
+	ExcludeReasoning bool
+
+	// StreamReplies edits the "Thinking..." placeholder with the reply as it
+	// is generated, and with the tool being run.
+	StreamReplies bool
+
+	// PrivateMode puts every chat in private mode: no episodes, facts, or
+	// summaries are saved, and /private off is refused.
+	PrivateMode bool
+
+	// MemoryBudget sizes the memory section of the system prompt (zero = default).
+	MemoryBudget cognition.ContextBudget
+
+	// SubagentSampling overrides sampling parameters for subagent and spawn
+	// runs (zero = the chat's own).
+	SubagentSampling llm.Sampling
+
+	// SessionTTL is how long an idle chat's history is kept in memory
+	// (zero = agent.DefaultSessionTTL).
+	SessionTTL time.Duration
+
+	// StorageQuota caps each chat's R2 footprint in bytes (see
+	// agent.Config.StorageQuota). Zero = unlimited.
+	StorageQuota int64
+
+	// Budget alerts: DM OwnerChatID when daily/monthly spend (USD) crosses a limit. Zero = off.
+	DailyBudgetUSD   float64
+	MonthlyBudgetUSD float64
+	OwnerChatID      int64
+
+	// Inbound events: POST /event with EventToken runs the payload through the
+	// agent in the EventChatID session (default OwnerChatID) and posts the reply
+	// to EventReplyChatID if set. Empty EventToken = endpoint off.
+	EventToken       string
+	EventListen      string // listen address when not in webhook mode; default ":8080"
+	EventChatID      int64
+	EventReplyChatID int64
+
+	// Incoming updates run on Workers goroutines with up to QueueSize waiting;
+	// past that, senders are asked to retry. Zero = default (8 and 100).
+	Workers   int
+	QueueSize int
 }
 
 // New creates a new Bot from the given config.
@@ -73,28 +167,34 @@ func New(cfg Config) (*Bot, error) {
 	}
 
 	var mcp *mcpclient.Client
-	if cfg.APIToken != "" && cfg.AccountID != "" {
-		mcp = mcpclient.NewClient("https://mcp.cloudflare.com/mcp", cfg.APIToken, cfg.AccountID)
+	mcpEndpoint := cfg.MCPEndpoint
+	if mcpEndpoint == "" {
+		mcpEndpoint = mcpclient.DefaultEndpoint
+	}
+	if err := mcpclient.ValidateEndpoint(mcpEndpoint); err != nil {
+		log.Printf("MCP disabled: %v", err)
+	} else if cfg.APIToken != "" && cfg.AccountID != "" {
+		mcp = mcpclient.NewClient(mcpEndpoint, cfg.APIToken, cfg.AccountID)
+		mcp.ProtocolVersion = cfg.MCPProtocol
 		if err := mcp.Initialize(context.Background()); err != nil {
 			log.Printf("MCP unavailable (%v), using Cloudflare REST API when available", err)
 			mcp = nil
 		}
 	}
 
-	var r2 *storage.R2Client
-	if cfg.AccountID != "" && cfg.R2AccessKey != "" && cfg.R2SecretKey != "" {
-		r2Client, err := storage.NewR2Client(cfg.AccountID, cfg.R2AccessKey, cfg.R2SecretKey)
-		if err != nil {
-			log.Printf("R2 client init failed (non-fatal): %v", err)
-		} else {
-			r2 = r2Client
-		}
-	}
-
 	var llmClient *llm.Client
-	if cfg.LLMAPIKey != "" {
+	if cfg.LLMProvider == "workers-ai" {
+		llmClient = llm.NewWorkersAIClient(cfg.AccountID, cfg.APIToken, cfg.LLMModel)
+		log.Printf("LLM: Workers AI (%s)", llmClient.Model)
+	} else if cfg.LLMAPIKey != "" || cfg.LLMBaseURL != "" {
 		llmClient = llm.NewClient(cfg.LLMAPIKey, cfg.LLMModel)
-		log.Printf("LLM: OpenRouter (%s)", llmClient.Model)
+		llmClient.SetBaseURL(cfg.LLMBaseURL)
+		llmClient.ExcludeReasoning = cfg.ExcludeReasoning
+		log.Printf("LLM: %s (%s)", llmClient.Endpoint, llmClient.Model)
+	}
+	if llmClient != nil && len(cfg.LLMFallbacks) > 0 {
+		llmClient.Fallbacks = cfg.LLMFallbacks
+		log.Printf("LLM fallbacks: %s", strings.Join(cfg.LLMFallbacks, ", "))
 	}
 
 	var cfClient *cf.Client
@@ -116,21 +216,121 @@ func New(cfg Config) (*Bot, error) {
 		}
 	}
 
-	b := &Bot{tg: tg, agent: nil}
+	if cfClient != nil && !cfg.SkipBootstrap {
+		created, err := cfClient.EnsureResources(context.Background(), cfg.R2Bucket, cfg.VectorizeIndex)
+		if err != nil {
+			log.Printf("Bootstrap: %v", err)
+		}
+		for _, c := range created {
+			log.Printf("Bootstrap: created %s", c)
+		}
+	}
+
+	var r2 storage.ObjectStore
+	switch {
+	case cfg.StorageBackend == storage.BackendMemory:
+		r2 = storage.NewMemStore()
+		log.Printf("Storage: in memory (STORAGE_BACKEND=memory); nothing persists across restarts")
+	case cfg.StorageBackend == storage.BackendFile:
+		root := cfg.StorageRoot
+		if root == "" {
+			root = storage.DefaultFileRoot
+		}
+		fileStore, err := storage.NewFileStore(root)
+		if err != nil {
+			log.Printf("File storage disabled: %v", err)
+		} else {
+			r2 = fileStore
+			log.Printf("Storage: files under %s (STORAGE_BACKEND=file)", root)
+		}
+	case (cfg.AccountID != "" || cfg.R2Endpoint != "") && cfg.R2AccessKey != "" && cfg.R2SecretKey != "":
+		r2Client, err := storage.NewR2Client(cfg.AccountID, cfg.R2AccessKey, cfg.R2SecretKey, cfg.R2Endpoint)
+		if err != nil {
+			log.Printf("R2 client init failed (non-fatal): %v", err)
+		} else if err := r2Client.Verify(context.Background(), cfg.R2Bucket); err != nil {
+			log.Printf("R2 disabled: %v — memory, file uploads, and other R2 features are off", err)
+		} else {
+			r2 = r2Client
+		}
+	}
+
+	b := &Bot{tg: tg, agent: nil, streamReplies: cfg.StreamReplies, live: make(map[int64]*liveReply)}
+	var onDelta func(chatID int64, partial string)
+	if cfg.StreamReplies {
+		onDelta = b.onDelta
+	}
+	if r2 != nil {
+		b.reminders = reminder.NewStore(r2, cfg.R2Bucket)
+	}
 	ag := agent.New(agent.Config{
-		LLM:       llmClient,
-		MCP:       mcp,
-		R2:        r2,
-		CF:        cfClient,
-		Bucket:    cfg.R2Bucket,
-		AccountID: cfg.AccountID,
-		Workspace: cfg.Workspace,
+		LLM:            llmClient,
+		MCP:            mcp,
+		R2:             r2,
+		CF:             cfClient,
+		Bucket:         cfg.R2Bucket,
+		AccountID:      cfg.AccountID,
+		Workspace:      cfg.Workspace,
+		VectorizeIndex: cfg.VectorizeIndex,
 		OnSubagentComplete: func(chatID int64, result string) {
 			b.sendFormattedReply(context.Background(), tu.ID(chatID), result)
 		},
+		OnDelta:       onDelta,
+		PrivateMode:   cfg.PrivateMode,
+		EnabledTools:  cfg.EnabledTools,
+		DisabledTools: cfg.DisabledTools,
+		PricingFile:   cfg.PricingFile,
+		Reminders:     b.reminders,
+		MemoryBudget:  cfg.MemoryBudget,
+
+		SubagentSampling: cfg.SubagentSampling,
+		SessionTTL:       cfg.SessionTTL,
+		StorageQuota:     cfg.StorageQuota,
+		SummaryModel:     cfg.SummaryModel,
 	})
 	b.agent = ag
-	b.openRouterKey = cfg.LLMAPIKey
+	if ag.Ledger != nil && (cfg.DailyBudgetUSD > 0 || cfg.MonthlyBudgetUSD > 0) {
+		ag.Ledger.SetBudget(cognition.Budget{DailyUSD: cfg.DailyBudgetUSD, MonthlyUSD: cfg.MonthlyBudgetUSD}, func(msg string) {
+			log.Printf("Budget alert: %s", msg)
+			if cfg.OwnerChatID != 0 {
+				b.sendFormattedReply(context.Background(), tu.ID(cfg.OwnerChatID), "⚠️ "+msg)
+			}
+		})
+		if cfg.OwnerChatID == 0 {
+			log.Printf("Budget alerts: TELEGRAM_OWNER_ID not set, alerts will only be logged")
+		}
+	}
+	if cfg.LLMAPIKey != "" {
+		b.transcriber = llm.NewClient(cfg.LLMAPIKey, llm.TranscribeModel)
+	}
+	b.ownerChatID = cfg.OwnerChatID
+	b.workspace = cfg.Workspace
+	b.modelChoices = cfg.ModelChoices
+	b.visionModel = cfg.VisionModel
+	b.showReasoning = cfg.ShowReasoning
+	if len(b.modelChoices) == 0 {
+		b.modelChoices = defaultModelChoices
+		if cfg.LLMProvider == "workers-ai" {
+			b.modelChoices = defaultWorkersAIModelChoices
+		}
+	}
+	b.eventToken = cfg.EventToken
+	b.eventListen = cfg.EventListen
+	if b.eventListen == "" {
+		b.eventListen = ":8080"
+	}
+	b.eventChatID = cfg.EventChatID
+	if b.eventChatID == 0 {
+		b.eventChatID = cfg.OwnerChatID
+	}
+	b.eventReplyChatID = cfg.EventReplyChatID
+	b.workers = cfg.Workers
+	if b.workers <= 0 {
+		b.workers = defaultWorkers
+	}
+	b.queueSize = cfg.QueueSize
+	if b.queueSize <= 0 {
+		b.queueSize = defaultQueueSize
+	}
 	b.customSpawnMap = make(map[int64]*customSpawnState)
 	if cfg.LLMAPIKey != "" {
 		log.Printf("Voice notes: OpenRouter transcription enabled")
@@ -161,11 +361,30 @@ func (b *Bot) Run() error {
 			{Command: "go", Description: "Spawn your custom tasks"},
 			{Command: "cancel", Description: "Cancel custom spawn"},
 			{Command: "status", Description: "Show running subagents"},
+			{Command: "stop", Description: "Stop the message I'm working on and background tasks"},
 			{Command: "model", Description: "Set or show LLM model"},
+			{Command: "temp", Description: "Set or show sampling temperature"},
+			{Command: "memory", Description: "Show what I remember about this chat"},
+			{Command: "summary", Description: "Show the running summary of this chat"},
+			{Command: "private", Description: "Private mode: on, off, or show"},
+			{Command: "tools", Description: "List or disable self-created tools"},
+			{Command: "prompt", Description: "List or remove self-written prompt patches"},
 			{Command: "voicenote", Description: "Save a voice message as a note"},
+			{Command: "vision", Description: "Describe a photo (reply to it)"},
+			{Command: "audit", Description: "Audit the Cloudflare account"},
+			{Command: "rebuild", Description: "Rebuild from source and restart if it compiles"},
 		},
 	})
 
+	go b.agent.DeliverPendingSubagents()
+
+	if b.reminders != nil {
+		if err := b.reminders.Load(ctx); err != nil {
+			log.Printf("Reminders: load failed: %v", err)
+		}
+		go b.runReminders(ctx)
+	}
+
 	// Webhook mode: requires WebhookURL in config (set via TELEGRAM_WEBHOOK_URL)
 	webhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL")
 	webhookPath := os.Getenv("TELEGRAM_WEBHOOK_PATH")
@@ -177,21 +396,64 @@ func (b *Bot) Run() error {
 		webhookListen = ":8080"
 	}
 
+	d := b.newDispatch(ctx)
 	if webhookURL != "" {
-		return b.runWebhook(ctx, webhookURL, webhookPath, webhookListen)
+		return b.runWebhook(ctx, d, webhookURL, webhookPath, webhookListen)
+	}
+	if b.eventToken != "" {
+		go b.serveEvents(ctx, d, b.eventListen)
+	}
+	return b.runLongPolling(ctx, d)
+}
+
+// serveEvents runs a standalone /event server (long-polling mode has no
+// webhook server to share) until ctx is cancelled.
+func (b *Bot) serveEvents(ctx context.Context, d *dispatch, listenAddr string) {
+	mux := http.NewServeMux()
+	mux.Handle(eventPath, d.eventHandler())
+	srv := &http.Server{Addr: listenAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	log.Printf("Event endpoint listening on %s%s", listenAddr, eventPath)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Event server error: %v", err)
 	}
-	return b.runLongPolling(ctx)
 }
 
-func (b *Bot) runLongPolling(ctx context.Context) error {
+// reminderPollInterval is how often due reminders are checked.
+const reminderPollInterval = 30 * time.Second
+
+// runReminders delivers due reminders until ctx is cancelled. A reminder is
+// removed once sent, so a restart resends at most the ones not yet delivered.
+func (b *Bot) runReminders(ctx context.Context) {
+	ticker := time.NewTicker(reminderPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, r := range b.reminders.Due(b.reminders.Now()) {
+				b.sendFormattedReply(ctx, tu.ID(r.ChatID), "⏰ **Reminder**: "+r.Text)
+				if err := b.reminders.Remove(ctx, r.ID); err != nil {
+					log.Printf("Reminders: remove %s: %v", r.ID, err)
+				}
+			}
+		}
+	}
+}
+
+func (b *Bot) runLongPolling(ctx context.Context, d *dispatch) error {
 	updates, err := b.tg.UpdatesViaLongPolling(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("long polling: %w", err)
 	}
-	return b.processUpdates(ctx, updates)
+	return b.processUpdates(ctx, d, updates)
 }
 
-func (b *Bot) runWebhook(ctx context.Context, webhookURL, path, listenAddr string) error {
+func (b *Bot) runWebhook(ctx context.Context, d *dispatch, webhookURL, path, listenAddr string) error {
 	mux := http.NewServeMux()
 	secretToken := b.tg.SecretToken()
 	updates, err := b.tg.UpdatesViaWebhook(ctx,
@@ -206,7 +468,12 @@ func (b *Bot) runWebhook(ctx context.Context, webhookURL, path, listenAddr strin
 	}
 	log.Printf("Webhook set: %s (path %s)", webhookURL, path)
 
-	srv := &http.Server{Addr: listenAddr, Handler: mux}
+	if b.eventToken != "" {
+		mux.Handle(eventPath, d.eventHandler())
+		log.Printf("Event endpoint enabled at %s", eventPath)
+	}
+
+	srv := &http.Server{Addr: listenAddr, Handler: webhookGuard(mux, path, secretToken, maxWebhookBodyBytes)}
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Printf("Webhook server error: %v", err)
@@ -215,10 +482,48 @@ func (b *Bot) runWebhook(ctx context.Context, webhookURL, path, listenAddr strin
 	defer func() { _ = srv.Shutdown(context.Background()) }()
 
 	log.Printf("Webhook server listening on %s", listenAddr)
-	return b.processUpdates(ctx, updates)
+	return b.processUpdates(ctx, d, updates)
+}
+
+// maxWebhookBodyBytes caps webhook request bodies. Telegram updates are a few KB;
+// files are fetched separately via getFile.
+const maxWebhookBodyBytes = 1 << 20
+
+// webhookGuard rejects webhook requests without the expected
+// X-Telegram-Bot-Api-Secret-Token (401) or with bodies over maxBytes (413)
+// before they reach the update handler.
+func webhookGuard(next http.Handler, path, secret string, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			log.Printf("Webhook: rejected request from %s (bad secret token)", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.ContentLength > maxBytes {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
 }
 
-func (b *Bot) processUpdates(ctx context.Context, updates <-chan telego.Update) error {
+func (b *Bot) processUpdates(ctx context.Context, d *dispatch, updates <-chan telego.Update) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -228,12 +533,7 @@ func (b *Bot) processUpdates(ctx context.Context, updates <-chan telego.Update)
 			if !ok {
 				return nil
 			}
-			if update.Message != nil {
-				go b.handleMessage(ctx, update.Message)
-			}
-			if update.CallbackQuery != nil {
-				go b.handleCallbackQuery(ctx, update.CallbackQuery)
-			}
+			d.submit(ctx, update)
 		}
 	}
 }
@@ -280,6 +580,14 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		return
 	}
 
+	// /stop: cancel the message the agent is working on in this chat
+	if text == "/stop" {
+		if !b.agent.Stop(msg.Chat.ID) {
+			b.sendFormattedReply(ctx, msg.Chat.ChatID(), "Nothing to stop.")
+		}
+		return
+	}
+
 	// /status: show running subagents
 	if text == "/status" {
 		b.sendStatus(ctx, msg.Chat.ID, msg.Chat.ChatID())
@@ -292,6 +600,48 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		return
 	}
 
+	// /temp: set or show the sampling temperature for this chat
+	if text == "/temp" || strings.HasPrefix(text, "/temp ") {
+		b.handleTemp(ctx, msg.Chat.ID, msg.Chat.ChatID(), strings.TrimSpace(strings.TrimPrefix(text, "/temp")))
+		return
+	}
+
+	// /cost: show this chat's LLM spend
+	if text == "/cost" {
+		b.sendCost(ctx, msg.Chat.ID, msg.Chat.ChatID())
+		return
+	}
+
+	// /private: stop (or resume) saving anything from this chat's turns
+	if text == "/private" || strings.HasPrefix(text, "/private ") {
+		b.handlePrivate(ctx, msg.Chat.ID, msg.Chat.ChatID(), strings.TrimSpace(strings.TrimPrefix(text, "/private")))
+		return
+	}
+
+	// /summary: show the rolling conversation summary
+	if text == "/summary" {
+		b.sendSummary(ctx, msg.Chat.ID, msg.Chat.ChatID())
+		return
+	}
+
+	// /memory: show what the agent has stored for this chat
+	if text == "/memory" || strings.HasPrefix(text, "/memory ") {
+		b.sendMemory(ctx, msg.Chat.ID, msg.Chat.ChatID(), strings.TrimSpace(strings.TrimPrefix(text, "/memory")))
+		return
+	}
+
+	// /tools: list or disable self-created tools
+	if text == "/tools" || strings.HasPrefix(text, "/tools ") {
+		b.handleTools(ctx, msg.Chat.ID, msg.Chat.ChatID(), strings.TrimSpace(strings.TrimPrefix(text, "/tools")))
+		return
+	}
+
+	// /prompt: list or remove self-written prompt patches
+	if text == "/prompt" || strings.HasPrefix(text, "/prompt ") {
+		b.handlePrompt(ctx, msg.Chat.ID, msg.Chat.ChatID(), strings.TrimSpace(strings.TrimPrefix(text, "/prompt")))
+		return
+	}
+
 	// /createagent: create a new agent/skill for later use
 	if text == "/createagent" || strings.HasPrefix(text, "/createagent ") {
 		b.handleCreateAgent(ctx, msg.Chat.ID, msg.Chat.ChatID(), msg.From, strings.TrimSpace(strings.TrimPrefix(text, "/createagent")))
@@ -304,6 +654,22 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		return
 	}
 
+	// /vision: describe a photo (reply to it, or send it captioned /vision)
+	if text == "/vision" || strings.HasPrefix(text, "/vision ") {
+		target := msg.ReplyToMessage
+		if msg.Photo != nil || msg.Document != nil {
+			target = msg
+		}
+		b.handleVision(ctx, msg.Chat.ID, msg.Chat.ChatID(), msg.From, target, strings.TrimSpace(strings.TrimPrefix(text, "/vision")))
+		return
+	}
+
+	// /rebuild: go build, then restart only if the build succeeded
+	if text == "/rebuild" {
+		b.handleRebuild(ctx, msg.Chat.ID, msg.Chat.ChatID())
+		return
+	}
+
 	// /reboot: trigger graceful shutdown so systemd/supervisor can restart the bot
 	if text == "/reboot" {
 		b.handleReboot(ctx, msg.Chat.ChatID())
@@ -331,6 +697,16 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		return
 	}
 
+	// /audit: review the Cloudflare account, always starting from a fresh inventory
+	if text == "/audit" || strings.HasPrefix(text, "/audit ") {
+		focus := strings.TrimSpace(strings.TrimPrefix(text, "/audit"))
+		ctx = agent.WithForcedTool(ctx, "cf_inventory")
+		text = "Audit my Cloudflare account: take an inventory, then flag unused, misconfigured, or costly resources and suggest cleanups. Don't change anything."
+		if focus != "" {
+			text += " Focus: " + focus
+		}
+	}
+
 	// Handle voice messages: transcribe via OpenRouter (single download)
 	if msg.Voice != nil {
 		voiceText := b.handleVoiceMessage(ctx, msg)
@@ -343,7 +719,9 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		}
 	} else {
 		// Handle other file uploads: download, upload to R2, tell the agent
-		fileDesc := b.handleFileUpload(ctx, msg)
+		// (and show it photos)
+		fileDesc, images := b.handleFileUpload(ctx, msg)
+		ctx = agent.WithImages(ctx, images)
 		if fileDesc != "" {
 			if text != "" {
 				text = text + "\n" + fileDesc
@@ -376,7 +754,12 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 	}
 	userCtx += "] " + text
 
-	reply := b.agent.ProcessMessage(ctx, msg.Chat.ID, userCtx)
+	stopLive := func() {}
+	if thinkMsg != nil {
+		stopLive = b.startLive(ctx, msg.Chat.ID, msg.Chat.ChatID(), thinkMsg.MessageID)
+	}
+	reply := b.agent.ProcessMessage(b.ownerContext(ctx, msg.Chat.ID), msg.Chat.ID, userCtx)
+	stopLive()
 	stopTyping()
 
 	if reply == "" {
@@ -391,6 +774,7 @@ func (b *Bot) handleMessage(ctx context.Context, msg *telego.Message) {
 		})
 	}
 
+	b.sendReasoning(ctx, msg.Chat.ID, msg.Chat.ChatID())
 	b.sendFormattedReply(ctx, msg.Chat.ChatID(), reply)
 }
 
@@ -432,7 +816,13 @@ func (b *Bot) handleCallbackQuery(ctx context.Context, q *telego.CallbackQuery)
 	case "custom_spawn_cancel":
 		b.cancelCustomSpawn(chat.ID)
 		b.sendFormattedReply(ctx, chatID, "Cancelled.")
+	case "model_show", "model_default":
+		b.handleModelCallback(ctx, chat.ID, chatID, q.Data)
 	default:
+		if strings.HasPrefix(q.Data, "model_set:") {
+			b.handleModelCallback(ctx, chat.ID, chatID, q.Data)
+			return
+		}
 		// Unknown callback, ignore
 	}
 }
@@ -504,6 +894,8 @@ func (b *Bot) sendStatus(ctx context.Context, chatIDInt int64, chatID telego.Cha
 			running++
 		} else if t.Status == "failed" {
 			icon = "❌"
+		} else if t.Status == "stopped" {
+			icon = "⏹"
 		}
 		label := t.Label
 		if label == "" {
@@ -517,6 +909,246 @@ func (b *Bot) sendStatus(ctx context.Context, chatIDInt int64, chatID telego.Cha
 	b.sendFormattedReply(ctx, chatID, sb.String())
 }
 
+// sendCost reports the token usage and estimated cost for this chat.
+func (b *Bot) sendCost(ctx context.Context, chatIDInt int64, chatID telego.ChatID) {
+	if b.agent.Ledger == nil {
+		b.sendFormattedReply(ctx, chatID, "Cost tracking is disabled (requires R2).")
+		return
+	}
+	agentID := agentctx.FormatAgentID(chatIDInt)
+	b.agent.Ledger.LoadChat(ctx, agentID)
+	cs := b.agent.Ledger.ChatStats(agentID)
+	if cs == nil || cs.Messages == 0 {
+		b.sendFormattedReply(ctx, chatID, "No spend recorded for this chat yet.")
+		return
+	}
+	today := cs.ByDay[time.Now().Format("20060102")]
+
+	var sb strings.Builder
+	sb.WriteString("💰 **Chat cost**\n\n")
+	sb.WriteString(fmt.Sprintf("Today: **$%.4f**\n", today))
+	sb.WriteString(fmt.Sprintf("Total: **$%.4f** since %s\n", cs.CostUSD, cs.FirstSeen.Format("2006-01-02")))
+	sb.WriteString(fmt.Sprintf("Tokens: %d in / %d out\n", cs.PromptTokens, cs.CompletionTokens))
+	sb.WriteString(fmt.Sprintf("Messages: %d | Tool calls: %d", cs.Messages, cs.ToolCalls))
+	b.sendFormattedReply(ctx, chatID, sb.String())
+}
+
+// handlePrivate handles /private [on|off]. In private mode the agent writes
+// no episodes, facts, or summary for the chat.
+func (b *Bot) handlePrivate(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
+	switch strings.ToLower(arg) {
+	case "":
+		if b.agent.IsPrivate(ctx, chatIDInt) {
+			b.sendFormattedReply(ctx, chatID, "🔒 Private mode is **on**: nothing from this chat is saved to memory. `/private off` to turn it off.")
+		} else {
+			b.sendFormattedReply(ctx, chatID, "Private mode is **off**. `/private on` to stop saving episodes, facts, and summaries from this chat.")
+		}
+		return
+	case "on", "off":
+	default:
+		b.sendFormattedReply(ctx, chatID, "Usage: `/private on` or `/private off`")
+		return
+	}
+	on := strings.EqualFold(arg, "on")
+	if err := b.agent.SetPrivate(ctx, chatIDInt, on); err != nil {
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't change private mode: %v", err))
+		return
+	}
+	b.agent.ForceRefreshSession(ctx, chatIDInt)
+	if on {
+		b.sendFormattedReply(ctx, chatID, "🔒 Private mode on. From now on nothing from this chat is written to memory (episodes, facts, summary). Files you send are still stored.")
+	} else {
+		b.sendFormattedReply(ctx, chatID, "🔓 Private mode off. I'll remember this chat again from here on.")
+	}
+}
+
+// sendSummary handles /summary: the agent's rolling summary of this chat.
+func (b *Bot) sendSummary(ctx context.Context, chatIDInt int64, chatID telego.ChatID) {
+	s := b.agent.Summary(ctx, chatIDInt)
+	if s == nil {
+		b.sendFormattedReply(ctx, chatID, "No summary yet. One is written every few messages once we've talked a while.")
+		return
+	}
+	b.sendFormattedReply(ctx, chatID, fmt.Sprintf("📝 **Conversation summary** (%d turns, updated %s)\n\n%s",
+		s.Turns, s.UpdatedAt.Format("2006-01-02 15:04"), s.Text))
+}
+
+// memoryViewBudget is larger than the system-prompt budget: /memory is for
+// humans reviewing what is stored, not for the model.
+var memoryViewBudget = cognition.ContextBudget{
+	MaxTotalChars: 20000,
+	EpisodicPct:   20,
+	SemanticPct:   50,
+	ProceduralPct: 30,
+}
+
+// sendMemory handles /memory [page]. Shows stored facts, recent activity,
+// procedures, and goals for this chat, one page at a time.
+func (b *Bot) sendMemory(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
+	if b.agent.Memory == nil {
+		b.sendFormattedReply(ctx, chatID, "Memory is disabled (requires R2).")
+		return
+	}
+	ctx = agentctx.WithAgentID(ctx, agentctx.FormatAgentID(chatIDInt))
+
+	var sb strings.Builder
+	sb.WriteString(b.agent.Memory.BuildContext(ctx, memoryViewBudget, ""))
+	if b.agent.Meta != nil {
+		if meta := b.agent.Meta.BuildMetaContext(ctx); meta != "" {
+			sb.WriteString("\n")
+			sb.WriteString(meta)
+		}
+	}
+
+	pages := splitMarkdownChunks(strings.TrimSpace(sb.String()), 3000)
+	page := 1
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(pages) {
+			b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Usage: /memory [page] (1-%d)", len(pages)))
+			return
+		}
+		page = n
+	}
+
+	text := "🧠 **Memory**\n\n" + pages[page-1]
+	if len(pages) > 1 {
+		text += fmt.Sprintf("\n\nPage %d/%d", page, len(pages))
+		if page < len(pages) {
+			text += fmt.Sprintf(" — /memory %d for more", page+1)
+		}
+	}
+	b.sendFormattedReply(ctx, chatID, text)
+}
+
+// canModify reports whether a chat may change the agent's self-modifications.
+// Without TELEGRAM_OWNER_ID every chat can.
+func (b *Bot) canModify(chatIDInt int64) bool {
+	return b.ownerChatID == 0 || chatIDInt == b.ownerChatID
+}
+
+// ownerContext marks a turn in the TELEGRAM_OWNER_ID chat as the operator's
+// (agent.WithOwner). Without an owner configured, no chat is.
+func (b *Bot) ownerContext(ctx context.Context, chatIDInt int64) context.Context {
+	if b.ownerChatID != 0 && chatIDInt == b.ownerChatID {
+		return agent.WithOwner(ctx)
+	}
+	return ctx
+}
+
+// handleTools handles /tools and /tools disable <name>.
+func (b *Bot) handleTools(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
+	registry := b.agent.Registry
+	if registry == nil {
+		b.sendFormattedReply(ctx, chatID, "Dynamic tools are disabled (requires R2).")
+		return
+	}
+
+	if arg != "" {
+		name, ok := strings.CutPrefix(arg, "disable ")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			b.sendFormattedReply(ctx, chatID, "Usage: /tools or /tools disable <name>")
+			return
+		}
+		if !b.canModify(chatIDInt) {
+			b.sendFormattedReply(ctx, chatID, "Only the owner can change tools.")
+			return
+		}
+		if err := registry.RemoveTool(ctx, name); err != nil {
+			b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't disable %s: %v", name, err))
+			return
+		}
+		b.agent.RefreshTools()
+		b.agent.RefreshAllSessions(ctx)
+		log.Printf("Operator disabled dynamic tool %s (chat %d)", name, chatIDInt)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("⛔ Disabled tool **%s**.", name))
+		return
+	}
+
+	tools, err := registry.LoadTools(ctx)
+	if err != nil {
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't load tools: %v", err))
+		return
+	}
+	if len(tools) == 0 {
+		b.sendFormattedReply(ctx, chatID, "No self-created tools.")
+		return
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🛠 **Dynamic tools** (%d)\n\n", len(tools)))
+	for _, t := range tools {
+		state := "✅"
+		if !t.Enabled {
+			state = "⛔"
+		}
+		sb.WriteString(fmt.Sprintf("%s **%s** (%s, used %dx) — %s\n", state, t.Name, t.Type, t.Uses, truncateRunes(t.Description, 80)))
+	}
+	sb.WriteString("\nDisable one with /tools disable <name>")
+	b.sendFormattedReply(ctx, chatID, sb.String())
+}
+
+// handlePrompt handles /prompt list and /prompt remove <name>.
+func (b *Bot) handlePrompt(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
+	registry := b.agent.Registry
+	if registry == nil {
+		b.sendFormattedReply(ctx, chatID, "Prompt patches are disabled (requires R2).")
+		return
+	}
+
+	if name, ok := strings.CutPrefix(arg, "remove "); ok {
+		name = strings.TrimSpace(name)
+		if !b.canModify(chatIDInt) {
+			b.sendFormattedReply(ctx, chatID, "Only the owner can change prompt patches.")
+			return
+		}
+		if err := registry.RemovePromptPatch(ctx, name); err != nil {
+			b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't remove %s: %v", name, err))
+			return
+		}
+		b.agent.RefreshAllSessions(ctx)
+		log.Printf("Operator removed prompt patch %s (chat %d)", name, chatIDInt)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("⛔ Removed prompt patch **%s**.", name))
+		return
+	}
+	if arg != "" && arg != "list" {
+		b.sendFormattedReply(ctx, chatID, "Usage: /prompt list or /prompt remove <name>")
+		return
+	}
+
+	patches, err := registry.LoadPromptPatches(ctx)
+	if err != nil {
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't load prompt patches: %v", err))
+		return
+	}
+	if len(patches) == 0 {
+		b.sendFormattedReply(ctx, chatID, "No prompt patches.")
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 **Prompt patches** (%d)\n\n", len(patches)))
+	for _, p := range patches {
+		state := "✅"
+		if !p.Enabled {
+			state = "⛔"
+		}
+		sb.WriteString(fmt.Sprintf("%s **%s** (priority %d, updated %s) — %s\n", state, p.Name, p.Priority, p.UpdatedAt.Format("2006-01-02"), truncateRunes(p.Content, 100)))
+	}
+	sb.WriteString("\nRemove one with /prompt remove <name>")
+	b.sendFormattedReply(ctx, chatID, sb.String())
+}
+
+// truncateRunes shortens s to n runes on a single line.
+func truncateRunes(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
 // handleCreateAgent handles /createagent [description]. Creates a new skill via the agent.
 func (b *Bot) handleCreateAgent(ctx context.Context, chatIDInt int64, chatID telego.ChatID, from *telego.User, desc string) {
 	if desc == "" {
@@ -545,7 +1177,7 @@ func (b *Bot) handleVoiceNote(ctx context.Context, chatID telego.ChatID, from *t
 		return
 	}
 	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.tg.Token(), file.FilePath)
-	resp, err := http.Get(fileURL)
+	resp, err := httpGet(ctx, fileURL)
 	if err != nil {
 		log.Printf("voicenote download failed: %v", err)
 		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't download: %v", err))
@@ -560,10 +1192,11 @@ func (b *Bot) handleVoiceNote(ctx context.Context, chatID telego.ChatID, from *t
 
 	ts := time.Now().Format("20060102_150405")
 	userID := from.ID
+	private := b.agent.IsPrivate(ctx, chatID.ID) // transcribe only, store nothing
 
 	// Store audio in R2
 	audioKey := fmt.Sprintf("users/%d/files/voice_%s.ogg", userID, ts)
-	if b.agent.R2 != nil {
+	if b.agent.R2 != nil && !private {
 		if err := b.agent.R2.UploadObject(ctx, b.agent.Bucket, audioKey, data); err != nil {
 			log.Printf("voicenote R2 upload failed: %v", err)
 		}
@@ -571,8 +1204,8 @@ func (b *Bot) handleVoiceNote(ctx context.Context, chatID telego.ChatID, from *t
 
 	// Transcribe
 	var transcript string
-	if b.openRouterKey != "" {
-		text, err := transcribe.Transcribe(ctx, b.openRouterKey, data, "ogg")
+	if b.transcriber != nil {
+		text, err := b.transcriber.TranscribeAudio(ctx, data, "ogg")
 		if err != nil {
 			log.Printf("voicenote transcribe failed: %v", err)
 			transcript = "(transcription failed)"
@@ -585,12 +1218,16 @@ func (b *Bot) handleVoiceNote(ctx context.Context, chatID telego.ChatID, from *t
 
 	// Store transcript in R2 under notes/
 	noteKey := fmt.Sprintf("users/%d/notes/voice_%s.txt", userID, ts)
-	if b.agent.R2 != nil && transcript != "" {
+	if b.agent.R2 != nil && transcript != "" && !private {
 		noteBody := []byte(transcript)
 		_ = b.agent.R2.UploadObject(ctx, b.agent.Bucket, noteKey, noteBody)
 	}
 
 	preview := transcript
+	if private {
+		b.sendFormattedReply(ctx, chatID, "🔒 Private mode is on, so the voice note wasn't saved. Transcript:\n\n"+preview)
+		return
+	}
 	if len(preview) > 200 {
 		preview = preview[:200] + "..."
 	}
@@ -605,6 +1242,32 @@ func (b *Bot) handleReboot(ctx context.Context, chatID telego.ChatID) {
 	}
 }
 
+// handleRebuild handles /rebuild. Rebuilds the binary from the workspace and
+// restarts only on success, so a broken build can't cause a crash loop.
+func (b *Bot) handleRebuild(ctx context.Context, chatIDInt int64, chatID telego.ChatID) {
+	if b.workspace == "" {
+		b.sendFormattedReply(ctx, chatID, "No workspace configured; can't rebuild.")
+		return
+	}
+	if !b.canModify(chatIDInt) {
+		b.sendFormattedReply(ctx, chatID, "Only the owner can rebuild.")
+		return
+	}
+	b.sendFormattedReply(ctx, chatID, "🔨 Building...")
+	output, err := agent.RebuildSelf(ctx, b.workspace)
+	if err != nil {
+		output = strings.TrimSpace(output)
+		if len(output) > 3000 {
+			output = "..." + output[len(output)-3000:]
+		}
+		log.Printf("Rebuild failed: %v", err)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("❌ **Build failed** (%v), still running the old binary.\n\n```\n%s\n```", err, output))
+		return
+	}
+	log.Printf("Rebuild succeeded, restarting (chat %d)", chatIDInt)
+	b.handleReboot(ctx, chatID)
+}
+
 // handleModel handles /model [model_id|default]. Empty = show current.
 func (b *Bot) handleModel(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
 	if b.agent.LLM == nil {
@@ -612,9 +1275,7 @@ func (b *Bot) handleModel(ctx context.Context, chatIDInt int64, chatID telego.Ch
 		return
 	}
 	if arg == "" {
-		// Show current model
-		model := b.agent.GetModel(chatIDInt)
-		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("🤖 <b>Model</b>: <code>%s</code>\n\nUse /model &lt;id&gt; to change, /model default to reset.", model))
+		b.sendModelPicker(ctx, chatIDInt, chatID)
 		return
 	}
 	if strings.EqualFold(arg, "default") {
@@ -627,6 +1288,102 @@ func (b *Bot) handleModel(ctx context.Context, chatIDInt int64, chatID telego.Ch
 	b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Model set to <code>%s</code>. Next messages will use this model.", arg))
 }
 
+// maxTemperature is the highest temperature /temp accepts (OpenRouter's range).
+const maxTemperature = 2.0
+
+// handleTemp handles /temp [0-2|default]. Empty = show current.
+func (b *Bot) handleTemp(ctx context.Context, chatIDInt int64, chatID telego.ChatID, arg string) {
+	if b.agent.LLM == nil {
+		b.sendFormattedReply(ctx, chatID, "LLM not configured.")
+		return
+	}
+	switch {
+	case arg == "":
+		if t := b.agent.GetTemperature(chatIDInt); t != nil {
+			b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Temperature: `%g`. Send /temp default to reset.", *t))
+		} else {
+			b.sendFormattedReply(ctx, chatID, "Temperature: the model's default. Send /temp <0-2> to change it (0 = most deterministic).")
+		}
+		return
+	case strings.EqualFold(arg, "default"):
+		b.agent.SetTemperature(chatIDInt, nil)
+		b.sendFormattedReply(ctx, chatID, "Temperature reset to the model's default.")
+		return
+	}
+	t, err := strconv.ParseFloat(arg, 64)
+	if err != nil || t < 0 || t > maxTemperature {
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Temperature must be a number from 0 to %g, or default.", maxTemperature))
+		return
+	}
+	b.agent.SetTemperature(chatIDInt, &t)
+	b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Temperature set to `%g`. Next messages will use it.", t))
+}
+
+// defaultModelChoices are offered by the /model picker when MODEL_CHOICES is unset.
+var defaultModelChoices = []string{
+	"moonshotai/kimi-k2.5",
+	"anthropic/claude-sonnet-4",
+	"openai/gpt-4o",
+	"openai/gpt-4o-mini",
+	"google/gemini-2.5-flash",
+	"deepseek/deepseek-chat",
+}
+
+var defaultWorkersAIModelChoices = []string{
+	"@cf/meta/llama-3.3-70b-instruct-fp8-fast",
+	"@cf/meta/llama-4-scout-17b-16e-instruct",
+	"@cf/mistralai/mistral-small-3.1-24b-instruct",
+	"@cf/qwen/qwq-32b",
+}
+
+// sendModelPicker shows the chat's model with one button per model choice.
+// Buttons carry the choice's index, since model IDs can exceed Telegram's
+// 64-byte callback data limit.
+func (b *Bot) sendModelPicker(ctx context.Context, chatIDInt int64, chatID telego.ChatID) {
+	current := b.agent.GetModel(chatIDInt)
+	var rows [][]telego.InlineKeyboardButton
+	for i, m := range b.modelChoices {
+		label := m
+		if m == current {
+			label = "✓ " + m
+		}
+		rows = append(rows, tu.InlineKeyboardRow(tu.InlineKeyboardButton(label).WithCallbackData(fmt.Sprintf("model_set:%d", i))))
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton("ℹ️ Show current").WithCallbackData("model_show"),
+		tu.InlineKeyboardButton("↩️ Default").WithCallbackData("model_default"),
+	))
+
+	text := fmt.Sprintf("🤖 <b>Model</b>: <code>%s</code>\n\nTap a model to switch, or send /model &lt;id&gt; for any other.", escapeHTML(current))
+	params := tu.Message(chatID, text).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(rows...))
+	if _, err := b.tg.SendMessage(ctx, params); err != nil {
+		log.Printf("Send model picker failed: %v", err)
+	}
+}
+
+// handleModelCallback applies a /model picker button.
+func (b *Bot) handleModelCallback(ctx context.Context, chatIDInt int64, chatID telego.ChatID, data string) {
+	if b.agent.LLM == nil {
+		b.sendFormattedReply(ctx, chatID, "LLM not configured.")
+		return
+	}
+	switch {
+	case data == "model_show":
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("🤖 **Model**: `%s`", b.agent.GetModel(chatIDInt)))
+	case data == "model_default":
+		b.agent.SetModel(chatIDInt, "")
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Model reset to default: `%s`", b.agent.GetModel(chatIDInt)))
+	case strings.HasPrefix(data, "model_set:"):
+		i, err := strconv.Atoi(strings.TrimPrefix(data, "model_set:"))
+		if err != nil || i < 0 || i >= len(b.modelChoices) {
+			b.sendFormattedReply(ctx, chatID, "That model list is out of date. Send /model to get a fresh one.")
+			return
+		}
+		b.agent.SetModel(chatIDInt, b.modelChoices[i])
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Model set to `%s`. Next messages will use this model.", b.modelChoices[i]))
+	}
+}
+
 // getAndSetCustomTasks stores the user's message as tasks and returns true if we handled it.
 // If state already has tasks, new lines are appended (add more).
 func (b *Bot) getAndSetCustomTasks(chatIDInt int64, text string) (handled bool, tasks []string) {
@@ -671,7 +1428,12 @@ func (b *Bot) processAgentPrompt(ctx context.Context, chatIDInt int64, chatID te
 	typingCtx, stopTyping := context.WithCancel(ctx)
 	go b.keepTyping(typingCtx, chatID)
 
-	reply := b.agent.ProcessMessage(ctx, chatIDInt, userCtx)
+	stopLive := func() {}
+	if thinkMsg != nil {
+		stopLive = b.startLive(ctx, chatIDInt, chatID, thinkMsg.MessageID)
+	}
+	reply := b.agent.ProcessMessage(b.ownerContext(ctx, chatIDInt), chatIDInt, userCtx)
+	stopLive()
 	stopTyping()
 
 	if thinkMsg != nil {
@@ -682,9 +1444,30 @@ func (b *Bot) processAgentPrompt(ctx context.Context, chatIDInt int64, chatID te
 	if reply == "" {
 		reply = "(no response)"
 	}
+	b.sendReasoning(ctx, chatIDInt, chatID)
 	b.sendFormattedReply(ctx, chatID, reply)
 }
 
+// maxReasoningChars bounds the thinking shown before a reply; Telegram
+// messages are capped at 4096 characters.
+const maxReasoningChars = 3500
+
+// sendReasoning posts the model's thinking behind the latest reply as a
+// collapsed quote, when ShowReasoning is on and the model reported any.
+func (b *Bot) sendReasoning(ctx context.Context, chatIDInt int64, chatID telego.ChatID) {
+	thinking := b.agent.TakeReasoning(chatIDInt)
+	if !b.showReasoning || thinking == "" {
+		return
+	}
+	if r := []rune(thinking); len(r) > maxReasoningChars {
+		thinking = "…" + string(r[len(r)-maxReasoningChars:])
+	}
+	html := "<blockquote expandable>💭 <b>Thinking</b>\n" + escapeHTML(thinking) + "</blockquote>"
+	if _, err := b.tg.SendMessage(ctx, tu.Message(chatID, html).WithParseMode(telego.ModeHTML)); err != nil {
+		log.Printf("Reasoning send failed: %v", err)
+	}
+}
+
 // sendFormattedReply splits a reply into code-block-aware chunks, converts each
 // to Telegram HTML, and falls back to plain text if Telegram rejects the HTML.
 func (b *Bot) sendFormattedReply(ctx context.Context, chatID telego.ChatID, reply string) {
@@ -692,38 +1475,45 @@ func (b *Bot) sendFormattedReply(ctx context.Context, chatID telego.ChatID, repl
 	if reply == "" {
 		reply = "(no response)"
 	}
-	chunks := splitMarkdownChunks(reply, 3500)
+	chunks := renderTelegramChunks(reply, telegramMaxMessage)
 	if len(chunks) == 0 {
 		b.sendPlainChunks(ctx, chatID, "(no response)")
 		return
 	}
 
 	for _, chunk := range chunks {
-		chunk = strings.TrimSpace(chunk)
-		if chunk == "" {
-			continue
-		}
-		html := markdownToTelegramHTML(chunk)
-		if strings.TrimSpace(html) == "" {
-			b.sendPlainChunks(ctx, chatID, chunk)
+		if chunk.html == "" {
+			log.Printf("Chunk can't be sent as HTML (%d chars), sending plain text", len(chunk.md))
+			b.sendPlainChunks(ctx, chatID, chunk.md)
 			continue
 		}
-
-		if len(html) > 4096 {
-			log.Printf("HTML chunk too long (%d), sending plain text", len(html))
-			b.sendPlainChunks(ctx, chatID, chunk)
-			continue
-		}
-
-		params := tu.Message(chatID, html).WithParseMode(telego.ModeHTML)
+		params := tu.Message(chatID, chunk.html).WithParseMode(telego.ModeHTML)
 		_, err := b.tg.SendMessage(ctx, params)
 		if err != nil {
 			log.Printf("HTML send failed (%v), falling back to plain text", err)
-			b.sendPlainChunks(ctx, chatID, chunk)
+			b.sendPlainChunks(ctx, chatID, chunk.md)
 		}
 	}
 }
 
+// httpGet is http.Get bound to ctx, so downloads stop when the message is
+// cancelled. Errors omit the URL, which contains the bot token.
+func httpGet(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.New("bad download URL")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (b *Bot) sendPlainChunks(ctx context.Context, chatID telego.ChatID, text string) {
 	for len(text) > 0 {
 		chunk := text
@@ -769,7 +1559,9 @@ func (b *Bot) keepTyping(ctx context.Context, chatID telego.ChatID) {
 
 // handleFileUpload detects file attachments, downloads them from Telegram,
 // uploads to the user's R2 space, and returns a description for the agent.
-func (b *Bot) handleFileUpload(ctx context.Context, msg *telego.Message) string {
+// Photos (and image documents) are also returned as an image part, for a
+// vision model to look at.
+func (b *Bot) handleFileUpload(ctx context.Context, msg *telego.Message) (string, []llm.ContentPart) {
 	var fileID, fileName, fileType string
 
 	switch {
@@ -809,55 +1601,110 @@ func (b *Bot) handleFileUpload(ctx context.Context, msg *telego.Message) string
 		fileName = fmt.Sprintf("sticker_%d.webp", msg.Date)
 		fileType = "sticker"
 	default:
-		return ""
+		return "", nil
 	}
 
-	if fileID == "" {
-		return ""
+	// Voice notes: handled by handleVoiceMessage (download, transcribe, upload)
+	if fileID == "" || fileType == "voice" {
+		return "", nil
 	}
 
 	// Get file info from Telegram
 	file, err := b.tg.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
 		log.Printf("GetFile failed: %v", err)
-		return fmt.Sprintf("[User sent a %s but I couldn't download it: %v]", fileType, err)
+		return fmt.Sprintf("[User sent a %s but I couldn't download it: %v]", fileType, err), nil
 	}
 
 	// Download from Telegram
 	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.tg.Token(), file.FilePath)
-	resp, err := http.Get(fileURL)
+	resp, err := httpGet(ctx, fileURL)
 	if err != nil {
 		log.Printf("Download file failed: %v", err)
-		return fmt.Sprintf("[User sent a %s but download failed: %v]", fileType, err)
+		return fmt.Sprintf("[User sent a %s but download failed: %v]", fileType, err), nil
 	}
 	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Sprintf("[User sent a %s but read failed: %v]", fileType, err)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("[User sent a %s but download failed: %s]", fileType, resp.Status), nil
 	}
 
-	userID := fmt.Sprintf("%d", msg.From.ID)
-	r2Key := fmt.Sprintf("users/%s/files/%s", userID, fileName)
+	// Images small enough are also shown to the model (see agent.WithImages)
+	var images []llm.ContentPart
+	var body io.Reader = resp.Body
+	size := resp.ContentLength
+	if _, mimeType := visionImage(msg); mimeType != "" && file.FileSize <= maxVisionBytes {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxVisionBytes+1))
+		if err != nil {
+			return fmt.Sprintf("[User sent a %s but download failed: %v]", fileType, err), nil
+		}
+		if len(data) <= maxVisionBytes {
+			images = []llm.ContentPart{llm.ImagePart(data, mimeType)}
+			body, size = bytes.NewReader(data), int64(len(data))
+		} else {
+			body, size = io.MultiReader(bytes.NewReader(data), resp.Body), -1
+		}
+	}
 
-	// Voice notes: handled by handleVoiceMessage (download, transcribe, upload)
-	if fileType == "voice" {
-		return ""
+	if b.agent.R2 == nil {
+		return fmt.Sprintf("[User sent %s: %q (%d bytes) but R2 not configured]", fileType, fileName, file.FileSize), images
 	}
 
-	// Upload to R2 user space (non-voice files)
+	if err := b.agent.CheckUpload(ctx, msg.Chat.ID, msg.From.ID, int64(file.FileSize)); err != nil {
+		log.Printf("Upload refused: %v", err)
+		return fmt.Sprintf("[User sent %s %q (%d bytes) but it was not stored: %v]", fileType, fileName, file.FileSize, err), images
+	}
 
-	if b.agent.R2 != nil {
-		if err := b.agent.R2.UploadObject(ctx, b.agent.Bucket, r2Key, data); err != nil {
-			log.Printf("R2 upload failed: %v", err)
-			return fmt.Sprintf("[User sent %s %q (%d bytes) but R2 upload failed: %v]", fileType, fileName, len(data), err)
-		}
-		log.Printf("File uploaded: %s -> r2://%s/%s (%d bytes)", fileType, b.agent.Bucket, r2Key, len(data))
-		return fmt.Sprintf("[User uploaded %s: %q (%d bytes) -> stored at r2://%s/%s]",
-			fileType, fileName, len(data), b.agent.Bucket, r2Key)
+	// Stream the download straight into the user's R2 space
+	r2Key := fmt.Sprintf("users/%d/files/%s", msg.From.ID, fileName)
+	size, err = storage.Upload(ctx, b.agent.R2, b.agent.Bucket, r2Key, body, size, uploadOptions(fileName, fileType, msg.From.ID))
+	if err != nil {
+		log.Printf("R2 upload failed: %v", err)
+		return fmt.Sprintf("[User sent %s %q (%d bytes) but R2 upload failed: %v]", fileType, fileName, size, err), images
 	}
+	b.agent.RecordUpload(ctx, msg.Chat.ID, size)
+	log.Printf("File uploaded: %s -> r2://%s/%s (%d bytes)", fileType, b.agent.Bucket, r2Key, size)
+	return fmt.Sprintf("[User uploaded %s: %q (%d bytes) -> stored at r2://%s/%s]",
+		fileType, fileName, size, b.agent.Bucket, r2Key), images
+}
 
-	return fmt.Sprintf("[User sent %s: %q (%d bytes) but R2 not configured]", fileType, fileName, len(data))
+// uploadTypes maps the extensions of files users commonly send to MIME
+// types, so they don't depend on the host's mime.types.
+var uploadTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+}
+
+// uploadOptions sets the content type of an uploaded file from its
+// extension, so it is served correctly (e.g. images render through a Worker),
+// and records where it came from.
+func uploadOptions(fileName, fileType string, userID int64) storage.UploadOptions {
+	ext := strings.ToLower(path.Ext(fileName))
+	contentType, ok := uploadTypes[ext]
+	if !ok {
+		contentType = mime.TypeByExtension(ext) // "" = the store's default
+	}
+	return storage.UploadOptions{
+		ContentType: contentType,
+		Metadata: map[string]string{
+			"source":    "telegram",
+			"file-type": fileType,
+			"user-id":   strconv.FormatInt(userID, 10),
+		},
+	}
 }
 
 // handleVoiceMessage transcribes a voice message using OpenRouter API.
@@ -874,7 +1721,7 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, msg *telego.Message) strin
 	}
 
 	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.tg.Token(), file.FilePath)
-	resp, err := http.Get(fileURL)
+	resp, err := httpGet(ctx, fileURL)
 	if err != nil {
 		log.Printf("Download voice failed: %v", err)
 		return fmt.Sprintf("[Voice download failed: %v]", err)
@@ -886,19 +1733,23 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, msg *telego.Message) strin
 		return fmt.Sprintf("[Voice read failed: %v]", err)
 	}
 
-	// Store voice file in R2
-	if b.agent.R2 != nil {
+	// Store voice file in R2, unless the chat is private
+	if b.agent.R2 != nil && !b.agent.IsPrivate(ctx, msg.Chat.ID) {
 		fileName := fmt.Sprintf("voice_%d.ogg", msg.Date)
 		r2Key := fmt.Sprintf("users/%d/files/%s", msg.From.ID, fileName)
-		_ = b.agent.R2.UploadObject(ctx, b.agent.Bucket, r2Key, data)
+		if err := b.agent.CheckUpload(ctx, msg.Chat.ID, msg.From.ID, int64(len(data))); err != nil {
+			log.Printf("Voice note not stored: %v", err) // still transcribed
+		} else if _, err := storage.Upload(ctx, b.agent.R2, b.agent.Bucket, r2Key, bytes.NewReader(data), int64(len(data)), uploadOptions(fileName, "voice", msg.From.ID)); err == nil {
+			b.agent.RecordUpload(ctx, msg.Chat.ID, int64(len(data)))
+		}
 	}
 
 	// Transcribe via OpenRouter only
-	if b.openRouterKey == "" {
+	if b.transcriber == nil {
 		return "[Voice transcription failed: no API key configured]"
 	}
 
-	text, err := transcribe.Transcribe(ctx, b.openRouterKey, data, "ogg")
+	text, err := b.transcriber.TranscribeAudio(ctx, data, "ogg")
 	if err != nil {
 		return fmt.Sprintf("[Voice transcription failed: %v]", err)
 	}
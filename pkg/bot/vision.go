@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/bigneek/picoflare/pkg/llm"
+)
+
+// maxVisionBytes caps images sent for /vision; they travel base64-encoded
+// inside the request.
+const maxVisionBytes = 10 << 20
+
+// handleVision handles /vision: it sends the photo in target to a
+// vision-capable model and replies with the description. The image and the
+// description are saved under the user's files/ and notes/ in R2, so
+// search_notes finds them later.
+func (b *Bot) handleVision(ctx context.Context, chatIDInt int64, chatID telego.ChatID, from *telego.User, target *telego.Message, question string) {
+	fileID, mimeType := visionImage(target)
+	if fileID == "" {
+		b.sendFormattedReply(ctx, chatID, "🖼️ **Describe a photo**\n\nReply to a photo with `/vision`, or send a photo captioned `/vision`. Add a question to ask something specific, e.g. `/vision what does the sign say?`")
+		return
+	}
+	if b.agent.LLM == nil {
+		b.sendFormattedReply(ctx, chatID, "No LLM configured.")
+		return
+	}
+
+	file, err := b.tg.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
+	if err != nil {
+		log.Printf("vision GetFile failed: %v", err)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't get the image: %v", err))
+		return
+	}
+	if file.FileSize > maxVisionBytes {
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("That image is too large (%d MB); send one under %d MB.", file.FileSize>>20, maxVisionBytes>>20))
+		return
+	}
+	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.tg.Token(), file.FilePath)
+	resp, err := httpGet(ctx, fileURL)
+	if err != nil {
+		log.Printf("vision download failed: %v", err)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't download: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVisionBytes+1))
+	if err != nil || len(data) > maxVisionBytes {
+		b.sendFormattedReply(ctx, chatID, "Couldn't read the image.")
+		return
+	}
+
+	model := b.visionModel
+	if model == "" {
+		model = b.agent.GetModel(chatIDInt)
+	}
+	typingCtx, stopTyping := context.WithCancel(ctx)
+	go b.keepTyping(typingCtx, chatID)
+	description, err := b.agent.LLM.DescribeImage(ctx, model, data, mimeType, question)
+	stopTyping()
+	if errors.Is(err, llm.ErrNoVision) {
+		log.Printf("vision: %v", err)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("The model `%s` can't read images. Switch to a vision model with /model (e.g. `google/gemini-2.0-flash-001`) or set VISION_MODEL.", model))
+		return
+	}
+	if err != nil {
+		log.Printf("vision failed: %v", err)
+		b.sendFormattedReply(ctx, chatID, fmt.Sprintf("Couldn't describe the image: %v", err))
+		return
+	}
+
+	saved := ""
+	if b.agent.R2 != nil && !b.agent.IsPrivate(ctx, chatIDInt) {
+		ts := time.Now().Format("20060102_150405")
+		ext := ".jpg"
+		if i := strings.LastIndex(file.FilePath, "."); i >= 0 {
+			ext = file.FilePath[i:]
+		}
+		imageKey := fmt.Sprintf("users/%d/files/vision_%s%s", from.ID, ts, ext)
+		noteKey := fmt.Sprintf("users/%d/notes/vision_%s.txt", from.ID, ts)
+		note := fmt.Sprintf("Image: %s\nModel: %s\n", imageKey, model)
+		if question != "" {
+			note += "Question: " + question + "\n"
+		}
+		note += "\n" + description
+		if err := b.agent.R2.UploadObject(ctx, b.agent.Bucket, imageKey, data); err != nil {
+			log.Printf("vision R2 upload failed: %v", err)
+		} else if err := b.agent.R2.UploadObject(ctx, b.agent.Bucket, noteKey, []byte(note)); err != nil {
+			log.Printf("vision note upload failed: %v", err)
+		} else {
+			saved = fmt.Sprintf("\n\n_Saved to `%s`_", noteKey)
+		}
+	}
+	b.sendFormattedReply(ctx, chatID, "🖼️ **Description**\n\n"+description+saved)
+}
+
+// visionImage returns the file ID and MIME type of the image in msg: the
+// largest photo size, or a document with an image MIME type.
+func visionImage(msg *telego.Message) (fileID, mimeType string) {
+	switch {
+	case msg == nil:
+		return "", ""
+	case len(msg.Photo) > 0:
+		return msg.Photo[len(msg.Photo)-1].FileID, "image/jpeg"
+	case msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/"):
+		return msg.Document.FileID, msg.Document.MimeType
+	}
+	return "", ""
+}
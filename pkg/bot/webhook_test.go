@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookGuard(t *testing.T) {
+	const secret = "s3cret"
+	var reached string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reached = string(body)
+	})
+	h := webhookGuard(next, "/hook", secret, 64)
+
+	tests := []struct {
+		name    string
+		path    string
+		secret  string
+		body    io.Reader
+		chunked bool // hide the length so only MaxBytesReader can catch it
+		want    int
+	}{
+		{name: "valid", path: "/hook", secret: secret, body: strings.NewReader(`{"update_id":1}`), want: http.StatusOK},
+		{name: "missing secret", path: "/hook", body: strings.NewReader(`{}`), want: http.StatusUnauthorized},
+		{name: "wrong secret", path: "/hook", secret: "guess", body: strings.NewReader(`{}`), want: http.StatusUnauthorized},
+		{name: "oversized", path: "/hook", secret: secret, body: strings.NewReader(strings.Repeat("x", 65)), want: http.StatusRequestEntityTooLarge},
+		{name: "oversized chunked", path: "/hook", secret: secret, body: strings.NewReader(strings.Repeat("x", 65)), chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "other path", path: "/health", body: strings.NewReader(""), want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = ""
+			req := httptest.NewRequest(http.MethodPost, tt.path, tt.body)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			if tt.secret != "" {
+				req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.name == "valid" && reached != `{"update_id":1}` {
+				t.Fatalf("handler got body %q", reached)
+			}
+			if tt.want != http.StatusOK && reached != "" {
+				t.Fatalf("rejected request reached the handler with %q", reached)
+			}
+		})
+	}
+}
@@ -9,10 +9,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
+	"sync"
 	"time"
+
+	"github.com/bigneek/picoflare/pkg/retry"
 )
 
 const baseURL = "https://api.cloudflare.com/client/v4"
@@ -21,14 +26,28 @@ type Client struct {
 	AccountID string
 	APIToken  string
 	http      *http.Client
-	Subdomain string
+	attempts  int // tries per retryable request (see roundTrip)
+
+	// subdomain caches the account's workers.dev subdomain. Guarded by
+	// subMu: TakeInventory looks it up while other requests run.
+	subMu     sync.Mutex
+	subdomain string
 }
 
+// NewClient creates a client that tries a request up to retry.DefaultAttempts
+// times on transient failures.
 func NewClient(accountID, apiToken string) *Client {
+	return NewClientWithRetries(accountID, apiToken, retry.DefaultAttempts)
+}
+
+// NewClientWithRetries is NewClient trying each request up to attempts times
+// (1 disables retrying).
+func NewClientWithRetries(accountID, apiToken string, attempts int) *Client {
 	return &Client{
 		AccountID: accountID,
 		APIToken:  apiToken,
 		http:      &http.Client{Timeout: 120 * time.Second},
+		attempts:  max(attempts, 1),
 	}
 }
 
@@ -45,31 +64,25 @@ type apiError struct {
 	Message string `json:"message"`
 }
 
+// maxRateLimitWait is the longest Retry-After a 429 is retried after; a
+// longer requested wait fails immediately instead.
+const maxRateLimitWait = 30 * time.Second
+
 func (c *Client) do(ctx context.Context, method, path string, body io.Reader, contentType string) (*apiResponse, error) {
-	url := baseURL + path
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+c.APIToken)
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
-	}
+	return c.send(ctx, method, path, body, contentType, idempotent(method))
+}
 
-	resp, err := c.http.Do(req)
+// send performs an API request and decodes the v4 envelope. resend allows
+// retrying the request after a transient failure.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, contentType string, resend bool) (*apiResponse, error) {
+	status, respBody, err := c.roundTrip(ctx, method, baseURL+path, body, contentType, resend)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
 
 	var apiResp apiResponse
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("decode response (HTTP %d): %s", resp.StatusCode, string(respBody[:min(len(respBody), 500)]))
+		return nil, fmt.Errorf("decode response (HTTP %d): %s", status, string(respBody[:min(len(respBody), 500)]))
 	}
 
 	if !apiResp.Success && len(apiResp.Errors) > 0 {
@@ -79,7 +92,88 @@ func (c *Client) do(ctx context.Context, method, path string, body io.Reader, co
 	return &apiResp, nil
 }
 
+// roundTrip sends one request and returns the final status code and body.
+// When resend is set, a 429, 5xx, or network error is retried up to
+// c.attempts tries in all, with backoff (or the 429's Retry-After).
+func (c *Client) roundTrip(ctx context.Context, method, url string, body io.Reader, contentType string, resend bool) (int, []byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.APIToken)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		canRetry := resend && attempt+1 < c.attempts
+		wait := retry.Backoff(attempt)
+
+		resp, err := c.http.Do(req)
+		var respBody []byte
+		if err == nil {
+			respBody, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				err = fmt.Errorf("read response: %w", err)
+			}
+		}
+		switch {
+		case err != nil:
+			if !canRetry || !retry.Network(err) {
+				return 0, nil, err
+			}
+			log.Printf("cloudflare: %s %s failed (%v), retrying in %s (%d/%d)", method, req.URL.Path, err, wait, attempt+2, c.attempts)
+		case !canRetry || !retry.Status(resp.StatusCode):
+			return resp.StatusCode, respBody, nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait = retry.After(resp.Header.Get("Retry-After"), wait)
+			if wait > maxRateLimitWait {
+				log.Printf("cloudflare: rate limited on %s %s, Retry-After %s exceeds %s; giving up", method, req.URL.Path, wait, maxRateLimitWait)
+				return resp.StatusCode, respBody, nil
+			}
+			log.Printf("cloudflare: rate limited on %s %s, retrying in %s (%d/%d)", method, req.URL.Path, wait, attempt+2, c.attempts)
+		default:
+			log.Printf("cloudflare: %s %s returned HTTP %d, retrying in %s (%d/%d)", method, req.URL.Path, resp.StatusCode, wait, attempt+2, c.attempts)
+		}
+		if err := retry.Sleep(ctx, wait); err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+// idempotent reports whether requests with method can be resent safely.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
 func (c *Client) doJSON(ctx context.Context, method, path string, payload interface{}) (*apiResponse, error) {
+	return c.sendJSON(ctx, method, path, payload, idempotent(method))
+}
+
+// doJSONRetrySafe is doJSON for calls that may be resent after a failure even
+// though their method isn't idempotent (they set fixed state or only read).
+func (c *Client) doJSONRetrySafe(ctx context.Context, method, path string, payload interface{}) (*apiResponse, error) {
+	return c.sendJSON(ctx, method, path, payload, true)
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, path string, payload interface{}, resend bool) (*apiResponse, error) {
 	var body io.Reader
 	if payload != nil {
 		data, err := json.Marshal(payload)
@@ -88,7 +182,7 @@ func (c *Client) doJSON(ctx context.Context, method, path string, payload interf
 		}
 		body = bytes.NewReader(data)
 	}
-	return c.do(ctx, method, path, body, "application/json")
+	return c.send(ctx, method, path, body, "application/json", resend)
 }
 
 // ---- Account / Subdomain ----
@@ -99,8 +193,11 @@ type SubdomainInfo struct {
 
 // GetSubdomain returns the workers.dev subdomain for this account.
 func (c *Client) GetSubdomain(ctx context.Context) (string, error) {
-	if c.Subdomain != "" {
-		return c.Subdomain, nil
+	c.subMu.Lock()
+	cached := c.subdomain
+	c.subMu.Unlock()
+	if cached != "" {
+		return cached, nil
 	}
 	resp, err := c.doJSON(ctx, "GET", fmt.Sprintf("/accounts/%s/workers/subdomain", c.AccountID), nil)
 	if err != nil {
@@ -110,7 +207,7 @@ func (c *Client) GetSubdomain(ctx context.Context) (string, error) {
 	if err := json.Unmarshal(resp.Result, &info); err != nil {
 		return "", err
 	}
-	c.Subdomain = info.Subdomain
+	c.setSubdomain(info.Subdomain)
 	return info.Subdomain, nil
 }
 
@@ -120,11 +217,20 @@ func (c *Client) RegisterSubdomain(ctx context.Context, subdomain string) error
 		"subdomain": subdomain,
 	})
 	if err == nil {
-		c.Subdomain = subdomain
+		c.setSubdomain(subdomain)
+	} else {
+		c.setSubdomain("") // state unknown; look it up again next time
 	}
 	return err
 }
 
+// setSubdomain replaces the cached subdomain; "" invalidates it.
+func (c *Client) setSubdomain(sub string) {
+	c.subMu.Lock()
+	c.subdomain = sub
+	c.subMu.Unlock()
+}
+
 // VerifyToken checks if the API token is valid and returns its status.
 func (c *Client) VerifyToken(ctx context.Context) (string, error) {
 	resp, err := c.do(ctx, "GET", "/user/tokens/verify", nil, "")
@@ -157,8 +263,50 @@ func (c *Client) ListWorkers(ctx context.Context) ([]WorkerScript, error) {
 	return scripts, nil
 }
 
+// DefaultCompatibilityDate is the Workers runtime date used when a deploy
+// doesn't choose one.
+const DefaultCompatibilityDate = "2024-09-23"
+
+// DefaultCompatibilityFlags are the runtime flags used when a deploy doesn't
+// choose any.
+var DefaultCompatibilityFlags = []string{"nodejs_compat"}
+
+// WorkerSettings selects the Workers runtime a script is deployed against,
+// plus any extra modules uploaded with it. An empty date or nil flags fall
+// back to the defaults; a non-nil empty flags slice deploys with no flags.
+type WorkerSettings struct {
+	CompatibilityDate  string
+	CompatibilityFlags []string
+	Files              []WorkerFile // extra modules and assets; ES module format only
+}
+
+// WithDefaults returns s with unset fields filled from the defaults.
+func (s WorkerSettings) WithDefaults() WorkerSettings {
+	if s.CompatibilityDate == "" {
+		s.CompatibilityDate = DefaultCompatibilityDate
+	}
+	if s.CompatibilityFlags == nil {
+		s.CompatibilityFlags = DefaultCompatibilityFlags
+	}
+	return s
+}
+
 // DeployWorker uploads a Worker script using multipart form data (ES module format).
-func (c *Client) DeployWorker(ctx context.Context, name, jsCode string) error {
+func (c *Client) DeployWorker(ctx context.Context, name, jsCode string, settings WorkerSettings) error {
+	return c.DeployWorkerScript(ctx, name, jsCode, true, settings)
+}
+
+// DeployWorkerScript uploads a Worker script. module selects the ES module
+// format (export default { fetch }); otherwise the script is uploaded in the
+// service-worker format (addEventListener("fetch", ...)).
+func (c *Client) DeployWorkerScript(ctx context.Context, name, jsCode string, module bool, settings WorkerSettings) error {
+	settings = settings.WithDefaults()
+	if len(settings.Files) > 0 && !module {
+		return fmt.Errorf("deploy worker %q: extra files need the ES module format", name)
+	}
+	if err := ValidateWorkerFiles(settings.Files); err != nil {
+		return fmt.Errorf("deploy worker %q: %w", name, err)
+	}
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
@@ -167,18 +315,34 @@ func (c *Client) DeployWorker(ctx context.Context, name, jsCode string) error {
 	metaHeader.Set("Content-Type", "application/json")
 	metaPart, _ := writer.CreatePart(metaHeader)
 	metadata := map[string]interface{}{
-		"main_module":         "worker.js",
-		"compatibility_date":  "2024-09-23",
-		"compatibility_flags": []string{"nodejs_compat"},
+		"compatibility_date":  settings.CompatibilityDate,
+		"compatibility_flags": settings.CompatibilityFlags,
+	}
+	partName, contentType := mainModule, "application/javascript+module"
+	if module {
+		metadata["main_module"] = partName
+	} else {
+		partName, contentType = "script", "application/javascript"
+		metadata["body_part"] = partName
 	}
 	json.NewEncoder(metaPart).Encode(metadata)
 
 	scriptHeader := make(textproto.MIMEHeader)
-	scriptHeader.Set("Content-Disposition", `form-data; name="worker.js"; filename="worker.js"`)
-	scriptHeader.Set("Content-Type", "application/javascript+module")
+	scriptHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="worker.js"`, partName))
+	scriptHeader.Set("Content-Type", contentType)
 	scriptPart, _ := writer.CreatePart(scriptHeader)
 	scriptPart.Write([]byte(jsCode))
 
+	// Extra modules are resolved by part name; the Content-Type sets how each
+	// one is imported (JS, text, data, JSON, or wasm).
+	for _, f := range settings.Files {
+		fileHeader := make(textproto.MIMEHeader)
+		fileHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.Name, f.Name))
+		fileHeader.Set("Content-Type", f.ContentType())
+		filePart, _ := writer.CreatePart(fileHeader)
+		filePart.Write(f.Content)
+	}
+
 	writer.Close()
 
 	path := fmt.Sprintf("/accounts/%s/workers/scripts/%s", c.AccountID, name)
@@ -211,10 +375,63 @@ func (c *Client) GetWorkerURL(ctx context.Context, name string) string {
 // EnableWorkerSubdomain enables/disables the workers.dev route for a script.
 func (c *Client) EnableWorkerSubdomain(ctx context.Context, name string, enabled bool) error {
 	path := fmt.Sprintf("/accounts/%s/workers/scripts/%s/subdomain", c.AccountID, name)
-	_, err := c.doJSON(ctx, "POST", path, map[string]bool{"enabled": enabled})
+	_, err := c.doJSONRetrySafe(ctx, "POST", path, map[string]bool{"enabled": enabled})
 	return err
 }
 
+// ---- Worker Routes ----
+
+type WorkerRoute struct {
+	ID      string `json:"id"`
+	Pattern string `json:"pattern"`
+	Script  string `json:"script,omitempty"`
+}
+
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ZoneID resolves a zone (domain) name such as "example.com" to its zone ID.
+func (c *Client) ZoneID(ctx context.Context, name string) (string, error) {
+	resp, err := c.doJSON(ctx, "GET", "/zones?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return "", err
+	}
+	var zones []Zone
+	json.Unmarshal(resp.Result, &zones)
+	if len(zones) == 0 {
+		return "", fmt.Errorf("zone %q not found on this account", name)
+	}
+	return zones[0].ID, nil
+}
+
+// ListWorkerRoutes returns the Worker routes configured on a zone.
+func (c *Client) ListWorkerRoutes(ctx context.Context, zoneID string) ([]WorkerRoute, error) {
+	resp, err := c.doJSON(ctx, "GET", fmt.Sprintf("/zones/%s/workers/routes", zoneID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var routes []WorkerRoute
+	json.Unmarshal(resp.Result, &routes)
+	return routes, nil
+}
+
+// CreateWorkerRoute binds a route pattern (e.g. "api.example.com/*") on a zone
+// to a worker script.
+func (c *Client) CreateWorkerRoute(ctx context.Context, zoneID, pattern, scriptName string) (*WorkerRoute, error) {
+	resp, err := c.doJSON(ctx, "POST", fmt.Sprintf("/zones/%s/workers/routes", zoneID), map[string]string{
+		"pattern": pattern,
+		"script":  scriptName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create route %q: %w", pattern, err)
+	}
+	route := &WorkerRoute{Pattern: pattern, Script: scriptName}
+	json.Unmarshal(resp.Result, route)
+	return route, nil
+}
+
 // ---- KV ----
 
 type KVNamespace struct {
@@ -252,17 +469,8 @@ func (c *Client) KVWrite(ctx context.Context, nsID, key string, value []byte) er
 
 func (c *Client) KVRead(ctx context.Context, nsID, key string) ([]byte, error) {
 	url := fmt.Sprintf("%s/accounts/%s/storage/kv/namespaces/%s/values/%s", baseURL, c.AccountID, nsID, key)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+c.APIToken)
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+	_, body, err := c.roundTrip(ctx, "GET", url, nil, "", true)
+	return body, err
 }
 
 // ---- D1 ----
@@ -334,6 +542,13 @@ func (c *Client) CreateR2Bucket(ctx context.Context, name string) error {
 	return err
 }
 
+// DeleteR2Bucket deletes an R2 bucket. Cloudflare rejects the request unless
+// the bucket is empty.
+func (c *Client) DeleteR2Bucket(ctx context.Context, name string) error {
+	_, err := c.doJSON(ctx, "DELETE", fmt.Sprintf("/accounts/%s/r2/buckets/%s", c.AccountID, name), nil)
+	return err
+}
+
 // ---- Vectorize ----
 
 type VectorizeIndex struct {
@@ -363,6 +578,99 @@ func (c *Client) CreateVectorizeIndex(ctx context.Context, name string, dimensio
 	return err
 }
 
+// DeleteVectorizeIndex deletes a Vectorize index and all vectors in it.
+func (c *Client) DeleteVectorizeIndex(ctx context.Context, name string) error {
+	_, err := c.doJSON(ctx, "DELETE", fmt.Sprintf("/accounts/%s/vectorize/v2/indexes/%s", c.AccountID, name), nil)
+	return err
+}
+
+// ---- Bootstrap ----
+
+// memoryIndexDimensions matches the embedding model used for agent memory.
+const memoryIndexDimensions = 768
+
+// EnsureResources creates the R2 bucket and Vectorize index if they are
+// missing. It is idempotent and returns the resources it created.
+func (c *Client) EnsureResources(ctx context.Context, bucket, index string) ([]string, error) {
+	var created []string
+
+	if bucket != "" {
+		buckets, err := c.ListR2Buckets(ctx)
+		if err != nil {
+			return created, fmt.Errorf("list R2 buckets: %w", err)
+		}
+		found := false
+		for _, b := range buckets {
+			if b.Name == bucket {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if err := c.CreateR2Bucket(ctx, bucket); err != nil {
+				return created, fmt.Errorf("create R2 bucket %s: %w", bucket, err)
+			}
+			created = append(created, "R2 bucket "+bucket)
+		}
+	}
+
+	if index != "" {
+		indexes, err := c.ListVectorizeIndexes(ctx)
+		if err != nil {
+			return created, fmt.Errorf("list Vectorize indexes: %w", err)
+		}
+		found := false
+		for _, ix := range indexes {
+			if ix.Name == index {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if err := c.CreateVectorizeIndex(ctx, index, memoryIndexDimensions, "cosine"); err != nil {
+				return created, fmt.Errorf("create Vectorize index %s: %w", index, err)
+			}
+			created = append(created, "Vectorize index "+index)
+		}
+	}
+
+	return created, nil
+}
+
+// ---- Workers AI ----
+
+// EmbeddingModel is the Workers AI model used by Embed. It returns 768-dim
+// vectors, matching memoryIndexDimensions.
+const EmbeddingModel = "@cf/baai/bge-base-en-v1.5"
+
+// embedBatchSize is the most texts Workers AI embeds per request.
+const embedBatchSize = 100
+
+// Embed returns one embedding per text using Workers AI.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	var out [][]float64
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch := texts[start:min(start+embedBatchSize, len(texts))]
+		resp, err := c.doJSONRetrySafe(ctx, "POST", fmt.Sprintf("/accounts/%s/ai/run/%s", c.AccountID, EmbeddingModel), map[string]interface{}{
+			"text": batch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("embed: %w", err)
+		}
+		var result struct {
+			Data [][]float64 `json:"data"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("embed: %w", err)
+		}
+		if len(result.Data) != len(batch) {
+			return nil, fmt.Errorf("embed: got %d vectors for %d texts", len(result.Data), len(batch))
+		}
+		out = append(out, result.Data...)
+	}
+	return out, nil
+}
+
 // ---- Pages / Full Inventory ----
 
 type Inventory struct {
@@ -374,14 +682,25 @@ type Inventory struct {
 	Vectorize []VectorizeIndex `json:"vectorize_indexes"`
 }
 
+// TakeInventory lists all resource types concurrently. A failed listing leaves
+// its field empty; the rest are still returned.
 func (c *Client) TakeInventory(ctx context.Context) *Inventory {
 	inv := &Inventory{}
-	inv.Subdomain, _ = c.GetSubdomain(ctx)
-	inv.Workers, _ = c.ListWorkers(ctx)
-	inv.KV, _ = c.ListKVNamespaces(ctx)
-	inv.D1, _ = c.ListD1Databases(ctx)
-	inv.R2, _ = c.ListR2Buckets(ctx)
-	inv.Vectorize, _ = c.ListVectorizeIndexes(ctx)
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	run(func() { inv.Subdomain, _ = c.GetSubdomain(ctx) })
+	run(func() { inv.Workers, _ = c.ListWorkers(ctx) })
+	run(func() { inv.KV, _ = c.ListKVNamespaces(ctx) })
+	run(func() { inv.D1, _ = c.ListD1Databases(ctx) })
+	run(func() { inv.R2, _ = c.ListR2Buckets(ctx) })
+	run(func() { inv.Vectorize, _ = c.ListVectorizeIndexes(ctx) })
+	wg.Wait()
 	return inv
 }
 
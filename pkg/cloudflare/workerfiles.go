@@ -0,0 +1,90 @@
+package cloudflare
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Module types for WorkerFile.Type.
+const (
+	ModuleESM  = "esm"  // JavaScript the main module imports
+	ModuleText = "text" // imported as a string, e.g. HTML or CSS
+	ModuleData = "data" // imported as an ArrayBuffer
+	ModuleJSON = "json" // imported as a parsed object
+	ModuleWasm = "wasm" // imported as a WebAssembly.Module
+)
+
+// mainModule is the part name the main script is uploaded under, so extra
+// files import each other relative to it ("./lib/util.js").
+const mainModule = "worker.js"
+
+// maxWorkerFiles caps the extra parts in one upload.
+const maxWorkerFiles = 50
+
+var moduleContentTypes = map[string]string{
+	ModuleESM:  "application/javascript+module",
+	ModuleText: "text/plain",
+	ModuleData: "application/octet-stream",
+	ModuleJSON: "application/json",
+	ModuleWasm: "application/wasm",
+}
+
+// WorkerFile is an extra module uploaded beside the main script: a helper
+// the main module imports, or a static asset bundled as a text, data, JSON,
+// or WebAssembly module. Files need the ES module format.
+type WorkerFile struct {
+	Name    string // import path relative to the main module, e.g. "lib/util.js"
+	Content []byte
+	Type    string // one of the Module* types; empty infers it from Name
+}
+
+// ModuleType returns f.Type, or the type implied by the file extension.
+func (f WorkerFile) ModuleType() string {
+	if f.Type != "" {
+		return f.Type
+	}
+	switch strings.ToLower(path.Ext(f.Name)) {
+	case ".js", ".mjs":
+		return ModuleESM
+	case ".json":
+		return ModuleJSON
+	case ".wasm":
+		return ModuleWasm
+	case ".html", ".htm", ".css", ".txt", ".md", ".svg", ".xml", ".csv":
+		return ModuleText
+	}
+	return ModuleData
+}
+
+// ContentType is the multipart Content-Type that tells Workers how to load f.
+func (f WorkerFile) ContentType() string {
+	return moduleContentTypes[f.ModuleType()]
+}
+
+// ValidateWorkerFiles checks names and types before an upload, so mistakes
+// surface as a clear error rather than a Cloudflare 400.
+func ValidateWorkerFiles(files []WorkerFile) error {
+	if len(files) > maxWorkerFiles {
+		return fmt.Errorf("too many files (%d, max %d)", len(files), maxWorkerFiles)
+	}
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		name := f.Name
+		if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "/") || strings.ContainsAny(name, "\"\\\r\n") ||
+			path.Clean(name) != name || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid file name %q (use a relative path like \"lib/util.js\")", name)
+		}
+		if name == mainModule {
+			return fmt.Errorf("file name %q is reserved for the main module", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate file %q", name)
+		}
+		seen[name] = true
+		if _, ok := moduleContentTypes[f.ModuleType()]; !ok {
+			return fmt.Errorf("file %q: unknown type %q (use esm, text, data, json, or wasm)", name, f.Type)
+		}
+	}
+	return nil
+}
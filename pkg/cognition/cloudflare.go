@@ -6,11 +6,15 @@ package cognition
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	cf "github.com/bigneek/picoflare/pkg/cloudflare"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
@@ -18,12 +22,21 @@ import (
 // CloudEnv gives the agent full access to the Cloudflare account.
 type CloudEnv struct {
 	MCP       *mcpclient.Client
-	R2        *storage.R2Client
+	R2        storage.ConditionalStore
 	Bucket    string
 	AccountID string
+
+	subMu        sync.Mutex
+	subdomain    string // cached workers.dev subdomain
+	subFetchedAt time.Time
+
+	usersMu sync.Mutex // serializes updates to the users index in this process
 }
 
-func NewCloudEnv(mcp *mcpclient.Client, r2 *storage.R2Client, bucket, accountID string) *CloudEnv {
+// subdomainTTL bounds how long the cached workers.dev subdomain is trusted.
+const subdomainTTL = 10 * time.Minute
+
+func NewCloudEnv(mcp *mcpclient.Client, r2 storage.ConditionalStore, bucket, accountID string) *CloudEnv {
 	return &CloudEnv{MCP: mcp, R2: r2, Bucket: bucket, AccountID: accountID}
 }
 
@@ -218,21 +231,33 @@ func (ce *CloudEnv) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
 }
 
 // DeployWorker uploads a Worker script. Tries ES module multipart first, falls
-// back to service-worker format (plain JS body) if FormData isn't available.
-func (ce *CloudEnv) DeployWorker(ctx context.Context, name, jsCode string) (string, error) {
+// back to service-worker format (plain JS body) if FormData isn't available;
+// the fallback upload carries no metadata, so settings only apply to the
+// module path, and a deploy with extra files fails instead of falling back.
+func (ce *CloudEnv) DeployWorker(ctx context.Context, name, jsCode string, settings cf.WorkerSettings) (string, error) {
+	settings = settings.WithDefaults()
+	if err := cf.ValidateWorkerFiles(settings.Files); err != nil {
+		return "", fmt.Errorf("deploy worker %q: %w", name, err)
+	}
+	flags, _ := json.Marshal(settings.CompatibilityFlags)
 	// Primary: ES module via multipart FormData (works if sandbox supports it)
 	code := fmt.Sprintf(`async () => {
 		const scriptContent = %s;
 		const workerName = %q;
+		const files = %s;
 		try {
 			const metadata = {
 				main_module: "worker.js",
-				compatibility_date: "2024-09-23",
-				compatibility_flags: ["nodejs_compat"]
+				compatibility_date: %s,
+				compatibility_flags: %s
 			};
 			const form = new FormData();
 			form.append("metadata", new Blob([JSON.stringify(metadata)], {type: "application/json"}));
 			form.append("worker.js", new Blob([scriptContent], {type: "application/javascript+module"}), "worker.js");
+			for (const f of files) {
+				const bytes = Uint8Array.from(atob(f.data), c => c.charCodeAt(0));
+				form.append(f.name, new Blob([bytes], {type: f.type}), f.name);
+			}
 			const resp = await cloudflare.request({
 				method: "PUT",
 				path: "/accounts/" + accountId + "/workers/scripts/" + workerName,
@@ -248,6 +273,7 @@ func (ce *CloudEnv) DeployWorker(ctx context.Context, name, jsCode string) (stri
 			} catch(e) {}
 			return resp;
 		} catch(e) {
+			if (files.length) throw e;
 			// Fallback: service-worker format (plain JS, no FormData needed)
 			const resp = await cloudflare.request({
 				method: "PUT",
@@ -264,7 +290,7 @@ func (ce *CloudEnv) DeployWorker(ctx context.Context, name, jsCode string) (stri
 			} catch(e2) {}
 			return resp;
 		}
-	}`, jsonEscapeValue(jsCode), name)
+	}`, jsonEscapeValue(jsCode), name, filePartsJS(settings.Files), jsonEscapeValue(settings.CompatibilityDate), flags)
 	raw, err := ce.MCP.Execute(ctx, code, ce.AccountID)
 	if err != nil {
 		return "", fmt.Errorf("deploy worker %q: %w", name, err)
@@ -289,7 +315,16 @@ func (ce *CloudEnv) DeleteWorker(ctx context.Context, name string) error {
 
 // --- Workers.dev Subdomain Management ---
 
+// GetSubdomain returns the account's workers.dev subdomain, cached for subdomainTTL.
 func (ce *CloudEnv) GetSubdomain(ctx context.Context) (string, error) {
+	ce.subMu.Lock()
+	if ce.subdomain != "" && time.Since(ce.subFetchedAt) < subdomainTTL {
+		sub := ce.subdomain
+		ce.subMu.Unlock()
+		return sub, nil
+	}
+	ce.subMu.Unlock()
+
 	code := `async () => {
 		const resp = await cloudflare.request({
 			method: "GET",
@@ -308,9 +343,23 @@ func (ce *CloudEnv) GetSubdomain(ctx context.Context) (string, error) {
 		} `json:"result"`
 	}
 	if json.Unmarshal([]byte(str), &wrapper) == nil && wrapper.Result.Subdomain != "" {
+		ce.setSubdomain(wrapper.Result.Subdomain)
 		return wrapper.Result.Subdomain, nil
 	}
-	return str, nil
+	return "", fmt.Errorf("no workers.dev subdomain in response: %.200s", str)
+}
+
+// setSubdomain replaces the cached subdomain; "" invalidates it.
+func (ce *CloudEnv) setSubdomain(sub string) {
+	ce.subMu.Lock()
+	ce.subdomain = sub
+	ce.subFetchedAt = time.Now()
+	ce.subMu.Unlock()
+}
+
+// InvalidateSubdomain drops the cached subdomain so the next lookup refetches it.
+func (ce *CloudEnv) InvalidateSubdomain() {
+	ce.setSubdomain("")
 }
 
 func (ce *CloudEnv) RegisterSubdomain(ctx context.Context, subdomain string) error {
@@ -323,7 +372,12 @@ func (ce *CloudEnv) RegisterSubdomain(ctx context.Context, subdomain string) err
 		return resp;
 	}`, subdomain)
 	_, err := ce.MCP.Execute(ctx, code, ce.AccountID)
-	return err
+	if err != nil {
+		ce.InvalidateSubdomain()
+		return err
+	}
+	ce.setSubdomain(subdomain)
+	return nil
 }
 
 func (ce *CloudEnv) GetWorkerURL(ctx context.Context, name string) string {
@@ -376,6 +430,18 @@ func (ce *CloudEnv) CreateVectorizeIndex(ctx context.Context, name string, dimen
 	return err
 }
 
+func (ce *CloudEnv) DeleteVectorizeIndex(ctx context.Context, name string) error {
+	code := fmt.Sprintf(`async () => {
+		const resp = await cloudflare.request({
+			method: "DELETE",
+			path: "/accounts/" + accountId + "/vectorize/v2/indexes/%s"
+		});
+		return resp;
+	}`, name)
+	_, err := ce.MCP.Execute(ctx, code, ce.AccountID)
+	return err
+}
+
 // --- Per-User Storage Provisioning ---
 
 // UserStorage represents a user's allocated resources.
@@ -391,33 +457,114 @@ type UserStorage struct {
 const userStorageIndex = "memory/users/index.json"
 
 func (ce *CloudEnv) ProvisionUserStorage(ctx context.Context, userID, username string) (*UserStorage, error) {
-	// Check if already provisioned
-	users, _ := ce.LoadUserStorage(ctx)
-	for _, u := range users {
-		if u.UserID == userID {
-			return &u, nil
+	ce.usersMu.Lock()
+	defer ce.usersMu.Unlock()
+
+	// Read-check-write with a conditional upload, so another process adding a
+	// user between our read and write makes us re-read instead of dropping it.
+	for attempt := 0; attempt < maxIndexWriteAttempts; attempt++ {
+		users, etag, err := ce.loadUserIndex(ctx)
+		if err != nil {
+			return nil, err
 		}
+		for _, u := range users {
+			if u.UserID == userID {
+				return &u, nil
+			}
+		}
+
+		us := UserStorage{
+			UserID:    userID,
+			Username:  username,
+			R2Prefix:  fmt.Sprintf("users/%s/", userID),
+			CreatedAt: time.Now(),
+		}
+		data, _ := json.Marshal(append(users, us))
+		err = ce.R2.UploadObjectIf(ctx, ce.Bucket, userStorageIndex, data, etag)
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			log.Printf("cloudenv: users index changed while provisioning %s, retrying", userID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Create user's welcome file
+		welcome := fmt.Sprintf("# Storage for %s\nProvisioned: %s\n", username, time.Now().Format(time.RFC3339))
+		_ = ce.R2.UploadObject(ctx, ce.Bucket, us.R2Prefix+"README.md", []byte(welcome))
+
+		log.Printf("cloudenv: provisioned storage for user %s (%s)", username, userID)
+		return &us, nil
 	}
+	return nil, fmt.Errorf("provision %s: users index kept changing, try again", userID)
+}
 
-	us := UserStorage{
-		UserID:    userID,
-		Username:  username,
-		R2Prefix:  fmt.Sprintf("users/%s/", userID),
-		CreatedAt: time.Now(),
+// DeprovisionUser deletes everything in the user's R2 space, the shares they
+// granted or received, and their users index entry. It returns how many
+// objects were deleted.
+func (ce *CloudEnv) DeprovisionUser(ctx context.Context, userID string) (int, error) {
+	if err := checkUserID(userID); err != nil {
+		return 0, err
+	}
+	deleted, err := storage.DeletePrefix(ctx, ce.R2, ce.Bucket, userObjectKey(userID, ""))
+	if err != nil {
+		return deleted, fmt.Errorf("delete files of %s: %w", userID, err)
+	}
+	n, err := ce.deleteShares(ctx, userID)
+	deleted += n
+	if err != nil {
+		return deleted, err
 	}
 
-	users = append(users, us)
-	data, _ := json.Marshal(users)
-	if err := ce.R2.UploadObject(ctx, ce.Bucket, userStorageIndex, data); err != nil {
-		return nil, err
+	ce.usersMu.Lock()
+	defer ce.usersMu.Unlock()
+	for attempt := 0; attempt < maxIndexWriteAttempts; attempt++ {
+		users, etag, err := ce.loadUserIndex(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		kept := users[:0:0]
+		for _, u := range users {
+			if u.UserID != userID {
+				kept = append(kept, u)
+			}
+		}
+		if len(kept) == len(users) {
+			return deleted, nil // not in the index
+		}
+		data, _ := json.Marshal(kept)
+		err = ce.R2.UploadObjectIf(ctx, ce.Bucket, userStorageIndex, data, etag)
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			log.Printf("cloudenv: users index changed while deprovisioning %s, retrying", userID)
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		log.Printf("cloudenv: deprovisioned user %s (%d objects deleted)", userID, deleted)
+		return deleted, nil
 	}
+	return deleted, fmt.Errorf("deprovision %s: users index kept changing, try again", userID)
+}
 
-	// Create user's welcome file
-	welcome := fmt.Sprintf("# Storage for %s\nProvisioned: %s\n", username, time.Now().Format(time.RFC3339))
-	_ = ce.R2.UploadObject(ctx, ce.Bucket, us.R2Prefix+"README.md", []byte(welcome))
+// maxIndexWriteAttempts bounds conditional-write retries on the users index.
+const maxIndexWriteAttempts = 5
 
-	log.Printf("cloudenv: provisioned storage for user %s (%s)", username, userID)
-	return &us, nil
+// loadUserIndex reads the users index with its ETag. A missing index is empty
+// with no ETag, so the first write creates it only if it still doesn't exist.
+func (ce *CloudEnv) loadUserIndex(ctx context.Context) ([]UserStorage, string, error) {
+	data, etag, err := ce.R2.DownloadObjectETag(ctx, ce.Bucket, userStorageIndex)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("read users index: %w", err)
+	}
+	var users []UserStorage
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, "", fmt.Errorf("users index is corrupt: %w", err)
+	}
+	return users, etag, nil
 }
 
 func (ce *CloudEnv) LoadUserStorage(ctx context.Context) ([]UserStorage, error) {
@@ -430,16 +577,38 @@ func (ce *CloudEnv) LoadUserStorage(ctx context.Context) ([]UserStorage, error)
 	return users, nil
 }
 
+func userObjectKey(userID, key string) string {
+	return fmt.Sprintf("users/%s/%s", userID, key)
+}
+
 // UserR2Write writes data into a user's R2 space.
 func (ce *CloudEnv) UserR2Write(ctx context.Context, userID, key string, data []byte) error {
-	fullKey := fmt.Sprintf("users/%s/%s", userID, key)
-	return ce.R2.UploadObject(ctx, ce.Bucket, fullKey, data)
+	return ce.R2.UploadObject(ctx, ce.Bucket, userObjectKey(userID, key), data)
 }
 
-// UserR2Read reads data from a user's R2 space.
-func (ce *CloudEnv) UserR2Read(ctx context.Context, userID, key string) ([]byte, error) {
-	fullKey := fmt.Sprintf("users/%s/%s", userID, key)
-	return ce.R2.DownloadObject(ctx, ce.Bucket, fullKey)
+// UserR2Read reads key from ownerID's R2 space on behalf of readerID. An
+// empty ownerID means the reader's own space; another user's file is only
+// readable if it was shared with the reader (see ShareFile).
+func (ce *CloudEnv) UserR2Read(ctx context.Context, readerID, ownerID, key string) ([]byte, error) {
+	if ownerID == "" || ownerID == readerID {
+		return ce.R2.DownloadObject(ctx, ce.Bucket, userObjectKey(readerID, key))
+	}
+	for _, id := range []string{readerID, ownerID} {
+		if err := checkUserID(id); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkUserKey(key); err != nil {
+		return nil, err
+	}
+	ok, err := ce.hasShare(ctx, ownerID, key, readerID)
+	if err != nil {
+		return nil, fmt.Errorf("check share: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("user %s has not shared %s with user %s", ownerID, key, readerID)
+	}
+	return ce.R2.DownloadObject(ctx, ce.Bucket, userObjectKey(ownerID, key))
 }
 
 // --- Resource Inventory ---
@@ -457,12 +626,22 @@ type ResourceInventory struct {
 func (ce *CloudEnv) TakeInventory(ctx context.Context) *ResourceInventory {
 	inv := &ResourceInventory{}
 
-	inv.Buckets, _ = ce.ListBuckets(ctx)
-	inv.KV, _ = ce.ListKVNamespaces(ctx)
-	inv.D1, _ = ce.ListD1Databases(ctx)
-	inv.Workers, _ = ce.ListWorkers(ctx)
-	inv.Vectorize, _ = ce.ListVectorizeIndexes(ctx)
-	inv.Users, _ = ce.LoadUserStorage(ctx)
+	// Independent round-trips: run them concurrently, keeping whatever succeeds
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	run(func() { inv.Buckets, _ = ce.ListBuckets(ctx) })
+	run(func() { inv.KV, _ = ce.ListKVNamespaces(ctx) })
+	run(func() { inv.D1, _ = ce.ListD1Databases(ctx) })
+	run(func() { inv.Workers, _ = ce.ListWorkers(ctx) })
+	run(func() { inv.Vectorize, _ = ce.ListVectorizeIndexes(ctx) })
+	run(func() { inv.Users, _ = ce.LoadUserStorage(ctx) })
+	wg.Wait()
 
 	return inv
 }
@@ -520,6 +699,23 @@ func parseJSON[T any](raw interface{}, field string) T {
 	return zero
 }
 
+// filePartsJS renders extra Worker files as a JSON array of
+// {name, type, data} for sandbox code, with data base64-encoded so binary
+// assets survive the trip.
+func filePartsJS(files []cf.WorkerFile) string {
+	type part struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+	parts := make([]part, len(files))
+	for i, f := range files {
+		parts[i] = part{Name: f.Name, Type: f.ContentType(), Data: base64.StdEncoding.EncodeToString(f.Content)}
+	}
+	b, _ := json.Marshal(parts)
+	return string(b)
+}
+
 func jsonEscapeValue(s string) string {
 	b, _ := json.Marshal(s)
 	return string(b)
@@ -0,0 +1,55 @@
+package cognition
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+func TestProvisionUsersConcurrently(t *testing.T) {
+	ctx := context.Background()
+	r2 := storage.NewMemStore()
+	// Two envs on one store stand in for two processes: usersMu serializes
+	// within each, the conditional write between them.
+	envs := []*CloudEnv{NewCloudEnv(nil, r2, "b", "acct"), NewCloudEnv(nil, r2, "b", "acct")}
+
+	const n = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for i := 0; i < n; i++ {
+		for _, ce := range envs {
+			wg.Add(1)
+			go func(ce *CloudEnv, id string) { // both envs provision every user
+				defer wg.Done()
+				if _, err := ce.ProvisionUserStorage(ctx, id, "user"+id); err != nil {
+					errs <- err
+				}
+			}(ce, fmt.Sprint(1000+i))
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("provision: %v", err)
+	}
+
+	users, err := envs[0].LoadUserStorage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	for _, u := range users {
+		seen[u.UserID]++
+	}
+	for i := 0; i < n; i++ {
+		if id := fmt.Sprint(1000 + i); seen[id] != 1 {
+			t.Errorf("user %s is in the index %d times, want once", id, seen[id])
+		}
+	}
+	if len(users) != n {
+		t.Errorf("index has %d entries, want %d", len(users), n)
+	}
+}
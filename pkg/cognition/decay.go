@@ -0,0 +1,50 @@
+package cognition
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultFactHalfLife is how long it takes an unconfirmed fact's
+	// confidence to halve in BuildContext.
+	DefaultFactHalfLife = 30 * 24 * time.Hour
+	// minFactConfidence is the decayed confidence below which a fact is left
+	// out of context.
+	minFactConfidence = 0.2
+)
+
+// SetFactHalfLife sets how fast facts lose confidence in BuildContext as
+// they age since last learned; d <= 0 turns decay off. Stored facts keep
+// their confidence. Call before the memory is in use.
+func (m *Memory) SetFactHalfLife(d time.Duration) {
+	m.factHalfLife = d
+}
+
+// effectiveConfidence is f's confidence halved for every factHalfLife since
+// it was last learned (UpdatedAt, else CreatedAt).
+func (m *Memory) effectiveConfidence(f Fact, now time.Time) float64 {
+	learned := f.UpdatedAt
+	if learned.IsZero() {
+		learned = f.CreatedAt
+	}
+	age := now.Sub(learned)
+	if m.factHalfLife <= 0 || learned.IsZero() || age <= 0 {
+		return f.Confidence
+	}
+	return f.Confidence * math.Pow(0.5, float64(age)/float64(m.factHalfLife))
+}
+
+// decayFacts returns facts with Confidence replaced by the effective
+// confidence, leaving out those below minFactConfidence.
+func (m *Memory) decayFacts(facts []Fact) []Fact {
+	now := m.clock.Now()
+	out := make([]Fact, 0, len(facts))
+	for _, f := range facts {
+		f.Confidence = m.effectiveConfidence(f, now)
+		if f.Confidence >= minFactConfidence {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -11,6 +11,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -43,12 +44,12 @@ const dynToolsKey = "memory/evolution/tools.json"
 
 // ToolRegistry manages dynamic tools.
 type ToolRegistry struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
 	http   *http.Client
 }
 
-func NewToolRegistry(r2 *storage.R2Client, bucket string) *ToolRegistry {
+func NewToolRegistry(r2 storage.ObjectStore, bucket string) *ToolRegistry {
 	return &ToolRegistry{
 		r2:     r2,
 		bucket: bucket,
@@ -279,6 +280,7 @@ type Feature struct {
 	Description  string    `json:"description"`
 	Status       string    `json:"status"` // "idea", "designed", "implemented", "deployed"
 	WorkerName   string    `json:"worker_name,omitempty"`
+	WorkerURL    string    `json:"worker_url,omitempty"`
 	WorkerCode   string    `json:"worker_code,omitempty"`
 	ToolName     string    `json:"tool_name,omitempty"`
 	Dependencies []string  `json:"dependencies,omitempty"`
@@ -288,18 +290,40 @@ type Feature struct {
 
 const featuresKey = "memory/evolution/features.json"
 
+// LoadFeatures returns the feature store; an empty store is not an error.
 func (tr *ToolRegistry) LoadFeatures(ctx context.Context) ([]Feature, error) {
 	data, err := tr.r2.DownloadObject(ctx, tr.bucket, featuresKey)
-	if err != nil {
+	if errors.Is(err, storage.ErrObjectNotFound) {
 		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read features: %w", err)
 	}
 	var features []Feature
-	json.Unmarshal(data, &features)
+	if err := json.Unmarshal(data, &features); err != nil {
+		return nil, fmt.Errorf("feature store is corrupt: %w", err)
+	}
 	return features, nil
 }
 
+// FindFeature returns the feature with the given name.
+func (tr *ToolRegistry) FindFeature(ctx context.Context, name string) (*Feature, error) {
+	features, err := tr.LoadFeatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range features {
+		if f.Name == name {
+			return &f, nil
+		}
+	}
+	return nil, fmt.Errorf("feature %q not found", name)
+}
+
 func (tr *ToolRegistry) SaveFeature(ctx context.Context, feature Feature) error {
-	features, _ := tr.LoadFeatures(ctx)
+	features, err := tr.LoadFeatures(ctx)
+	if err != nil {
+		return err // saving anyway would drop the other features
+	}
 
 	if feature.CreatedAt.IsZero() {
 		feature.CreatedAt = time.Now()
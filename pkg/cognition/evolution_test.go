@@ -0,0 +1,35 @@
+package cognition
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+func TestFeatureStoreReadErrors(t *testing.T) {
+	ctx := context.Background()
+	store := &failingReads{MemStore: storage.NewMemStore()}
+	tr := NewToolRegistry(store, "b")
+
+	if _, err := tr.FindFeature(ctx, "weather"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("empty store: err = %v, want not found", err)
+	}
+	if err := tr.SaveFeature(ctx, Feature{Name: "weather", Status: "idea"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store.fail = true
+	if _, err := tr.FindFeature(ctx, "weather"); err == nil || strings.Contains(err.Error(), "not found") {
+		t.Fatalf("failed read: err = %v, want the read error", err)
+	}
+	if err := tr.SaveFeature(ctx, Feature{Name: "stocks", Status: "idea"}); err == nil {
+		t.Fatal("SaveFeature wrote over a store it couldn't read")
+	}
+	store.fail = false
+
+	if f, err := tr.FindFeature(ctx, "weather"); err != nil || f.Status != "idea" {
+		t.Fatalf("after the outage: %+v, %v", f, err)
+	}
+}
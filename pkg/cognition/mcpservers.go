@@ -0,0 +1,88 @@
+package cognition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// --- External MCP servers ---
+
+// MCPServer is a third-party MCP server the agent connected to; its tools
+// are offered alongside the built-in ones. Token is sent as a Bearer token.
+type MCPServer struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const mcpServersKey = "memory/evolution/mcp_servers.json"
+
+// LoadMCPServers returns the saved MCP server connections.
+func (tr *ToolRegistry) LoadMCPServers(ctx context.Context) ([]MCPServer, error) {
+	exists, err := tr.r2.ObjectExists(ctx, tr.bucket, mcpServersKey)
+	if err != nil {
+		return nil, fmt.Errorf("read MCP servers: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	data, err := tr.r2.DownloadObject(ctx, tr.bucket, mcpServersKey)
+	if err != nil {
+		return nil, fmt.Errorf("read MCP servers: %w", err)
+	}
+	var servers []MCPServer
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("MCP servers list is corrupt: %w", err)
+	}
+	return servers, nil
+}
+
+func (tr *ToolRegistry) saveMCPServers(ctx context.Context, servers []MCPServer) error {
+	data, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tr.r2.UploadObject(ctx, tr.bucket, mcpServersKey, data)
+}
+
+// SaveMCPServer adds a server connection, replacing one with the same name.
+func (tr *ToolRegistry) SaveMCPServer(ctx context.Context, server MCPServer) error {
+	servers, err := tr.LoadMCPServers(ctx)
+	if err != nil {
+		return err
+	}
+	if server.CreatedAt.IsZero() {
+		server.CreatedAt = time.Now()
+	}
+	found := false
+	for i, s := range servers {
+		if s.Name == server.Name {
+			servers[i] = server
+			found = true
+			break
+		}
+	}
+	if !found {
+		servers = append(servers, server)
+	}
+	log.Printf("evolution: saved MCP server %q (%s)", server.Name, server.URL)
+	return tr.saveMCPServers(ctx, servers)
+}
+
+// RemoveMCPServer deletes a saved server connection.
+func (tr *ToolRegistry) RemoveMCPServer(ctx context.Context, name string) error {
+	servers, err := tr.LoadMCPServers(ctx)
+	if err != nil {
+		return err
+	}
+	for i, s := range servers {
+		if s.Name == name {
+			return tr.saveMCPServers(ctx, append(servers[:i], servers[i+1:]...))
+		}
+	}
+	return fmt.Errorf("MCP server %q not found", name)
+}
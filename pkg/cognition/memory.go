@@ -14,24 +14,55 @@ package cognition
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/clock"
+	"github.com/bigneek/picoflare/pkg/memory"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
 // Memory is the full cognitive memory system backed by R2.
 type Memory struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
+
+	embMu    sync.Mutex
+	embedder Embedder             // optional; see SetEmbedder
+	embCache map[string][]float64 // fact text -> embedding
+
+	vectors     *memory.Client // optional; see SetVectorIndex
+	vectorIndex string
+
+	logMu    sync.Mutex     // serializes daily log appends
+	logParts map[string]int // day directory -> part being appended to
+
+	updateMu sync.Mutex // serializes read-modify-writes in this process (see updateObject)
+
+	factHalfLife      time.Duration // see SetFactHalfLife
+	episodeSearchDays int           // see SetEpisodeSearchDays
+
+	clock clock.Clock // see SetClock
 }
 
-func NewMemory(r2 *storage.R2Client, bucket string) *Memory {
-	return &Memory{r2: r2, bucket: bucket}
+func NewMemory(r2 storage.ObjectStore, bucket string) *Memory {
+	return &Memory{
+		r2: r2, bucket: bucket, logParts: make(map[string]int), clock: clock.Real,
+		factHalfLife: DefaultFactHalfLife, episodeSearchDays: DefaultEpisodeSearchDays,
+	}
+}
+
+// SetClock replaces the clock used for timestamps and "today". Call before
+// the memory is in use.
+func (m *Memory) SetClock(c clock.Clock) {
+	m.clock = clock.OrReal(c)
 }
 
 // --- Episodic Memory: timestamped experiences ---
@@ -58,7 +89,7 @@ func (m *Memory) SaveEpisode(ctx context.Context, ep Episode) error {
 		ep.ID = fmt.Sprintf("ep-%d", time.Now().UnixNano())
 	}
 	if ep.Timestamp.IsZero() {
-		ep.Timestamp = time.Now()
+		ep.Timestamp = m.clock.Now()
 	}
 
 	data, err := json.Marshal(ep)
@@ -75,41 +106,136 @@ func (m *Memory) SaveEpisode(ctx context.Context, ep Episode) error {
 	}
 
 	// Also append to daily log for sequential access
-	logKey := p + fmt.Sprintf("memory/episodes/%s/log.jsonl", ep.Timestamp.Format("20060102"))
-	existing, _ := m.r2.DownloadObject(ctx, m.bucket, logKey)
-	line := string(data) + "\n"
-	return m.r2.UploadObject(ctx, m.bucket, logKey, append(existing, []byte(line)...))
+	dir := p + fmt.Sprintf("memory/episodes/%s/", ep.Timestamp.Format("20060102"))
+	return m.appendLog(ctx, dir, append(data, '\n'))
+}
+
+// maxLogPartBytes caps one part of a daily episode log. Appending rewrites
+// the current part, so the cap bounds the cost of each append rather than
+// letting it grow with the whole day.
+const maxLogPartBytes = 256 << 10
+
+// maxTrackedLogs bounds the day directories whose current part is remembered.
+const maxTrackedLogs = 256
+
+// logPartKey names part n of the log in dir: log.jsonl, then log.1.jsonl,
+// log.2.jsonl, ...
+func logPartKey(dir string, n int) string {
+	if n == 0 {
+		return dir + "log.jsonl"
+	}
+	return fmt.Sprintf("%slog.%d.jsonl", dir, n)
+}
+
+// logPartNum parses the part number from a log key in dir.
+func logPartNum(dir, key string) (int, bool) {
+	name := strings.TrimPrefix(key, dir)
+	if name == "log.jsonl" {
+		return 0, true
+	}
+	if !strings.HasPrefix(name, "log.") || !strings.HasSuffix(name, ".jsonl") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "log."), ".jsonl"))
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// logPartKeys lists the log parts in dir in order.
+func (m *Memory) logPartKeys(ctx context.Context, dir string) ([]string, error) {
+	keys, err := m.r2.ListObjects(ctx, m.bucket, dir+"log", 0)
+	if err != nil {
+		return nil, err
+	}
+	nums := make(map[string]int)
+	var parts []string
+	for _, k := range keys {
+		if n, ok := logPartNum(dir, k); ok {
+			nums[k] = n
+			parts = append(parts, k)
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return nums[parts[i]] < nums[parts[j]] })
+	return parts, nil
+}
+
+// appendLog adds line to the last part of the log in dir, starting a new
+// part when it would grow past maxLogPartBytes.
+func (m *Memory) appendLog(ctx context.Context, dir string, line []byte) error {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+
+	part, ok := m.logParts[dir]
+	if !ok {
+		// First append to this day since startup: find the last part
+		parts, err := m.logPartKeys(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("list episode log: %w", err)
+		}
+		if len(parts) > 0 {
+			part, _ = logPartNum(dir, parts[len(parts)-1])
+		}
+		if len(m.logParts) >= maxTrackedLogs {
+			m.logParts = make(map[string]int) // old days; relisted if written again
+		}
+	}
+
+	existing, err := m.r2.DownloadObject(ctx, m.bucket, logPartKey(dir, part))
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		existing = nil
+	} else if err != nil {
+		// Writing anyway would replace the part with just this line
+		return fmt.Errorf("read episode log: %w", err)
+	}
+	if len(existing) > 0 && len(existing)+len(line) > maxLogPartBytes {
+		part++
+		existing = nil
+	}
+	if err := m.r2.UploadObject(ctx, m.bucket, logPartKey(dir, part), append(existing, line...)); err != nil {
+		return err
+	}
+	m.logParts[dir] = part
+	return nil
 }
 
 func (m *Memory) LoadTodayEpisodes(ctx context.Context) ([]Episode, error) {
-	return m.LoadEpisodesForDate(ctx, time.Now())
+	return m.LoadEpisodesForDate(ctx, m.clock.Now())
 }
 
+// LoadEpisodesForDate reads every part of the day's episode log, oldest first.
 func (m *Memory) LoadEpisodesForDate(ctx context.Context, date time.Time) ([]Episode, error) {
-	key := fmt.Sprintf("memory/episodes/%s/log.jsonl", date.Format("20060102"))
-	data, err := m.r2.DownloadObject(ctx, m.bucket, key)
+	dir := m.prefix(ctx) + fmt.Sprintf("memory/episodes/%s/", date.Format("20060102"))
+	parts, err := m.logPartKeys(ctx, dir)
 	if err != nil {
 		return nil, nil // no episodes for this date
 	}
 
 	var episodes []Episode
-	for _, line := range strings.Split(string(data), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, key := range parts {
+		data, err := m.r2.DownloadObject(ctx, m.bucket, key)
+		if err != nil {
 			continue
 		}
-		var ep Episode
-		if err := json.Unmarshal([]byte(line), &ep); err != nil {
-			continue
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			var ep Episode
+			if err := json.Unmarshal([]byte(line), &ep); err != nil {
+				continue
+			}
+			episodes = append(episodes, ep)
 		}
-		episodes = append(episodes, ep)
 	}
 	return episodes, nil
 }
 
 func (m *Memory) LoadRecentEpisodes(ctx context.Context, days int, maxCount int) []Episode {
 	var all []Episode
-	now := time.Now()
+	now := m.clock.Now()
 	for i := 0; i < days; i++ {
 		d := now.AddDate(0, 0, -i)
 		eps, _ := m.LoadEpisodesForDate(ctx, d)
@@ -160,7 +286,7 @@ func (m *Memory) LoadKnowledge(ctx context.Context) (*KnowledgeBase, error) {
 }
 
 func (m *Memory) SaveKnowledge(ctx context.Context, kb *KnowledgeBase) error {
-	kb.UpdatedAt = time.Now()
+	kb.UpdatedAt = m.clock.Now()
 	data, err := json.Marshal(kb)
 	if err != nil {
 		return err
@@ -168,38 +294,162 @@ func (m *Memory) SaveKnowledge(ctx context.Context, kb *KnowledgeBase) error {
 	return m.r2.UploadObject(ctx, m.bucket, m.knowledgeKey(ctx), data)
 }
 
-// LearnFact adds or updates a fact in the knowledge base.
-func (m *Memory) LearnFact(ctx context.Context, fact Fact) error {
-	kb, err := m.LoadKnowledge(ctx)
-	if err != nil {
+// maxUpdateAttempts bounds retries of a conditional read-modify-write that
+// keeps losing to writers in other processes.
+const maxUpdateAttempts = 5
+
+// updateObject applies modify to the object at key and writes the result.
+// modify gets nil when the object doesn't exist. Updates in this process
+// are serialized; against other processes, a ConditionalStore write fails if
+// the object changed since it was read, and the update is redone on the
+// fresh copy.
+func (m *Memory) updateObject(ctx context.Context, key string, modify func(data []byte) ([]byte, error)) error {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	cs, ok := m.r2.(storage.ConditionalStore)
+	if !ok {
+		data, err := m.r2.DownloadObject(ctx, m.bucket, key)
+		if err != nil {
+			data = nil // treated as missing, like LoadKnowledge
+		}
+		out, err := modify(data)
+		if err != nil {
+			return err
+		}
+		return m.r2.UploadObject(ctx, m.bucket, key, out)
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		data, etag, err := cs.DownloadObjectETag(ctx, m.bucket, key)
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			data, etag = nil, ""
+		} else if err != nil {
+			return fmt.Errorf("read %s: %w", key, err)
+		}
+		out, err := modify(data)
+		if err != nil {
+			return err
+		}
+		err = cs.UploadObjectIf(ctx, m.bucket, key, out, etag)
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			log.Printf("memory: %s changed while updating, retrying", key)
+			continue
+		}
 		return err
 	}
+	return fmt.Errorf("update %s: it kept changing, try again", key)
+}
 
+// UpdateKnowledge applies modify to the knowledge base and saves it, without
+// losing facts written concurrently (see updateObject).
+func (m *Memory) UpdateKnowledge(ctx context.Context, modify func(kb *KnowledgeBase) error) error {
+	key := m.knowledgeKey(ctx)
+	return m.updateObject(ctx, key, func(data []byte) ([]byte, error) {
+		var kb KnowledgeBase
+		if data != nil {
+			if err := json.Unmarshal(data, &kb); err != nil {
+				return nil, fmt.Errorf("%s is corrupt, not overwriting it: %w", key, err)
+			}
+		}
+		if err := modify(&kb); err != nil {
+			return nil, err
+		}
+		kb.UpdatedAt = m.clock.Now()
+		return json.Marshal(&kb)
+	})
+}
+
+// updateProcedures is UpdateKnowledge for the procedure list.
+func (m *Memory) updateProcedures(ctx context.Context, modify func(procs []Procedure) []Procedure) error {
+	key := m.proceduresKey(ctx)
+	return m.updateObject(ctx, key, func(data []byte) ([]byte, error) {
+		var procs []Procedure
+		if data != nil {
+			if err := json.Unmarshal(data, &procs); err != nil {
+				return nil, fmt.Errorf("%s is corrupt, not overwriting it: %w", key, err)
+			}
+		}
+		return json.Marshal(modify(procs))
+	})
+}
+
+// LearnFact adds or updates a fact in the knowledge base.
+func (m *Memory) LearnFact(ctx context.Context, fact Fact) error {
 	if fact.ID == "" {
 		fact.ID = fmt.Sprintf("fact-%d", time.Now().UnixNano())
 	}
 	if fact.CreatedAt.IsZero() {
-		fact.CreatedAt = time.Now()
+		fact.CreatedAt = m.clock.Now()
 	}
-	fact.UpdatedAt = time.Now()
+	fact.UpdatedAt = m.clock.Now()
 	if fact.Confidence == 0 {
 		fact.Confidence = 0.8
 	}
 
-	// Update existing or append
-	found := false
-	for i, f := range kb.Facts {
-		if f.ID == fact.ID || (f.Category == fact.Category && f.Content == fact.Content) {
-			kb.Facts[i] = fact
-			found = true
-			break
+	err := m.UpdateKnowledge(ctx, func(kb *KnowledgeBase) error {
+		// Update existing or append
+		for i, f := range kb.Facts {
+			if f.ID == fact.ID || (f.Category == fact.Category && f.Content == fact.Content) {
+				fact.ID = f.ID // keep the ID its vector is stored under
+				kb.Facts[i] = fact
+				return nil
+			}
 		}
-	}
-	if !found {
 		kb.Facts = append(kb.Facts, fact)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	m.indexFact(ctx, fact)
+	return nil
+}
 
-	return m.SaveKnowledge(ctx, kb)
+// ForgetFact removes the fact with the given ID and reports how many facts
+// were removed (0 or 1).
+func (m *Memory) ForgetFact(ctx context.Context, id string) (int, error) {
+	if strings.TrimSpace(id) == "" {
+		return 0, fmt.Errorf("fact ID is required")
+	}
+	return m.forgetFacts(ctx, func(f Fact) bool { return f.ID == id })
+}
+
+// ForgetFactsByContent removes the facts whose content contains substring
+// (case-insensitive), limited to category unless it is empty, and reports
+// how many were removed.
+func (m *Memory) ForgetFactsByContent(ctx context.Context, category, substring string) (int, error) {
+	substring = strings.ToLower(strings.TrimSpace(substring))
+	if substring == "" {
+		return 0, fmt.Errorf("content to match is required")
+	}
+	return m.forgetFacts(ctx, func(f Fact) bool {
+		return (category == "" || f.Category == category) && strings.Contains(strings.ToLower(f.Content), substring)
+	})
+}
+
+// forgetFacts removes the facts matching drop from the knowledge base and
+// from the vector index, if any.
+func (m *Memory) forgetFacts(ctx context.Context, drop func(Fact) bool) (int, error) {
+	var removed []string
+	err := m.UpdateKnowledge(ctx, func(kb *KnowledgeBase) error {
+		removed = nil // recomputed if the update is retried
+		kept := kb.Facts[:0]
+		for _, f := range kb.Facts {
+			if drop(f) {
+				removed = append(removed, f.ID)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		kb.Facts = kept
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	m.unindexFacts(ctx, removed)
+	return len(removed), nil
 }
 
 func (m *Memory) QueryFacts(ctx context.Context, category string) []Fact {
@@ -252,45 +502,42 @@ func (m *Memory) LoadProcedures(ctx context.Context) ([]Procedure, error) {
 }
 
 func (m *Memory) SaveProcedure(ctx context.Context, proc Procedure) error {
-	procs, _ := m.LoadProcedures(ctx)
-
 	if proc.ID == "" {
 		proc.ID = fmt.Sprintf("proc-%d", time.Now().UnixNano())
 	}
 	if proc.CreatedAt.IsZero() {
-		proc.CreatedAt = time.Now()
+		proc.CreatedAt = m.clock.Now()
 	}
 
-	found := false
-	for i, p := range procs {
-		if p.ID == proc.ID || p.Name == proc.Name {
-			procs[i] = proc
-			found = true
-			break
+	return m.updateProcedures(ctx, func(procs []Procedure) []Procedure {
+		for i, p := range procs {
+			if p.ID == proc.ID || p.Name == proc.Name {
+				procs[i] = proc
+				return procs
+			}
 		}
-	}
-	if !found {
-		procs = append(procs, proc)
-	}
-
-	data, err := json.Marshal(procs)
-	if err != nil {
-		return err
-	}
-	return m.r2.UploadObject(ctx, m.bucket, m.proceduresKey(ctx), data)
+		return append(procs, proc)
+	})
 }
 
 func (m *Memory) RecordProcedureUse(ctx context.Context, name string) {
 	procs, _ := m.LoadProcedures(ctx)
-	for i, p := range procs {
-		if p.Name == name {
-			procs[i].Uses++
-			procs[i].LastUsed = time.Now()
-			data, _ := json.Marshal(procs)
-			_ = m.r2.UploadObject(ctx, m.bucket, m.proceduresKey(ctx), data)
-			return
+	known := false
+	for _, p := range procs {
+		known = known || p.Name == name
+	}
+	if !known {
+		return // nothing to write
+	}
+	_ = m.updateProcedures(ctx, func(procs []Procedure) []Procedure {
+		for i, p := range procs {
+			if p.Name == name {
+				procs[i].Uses++
+				procs[i].LastUsed = m.clock.Now()
+			}
 		}
-	}
+		return procs
+	})
 }
 
 // --- Cortex: smart retrieval across memory layers ---
@@ -310,9 +557,26 @@ var DefaultBudget = ContextBudget{
 	ProceduralPct: 20,
 }
 
+// Validate checks that the budget is positive and the layer percentages sum to 100.
+func (b ContextBudget) Validate() error {
+	if b.MaxTotalChars <= 0 {
+		return fmt.Errorf("memory budget must be positive, got %d chars", b.MaxTotalChars)
+	}
+	if b.EpisodicPct < 0 || b.SemanticPct < 0 || b.ProceduralPct < 0 {
+		return fmt.Errorf("memory budget percentages must not be negative")
+	}
+	if sum := b.EpisodicPct + b.SemanticPct + b.ProceduralPct; sum != 100 {
+		return fmt.Errorf("memory budget percentages sum to %d, want 100", sum)
+	}
+	return nil
+}
+
 // BuildContext assembles a memory context string optimized for the token budget.
-// It pulls from all layers and formats them for the system prompt.
-func (m *Memory) BuildContext(ctx context.Context, budget ContextBudget) string {
+// It pulls from all layers and formats them for the system prompt. Facts most
+// relevant to query (usually the user's message) fill the semantic budget
+// first; an empty query ranks them by confidence, decayed with age (see
+// SetFactHalfLife).
+func (m *Memory) BuildContext(ctx context.Context, budget ContextBudget, query string) string {
 	if m.r2 == nil {
 		return "(No memory backend connected)\n"
 	}
@@ -322,14 +586,11 @@ func (m *Memory) BuildContext(ctx context.Context, budget ContextBudget) string
 
 	// Semantic: facts (highest priority -- they define identity)
 	semanticBudget := budget.MaxTotalChars * budget.SemanticPct / 100
-	facts := m.QueryFacts(ctx, "")
+	facts := m.decayFacts(m.QueryFacts(ctx, ""))
 	if len(facts) > 0 {
 		var factLines []string
 		charCount := 0
-		// Sort by confidence descending
-		sort.Slice(facts, func(i, j int) bool {
-			return facts[i].Confidence > facts[j].Confidence
-		})
+		m.rankFacts(ctx, facts, query)
 		for _, f := range facts {
 			line := fmt.Sprintf("- [%s] %s (confidence: %.0f%%)", f.Category, f.Content, f.Confidence*100)
 			if charCount+len(line) > semanticBudget {
@@ -407,7 +668,7 @@ func (m *Memory) BuildContext(ctx context.Context, budget ContextBudget) string
 func (m *Memory) ExtractAndLearn(ctx context.Context, userMsg, agentReply string, toolsUsed []string) {
 	// Log the interaction as an episode
 	ep := Episode{
-		Timestamp: time.Now(),
+		Timestamp: m.clock.Now(),
 		Type:      "conversation",
 		Summary:   truncateStr(userMsg, 200),
 		Tags:      toolsUsed,
@@ -0,0 +1,92 @@
+package cognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// failingReads is a MemStore whose downloads fail while fail is set, like
+// R2 during an outage.
+type failingReads struct {
+	*storage.MemStore
+	fail bool
+}
+
+func (s *failingReads) DownloadObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	if s.fail {
+		return nil, errors.New("503 service unavailable")
+	}
+	return s.MemStore.DownloadObject(ctx, bucket, key)
+}
+
+func TestAppendLogKeepsLogWhenReadFails(t *testing.T) {
+	ctx := context.Background()
+	store := &failingReads{MemStore: storage.NewMemStore()}
+	m := NewMemory(store, "b")
+
+	if err := m.appendLog(ctx, "day/", []byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	store.fail = true
+	if err := m.appendLog(ctx, "day/", []byte("second\n")); err == nil {
+		t.Fatal("append succeeded though the log couldn't be read")
+	}
+	store.fail = false
+
+	data, err := store.DownloadObject(ctx, "b", "day/log.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "first") {
+		t.Fatalf("log = %q, earlier lines were overwritten", data)
+	}
+}
+
+func TestLearnFactConcurrently(t *testing.T) {
+	ctx := context.Background()
+	r2 := storage.NewMemStore()
+	// Two memories on one store stand in for two processes, as in
+	// TestProvisionUsersConcurrently
+	mems := []*Memory{NewMemory(r2, "b"), NewMemory(r2, "b")}
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fact := Fact{Category: "user", Content: fmt.Sprintf("fact %d", i)}
+			if err := mems[i%2].LearnFact(ctx, fact); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("LearnFact: %v", err)
+	}
+
+	facts := mems[0].QueryFacts(ctx, "user")
+	seen := make(map[string]bool)
+	ids := make(map[string]bool)
+	for _, f := range facts {
+		seen[f.Content] = true
+		ids[f.ID] = true
+	}
+	for i := 0; i < n; i++ {
+		if c := fmt.Sprintf("fact %d", i); !seen[c] {
+			t.Errorf("%q was lost", c)
+		}
+	}
+	if len(facts) != n || len(ids) != n {
+		t.Errorf("%d facts with %d distinct IDs, want %d", len(facts), len(ids), n)
+	}
+}
@@ -7,18 +7,26 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bigneek/picoflare/pkg/clock"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
 // MetaCognition tracks the agent's self-awareness: goals, capabilities,
 // performance metrics, and self-improvement directives.
 type MetaCognition struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
+	clock  clock.Clock // see SetClock
 }
 
-func NewMetaCognition(r2 *storage.R2Client, bucket string) *MetaCognition {
-	return &MetaCognition{r2: r2, bucket: bucket}
+func NewMetaCognition(r2 storage.ObjectStore, bucket string) *MetaCognition {
+	return &MetaCognition{r2: r2, bucket: bucket, clock: clock.Real}
+}
+
+// SetClock replaces the clock used for goal and reflection timestamps. Call
+// before use.
+func (mc *MetaCognition) SetClock(c clock.Clock) {
+	mc.clock = clock.OrReal(c)
 }
 
 // --- Goals ---
@@ -52,9 +60,9 @@ func (mc *MetaCognition) SaveGoal(ctx context.Context, goal Goal) error {
 		goal.ID = fmt.Sprintf("goal-%d", time.Now().UnixNano())
 	}
 	if goal.CreatedAt.IsZero() {
-		goal.CreatedAt = time.Now()
+		goal.CreatedAt = mc.clock.Now()
 	}
-	goal.UpdatedAt = time.Now()
+	goal.UpdatedAt = mc.clock.Now()
 
 	found := false
 	for i, g := range goals {
@@ -86,7 +94,7 @@ type Reflection struct {
 const reflectionsKey = "memory/meta/reflections.jsonl"
 
 func (mc *MetaCognition) SaveReflection(ctx context.Context, r Reflection) error {
-	r.Timestamp = time.Now()
+	r.Timestamp = mc.clock.Now()
 	data, _ := json.Marshal(r)
 	existing, _ := mc.r2.DownloadObject(ctx, mc.bucket, reflectionsKey)
 	return mc.r2.UploadObject(ctx, mc.bucket, reflectionsKey, append(existing, append(data, '\n')...))
@@ -0,0 +1,154 @@
+package cognition
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns text into vectors for relevance ranking. cloudflare.Client
+// implements it with a Workers AI embedding model.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// maxEmbeddingCache bounds the per-process cache of fact embeddings.
+const maxEmbeddingCache = 5000
+
+// SetEmbedder enables embedding-based fact ranking in BuildContext. Without
+// one, facts are ranked by word overlap with the query.
+func (m *Memory) SetEmbedder(e Embedder) {
+	m.embMu.Lock()
+	m.embedder = e
+	m.embCache = make(map[string][]float64)
+	m.embMu.Unlock()
+}
+
+// rankFacts orders facts by relevance to query, most relevant first, breaking
+// ties by confidence. An empty query sorts by confidence alone.
+func (m *Memory) rankFacts(ctx context.Context, facts []Fact, query string) {
+	scores := make(map[string]float64, len(facts))
+	if strings.TrimSpace(query) != "" {
+		texts := make([]string, len(facts))
+		for i, f := range facts {
+			texts[i] = f.Content
+		}
+		sims, err := m.embeddingScores(ctx, query, texts)
+		if err != nil {
+			log.Printf("memory: embedding rank failed, using word overlap: %v", err)
+		}
+		if sims == nil {
+			sims = overlapScores(query, texts)
+		}
+		for i, f := range facts {
+			scores[f.Content] = sims[i]
+		}
+	}
+	sort.SliceStable(facts, func(i, j int) bool {
+		si, sj := scores[facts[i].Content], scores[facts[j].Content]
+		if si != sj {
+			return si > sj
+		}
+		return facts[i].Confidence > facts[j].Confidence
+	})
+}
+
+// embeddingScores returns the cosine similarity of each text to query, or nil
+// if no embedder is set. Text embeddings are cached across calls.
+func (m *Memory) embeddingScores(ctx context.Context, query string, texts []string) ([]float64, error) {
+	m.embMu.Lock()
+	embedder := m.embedder
+	var missing []string
+	seen := make(map[string]bool)
+	for _, t := range append([]string{query}, texts...) {
+		if _, ok := m.embCache[t]; !ok && !seen[t] {
+			seen[t] = true
+			missing = append(missing, t)
+		}
+	}
+	m.embMu.Unlock()
+	if embedder == nil {
+		return nil, nil
+	}
+
+	if len(missing) > 0 {
+		vecs, err := embedder.Embed(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		m.embMu.Lock()
+		if len(m.embCache)+len(vecs) > maxEmbeddingCache {
+			m.embCache = make(map[string][]float64)
+		}
+		for i, v := range vecs {
+			m.embCache[missing[i]] = v
+		}
+		m.embMu.Unlock()
+	}
+
+	m.embMu.Lock()
+	defer m.embMu.Unlock()
+	q := m.embCache[query]
+	scores := make([]float64, len(texts))
+	for i, t := range texts {
+		scores[i] = cosine(q, m.embCache[t])
+	}
+	return scores, nil
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// overlapScores scores each text by shared words with query (cosine over word
+// sets), a cheap stand-in when embeddings aren't available.
+func overlapScores(query string, texts []string) []float64 {
+	q := wordSet(query)
+	scores := make([]float64, len(texts))
+	if len(q) == 0 {
+		return scores
+	}
+	for i, t := range texts {
+		w := wordSet(t)
+		if len(w) == 0 {
+			continue
+		}
+		shared := 0
+		for word := range w {
+			if q[word] {
+				shared++
+			}
+		}
+		scores[i] = float64(shared) / math.Sqrt(float64(len(q)*len(w)))
+	}
+	return scores
+}
+
+// wordSet lowercases s and returns its words of 3+ letters or digits.
+func wordSet(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		if len(w) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
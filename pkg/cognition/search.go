@@ -0,0 +1,81 @@
+package cognition
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// DefaultEpisodeSearchDays is how far back SearchEpisodes looks unless
+// SetEpisodeSearchDays says otherwise. Each day costs a list and a read per
+// log part, so the window bounds R2 requests.
+const DefaultEpisodeSearchDays = 30
+
+// maxEpisodeSearchDays caps SetEpisodeSearchDays.
+const maxEpisodeSearchDays = 365
+
+// SetEpisodeSearchDays sets how many days of episode logs SearchEpisodes
+// scans, up to a year; days <= 0 restores the default. Call before the
+// memory is in use.
+func (m *Memory) SetEpisodeSearchDays(days int) {
+	if days <= 0 {
+		days = DefaultEpisodeSearchDays
+	}
+	m.episodeSearchDays = min(days, maxEpisodeSearchDays)
+}
+
+// SearchEpisodes returns up to maxResults episodes from the scan window
+// whose summary or detail matches query, best first. An episode containing
+// the whole query ranks above those sharing only some of its words; ties
+// go to the newer one.
+func (m *Memory) SearchEpisodes(ctx context.Context, query string, maxResults int) []Episode {
+	phrase := strings.ToLower(strings.TrimSpace(query))
+	words := wordSet(query)
+	if phrase == "" || maxResults <= 0 {
+		return nil
+	}
+	days := m.episodeSearchDays
+	if days <= 0 {
+		days = DefaultEpisodeSearchDays
+	}
+
+	type hit struct {
+		ep    Episode
+		score float64
+	}
+	var hits []hit
+	now := m.clock.Now()
+	for i := 0; i < days; i++ {
+		eps, _ := m.LoadEpisodesForDate(ctx, now.AddDate(0, 0, -i))
+		for _, ep := range eps {
+			text := strings.ToLower(ep.Summary + "\n" + ep.Detail)
+			score := 0.0
+			if strings.Contains(text, phrase) {
+				score = 1
+			}
+			if len(words) > 0 {
+				matched := 0
+				for w := range words {
+					if strings.Contains(text, w) {
+						matched++
+					}
+				}
+				score += float64(matched) / float64(len(words))
+			}
+			if score > 0 {
+				hits = append(hits, hit{ep, score})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].ep.Timestamp.After(hits[j].ep.Timestamp)
+	})
+	out := make([]Episode, 0, min(len(hits), maxResults))
+	for _, h := range hits[:min(len(hits), maxResults)] {
+		out = append(out, h.ep)
+	}
+	return out
+}
@@ -2,11 +2,15 @@ package cognition
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	cf "github.com/bigneek/picoflare/pkg/cloudflare"
 	"github.com/bigneek/picoflare/pkg/mcpclient"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
@@ -15,9 +19,10 @@ import (
 // Cloudflare Workers — effectively extending its own capabilities at runtime.
 type SelfBuilder struct {
 	mcp       *mcpclient.Client
-	r2        *storage.R2Client
+	r2        storage.ObjectStore
 	bucket    string
 	accountID string
+	cloud     *CloudEnv // looks up (and caches) the workers.dev subdomain
 }
 
 // DeployedWorker tracks a worker the agent created.
@@ -25,6 +30,10 @@ type DeployedWorker struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Code        string    `json:"code"`
+	CodeHash    string    `json:"code_hash,omitempty"` // sha256 of Code and runtime settings, for skipping no-op redeploys
+	CompatDate  string    `json:"compatibility_date,omitempty"`
+	CompatFlags []string  `json:"compatibility_flags,omitempty"`
+	Files       []string  `json:"files,omitempty"` // extra module names; contents are not kept
 	Route       string    `json:"route,omitempty"`
 	DeployedAt  time.Time `json:"deployed_at"`
 	Status      string    `json:"status"` // "active", "failed", "deleted"
@@ -33,20 +42,32 @@ type DeployedWorker struct {
 
 const workersIndexKey = "memory/workers/index.json"
 
-func NewSelfBuilder(mcp *mcpclient.Client, r2 *storage.R2Client, bucket, accountID string) *SelfBuilder {
+func NewSelfBuilder(mcp *mcpclient.Client, r2 storage.ObjectStore, bucket, accountID string) *SelfBuilder {
 	return &SelfBuilder{
 		mcp:       mcp,
 		r2:        r2,
 		bucket:    bucket,
 		accountID: accountID,
+		cloud:     NewCloudEnv(mcp, nil, bucket, accountID),
 	}
 }
 
+// SetCloudEnv shares ce's workers.dev subdomain cache, so a subdomain
+// registered through ce shows up in deployed worker URLs. Call before use.
+func (sb *SelfBuilder) SetCloudEnv(ce *CloudEnv) {
+	sb.cloud = ce
+}
+
 // DeployWorker creates and deploys a Cloudflare Worker using Code Mode MCP.
-func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, workerCode string) (*DeployedWorker, error) {
+func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, workerCode string, settings cf.WorkerSettings) (*DeployedWorker, error) {
 	if sb.mcp == nil {
 		return nil, fmt.Errorf("MCP not configured")
 	}
+	settings = settings.WithDefaults()
+	if err := cf.ValidateWorkerFiles(settings.Files); err != nil {
+		return nil, fmt.Errorf("deploy worker %q: %w", name, err)
+	}
+	flags, _ := json.Marshal(settings.CompatibilityFlags)
 
 	// Use cf_execute to deploy the Worker script via the Cloudflare API
 	deployJS := fmt.Sprintf(`async () => {
@@ -55,10 +76,15 @@ func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, work
 
 		const metadata = {
 			main_module: "worker.js",
-			compatibility_date: "2024-01-01"
+			compatibility_date: %s,
+			compatibility_flags: %s
 		};
 		formData.append("metadata", new Blob([JSON.stringify(metadata)], {type: "application/json"}));
 		formData.append("worker.js", new Blob([scriptContent], {type: "application/javascript+module"}), "worker.js");
+		for (const f of %s) {
+			const bytes = Uint8Array.from(atob(f.data), c => c.charCodeAt(0));
+			formData.append(f.name, new Blob([bytes], {type: f.type}), f.name);
+		}
 
 		const response = await cloudflare.request({
 			method: "PUT",
@@ -67,7 +93,7 @@ func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, work
 			headers: {}
 		});
 		return response;
-	}`, jsonEscape(workerCode), name)
+	}`, jsonEscape(workerCode), jsonEscape(settings.CompatibilityDate), flags, filePartsJS(settings.Files), name)
 
 	result, err := sb.mcp.Execute(ctx, deployJS, sb.accountID)
 	if err != nil {
@@ -78,9 +104,13 @@ func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, work
 		Name:        name,
 		Description: description,
 		Code:        workerCode,
+		CodeHash:    codeHash(workerCode, settings),
+		CompatDate:  settings.CompatibilityDate,
+		CompatFlags: settings.CompatibilityFlags,
+		Files:       fileNames(settings.Files),
 		DeployedAt:  time.Now(),
 		Status:      "active",
-		URL:         fmt.Sprintf("https://%s.%s.workers.dev", name, sb.accountID),
+		URL:         sb.workerURL(ctx, name),
 	}
 
 	log.Printf("selfbuild: deployed worker %q: %v", name, result)
@@ -97,6 +127,39 @@ func (sb *SelfBuilder) DeployWorker(ctx context.Context, name, description, work
 	return worker, nil
 }
 
+// workerURL returns the workers.dev URL for a script, or "" if the account
+// has no workers.dev subdomain registered yet.
+func (sb *SelfBuilder) workerURL(ctx context.Context, name string) string {
+	sub, err := sb.cloud.GetSubdomain(ctx)
+	if err == nil && !isDNSLabel(sub) {
+		err = fmt.Errorf("invalid subdomain %q", sub)
+	}
+	if err != nil {
+		log.Printf("selfbuild: workers.dev subdomain unavailable: %v", err)
+		return ""
+	}
+	return workersDevURL(name, sub)
+}
+
+// isDNSLabel reports whether s can be a workers.dev subdomain.
+func isDNSLabel(s string) bool {
+	if s == "" || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// workersDevURL formats the public URL of a worker on the account's
+// workers.dev subdomain (not the account ID).
+func workersDevURL(name, subdomain string) string {
+	return fmt.Sprintf("https://%s.%s.workers.dev", name, subdomain)
+}
+
 // ListWorkers returns all workers the agent has deployed.
 func (sb *SelfBuilder) ListWorkers(ctx context.Context) ([]DeployedWorker, error) {
 	data, err := sb.r2.DownloadObject(ctx, sb.bucket, workersIndexKey)
@@ -110,8 +173,71 @@ func (sb *SelfBuilder) ListWorkers(ctx context.Context) ([]DeployedWorker, error
 	return workers, nil
 }
 
+// Unchanged reports whether name is tracked as active with exactly this code
+// and settings, meaning a redeploy would be a no-op. It returns the tracked
+// worker.
+func (sb *SelfBuilder) Unchanged(ctx context.Context, name, code string, settings cf.WorkerSettings) (*DeployedWorker, bool) {
+	workers, _ := sb.ListWorkers(ctx)
+	for i, w := range workers {
+		if w.Name == name {
+			if w.Status == "active" && w.CodeHash != "" && w.CodeHash == codeHash(code, settings) {
+				return &workers[i], true
+			}
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// RecordDeploy tracks a worker deployed by another client (the REST API or
+// Code Mode) so an identical redeploy can be skipped later.
+func (sb *SelfBuilder) RecordDeploy(ctx context.Context, name, code, url string, settings cf.WorkerSettings) {
+	settings = settings.WithDefaults()
+	worker := &DeployedWorker{
+		Name:        name,
+		Code:        code,
+		CodeHash:    codeHash(code, settings),
+		CompatDate:  settings.CompatibilityDate,
+		CompatFlags: settings.CompatibilityFlags,
+		Files:       fileNames(settings.Files),
+		DeployedAt:  time.Now(),
+		Status:      "active",
+		URL:         url,
+	}
+	workers, _ := sb.ListWorkers(ctx)
+	for _, w := range workers {
+		if w.Name == name {
+			worker.Description = w.Description
+			worker.Route = w.Route
+			break
+		}
+	}
+	if err := sb.trackWorker(ctx, worker); err != nil {
+		log.Printf("selfbuild: track worker failed: %v", err)
+	}
+	codeKey := fmt.Sprintf("memory/workers/%s/worker.js", name)
+	_ = sb.r2.UploadObject(ctx, sb.bucket, codeKey, []byte(code))
+}
+
+// MarkDeleted records that a tracked worker was removed, so redeploying it
+// uploads again.
+func (sb *SelfBuilder) MarkDeleted(ctx context.Context, name string) {
+	workers, _ := sb.ListWorkers(ctx)
+	for i, w := range workers {
+		if w.Name == name {
+			workers[i].Status = "deleted"
+			data, _ := json.Marshal(workers)
+			_ = sb.r2.UploadObject(ctx, sb.bucket, workersIndexKey, data)
+			return
+		}
+	}
+}
+
 // DeleteWorker removes a worker from Cloudflare.
 func (sb *SelfBuilder) DeleteWorker(ctx context.Context, name string) error {
+	if sb.mcp == nil {
+		return fmt.Errorf("MCP not configured")
+	}
 	deleteJS := fmt.Sprintf(`async () => {
 		const response = await cloudflare.request({
 			method: "DELETE",
@@ -125,17 +251,7 @@ func (sb *SelfBuilder) DeleteWorker(ctx context.Context, name string) error {
 		return fmt.Errorf("delete worker %q: %w", name, err)
 	}
 
-	// Update tracking
-	workers, _ := sb.ListWorkers(ctx)
-	for i, w := range workers {
-		if w.Name == name {
-			workers[i].Status = "deleted"
-			break
-		}
-	}
-	data, _ := json.Marshal(workers)
-	_ = sb.r2.UploadObject(ctx, sb.bucket, workersIndexKey, data)
-
+	sb.MarkDeleted(ctx, name)
 	return nil
 }
 
@@ -151,6 +267,9 @@ func (sb *SelfBuilder) GetWorkerCode(ctx context.Context, name string) (string,
 
 // CreateKVNamespace creates a KV namespace for Workers to use.
 func (sb *SelfBuilder) CreateKVNamespace(ctx context.Context, title string) (string, error) {
+	if sb.mcp == nil {
+		return "", fmt.Errorf("MCP not configured")
+	}
 	createJS := fmt.Sprintf(`async () => {
 		const response = await cloudflare.request({
 			method: "POST",
@@ -169,6 +288,9 @@ func (sb *SelfBuilder) CreateKVNamespace(ctx context.Context, title string) (str
 
 // CreateD1Database creates a D1 (SQLite) database.
 func (sb *SelfBuilder) CreateD1Database(ctx context.Context, name string) (string, error) {
+	if sb.mcp == nil {
+		return "", fmt.Errorf("MCP not configured")
+	}
 	createJS := fmt.Sprintf(`async () => {
 		const response = await cloudflare.request({
 			method: "POST",
@@ -205,6 +327,28 @@ func (sb *SelfBuilder) trackWorker(ctx context.Context, worker *DeployedWorker)
 	return sb.r2.UploadObject(ctx, sb.bucket, workersIndexKey, data)
 }
 
+// codeHash fingerprints a deploy: the script plus the runtime settings and
+// extra files it was deployed with, so changing only the date, flags, or an
+// asset still uploads.
+func codeHash(code string, settings cf.WorkerSettings) string {
+	settings = settings.WithDefaults()
+	h := sha256.New()
+	h.Write([]byte(code))
+	fmt.Fprintf(h, "\x00%s\x00%s", settings.CompatibilityDate, strings.Join(settings.CompatibilityFlags, ","))
+	for _, f := range settings.Files {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%x", f.Name, f.ModuleType(), sha256.Sum256(f.Content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fileNames(files []cf.WorkerFile) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
 func jsonEscape(s string) string {
 	b, _ := json.Marshal(s)
 	return string(b)
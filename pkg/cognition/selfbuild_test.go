@@ -0,0 +1,120 @@
+package cognition
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cf "github.com/bigneek/picoflare/pkg/cloudflare"
+	"github.com/bigneek/picoflare/pkg/mcpclient"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// fakeMCP answers every execute call with the text reply returns for its code.
+func fakeMCP(t *testing.T, reply func(code string) string) *mcpclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+			Params struct {
+				Arguments struct {
+					Code string `json:"code"`
+				} `json:"arguments"`
+			} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode MCP request: %v", err)
+		}
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted) // notification
+			return
+		}
+		result := map[string]interface{}{}
+		if req.Method == "tools/call" {
+			result["content"] = []map[string]string{{"type": "text", "text": reply(req.Params.Arguments.Code)}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return mcpclient.NewClient(srv.URL, "token", "")
+}
+
+func TestDeployWorkerURLUsesSubdomain(t *testing.T) {
+	const accountID = "0123456789abcdef"
+	mcp := fakeMCP(t, func(code string) string {
+		if strings.Contains(code, "/workers/subdomain") {
+			return `{"success":true,"result":{"subdomain":"acme"}}`
+		}
+		return `{"success":true}`
+	})
+	sb := NewSelfBuilder(mcp, storage.NewMemStore(), "bucket", accountID)
+
+	w, err := sb.DeployWorker(context.Background(), "hello", "test", "export default {}", cf.WorkerSettings{})
+	if err != nil {
+		t.Fatalf("DeployWorker: %v", err)
+	}
+	if want := "https://hello.acme.workers.dev"; w.URL != want {
+		t.Errorf("URL = %q, want %q", w.URL, want)
+	}
+	if strings.Contains(w.URL, accountID) {
+		t.Errorf("URL %q contains the account ID", w.URL)
+	}
+}
+
+func TestDeployWorkerURLWithoutSubdomain(t *testing.T) {
+	mcp := fakeMCP(t, func(code string) string {
+		if strings.Contains(code, "/workers/subdomain") {
+			return `{"success":false,"errors":[{"message":"no subdomain"}]}`
+		}
+		return `{"success":true}`
+	})
+	sb := NewSelfBuilder(mcp, storage.NewMemStore(), "bucket", "acct")
+
+	w, err := sb.DeployWorker(context.Background(), "hello", "test", "export default {}", cf.WorkerSettings{})
+	if err != nil {
+		t.Fatalf("DeployWorker: %v", err)
+	}
+	if strings.Contains(w.URL, "acct") {
+		t.Errorf("URL = %q, want no account ID", w.URL)
+	}
+	if w.URL != "" {
+		t.Errorf("URL = %q, want empty without a subdomain", w.URL)
+	}
+}
+
+func TestDeployWorkerURLSharesSubdomainCache(t *testing.T) {
+	lookups := 0
+	mcp := fakeMCP(t, func(code string) string {
+		if strings.Contains(code, "/workers/subdomain") && strings.Contains(code, `"GET"`) {
+			lookups++
+			return `{"success":false,"errors":[{"message":"no subdomain"}]}`
+		}
+		return `{"success":true}`
+	})
+	cloud := NewCloudEnv(mcp, storage.NewMemStore(), "bucket", "acct")
+	sb := NewSelfBuilder(mcp, storage.NewMemStore(), "bucket", "acct")
+	sb.SetCloudEnv(cloud)
+	ctx := context.Background()
+
+	if _, err := cloud.GetSubdomain(ctx); err == nil {
+		t.Fatal("GetSubdomain succeeded on an error response")
+	}
+	if err := cloud.RegisterSubdomain(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sb.DeployWorker(ctx, "hello", "test", "export default {}", cf.WorkerSettings{})
+	if err != nil {
+		t.Fatalf("DeployWorker: %v", err)
+	}
+	if want := "https://hello.acme.workers.dev"; w.URL != want {
+		t.Errorf("URL = %q, want %q from the registered subdomain", w.URL, want)
+	}
+	if lookups != 1 {
+		t.Errorf("subdomain looked up %d times, want 1", lookups)
+	}
+}
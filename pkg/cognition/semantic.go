@@ -0,0 +1,149 @@
+package cognition
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bigneek/picoflare/pkg/agentctx"
+	"github.com/bigneek/picoflare/pkg/memory"
+)
+
+// maxRecall caps SemanticRecall's topK.
+const maxRecall = 50
+
+// SetVectorIndex enables Vectorize-backed SemanticRecall: LearnFact embeds
+// each fact (with the Embedder from SetEmbedder) and upserts it into index
+// under the fact's ID, one namespace per agent. Without an index or an
+// embedder, SemanticRecall matches by words instead.
+func (m *Memory) SetVectorIndex(client *memory.Client, index string) {
+	m.embMu.Lock()
+	m.vectors = client
+	m.vectorIndex = index
+	m.embMu.Unlock()
+}
+
+// vectorTarget returns the vector index and embedder, or nils when either
+// isn't set.
+func (m *Memory) vectorTarget() (*memory.Client, string, Embedder) {
+	m.embMu.Lock()
+	defer m.embMu.Unlock()
+	if m.vectors == nil || m.vectorIndex == "" || m.embedder == nil {
+		return nil, "", nil
+	}
+	return m.vectors, m.vectorIndex, m.embedder
+}
+
+// vectorNamespace keeps each agent's facts apart in the shared index.
+func vectorNamespace(ctx context.Context) string {
+	id, _ := agentctx.AgentIDFromContext(ctx)
+	return id
+}
+
+// indexFact upserts fact's embedding. Errors are logged: the fact is saved
+// in R2 either way, only semantic recall misses it.
+func (m *Memory) indexFact(ctx context.Context, fact Fact) {
+	vectors, index, embedder := m.vectorTarget()
+	if vectors == nil {
+		return
+	}
+	vecs, err := embedder.Embed(ctx, []string{fact.Content})
+	if err == nil && len(vecs) != 1 {
+		err = fmt.Errorf("got %d embeddings for 1 text", len(vecs))
+	}
+	if err == nil {
+		err = vectors.InsertVector(ctx, index, vectorNamespace(ctx), fact.ID, vecs[0],
+			map[string]string{"category": fact.Category})
+	}
+	if err != nil {
+		log.Printf("memory: index fact %s: %v", fact.ID, err)
+	}
+}
+
+// unindexFacts deletes the vectors of forgotten facts. Errors are logged:
+// SemanticRecall skips matches whose fact no longer exists anyway.
+func (m *Memory) unindexFacts(ctx context.Context, ids []string) {
+	vectors, index, _ := m.vectorTarget()
+	if vectors == nil || len(ids) == 0 {
+		return
+	}
+	if err := vectors.DeleteVectors(ctx, index, ids); err != nil {
+		log.Printf("memory: unindex %d facts: %v", len(ids), err)
+	}
+}
+
+// SemanticRecall returns up to topK facts most related to query, regardless
+// of category. It queries the Vectorize index when one is set. Otherwise, or
+// if the index finds nothing (e.g. facts learned before it was set), it
+// scores every fact by embedding similarity or shared words, leaving out
+// those with nothing in common.
+func (m *Memory) SemanticRecall(ctx context.Context, query string, topK int) []Fact {
+	topK = min(max(topK, 1), maxRecall)
+	kb, err := m.LoadKnowledge(ctx)
+	if err != nil || len(kb.Facts) == 0 || strings.TrimSpace(query) == "" {
+		return nil
+	}
+	if facts, err := m.vectorRecall(ctx, kb.Facts, query, topK); err != nil {
+		log.Printf("memory: vector recall failed, matching locally: %v", err)
+	} else if len(facts) > 0 {
+		return facts
+	}
+
+	texts := make([]string, len(kb.Facts))
+	for i, f := range kb.Facts {
+		texts[i] = f.Content
+	}
+	scores, err := m.embeddingScores(ctx, query, texts)
+	if err != nil {
+		log.Printf("memory: embedding recall failed, using word overlap: %v", err)
+	}
+	if scores == nil {
+		scores = overlapScores(query, texts)
+	}
+	idx := make([]int, 0, len(kb.Facts))
+	for i := range kb.Facts {
+		if scores[i] > 0 {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	facts := make([]Fact, 0, min(len(idx), topK))
+	for _, i := range idx[:min(len(idx), topK)] {
+		facts = append(facts, kb.Facts[i])
+	}
+	return facts
+}
+
+// vectorRecall looks query up in the Vectorize index and returns the
+// matching facts, best first. It returns nil, nil when no index is set.
+// Matches whose fact no longer exists are skipped.
+func (m *Memory) vectorRecall(ctx context.Context, facts []Fact, query string, topK int) ([]Fact, error) {
+	vectors, index, embedder := m.vectorTarget()
+	if vectors == nil {
+		return nil, nil
+	}
+	vecs, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for 1 text", len(vecs))
+	}
+	matches, err := vectors.QueryVector(ctx, index, vectorNamespace(ctx), vecs[0], topK)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Fact, len(facts))
+	for _, f := range facts {
+		byID[f.ID] = f
+	}
+	out := make([]Fact, 0, len(matches))
+	for _, match := range matches {
+		if f, ok := byID[match.ID]; ok {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,130 @@
+package cognition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+// --- File sharing between users ---
+//
+// Per-user storage is isolated by prefix. A share grants one user read
+// access to one object in another user's space; grants live outside every
+// user prefix, so user_store can't forge them.
+
+// Share is a read grant on one object in the owner's space.
+type Share struct {
+	OwnerID   string    `json:"owner_id"`
+	GranteeID string    `json:"grantee_id"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const sharesPrefix = "memory/shares/"
+
+func shareKey(ownerID, granteeID, key string) string {
+	return fmt.Sprintf("%s%s/%s/%s", sharesPrefix, ownerID, granteeID, key)
+}
+
+// checkUserID rejects IDs that would escape or alias another user's prefix.
+func checkUserID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("invalid user ID %q", id)
+	}
+	return nil
+}
+
+// checkUserKey rejects keys that name no object or aren't a plain path.
+func checkUserKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// ShareFile lets granteeID read key in ownerID's space. The object must
+// exist; sharing it again refreshes the grant.
+func (ce *CloudEnv) ShareFile(ctx context.Context, ownerID, key, granteeID string) (*Share, error) {
+	for _, id := range []string{ownerID, granteeID} {
+		if err := checkUserID(id); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkUserKey(key); err != nil {
+		return nil, err
+	}
+	if ownerID == granteeID {
+		return nil, fmt.Errorf("%s already owns %s", ownerID, key)
+	}
+	exists, err := ce.R2.ObjectExists(ctx, ce.Bucket, userObjectKey(ownerID, key))
+	if err != nil {
+		return nil, fmt.Errorf("check %s: %w", key, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %s has no file %s", ownerID, key)
+	}
+	s := Share{OwnerID: ownerID, GranteeID: granteeID, Key: key, CreatedAt: time.Now()}
+	data, _ := json.Marshal(s)
+	if err := ce.R2.UploadObject(ctx, ce.Bucket, shareKey(ownerID, granteeID, key), data); err != nil {
+		return nil, fmt.Errorf("save share: %w", err)
+	}
+	return &s, nil
+}
+
+// RevokeShare removes granteeID's access to key in ownerID's space.
+func (ce *CloudEnv) RevokeShare(ctx context.Context, ownerID, key, granteeID string) error {
+	for _, id := range []string{ownerID, granteeID} {
+		if err := checkUserID(id); err != nil {
+			return err
+		}
+	}
+	if err := checkUserKey(key); err != nil {
+		return err
+	}
+	k := shareKey(ownerID, granteeID, key)
+	exists, err := ce.R2.ObjectExists(ctx, ce.Bucket, k)
+	if err != nil {
+		return fmt.Errorf("check share: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%s is not shared with %s", key, granteeID)
+	}
+	return ce.R2.DeleteObject(ctx, ce.Bucket, k)
+}
+
+// deleteShares removes every share userID granted or received.
+func (ce *CloudEnv) deleteShares(ctx context.Context, userID string) (int, error) {
+	deleted, err := storage.DeletePrefix(ctx, ce.R2, ce.Bucket, sharesPrefix+userID+"/")
+	if err != nil {
+		return deleted, fmt.Errorf("delete shares of %s: %w", userID, err)
+	}
+	infos, err := ce.R2.ListObjectInfos(ctx, ce.Bucket, sharesPrefix)
+	if err != nil {
+		return deleted, fmt.Errorf("list shares: %w", err)
+	}
+	for _, info := range infos {
+		// memory/shares/<owner>/<grantee>/<key>
+		parts := strings.SplitN(strings.TrimPrefix(info.Key, sharesPrefix), "/", 3)
+		if len(parts) == 3 && parts[1] == userID {
+			if err := ce.R2.DeleteObject(ctx, ce.Bucket, info.Key); err != nil {
+				return deleted, fmt.Errorf("delete share %s: %w", info.Key, err)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// hasShare reports whether granteeID may read key in ownerID's space.
+func (ce *CloudEnv) hasShare(ctx context.Context, ownerID, key, granteeID string) (bool, error) {
+	return ce.R2.ObjectExists(ctx, ce.Bucket, shareKey(ownerID, granteeID, key))
+}
@@ -5,17 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/bigneek/picoflare/pkg/clock"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
 // TokenLedger tracks all token expenditure across the agent's lifetime.
 type TokenLedger struct {
 	mu     sync.Mutex
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
 
 	// Current session
@@ -23,6 +26,19 @@ type TokenLedger struct {
 
 	// Lifetime (loaded from R2)
 	Lifetime LifetimeStats
+
+	// Per-chat spend, keyed by agent ID (see agentctx.FormatAgentID)
+	chats map[string]*ChatStats
+
+	// Budget alerts (see SetBudget)
+	budget        Budget
+	onBudgetAlert func(msg string)
+
+	// Model pricing in effect: built-in defaults merged with overrides.
+	pricing   map[string][2]float64
+	overrides map[string]ModelPrice
+
+	clock clock.Clock // see SetClock
 }
 
 type SessionStats struct {
@@ -35,6 +51,8 @@ type SessionStats struct {
 	ByTool           map[string]int `json:"by_tool"`
 	ByModel          map[string]int `json:"by_model"`
 	CostUSD          float64        `json:"cost_usd"`
+
+	ToolTime map[string]*ToolTiming `json:"tool_time"`
 }
 
 type LifetimeStats struct {
@@ -47,11 +65,68 @@ type LifetimeStats struct {
 	TotalCostUSD     float64          `json:"total_cost_usd"`
 	ByTool           map[string]int64 `json:"by_tool"`
 	ByDay            map[string]int64 `json:"by_day"` // "20060102" -> total tokens
+
+	ToolTime map[string]*ToolTiming `json:"tool_time"`
+
+	CostByDay    map[string]float64 `json:"cost_by_day"`   // "20060102" -> cost
+	BudgetAlerts map[string]string  `json:"budget_alerts"` // "daily"/"monthly" -> last period alerted
 }
 
-const ledgerKey = "memory/tokenomics/lifetime.json"
+// ToolTiming is the accumulated wall-clock time spent in one tool.
+type ToolTiming struct {
+	Calls   int64 `json:"calls"`
+	TotalMs int64 `json:"total_ms"`
+}
+
+// AvgMs is the mean duration of a call in milliseconds.
+func (t *ToolTiming) AvgMs() int64 {
+	if t.Calls == 0 {
+		return 0
+	}
+	return t.TotalMs / t.Calls
+}
+
+func (t *ToolTiming) add(d time.Duration) {
+	t.Calls++
+	t.TotalMs += d.Milliseconds()
+}
+
+// Budget holds cost alert thresholds in USD. Zero = no alert.
+type Budget struct {
+	DailyUSD   float64
+	MonthlyUSD float64
+}
+
+// ChatStats is the spend attributed to one chat (agent ID).
+type ChatStats struct {
+	AgentID          string             `json:"agent_id"`
+	FirstSeen        time.Time          `json:"first_seen"`
+	LastUsed         time.Time          `json:"last_used"`
+	Messages         int64              `json:"messages"`
+	PromptTokens     int64              `json:"prompt_tokens"`
+	CompletionTokens int64              `json:"completion_tokens"`
+	ToolCalls        int64              `json:"tool_calls"`
+	CostUSD          float64            `json:"cost_usd"`
+	ByDay            map[string]float64 `json:"by_day"` // "20060102" -> cost
+}
+
+// ModelPrice is the USD price per 1M tokens for a model.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
 
-// Model pricing (per 1M tokens) -- approximations for OpenRouter
+const (
+	ledgerKey  = "memory/tokenomics/lifetime.json"
+	pricingKey = "memory/tokenomics/pricing.json"
+)
+
+func chatLedgerKey(agentID string) string {
+	return fmt.Sprintf("agents/%s/tokenomics.json", agentID)
+}
+
+// Model pricing (per 1M tokens) -- approximations for OpenRouter.
+// Built-in defaults; overridden by pricing.json in R2 or a local file (see LoadPricing).
 var modelPricing = map[string][2]float64{
 	"moonshotai/kimi-k2.5":        {0.60, 2.40},
 	"anthropic/claude-sonnet-4":   {3.00, 15.00},
@@ -62,15 +137,26 @@ var modelPricing = map[string][2]float64{
 	"deepseek/deepseek-chat":      {0.14, 0.28},
 }
 
-func NewTokenLedger(r2 *storage.R2Client, bucket string) *TokenLedger {
+// defaultPricing is used for models with no known price (cheap model pricing).
+var defaultPricing = [2]float64{0.50, 2.00}
+
+func NewTokenLedger(r2 storage.ObjectStore, bucket string) *TokenLedger {
 	tl := &TokenLedger{
 		r2:     r2,
 		bucket: bucket,
 		Session: SessionStats{
-			StartedAt: time.Now(),
-			ByTool:    make(map[string]int),
-			ByModel:   make(map[string]int),
+			ByTool:   make(map[string]int),
+			ByModel:  make(map[string]int),
+			ToolTime: make(map[string]*ToolTiming),
 		},
+		chats:     make(map[string]*ChatStats),
+		pricing:   make(map[string][2]float64, len(modelPricing)),
+		overrides: make(map[string]ModelPrice),
+		clock:     clock.Real,
+	}
+	tl.Session.StartedAt = tl.clock.Now()
+	for model, p := range modelPricing {
+		tl.pricing[model] = p
 	}
 	return tl
 }
@@ -82,15 +168,13 @@ func (tl *TokenLedger) LoadLifetime(ctx context.Context) {
 	data, err := tl.r2.DownloadObject(ctx, tl.bucket, ledgerKey)
 	if err != nil {
 		tl.Lifetime = LifetimeStats{
-			FirstSeen: time.Now(),
+			FirstSeen: tl.clock.Now(),
 			ByTool:    make(map[string]int64),
 			ByDay:     make(map[string]int64),
 		}
-		return
-	}
-	if err := json.Unmarshal(data, &tl.Lifetime); err != nil {
+	} else if err := json.Unmarshal(data, &tl.Lifetime); err != nil {
 		tl.Lifetime = LifetimeStats{
-			FirstSeen: time.Now(),
+			FirstSeen: tl.clock.Now(),
 			ByTool:    make(map[string]int64),
 			ByDay:     make(map[string]int64),
 		}
@@ -101,6 +185,97 @@ func (tl *TokenLedger) LoadLifetime(ctx context.Context) {
 	if tl.Lifetime.ByDay == nil {
 		tl.Lifetime.ByDay = make(map[string]int64)
 	}
+	if tl.Lifetime.ToolTime == nil {
+		tl.Lifetime.ToolTime = make(map[string]*ToolTiming)
+	}
+	if tl.Lifetime.CostByDay == nil {
+		tl.Lifetime.CostByDay = make(map[string]float64)
+	}
+	if tl.Lifetime.BudgetAlerts == nil {
+		tl.Lifetime.BudgetAlerts = make(map[string]string)
+	}
+}
+
+// LoadPricing merges model prices over the built-in defaults: first from
+// pricing.json in R2, then from the local JSON file at path (if set), so the
+// file wins. Both use the form {"model": {"input": 0.6, "output": 2.4}}.
+func (tl *TokenLedger) LoadPricing(ctx context.Context, path string) {
+	if tl.r2 != nil {
+		if data, err := tl.r2.DownloadObject(ctx, tl.bucket, pricingKey); err == nil {
+			var prices map[string]ModelPrice
+			if err := json.Unmarshal(data, &prices); err != nil {
+				log.Printf("tokenomics: invalid %s: %v", pricingKey, err)
+			} else {
+				tl.mergePricing(prices, true)
+				log.Printf("tokenomics: loaded pricing for %d models from R2", len(prices))
+			}
+		}
+	}
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("tokenomics: read pricing file: %v", err)
+		return
+	}
+	var prices map[string]ModelPrice
+	if err := json.Unmarshal(data, &prices); err != nil {
+		log.Printf("tokenomics: invalid pricing file %s: %v", path, err)
+		return
+	}
+	tl.mergePricing(prices, false)
+	log.Printf("tokenomics: loaded pricing for %d models from %s", len(prices), path)
+}
+
+// mergePricing applies prices. persisted marks them as R2 overrides, which
+// SetPricing writes back; file prices apply only to this process.
+func (tl *TokenLedger) mergePricing(prices map[string]ModelPrice, persisted bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	for model, p := range prices {
+		tl.pricing[model] = [2]float64{p.Input, p.Output}
+		if persisted {
+			tl.overrides[model] = p
+		}
+	}
+}
+
+// SetPricing sets the per-1M-token price for a model and saves it to R2.
+func (tl *TokenLedger) SetPricing(ctx context.Context, model string, input, output float64) error {
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if input < 0 || output < 0 {
+		return fmt.Errorf("prices must not be negative")
+	}
+	tl.mu.Lock()
+	tl.pricing[model] = [2]float64{input, output}
+	tl.overrides[model] = ModelPrice{Input: input, Output: output}
+	data, err := json.MarshalIndent(tl.overrides, "", "  ")
+	tl.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if tl.r2 == nil {
+		return nil
+	}
+	if err := tl.r2.UploadObject(ctx, tl.bucket, pricingKey, data); err != nil {
+		return fmt.Errorf("save pricing: %w", err)
+	}
+	return nil
+}
+
+// Pricing returns the per-1M-token price in effect for a model and whether
+// it is known (false = default estimate).
+func (tl *TokenLedger) Pricing(model string) (ModelPrice, bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	p, ok := tl.pricing[model]
+	if !ok {
+		p = defaultPricing
+	}
+	return ModelPrice{Input: p[0], Output: p[1]}, ok
 }
 
 func (tl *TokenLedger) SaveLifetime(ctx context.Context) {
@@ -119,8 +294,91 @@ func (tl *TokenLedger) SaveLifetime(ctx context.Context) {
 	}
 }
 
-// RecordLLMCall logs token usage for a single LLM API call.
-func (tl *TokenLedger) RecordLLMCall(model string, promptTokens, completionTokens int) {
+// LoadChat loads a chat's spend from R2 into the ledger. It is a no-op if the
+// chat is already loaded.
+func (tl *TokenLedger) LoadChat(ctx context.Context, agentID string) {
+	tl.mu.Lock()
+	_, ok := tl.chats[agentID]
+	tl.mu.Unlock()
+	if ok || agentID == "" {
+		return
+	}
+
+	cs := &ChatStats{AgentID: agentID, FirstSeen: tl.clock.Now()}
+	if tl.r2 != nil {
+		if data, err := tl.r2.DownloadObject(ctx, tl.bucket, chatLedgerKey(agentID)); err == nil {
+			if err := json.Unmarshal(data, cs); err != nil {
+				log.Printf("tokenomics: corrupt chat ledger for %s: %v", agentID, err)
+				cs = &ChatStats{AgentID: agentID, FirstSeen: tl.clock.Now()}
+			}
+		}
+	}
+	if cs.ByDay == nil {
+		cs.ByDay = make(map[string]float64)
+	}
+
+	tl.mu.Lock()
+	if _, ok := tl.chats[agentID]; !ok {
+		tl.chats[agentID] = cs
+	}
+	tl.mu.Unlock()
+}
+
+// SaveChat persists a chat's spend to agents/{id}/tokenomics.json.
+func (tl *TokenLedger) SaveChat(ctx context.Context, agentID string) {
+	if tl.r2 == nil {
+		return
+	}
+	tl.mu.Lock()
+	cs, ok := tl.chats[agentID]
+	var data []byte
+	var err error
+	if ok {
+		data, err = json.MarshalIndent(cs, "", "  ")
+	}
+	tl.mu.Unlock()
+	if !ok || err != nil {
+		return
+	}
+	if err := tl.r2.UploadObject(ctx, tl.bucket, chatLedgerKey(agentID), data); err != nil {
+		log.Printf("tokenomics: save chat %s failed: %v", agentID, err)
+	}
+}
+
+// ChatStats returns a copy of a chat's spend, or nil if it has not been loaded.
+func (tl *TokenLedger) ChatStats(agentID string) *ChatStats {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	cs, ok := tl.chats[agentID]
+	if !ok {
+		return nil
+	}
+	cp := *cs
+	cp.ByDay = make(map[string]float64, len(cs.ByDay))
+	for k, v := range cs.ByDay {
+		cp.ByDay[k] = v
+	}
+	return &cp
+}
+
+// chat returns the stats for agentID, creating them if needed. Caller holds tl.mu.
+func (tl *TokenLedger) chat(agentID string) *ChatStats {
+	if agentID == "" {
+		return nil
+	}
+	cs, ok := tl.chats[agentID]
+	if !ok {
+		cs = &ChatStats{AgentID: agentID, FirstSeen: tl.clock.Now(), ByDay: make(map[string]float64)}
+		tl.chats[agentID] = cs
+	}
+	cs.LastUsed = tl.clock.Now()
+	return cs
+}
+
+// RecordLLMCall logs token usage for a single LLM API call. agentID attributes
+// the call to a chat; empty = not attributed.
+func (tl *TokenLedger) RecordLLMCall(agentID, model string, promptTokens, completionTokens int) {
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
@@ -136,12 +394,74 @@ func (tl *TokenLedger) RecordLLMCall(model string, promptTokens, completionToken
 	tl.Lifetime.CompletionTokens += int64(completionTokens)
 	tl.Lifetime.TotalCostUSD += cost
 
-	today := time.Now().Format("20060102")
+	now := tl.clock.Now()
+	today := now.Format("20060102")
 	tl.Lifetime.ByDay[today] += int64(promptTokens + completionTokens)
+	tl.Lifetime.CostByDay[today] += cost
+
+	if cs := tl.chat(agentID); cs != nil {
+		cs.PromptTokens += int64(promptTokens)
+		cs.CompletionTokens += int64(completionTokens)
+		cs.CostUSD += cost
+		cs.ByDay[today] += cost
+	}
+
+	tl.checkBudget(now)
+}
+
+// SetClock replaces the clock that dates spend (ByDay, budget periods). Call
+// before the ledger is in use.
+func (tl *TokenLedger) SetClock(c clock.Clock) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.clock = clock.OrReal(c)
+	tl.Session.StartedAt = tl.clock.Now()
+}
+
+// SetBudget configures daily/monthly cost alerts. onAlert is called in its own
+// goroutine the first time spend crosses a threshold in each day or month.
+func (tl *TokenLedger) SetBudget(b Budget, onAlert func(msg string)) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.budget = b
+	tl.onBudgetAlert = onAlert
 }
 
-// RecordToolCall logs a tool invocation.
-func (tl *TokenLedger) RecordToolCall(toolName string) {
+// checkBudget fires alerts for thresholds crossed in the current period.
+// Caller holds tl.mu.
+func (tl *TokenLedger) checkBudget(now time.Time) {
+	if tl.onBudgetAlert == nil {
+		return
+	}
+	if tl.Lifetime.BudgetAlerts == nil {
+		tl.Lifetime.BudgetAlerts = make(map[string]string)
+	}
+
+	day := now.Format("20060102")
+	if limit := tl.budget.DailyUSD; limit > 0 && tl.Lifetime.BudgetAlerts["daily"] != day {
+		if spent := tl.Lifetime.CostByDay[day]; spent >= limit {
+			tl.Lifetime.BudgetAlerts["daily"] = day
+			go tl.onBudgetAlert(fmt.Sprintf("Daily budget reached: $%.4f spent today (limit $%.2f).", spent, limit))
+		}
+	}
+
+	month := now.Format("200601")
+	if limit := tl.budget.MonthlyUSD; limit > 0 && tl.Lifetime.BudgetAlerts["monthly"] != month {
+		var spent float64
+		for d, c := range tl.Lifetime.CostByDay {
+			if strings.HasPrefix(d, month) {
+				spent += c
+			}
+		}
+		if spent >= limit {
+			tl.Lifetime.BudgetAlerts["monthly"] = month
+			go tl.onBudgetAlert(fmt.Sprintf("Monthly budget reached: $%.4f spent in %s (limit $%.2f).", spent, now.Format("January 2006"), limit))
+		}
+	}
+}
+
+// RecordToolCall logs a tool invocation and how long it took.
+func (tl *TokenLedger) RecordToolCall(agentID, toolName string, elapsed time.Duration) {
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
@@ -149,15 +469,43 @@ func (tl *TokenLedger) RecordToolCall(toolName string) {
 	tl.Session.ByTool[toolName]++
 	tl.Lifetime.TotalToolCalls++
 	tl.Lifetime.ByTool[toolName]++
+
+	for _, m := range []map[string]*ToolTiming{tl.Session.ToolTime, tl.Lifetime.ToolTime} {
+		if m == nil {
+			continue
+		}
+		t := m[toolName]
+		if t == nil {
+			t = &ToolTiming{}
+			m[toolName] = t
+		}
+		t.add(elapsed)
+	}
+
+	if cs := tl.chat(agentID); cs != nil {
+		cs.ToolCalls++
+	}
 }
 
 // RecordMessage logs a user message.
-func (tl *TokenLedger) RecordMessage() {
+func (tl *TokenLedger) RecordMessage(agentID string) {
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
 	tl.Session.Messages++
 	tl.Lifetime.TotalMessages++
+
+	if cs := tl.chat(agentID); cs != nil {
+		cs.Messages++
+	}
+}
+
+// Totals returns this session's token counts and the lifetime cost. Use it
+// instead of reading Session and Lifetime while calls may be recorded.
+func (tl *TokenLedger) Totals() (sessionIn, sessionOut int, lifetimeCost float64) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return tl.Session.PromptTokens, tl.Session.CompletionTokens, tl.Lifetime.TotalCostUSD
 }
 
 // FlushSession saves session data to lifetime and persists.
@@ -169,10 +517,9 @@ func (tl *TokenLedger) FlushSession(ctx context.Context) {
 }
 
 func (tl *TokenLedger) estimateCost(model string, prompt, completion int) float64 {
-	pricing, ok := modelPricing[model]
+	pricing, ok := tl.pricing[model]
 	if !ok {
-		// Default to cheap model pricing
-		pricing = [2]float64{0.50, 2.00}
+		pricing = defaultPricing
 	}
 	return (float64(prompt)*pricing[0] + float64(completion)*pricing[1]) / 1_000_000
 }
@@ -202,6 +549,7 @@ func (tl *TokenLedger) Report() string {
 		}
 		sb.WriteString(strings.Join(parts, ", ") + "\n")
 	}
+	writeSlowTools(&sb, tl.Session.ToolTime)
 
 	sb.WriteString("\n### Lifetime\n")
 	sb.WriteString(fmt.Sprintf("- Since: %s\n", tl.Lifetime.FirstSeen.Format("2006-01-02")))
@@ -210,10 +558,46 @@ func (tl *TokenLedger) Report() string {
 	sb.WriteString(fmt.Sprintf("- Tokens: %d in / %d out\n", tl.Lifetime.PromptTokens, tl.Lifetime.CompletionTokens))
 	sb.WriteString(fmt.Sprintf("- Tool calls: %d\n", tl.Lifetime.TotalToolCalls))
 	sb.WriteString(fmt.Sprintf("- Total cost: $%.6f\n", tl.Lifetime.TotalCostUSD))
+	writeSlowTools(&sb, tl.Lifetime.ToolTime)
 
 	return sb.String()
 }
 
+// slowToolsShown caps the tool latency list in the report.
+const slowToolsShown = 5
+
+// writeSlowTools lists the tools with the most total time, with their average.
+func writeSlowTools(sb *strings.Builder, times map[string]*ToolTiming) {
+	if len(times) == 0 {
+		return
+	}
+	names := make([]string, 0, len(times))
+	for name := range times {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := times[names[i]].TotalMs, times[names[j]].TotalMs
+		if ti != tj {
+			return ti > tj
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > slowToolsShown {
+		names = names[:slowToolsShown]
+	}
+	sb.WriteString("- Slowest tools (total / avg): ")
+	parts := make([]string, len(names))
+	for i, name := range names {
+		t := times[name]
+		parts[i] = fmt.Sprintf("%s %s / %s", name, fmtMs(t.TotalMs), fmtMs(t.AvgMs()))
+	}
+	sb.WriteString(strings.Join(parts, ", ") + "\n")
+}
+
+func fmtMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
+
 // ContextCost estimates token count for a string (rough: 1 token ~ 4 chars).
 func ContextCost(s string) int {
 	return len(s) / 4
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TranscribeModel is the audio-capable model TranscribeAudio uses.
+const TranscribeModel = "google/gemini-2.5-flash"
+
+const transcribePrompt = "Transcribe this audio word for word. Reply with the transcript only, in the language spoken; reply with nothing if there is no speech."
+
+// TranscribeAudio transcribes speech by sending the audio as an input_audio
+// content part to TranscribeModel. audioData is the raw audio bytes; format
+// is its format (ogg, wav, mp3, etc.; default ogg). Only OpenAI-compatible
+// endpoints that accept audio input (OpenRouter) can do this.
+func (c *Client) TranscribeAudio(ctx context.Context, audioData []byte, format string) (string, error) {
+	if c.Provider != nil {
+		return "", fmt.Errorf("transcription is only supported on OpenAI-compatible endpoints")
+	}
+	if len(audioData) == 0 {
+		return "", fmt.Errorf("no audio data")
+	}
+	if format == "" {
+		format = "ogg"
+	}
+	messages := []Message{{
+		Role:  "user",
+		Parts: []ContentPart{TextPart(transcribePrompt), AudioPart(audioData, format)},
+	}}
+	result, err := c.ChatWithModel(ctx, TranscribeModel, messages, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	text := strings.TrimSpace(result.Content)
+	if text == "" {
+		return "", fmt.Errorf("no speech recognized")
+	}
+	return text, nil
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTranscribeAudio(t *testing.T) {
+	audio, err := os.ReadFile("testdata/silence.ogg") // one silent Opus frame
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct { // Message doesn't read Parts back, so decode them here
+		Model    string `json:"model"`
+		Messages []struct {
+			Content []ContentPart `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  hello there \n"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+	c := NewClient("key", "")
+	c.SetBaseURL(srv.URL)
+
+	text, err := c.TranscribeAudio(context.Background(), audio, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello there" {
+		t.Fatalf("transcript = %q, want %q", text, "hello there")
+	}
+
+	if got.Model != TranscribeModel {
+		t.Errorf("model = %q, want %q", got.Model, TranscribeModel)
+	}
+	var part *InputAudio
+	for _, m := range got.Messages {
+		for _, p := range m.Content {
+			if p.Type == "input_audio" {
+				part = p.InputAudio
+			}
+		}
+	}
+	if part == nil {
+		t.Fatalf("request has no input_audio part: %+v", got.Messages)
+	}
+	if part.Format != "ogg" {
+		t.Errorf("format = %q, want the ogg default", part.Format)
+	}
+	if data, err := base64.StdEncoding.DecodeString(part.Data); err != nil || !bytes.Equal(data, audio) {
+		t.Errorf("input_audio data doesn't decode to the fixture (%v)", err)
+	}
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// APIError is an error answer from the LLM provider.
+type APIError struct {
+	StatusCode int    // HTTP status; 200 for an error sent in a stream
+	Message    string // as shown to the user
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+// unavailableMarkers are phrases in provider errors meaning this model can't
+// serve the request right now, whatever the status code.
+var unavailableMarkers = []string{
+	"unavailable",
+	"overloaded",
+	"no endpoints found",
+	"no allowed providers",
+	"model not found",
+}
+
+// modelUnavailable reports whether err means another model may succeed: a 5xx
+// from the provider, or an error saying the model is unavailable.
+func modelUnavailable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 500 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range unavailableMarkers {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// noFallback marks an error that must not be retried on another model, e.g.
+// one after part of the answer was already streamed.
+type noFallback struct{ err error }
+
+func (e noFallback) Error() string { return e.err.Error() }
+
+// withFallbacks calls try with model and, while it fails with
+// modelUnavailable, with each of c.Fallbacks in turn. The result's Model is
+// the one that answered.
+func (c *Client) withFallbacks(model string, try func(model string) (*ChatResult, error)) (*ChatResult, error) {
+	chain := []string{model}
+	for _, m := range c.Fallbacks {
+		if m != "" && m != model {
+			chain = append(chain, m)
+		}
+	}
+	var err error
+	for i, m := range chain {
+		var result *ChatResult
+		if result, err = try(m); err == nil {
+			result.Model = m
+			if i > 0 {
+				log.Printf("LLM: fallback %s served the request instead of %s", m, model)
+			}
+			return result, nil
+		}
+		var nf noFallback
+		if errors.As(err, &nf) {
+			return nil, nf.err
+		}
+		if !modelUnavailable(err) || i == len(chain)-1 {
+			break
+		}
+		log.Printf("LLM: %s failed (%v), falling back to %s", m, err, chain[i+1])
+	}
+	return nil, err
+}
@@ -1,17 +1,20 @@
 // Package llm provides an OpenRouter (OpenAI-compatible) chat client
-// with function-calling (tool use) support.
+// with function-calling (tool use) support. Workers AI can be used as an
+// alternative provider (see NewWorkersAIClient).
 package llm
 
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/bigneek/picoflare/pkg/retry"
 )
 
 const defaultEndpoint = "https://openrouter.ai/api/v1/chat/completions"
@@ -23,6 +26,10 @@ type Message struct {
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 	Name       string     `json:"name,omitempty"`
+
+	// Parts, when set, replaces Content with multimodal parts (text and
+	// images); see MarshalJSON.
+	Parts []ContentPart `json:"-"`
 }
 
 // ToolCall is the LLM's request to invoke a tool.
@@ -55,6 +62,26 @@ type Client struct {
 	Endpoint string
 	http     *http.Client
 
+	// Provider, when set, handles chat completions instead of the
+	// OpenAI-compatible Endpoint (e.g. Workers AI). Nil = OpenRouter.
+	Provider Provider
+
+	// ExcludeReasoning asks OpenRouter not to return reasoning models'
+	// thinking at all. Either way it is kept out of ChatResult.Content.
+	ExcludeReasoning bool
+
+	// Sampling is the default temperature, max_tokens, and top_p of every
+	// call (unset = the provider's); WithSampling overrides it per call.
+	Sampling
+
+	// Fallbacks are models tried in order when the requested one fails with
+	// a 5xx or says it is unavailable.
+	Fallbacks []string
+
+	// MaxRetries is how many times a request rate limited with HTTP 429 is
+	// resent, after the Retry-After wait (or an exponential backoff).
+	MaxRetries int
+
 	TotalPromptTokens     int
 	TotalCompletionTokens int
 }
@@ -64,29 +91,91 @@ func NewClient(apiKey, model string) *Client {
 		model = "moonshotai/kimi-k2.5"
 	}
 	return &Client{
-		APIKey:   apiKey,
-		Model:    model,
-		Endpoint: defaultEndpoint,
-		http:     &http.Client{Timeout: 600 * time.Second},
+		APIKey:     apiKey,
+		Model:      model,
+		Endpoint:   defaultEndpoint,
+		http:       &http.Client{Timeout: 600 * time.Second},
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+const (
+	defaultMaxRetries = 3
+	// rateLimitBackoff is the first wait after a 429 without Retry-After;
+	// it doubles on each further retry.
+	rateLimitBackoff = 2 * time.Second
+	// maxRateLimitWait is the longest wait before a retry. A longer
+	// Retry-After fails immediately instead of stalling the reply.
+	maxRateLimitWait = time.Minute
+)
+
+// post sends body to the chat completions endpoint, resending it up to
+// MaxRetries times while the server answers 429. The caller closes the
+// response body.
+func (c *Client) post(ctx context.Context, body []byte, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			httpReq.Header.Set("Accept", accept)
+		}
+		if c.APIKey != "" { // local servers (Ollama, LM Studio) need no key
+			httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+		}
+		httpReq.Header.Set("HTTP-Referer", "https://github.com/walter-grace/pico-flare")
+		httpReq.Header.Set("X-Title", "PicoFlare")
+
+		resp, err := c.http.Do(httpReq)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= c.MaxRetries {
+			return resp, err
+		}
+		wait := retry.After(resp.Header.Get("Retry-After"), rateLimitBackoff<<attempt)
+		if wait > maxRateLimitWait {
+			log.Printf("LLM rate limited, Retry-After %s exceeds %s; giving up", wait, maxRateLimitWait)
+			return resp, nil
+		}
+		resp.Body.Close()
+		log.Printf("LLM rate limited (HTTP 429), retrying in %s (%d/%d)", wait, attempt+1, c.MaxRetries)
+		if err := retry.Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SetBaseURL points the client at another OpenAI-compatible server, e.g.
+// "http://localhost:11434/v1" for Ollama or "http://localhost:1234/v1" for
+// LM Studio. A full ".../chat/completions" URL is used as-is.
+func (c *Client) SetBaseURL(baseURL string) {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return
 	}
+	if strings.HasSuffix(baseURL, "/chat/completions") {
+		c.Endpoint = baseURL
+		return
+	}
+	c.Endpoint = baseURL + "/chat/completions"
 }
 
 type chatRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
 	Tools    []ToolDef `json:"tools,omitempty"`
+
+	ToolChoice *ToolChoice       `json:"tool_choice,omitempty"`
+	Reasoning  *reasoningOptions `json:"reasoning,omitempty"`
+	samplingParams
 }
 
 type chatResponse struct {
 	Choices []struct {
-		Message      Message `json:"message"`
-		FinishReason string  `json:"finish_reason"`
+		Message      responseMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
 	} `json:"choices"`
-	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -97,70 +186,120 @@ type ChatResult struct {
 	Content      string
 	ToolCalls    []ToolCall
 	FinishReason string
+
+	// Reasoning is a reasoning model's thinking, from the response's
+	// reasoning field or inline <think> tags (which are removed from Content).
+	Reasoning string
+
+	// Model is the model that answered: the one asked for, or a fallback
+	// (see Client.Fallbacks).
+	Model string
+
+	// PromptTokens and CompletionTokens are the call's usage as reported by
+	// the provider; both are 0 when it reports none.
+	PromptTokens     int
+	CompletionTokens int
 }
 
 // Chat sends messages (with optional tools) and returns the full result.
 func (c *Client) Chat(ctx context.Context, messages []Message, tools []ToolDef) (*ChatResult, error) {
-	return c.ChatWithModel(ctx, "", messages, tools)
+	return c.ChatWithModel(ctx, "", messages, tools, nil)
 }
 
 // ChatWithModel sends messages using the given model. If model is empty, uses c.Model.
-func (c *Client) ChatWithModel(ctx context.Context, model string, messages []Message, tools []ToolDef) (*ChatResult, error) {
+// A non-nil choice forces the model to call that tool (see ForceTool).
+func (c *Client) ChatWithModel(ctx context.Context, model string, messages []Message, tools []ToolDef, choice *ToolChoice) (*ChatResult, error) {
 	if model == "" {
 		model = c.Model
 	}
+
+	var p Provider = openAICompat{c}
+	if c.Provider != nil {
+		p = c.Provider
+	}
+	ctx = c.withClientSampling(ctx)
+	return c.withFallbacks(model, func(model string) (*ChatResult, error) {
+		ctx, span := startChatSpan(ctx, model, len(tools), false)
+		result, usage, err := p.ChatCompletion(ctx, model, messages, tools, choice)
+		endChatSpan(span, result, usage, err)
+		if err != nil {
+			return nil, err
+		}
+		c.finishResult(model, result, usage)
+		return result, nil
+	})
+}
+
+// finishResult moves inline <think> reasoning out of the answer, copies the
+// usage into result, and adds it to the session totals.
+func (c *Client) finishResult(model string, result *ChatResult, usage *Usage) {
+	if content, thinking := splitThinking(result.Content); thinking != "" {
+		result.Content = content
+		if result.Reasoning == "" {
+			result.Reasoning = thinking
+		}
+	}
+
+	if usage != nil {
+		result.PromptTokens = usage.PromptTokens
+		result.CompletionTokens = usage.CompletionTokens
+		c.TotalPromptTokens += usage.PromptTokens
+		c.TotalCompletionTokens += usage.CompletionTokens
+		log.Printf("LLM %s [tokens: %d in, %d out | session total: %d in, %d out]",
+			model, usage.PromptTokens, usage.CompletionTokens,
+			c.TotalPromptTokens, c.TotalCompletionTokens)
+	}
+}
+
+// openAICompat is the default provider: an OpenAI-compatible chat completions
+// endpoint (OpenRouter unless Client.Endpoint is changed).
+type openAICompat struct{ c *Client }
+
+func (o openAICompat) ChatCompletion(ctx context.Context, model string, messages []Message, tools []ToolDef, toolChoice *ToolChoice) (*ChatResult, *Usage, error) {
+	c := o.c
 	req := chatRequest{
-		Model:    model,
-		Messages: messages,
+		Model:          model,
+		Messages:       messages,
+		samplingParams: samplingFrom(ctx).params(),
 	}
 	if len(tools) > 0 {
 		req.Tools = tools
+		req.ToolChoice = toolChoice
 	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
+	if c.ExcludeReasoning {
+		req.Reasoning = &reasoningOptions{Exclude: true}
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-	httpReq.Header.Set("HTTP-Referer", "https://github.com/walter-grace/pico-flare")
-	httpReq.Header.Set("X-Title", "PicoFlare")
 
-	resp, err := c.http.Do(httpReq)
+	resp, err := c.post(ctx, body, "")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var chatResp chatResponse
 	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return nil, fmt.Errorf("decode LLM response: %w\nBody: %s", err, string(respBody[:min(len(respBody), 500)]))
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("LLM error: HTTP %d: %s", resp.StatusCode, string(respBody[:min(len(respBody), 500)]))}
+		}
+		return nil, nil, fmt.Errorf("decode LLM response: %w\nBody: %s", err, string(respBody[:min(len(respBody), 500)]))
 	}
 
 	if chatResp.Error != nil {
-		return nil, fmt.Errorf("LLM error: %s", chatResp.Error.Message)
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: "LLM error: " + chatResp.Error.Message}
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return nil, fmt.Errorf("LLM returned no choices")
-	}
-
-	if chatResp.Usage != nil {
-		c.TotalPromptTokens += chatResp.Usage.PromptTokens
-		c.TotalCompletionTokens += chatResp.Usage.CompletionTokens
-		log.Printf("LLM [tokens: %d in, %d out | session total: %d in, %d out]",
-			chatResp.Usage.PromptTokens, chatResp.Usage.CompletionTokens,
-			c.TotalPromptTokens, c.TotalCompletionTokens)
+		return nil, nil, fmt.Errorf("LLM returned no choices")
 	}
 
 	choice := chatResp.Choices[0]
@@ -168,7 +307,8 @@ func (c *Client) ChatWithModel(ctx context.Context, model string, messages []Mes
 		Content:      choice.Message.Content,
 		ToolCalls:    choice.Message.ToolCalls,
 		FinishReason: choice.FinishReason,
-	}, nil
+		Reasoning:    choice.Message.reasoning(),
+	}, chatResp.Usage, nil
 }
 
 // SimpleChat is a convenience method for tool-free chat.
@@ -186,33 +326,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-// TranscribeAudio transcribes audio using OpenRouter's multimodal API.
-// audioData is the raw audio bytes. format is the audio format (ogg, wav, mp3, etc.)
-func (c *Client) TranscribeAudio(ctx context.Context, audioData []byte, format string) (string, error) {
-	if format == "" {
-		format = "ogg"
-	}
-
-	// Base64 encode the audio
-	base64Audio := base64.StdEncoding.EncodeToString(audioData)
-
-	// Build request with audio content
-	messages := []Message{
-		{
-			Role: "user",
-			Content: fmt.Sprintf(`Transcribe this audio file word for word.
-
-Audio data (base64, format: %s):
-%s`, format, base64Audio),
-		},
-	}
-
-	// Use a model that supports audio
-	result, err := c.ChatWithModel(ctx, "google/gemini-2.0-flash-exp:free", messages, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return result.Content, nil
-}
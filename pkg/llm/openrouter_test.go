@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatLocalBaseURL(t *testing.T) {
+	var path, auth, model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		model = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+	c := NewClient("", "llama3.1") // Ollama-style: no key, the server's model name
+	c.SetBaseURL(srv.URL + "/v1")
+
+	res, err := c.Chat(context.Background(), []Message{{Role: "user", Content: "hello"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "hi" {
+		t.Errorf("Content = %q, want %q", res.Content, "hi")
+	}
+	if path != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", path)
+	}
+	if model != "llama3.1" {
+		t.Errorf("model = %q, want llama3.1", model)
+	}
+	if auth != "" {
+		t.Errorf("Authorization = %q, want none without an API key", auth)
+	}
+}
@@ -0,0 +1,32 @@
+package llm
+
+import "context"
+
+// Provider is a chat-completion backend. Client handles model defaults and
+// token accounting; the provider only performs the request.
+type Provider interface {
+	ChatCompletion(ctx context.Context, model string, messages []Message, tools []ToolDef, choice *ToolChoice) (*ChatResult, *Usage, error)
+}
+
+// ToolChoice is the OpenAI "tool_choice" request field naming a function the
+// model must call. A nil *ToolChoice leaves the choice to the model.
+type ToolChoice struct {
+	Type     string             `json:"type"`
+	Function ToolChoiceFunction `json:"function"`
+}
+
+type ToolChoiceFunction struct {
+	Name string `json:"name"`
+}
+
+// ForceTool returns a ToolChoice that requires a call to the named tool.
+func ForceTool(name string) *ToolChoice {
+	return &ToolChoice{Type: "function", Function: ToolChoiceFunction{Name: name}}
+}
+
+// Usage is the token usage reported for a single completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
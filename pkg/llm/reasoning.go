@@ -0,0 +1,58 @@
+package llm
+
+import "strings"
+
+// responseMessage is an assistant message as returned by the API. Reasoning
+// models add their chain of thought in a separate field: "reasoning" on
+// OpenRouter, "reasoning_content" on DeepSeek-style endpoints.
+type responseMessage struct {
+	Message
+	Reasoning        string `json:"reasoning"`
+	ReasoningContent string `json:"reasoning_content"`
+}
+
+// reasoning returns whichever reasoning field the provider filled.
+func (m responseMessage) reasoning() string {
+	if m.Reasoning != "" {
+		return m.Reasoning
+	}
+	return m.ReasoningContent
+}
+
+// reasoningOptions is OpenRouter's "reasoning" request field.
+type reasoningOptions struct {
+	Exclude bool `json:"exclude"`
+}
+
+// splitThinking separates <think>...</think> blocks, which some models emit
+// inline, from the answer. A "</think>" without an opening tag (models whose
+// template opens it in the prompt) marks everything before it as reasoning.
+func splitThinking(content string) (answer, thinking string) {
+	const open, close = "<think>", "</think>"
+	end := strings.Index(content, close)
+	if end < 0 {
+		return content, ""
+	}
+	start := strings.Index(content, open)
+	if start < 0 || start > end {
+		return strings.TrimSpace(content[end+len(close):]), strings.TrimSpace(content[:end])
+	}
+	var thoughts []string
+	var sb strings.Builder
+	for {
+		start := strings.Index(content, open)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(content[start:], close)
+		if end < 0 {
+			break
+		}
+		end += start
+		thoughts = append(thoughts, strings.TrimSpace(content[start+len(open):end]))
+		sb.WriteString(content[:start])
+		content = content[end+len(close):]
+	}
+	sb.WriteString(content)
+	return strings.TrimSpace(sb.String()), strings.Join(thoughts, "\n\n")
+}
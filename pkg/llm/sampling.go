@@ -0,0 +1,61 @@
+package llm
+
+import "context"
+
+// Sampling holds optional generation parameters. Unset fields are left out
+// of the request, so the provider's defaults apply.
+type Sampling struct {
+	// Temperature is a pointer so that 0 (deterministic) can be asked for;
+	// nil leaves it to the provider.
+	Temperature *float64
+	MaxTokens   int
+	TopP        float64
+}
+
+// Temperature returns a Sampling.Temperature of t.
+func Temperature(t float64) *float64 { return &t }
+
+// over returns s with the fields set in o replacing its own.
+func (s Sampling) over(o Sampling) Sampling {
+	if o.Temperature != nil {
+		s.Temperature = o.Temperature
+	}
+	if o.MaxTokens > 0 {
+		s.MaxTokens = o.MaxTokens
+	}
+	if o.TopP > 0 {
+		s.TopP = o.TopP
+	}
+	return s
+}
+
+type samplingKey struct{}
+
+// WithSampling overrides sampling parameters for the calls made with ctx: the
+// fields set in s replace those of the Client, and of any WithSampling
+// further up the context.
+func WithSampling(ctx context.Context, s Sampling) context.Context {
+	return context.WithValue(ctx, samplingKey{}, samplingFrom(ctx).over(s))
+}
+
+func samplingFrom(ctx context.Context) Sampling {
+	s, _ := ctx.Value(samplingKey{}).(Sampling)
+	return s
+}
+
+// withClientSampling resolves the parameters for one call, c.Sampling
+// overlaid with ctx's, and stores them in ctx for the Provider to read.
+func (c *Client) withClientSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, samplingKey{}, c.Sampling.over(samplingFrom(ctx)))
+}
+
+// samplingParams are the OpenAI-compatible request fields for Sampling.
+type samplingParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+}
+
+func (s Sampling) params() samplingParams {
+	return samplingParams{Temperature: s.Temperature, MaxTokens: s.MaxTokens, TopP: s.TopP}
+}
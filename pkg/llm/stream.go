@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DeltaFunc receives the answer text streamed so far, with any inline
+// <think> reasoning removed. It is called from the request goroutine and
+// should return quickly.
+type DeltaFunc func(partial string)
+
+// StreamingProvider is a Provider that can stream completions. Providers
+// without it still work with ChatStream; they deliver one delta at the end.
+type StreamingProvider interface {
+	Provider
+	ChatCompletionStream(ctx context.Context, model string, messages []Message, tools []ToolDef, choice *ToolChoice, onDelta func(chunk string)) (*ChatResult, *Usage, error)
+}
+
+// ChatStream is ChatWithModel with the answer streamed to onDelta as it is
+// generated. The returned result is the same as ChatWithModel's.
+func (c *Client) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, choice *ToolChoice, onDelta DeltaFunc) (*ChatResult, error) {
+	if onDelta == nil {
+		return c.ChatWithModel(ctx, model, messages, tools, choice)
+	}
+	if model == "" {
+		model = c.Model
+	}
+
+	var p Provider = openAICompat{c}
+	if c.Provider != nil {
+		p = c.Provider
+	}
+	sp, ok := p.(StreamingProvider)
+	if !ok {
+		result, err := c.ChatWithModel(ctx, model, messages, tools, choice)
+		if err == nil && result.Content != "" {
+			onDelta(result.Content)
+		}
+		return result, err
+	}
+
+	ctx = c.withClientSampling(ctx)
+	return c.withFallbacks(model, func(model string) (*ChatResult, error) {
+		var acc strings.Builder
+		last := ""
+		ctx, span := startChatSpan(ctx, model, len(tools), true)
+		result, usage, err := sp.ChatCompletionStream(ctx, model, messages, tools, choice, func(chunk string) {
+			acc.WriteString(chunk)
+			if partial := visibleContent(acc.String()); partial != last {
+				last = partial
+				onDelta(partial)
+			}
+		})
+		endChatSpan(span, result, usage, err)
+		if err != nil {
+			if acc.Len() > 0 {
+				return nil, noFallback{err} // the user has seen part of this model's answer
+			}
+			return nil, err
+		}
+		c.finishResult(model, result, usage)
+		return result, nil
+	})
+}
+
+// visibleContent is the part of a partial answer worth showing: reasoning in
+// a closed <think> block is dropped, and so is an unclosed one still being
+// streamed.
+func visibleContent(partial string) string {
+	if start := strings.LastIndex(partial, "<think>"); start >= 0 && !strings.Contains(partial[start:], "</think>") {
+		partial = partial[:start]
+	}
+	answer, _ := splitThinking(partial)
+	return answer
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type streamRequest struct {
+	chatRequest
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamChunk is one server-sent event of a streamed completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string          `json:"content"`
+			Reasoning        string          `json:"reasoning"`
+			ReasoningContent string          `json:"reasoning_content"`
+			ToolCalls        []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// toolCallDelta is a fragment of a tool call; fragments with the same Index
+// belong to one call and their arguments concatenate.
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatCompletionStream implements StreamingProvider with server-sent events.
+func (o openAICompat) ChatCompletionStream(ctx context.Context, model string, messages []Message, tools []ToolDef, toolChoice *ToolChoice, onDelta func(chunk string)) (*ChatResult, *Usage, error) {
+	c := o.c
+	req := streamRequest{
+		chatRequest:   chatRequest{Model: model, Messages: messages, samplingParams: samplingFrom(ctx).params()},
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+	}
+	if len(tools) > 0 {
+		req.Tools = tools
+		req.ToolChoice = toolChoice
+	}
+	if c.ExcludeReasoning {
+		req.Reasoning = &reasoningOptions{Exclude: true}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.post(ctx, body, "text/event-stream")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Errors before streaming starts come back as a plain JSON body
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		respBody, _ := io.ReadAll(resp.Body)
+		var chatResp chatResponse
+		if json.Unmarshal(respBody, &chatResp) == nil && chatResp.Error != nil {
+			return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: "LLM error: " + chatResp.Error.Message}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("LLM error: HTTP %d: %s", resp.StatusCode, string(respBody[:min(len(respBody), 500)]))}
+		}
+		// A server that ignored "stream": an ordinary response
+		if len(chatResp.Choices) == 0 {
+			return nil, nil, fmt.Errorf("LLM returned no choices")
+		}
+		choice := chatResp.Choices[0]
+		onDelta(choice.Message.Content)
+		return &ChatResult{
+			Content:      choice.Message.Content,
+			ToolCalls:    choice.Message.ToolCalls,
+			FinishReason: choice.FinishReason,
+			Reasoning:    choice.Message.reasoning(),
+		}, chatResp.Usage, nil
+	}
+
+	var content, reasoning strings.Builder
+	calls := make(map[int]*ToolCall)
+	var usage *Usage
+	result := &ChatResult{}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators and ": keep-alive" comments
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, nil, fmt.Errorf("decode LLM stream: %w\nChunk: %s", err, data[:min(len(data), 500)])
+		}
+		if chunk.Error != nil {
+			return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: "LLM error: " + chunk.Error.Message}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		delta := choice.Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(delta.Content)
+		}
+		reasoning.WriteString(delta.Reasoning)
+		reasoning.WriteString(delta.ReasoningContent)
+		for _, tc := range delta.ToolCalls {
+			call := calls[tc.Index]
+			if call == nil {
+				call = &ToolCall{Type: "function"}
+				calls[tc.Index] = call
+			}
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+		if choice.FinishReason != "" {
+			result.FinishReason = choice.FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read LLM stream: %w", err)
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for i := range calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		result.ToolCalls = append(result.ToolCalls, *calls[i])
+	}
+	result.Content = content.String()
+	result.Reasoning = reasoning.String()
+	return result, usage, nil
+}
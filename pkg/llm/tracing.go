@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span per chat completion. It is a no-op unless
+// tracing.Setup installed an exporter.
+var tracer = otel.Tracer("github.com/bigneek/picoflare/pkg/llm")
+
+// startChatSpan starts the span for one chat completion. Attribute names
+// follow the OpenTelemetry GenAI conventions.
+func startChatSpan(ctx context.Context, model string, tools int, stream bool) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "chat "+model, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gen_ai.operation.name", "chat"),
+		attribute.String("gen_ai.request.model", model),
+		attribute.Int("llm.tools", tools),
+		attribute.Bool("llm.stream", stream),
+	))
+}
+
+// endChatSpan records the outcome and token usage of a completion and ends
+// the span.
+func endChatSpan(span trace.Span, result *ChatResult, usage *Usage, err error) {
+	defer span.End()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(
+		attribute.StringSlice("gen_ai.response.finish_reasons", []string{result.FinishReason}),
+		attribute.Int("llm.tool_calls", len(result.ToolCalls)),
+	)
+	if usage != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", usage.CompletionTokens),
+		)
+	}
+}
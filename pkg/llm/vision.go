@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoVision means the provider or model can't take image input.
+var ErrNoVision = errors.New("model does not accept images")
+
+// ContentPart is one element of a multimodal message's content array:
+// {"type": "text", "text": ...}, {"type": "image_url", "image_url": {...}},
+// or {"type": "input_audio", "input_audio": {...}}.
+type ContentPart struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+}
+
+// ImageURL points at an image, either a public URL or a data: URL.
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// InputAudio is base64-encoded audio and its format ("wav", "mp3", "ogg", ...).
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// TextPart returns a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+// ImagePart returns an image content part carrying data inline as a data: URL.
+func ImagePart(data []byte, mimeType string) ContentPart {
+	url := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// AudioPart returns an audio content part carrying data inline.
+func AudioPart(data []byte, format string) ContentPart {
+	return ContentPart{Type: "input_audio", InputAudio: &InputAudio{Data: base64.StdEncoding.EncodeToString(data), Format: format}}
+}
+
+// MarshalJSON sends Parts as the OpenAI content array when set, and Content
+// as a plain string otherwise. Parts are request-only: they are not read back
+// when a message is decoded, so don't keep multimodal messages in sessions.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []ContentPart `json:"content"`
+	}{plain(m), m.Parts})
+}
+
+// DescribeImage asks model (c.Model if empty) about an image. prompt is the
+// question; empty asks for a general description. It returns ErrNoVision
+// for providers without image input (Workers AI) and when the API rejects
+// the image as unsupported by the model.
+func (c *Client) DescribeImage(ctx context.Context, model string, image []byte, mimeType, prompt string) (string, error) {
+	if c.Provider != nil {
+		return "", fmt.Errorf("%w: image input is only supported on OpenAI-compatible endpoints", ErrNoVision)
+	}
+	if prompt == "" {
+		prompt = "Describe this image in detail: what it shows, any visible text, and anything notable."
+	}
+	messages := []Message{{
+		Role:  "user",
+		Parts: []ContentPart{TextPart(prompt), ImagePart(image, mimeType)},
+	}}
+	result, err := c.ChatWithModel(ctx, model, messages, nil, nil)
+	if err != nil {
+		if rejectsImages(err) {
+			return "", fmt.Errorf("%w: %v", ErrNoVision, err)
+		}
+		return "", err
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return "", fmt.Errorf("model returned an empty description")
+	}
+	return result.Content, nil
+}
+
+// ChatWithImages is ChatStream with images added to the last user message,
+// for a vision model to look at; messages itself is left text-only. It
+// returns ErrNoVision when the provider or model can't take images, so the
+// caller can retry without them.
+func (c *Client) ChatWithImages(ctx context.Context, model string, messages []Message, images []ContentPart, tools []ToolDef, choice *ToolChoice, onDelta DeltaFunc) (*ChatResult, error) {
+	if c.Provider != nil {
+		return nil, fmt.Errorf("%w: image input is only supported on OpenAI-compatible endpoints", ErrNoVision)
+	}
+	withImages := make([]Message, len(messages))
+	copy(withImages, messages)
+	for i := len(withImages) - 1; i >= 0; i-- {
+		if withImages[i].Role == "user" {
+			parts := []ContentPart{TextPart(withImages[i].Content)}
+			withImages[i].Parts = append(parts, images...)
+			break
+		}
+	}
+	result, err := c.ChatStream(ctx, model, withImages, tools, choice, onDelta)
+	if err != nil && rejectsImages(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoVision, err)
+	}
+	return result, err
+}
+
+// rejectsImages guesses whether an API error means the model has no image
+// input; providers word this differently.
+func rejectsImages(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"image input", "support image", "vision", "modalit", "multimodal", "image_url"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
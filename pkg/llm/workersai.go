@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	workersAIBaseURL      = "https://api.cloudflare.com/client/v4/accounts"
+	defaultWorkersAIModel = "@cf/meta/llama-3.3-70b-instruct-fp8-fast"
+)
+
+// WorkersAI runs chat completions on Cloudflare Workers AI
+// (POST /accounts/{id}/ai/run/{model}), keeping inference on Cloudflare.
+type WorkersAI struct {
+	AccountID string
+	APIToken  string
+	BaseURL   string
+	http      *http.Client
+}
+
+// NewWorkersAI creates a Workers AI provider for the given account and API token.
+// The token needs the "Workers AI - Read" permission.
+func NewWorkersAI(accountID, apiToken string) *WorkersAI {
+	return &WorkersAI{
+		AccountID: accountID,
+		APIToken:  apiToken,
+		BaseURL:   workersAIBaseURL,
+		http:      &http.Client{Timeout: 600 * time.Second},
+	}
+}
+
+// NewWorkersAIClient returns a Client backed by Workers AI.
+// If model is empty, a Llama 3.3 instruct model with function calling is used.
+func NewWorkersAIClient(accountID, apiToken, model string) *Client {
+	if model == "" {
+		model = defaultWorkersAIModel
+	}
+	c := NewClient("", model)
+	c.Provider = NewWorkersAI(accountID, apiToken)
+	return c
+}
+
+type workersAIRequest struct {
+	Messages []Message `json:"messages"`
+	Tools    []ToolDef `json:"tools,omitempty"`
+	samplingParams
+}
+
+type workersAIResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result struct {
+		Response  json.RawMessage `json:"response"`
+		ToolCalls []struct {
+			ID        string          `json:"id,omitempty"`
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"tool_calls"`
+		Usage *Usage `json:"usage"`
+
+		// Newer models answer in the OpenAI chat completions shape.
+		Choices []struct {
+			Message      responseMessage `json:"message"`
+			FinishReason string          `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"result"`
+}
+
+// ChatCompletion implements Provider. Workers AI has no tool_choice, so a
+// forced tool is approximated by offering only that tool.
+func (w *WorkersAI) ChatCompletion(ctx context.Context, model string, messages []Message, tools []ToolDef, choice *ToolChoice) (*ChatResult, *Usage, error) {
+	if w.AccountID == "" || w.APIToken == "" {
+		return nil, nil, fmt.Errorf("workers-ai: CLOUDFLARE_ACCOUNT_ID and CLOUDFLARE_API_TOKEN are required")
+	}
+	if choice != nil {
+		for _, t := range tools {
+			if t.Function.Name == choice.Function.Name {
+				tools = []ToolDef{t}
+				break
+			}
+		}
+	}
+	body, err := json.Marshal(workersAIRequest{Messages: messages, Tools: tools, samplingParams: samplingFrom(ctx).params()})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url := fmt.Sprintf("%s/%s/ai/run/%s", w.BaseURL, w.AccountID, model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+w.APIToken)
+
+	resp, err := w.http.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var aiResp workersAIResponse
+	if err := json.Unmarshal(respBody, &aiResp); err != nil {
+		return nil, nil, fmt.Errorf("decode Workers AI response (HTTP %d): %s", resp.StatusCode, string(respBody[:min(len(respBody), 500)]))
+	}
+	if !aiResp.Success {
+		if len(aiResp.Errors) > 0 {
+			return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("Workers AI error: [%d] %s", aiResp.Errors[0].Code, aiResp.Errors[0].Message)}
+		}
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("Workers AI error (HTTP %d)", resp.StatusCode)}
+	}
+
+	r := aiResp.Result
+	if len(r.Choices) > 0 {
+		choice := r.Choices[0]
+		return &ChatResult{
+			Content:      choice.Message.Content,
+			ToolCalls:    choice.Message.ToolCalls,
+			FinishReason: choice.FinishReason,
+			Reasoning:    choice.Message.reasoning(),
+		}, r.Usage, nil
+	}
+
+	result := &ChatResult{Content: decodeWorkersAIText(r.Response), FinishReason: "stop"}
+	for i, tc := range r.ToolCalls {
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: FunctionCall{Name: tc.Name, Arguments: decodeWorkersAIArgs(tc.Arguments)},
+		})
+	}
+	if len(result.ToolCalls) > 0 {
+		result.FinishReason = "tool_calls"
+	}
+	return result, r.Usage, nil
+}
+
+// decodeWorkersAIText returns the response text. Workers AI sends a string,
+// but some models return null or a JSON object, which is passed through raw.
+func decodeWorkersAIText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// decodeWorkersAIArgs normalizes tool arguments to the JSON string form used
+// by the OpenAI shape. Workers AI sends an object; some models send a string.
+func decodeWorkersAIArgs(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}"
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
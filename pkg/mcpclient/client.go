@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,17 +19,38 @@ const (
 	// SystemPrompt instructs the LLM to use Cloudflare Code Mode: search() and execute()
 	// with JavaScript strings. The LLM chains commands in a single JS payload.
 	SystemPrompt = `You are PicoFlare. You manage Cloudflare via Code Mode. Use the search() tool with JS to search the OpenAPI spec, and the execute() tool with JS to make authenticated calls via the cloudflare.request() object. Chain your commands in a single JS payload.`
+
+	// DefaultEndpoint is Cloudflare's hosted MCP server (MCP_ENDPOINT overrides).
+	DefaultEndpoint = "https://mcp.cloudflare.com/mcp"
+	// DefaultProtocolVersion is the MCP revision sent in initialize
+	// (MCP_PROTOCOL_VERSION overrides).
+	DefaultProtocolVersion = "2024-11-05"
 )
 
+// ValidateEndpoint checks that endpoint is an absolute http(s) URL.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid MCP endpoint %q: %w", endpoint, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid MCP endpoint %q: must be an http:// or https:// URL", endpoint)
+	}
+	return nil
+}
+
 // Client connects to Cloudflare's MCP endpoint via Streamable HTTP with Bearer token auth.
 type Client struct {
-	Endpoint    string
-	APIToken    string
-	AccountID   string
-	http        *http.Client
-	mu          sync.Mutex
-	requestID   int
-	initialized bool
+	Endpoint        string
+	APIToken        string
+	AccountID       string
+	ProtocolVersion string // sent in initialize; empty = DefaultProtocolVersion
+	http            *http.Client
+	mu              sync.Mutex
+	idMu            sync.Mutex // guards requestID; calls may run concurrently
+	requestID       int
+	initialized     bool
+	sessionID       string // Mcp-Session-Id from initialize, for servers that keep sessions
 }
 
 // NewClient creates a new MCP client for the given endpoint and API token.
@@ -76,13 +99,25 @@ func (c *Client) post(ctx context.Context, req *jsonRPCRequest) (*jsonRPCRespons
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "application/json, text/event-stream")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIToken)
+	if c.APIToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIToken)
+	}
+	c.idMu.Lock()
+	if c.sessionID != "" {
+		httpReq.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	c.idMu.Unlock()
 
 	resp, err := c.http.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		c.idMu.Lock()
+		c.sessionID = id
+		c.idMu.Unlock()
+	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		body, _ := io.ReadAll(resp.Body)
@@ -99,6 +134,12 @@ func (c *Client) post(ctx context.Context, req *jsonRPCRequest) (*jsonRPCRespons
 		return &jsonRPCResponse{JSONRPC: "2.0", Result: json.RawMessage(`{}`)}, nil
 	}
 
+	// Streamable HTTP servers may answer with an event stream; the response
+	// is the last JSON-RPC message in it
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body = lastSSEData(body)
+	}
+
 	var rpcResp jsonRPCResponse
 	if err := json.Unmarshal(body, &rpcResp); err != nil {
 		return nil, fmt.Errorf("decode MCP response: %w", err)
@@ -121,12 +162,16 @@ func (c *Client) Initialize(ctx context.Context) error {
 		return nil
 	}
 
+	version := c.ProtocolVersion
+	if version == "" {
+		version = DefaultProtocolVersion
+	}
 	req := &jsonRPCRequest{
 		JSONRPC: "2.0",
 		ID:      c.nextID(),
 		Method:  "initialize",
 		Params: map[string]interface{}{
-			"protocolVersion": "2024-11-05",
+			"protocolVersion": version,
 			"capabilities":    map[string]interface{}{},
 			"clientInfo": map[string]interface{}{
 				"name":    "picoflare",
@@ -151,7 +196,30 @@ func (c *Client) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// lastSSEData returns the data of the last event in a server-sent event
+// stream, joining multi-line data fields.
+func lastSSEData(stream []byte) []byte {
+	var last, cur []string
+	for _, line := range strings.Split(string(stream), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case line == "":
+			if len(cur) > 0 {
+				last, cur = cur, nil
+			}
+		case strings.HasPrefix(line, "data:"):
+			cur = append(cur, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(cur) > 0 {
+		last = cur
+	}
+	return []byte(strings.Join(last, "\n"))
+}
+
 func (c *Client) nextID() int {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
 	id := c.requestID
 	c.requestID++
 	return id
@@ -0,0 +1,119 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ToolInfo describes a tool offered by an MCP server (tools/list).
+type ToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+// maxToolPages bounds tools/list pagination against a server that never
+// stops returning a cursor.
+const maxToolPages = 20
+
+// ensureInitialized runs the initialize handshake once.
+func (c *Client) ensureInitialized(ctx context.Context) error {
+	c.mu.Lock()
+	done := c.initialized
+	c.mu.Unlock()
+	if done {
+		return nil
+	}
+	return c.Initialize(ctx)
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	if err := c.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+	var tools []ToolInfo
+	cursor := ""
+	for page := 0; page < maxToolPages; page++ {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		resp, err := c.post(ctx, &jsonRPCRequest{
+			JSONRPC: "2.0",
+			ID:      c.nextID(),
+			Method:  "tools/list",
+			Params:  params,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor,omitempty"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("decode tools/list: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// CallTool calls a server tool and returns its text content. A result the
+// server flags as an error is returned as an error.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	if err := c.ensureInitialized(ctx); err != nil {
+		return "", err
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	resp, err := c.post(ctx, &jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID(),
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      name,
+			"arguments": args,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text,omitempty"`
+			MimeType string `json:"mimeType,omitempty"`
+		} `json:"content"`
+		IsError bool `json:"isError,omitempty"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return string(resp.Result), nil
+	}
+	var parts []string
+	for _, part := range result.Content {
+		switch {
+		case part.Text != "":
+			parts = append(parts, part.Text)
+		case part.Type != "":
+			parts = append(parts, fmt.Sprintf("[%s content %s]", part.Type, part.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if text == "" {
+		text = string(resp.Result)
+	}
+	if result.IsError {
+		return "", fmt.Errorf("%s: %s", name, text)
+	}
+	return text, nil
+}
@@ -35,32 +35,40 @@ type VectorMatch struct {
 	Score float64 `json:"score"`
 }
 
-// InsertVector upserts a vector into the given index.
-// Uses POST to .../upsert with JSON { "vectors": [{ "id", "values", "metadata" }] }.
-func (c *Client) InsertVector(ctx context.Context, indexName, id string, vector []float64, metadata map[string]string) error {
+// InsertVector upserts a vector into the given index, in namespace if set.
+// Vectorize v2 takes upserts as NDJSON, one vector per line:
+// POST .../upsert with { "id", "values", "metadata", "namespace" }.
+func (c *Client) InsertVector(ctx context.Context, indexName, namespace, id string, vector []float64, metadata map[string]string) error {
 	if metadata == nil {
 		metadata = make(map[string]string)
 	}
-	body := map[string]interface{}{
-		"vectors": []map[string]interface{}{
-			{
-				"id":       id,
-				"values":   vector,
-				"metadata": metadata,
-			},
-		},
-	}
-	return c.post(ctx, indexName, "upsert", body)
+	v := map[string]interface{}{
+		"id":       id,
+		"values":   vector,
+		"metadata": metadata,
+	}
+	if namespace != "" {
+		v["namespace"] = namespace
+	}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, indexName, "upsert", "application/x-ndjson", append(line, '\n'))
 }
 
-// QueryVector queries the index with the given vector and returns top K matches.
-func (c *Client) QueryVector(ctx context.Context, indexName string, queryVector []float64, topK int) ([]VectorMatch, error) {
+// QueryVector queries the index with the given vector and returns top K
+// matches, searching only namespace if set.
+func (c *Client) QueryVector(ctx context.Context, indexName, namespace string, queryVector []float64, topK int) ([]VectorMatch, error) {
 	body := map[string]interface{}{
 		"vector":         queryVector,
 		"topK":           topK,
 		"returnValues":   false,
 		"returnMetadata": "none",
 	}
+	if namespace != "" {
+		body["namespace"] = namespace
+	}
 	reqBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -94,19 +102,29 @@ func (c *Client) QueryVector(ctx context.Context, indexName string, queryVector
 	return result.Matches, nil
 }
 
+// DeleteVectors removes the vectors with the given IDs from the index.
+// Uses POST to .../delete_by_ids with JSON { "ids": [...] }.
+func (c *Client) DeleteVectors(ctx context.Context, indexName string, ids []string) error {
+	return c.post(ctx, indexName, "delete_by_ids", map[string]interface{}{"ids": ids})
+}
+
 func (c *Client) post(ctx context.Context, indexName, path string, body interface{}) error {
 	reqBody, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
+	return c.send(ctx, indexName, path, "application/json", reqBody)
+}
 
+// send POSTs body, of the given content type, to path under the index.
+func (c *Client) send(ctx context.Context, indexName, path, contentType string, body []byte) error {
 	url := fmt.Sprintf("%s/%s/%s", c.BaseURL, indexName, path)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.APIToken)
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
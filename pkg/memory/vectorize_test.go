@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInsertVectorSendsNDJSON(t *testing.T) {
+	var path, contentType string
+	var lines []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var v map[string]interface{}
+			if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			lines = append(lines, v)
+		}
+		w.Write([]byte(`{"success":true,"result":{"mutationId":"m1"}}`))
+	}))
+	defer srv.Close()
+	c := NewClient("acct", "token")
+	c.BaseURL = srv.URL
+
+	err := c.InsertVector(context.Background(), "my-index", "chat-1", "fact-1", []float64{0.5, 0.25}, map[string]string{"category": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/my-index/upsert" || contentType != "application/x-ndjson" {
+		t.Fatalf("POST %s as %q, want /my-index/upsert as application/x-ndjson", path, contentType)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("body has %d vectors, want 1 line", len(lines))
+	}
+	v := lines[0]
+	if v["id"] != "fact-1" || v["namespace"] != "chat-1" || len(v["values"].([]interface{})) != 2 {
+		t.Fatalf("vector = %v", v)
+	}
+}
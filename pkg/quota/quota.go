@@ -4,13 +4,19 @@ package quota
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/bigneek/picoflare/pkg/clock"
 	"github.com/bigneek/picoflare/pkg/storage"
 )
 
+// ErrExceeded is wrapped by the errors Check and CheckUpload return when a
+// limit would be exceeded.
+var ErrExceeded = errors.New("quota exceeded")
+
 // Usage holds per-agent usage stats.
 type Usage struct {
 	AgentID          string    `json:"agent_id"`
@@ -21,8 +27,20 @@ type Usage struct {
 	StorageBytes     int64     `json:"storage_bytes"`
 	LastUsed         time.Time `json:"last_used"`
 	CreatedAt        time.Time `json:"created_at"`
+
+	// PeriodStart is the start of the current reset window (see Limits.ResetPeriod).
+	PeriodStart time.Time `json:"period_start,omitempty"`
 }
 
+// ResetPeriod controls how often usage counters roll over.
+type ResetPeriod string
+
+const (
+	ResetNone    ResetPeriod = ""        // absolute caps that never reset
+	ResetDaily   ResetPeriod = "daily"   // counters reset at local midnight
+	ResetMonthly ResetPeriod = "monthly" // counters reset on the 1st of the month
+)
+
 // Limits defines per-agent quotas. Zero = unlimited.
 type Limits struct {
 	MaxMessages         int64
@@ -30,27 +48,48 @@ type Limits struct {
 	MaxCompletionTokens int64
 	MaxToolCalls        int64
 	MaxStorageBytes     int64
+
+	// ResetPeriod turns the caps into recurring allowances. Storage is a
+	// footprint, not a flow, so StorageBytes is never reset.
+	ResetPeriod ResetPeriod
 }
 
 // Manager tracks and enforces per-agent quotas.
 type Manager struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
 	limits Limits
 	mu     sync.Mutex
 	cache  map[string]*Usage
+
+	measuredAt map[string]time.Time // last MeasureStorage per agent
+
+	clock clock.Clock // see SetClock
 }
 
+// storageMeasureTTL is how long a MeasureStorage result is reused before
+// listing the agent's objects again.
+const storageMeasureTTL = 10 * time.Minute
+
 // NewManager creates a quota manager.
-func NewManager(r2 *storage.R2Client, bucket string, limits Limits) *Manager {
+func NewManager(r2 storage.ObjectStore, bucket string, limits Limits) *Manager {
 	return &Manager{
 		r2:     r2,
 		bucket: bucket,
 		limits: limits,
 		cache:  make(map[string]*Usage),
+
+		measuredAt: make(map[string]time.Time),
+		clock:      clock.Real,
 	}
 }
 
+// SetClock replaces the clock used for reset windows and timestamps. Call
+// before the manager is in use.
+func (m *Manager) SetClock(c clock.Clock) {
+	m.clock = clock.OrReal(c)
+}
+
 func (m *Manager) key(agentID string) string {
 	return fmt.Sprintf("agents/%s/quota.json", agentID)
 }
@@ -58,15 +97,15 @@ func (m *Manager) key(agentID string) string {
 // Load loads usage for an agent from R2.
 func (m *Manager) Load(ctx context.Context, agentID string) (*Usage, error) {
 	if m.r2 == nil {
-		return &Usage{AgentID: agentID, CreatedAt: time.Now()}, nil
+		return &Usage{AgentID: agentID, CreatedAt: m.clock.Now()}, nil
 	}
 	data, err := m.r2.DownloadObject(ctx, m.bucket, m.key(agentID))
 	if err != nil {
-		return &Usage{AgentID: agentID, CreatedAt: time.Now()}, nil
+		return &Usage{AgentID: agentID, CreatedAt: m.clock.Now()}, nil
 	}
 	var u Usage
 	if err := json.Unmarshal(data, &u); err != nil {
-		return &Usage{AgentID: agentID, CreatedAt: time.Now()}, nil
+		return &Usage{AgentID: agentID, CreatedAt: m.clock.Now()}, nil
 	}
 	return &u, nil
 }
@@ -76,7 +115,7 @@ func (m *Manager) Save(ctx context.Context, u *Usage) error {
 	if m.r2 == nil {
 		return nil
 	}
-	u.LastUsed = time.Now()
+	u.LastUsed = m.clock.Now()
 	data, err := json.Marshal(u)
 	if err != nil {
 		return err
@@ -84,26 +123,60 @@ func (m *Manager) Save(ctx context.Context, u *Usage) error {
 	return m.r2.UploadObject(ctx, m.bucket, m.key(u.AgentID), data)
 }
 
+// periodStart returns the start of the reset window containing t.
+func periodStart(p ResetPeriod, t time.Time) time.Time {
+	switch p {
+	case ResetDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case ResetMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// rollover zeroes the periodic counters if the reset window has elapsed.
+// Returns true if u changed.
+func (m *Manager) rollover(u *Usage, now time.Time) bool {
+	if m.limits.ResetPeriod == ResetNone {
+		return false
+	}
+	start := periodStart(m.limits.ResetPeriod, now)
+	if !u.PeriodStart.Before(start) {
+		return false
+	}
+	u.Messages = 0
+	u.PromptTokens = 0
+	u.CompletionTokens = 0
+	u.ToolCalls = 0
+	u.PeriodStart = start
+	return true
+}
+
 // Check returns an error if the agent would exceed limits after the given delta.
 func (m *Manager) Check(ctx context.Context, agentID string, delta Usage) error {
 	u, err := m.Load(ctx, agentID)
 	if err != nil {
 		return err
 	}
+	if m.rollover(u, m.clock.Now()) {
+		if err := m.Save(ctx, u); err != nil {
+			return fmt.Errorf("save quota window: %w", err)
+		}
+	}
 	if m.limits.MaxMessages > 0 && u.Messages+delta.Messages > m.limits.MaxMessages {
-		return fmt.Errorf("quota exceeded: messages (%d/%d)", u.Messages+delta.Messages, m.limits.MaxMessages)
+		return fmt.Errorf("%w: messages (%d/%d)", ErrExceeded, u.Messages+delta.Messages, m.limits.MaxMessages)
 	}
 	if m.limits.MaxPromptTokens > 0 && u.PromptTokens+delta.PromptTokens > m.limits.MaxPromptTokens {
-		return fmt.Errorf("quota exceeded: prompt tokens (%d/%d)", u.PromptTokens+delta.PromptTokens, m.limits.MaxPromptTokens)
+		return fmt.Errorf("%w: prompt tokens (%d/%d)", ErrExceeded, u.PromptTokens+delta.PromptTokens, m.limits.MaxPromptTokens)
 	}
 	if m.limits.MaxCompletionTokens > 0 && u.CompletionTokens+delta.CompletionTokens > m.limits.MaxCompletionTokens {
-		return fmt.Errorf("quota exceeded: completion tokens (%d/%d)", u.CompletionTokens+delta.CompletionTokens, m.limits.MaxCompletionTokens)
+		return fmt.Errorf("%w: completion tokens (%d/%d)", ErrExceeded, u.CompletionTokens+delta.CompletionTokens, m.limits.MaxCompletionTokens)
 	}
 	if m.limits.MaxToolCalls > 0 && u.ToolCalls+delta.ToolCalls > m.limits.MaxToolCalls {
-		return fmt.Errorf("quota exceeded: tool calls (%d/%d)", u.ToolCalls+delta.ToolCalls, m.limits.MaxToolCalls)
+		return fmt.Errorf("%w: tool calls (%d/%d)", ErrExceeded, u.ToolCalls+delta.ToolCalls, m.limits.MaxToolCalls)
 	}
 	if m.limits.MaxStorageBytes > 0 && u.StorageBytes+delta.StorageBytes > m.limits.MaxStorageBytes {
-		return fmt.Errorf("quota exceeded: storage (%d/%d bytes)", u.StorageBytes+delta.StorageBytes, m.limits.MaxStorageBytes)
+		return fmt.Errorf("%w: storage (%d/%d bytes)", ErrExceeded, u.StorageBytes+delta.StorageBytes, m.limits.MaxStorageBytes)
 	}
 	return nil
 }
@@ -114,14 +187,72 @@ func (m *Manager) Record(ctx context.Context, agentID string, delta Usage) error
 	if err != nil {
 		return err
 	}
+	m.rollover(u, m.clock.Now())
 	u.Messages += delta.Messages
 	u.PromptTokens += delta.PromptTokens
 	u.CompletionTokens += delta.CompletionTokens
 	u.ToolCalls += delta.ToolCalls
 	u.StorageBytes += delta.StorageBytes
-	u.LastUsed = time.Now()
+	u.LastUsed = m.clock.Now()
 	if u.CreatedAt.IsZero() {
-		u.CreatedAt = time.Now()
+		u.CreatedAt = m.clock.Now()
 	}
 	return m.Save(ctx, u)
 }
+
+// MeasureStorage sums the size of the agent's objects under agents/{id}/
+// (plus any extra prefixes, e.g. the user's users/{id}/ files) and stores it
+// in Usage.StorageBytes. Results are cached for storageMeasureTTL; in between,
+// Record keeps the figure current with StorageBytes deltas.
+func (m *Manager) MeasureStorage(ctx context.Context, agentID string, extraPrefixes ...string) (int64, error) {
+	m.mu.Lock()
+	last, ok := m.measuredAt[agentID]
+	m.mu.Unlock()
+	if ok && m.clock.Now().Sub(last) < storageMeasureTTL {
+		u, err := m.Load(ctx, agentID)
+		if err != nil {
+			return 0, err
+		}
+		return u.StorageBytes, nil
+	}
+	if m.r2 == nil {
+		return 0, nil
+	}
+
+	var total int64
+	for _, prefix := range append([]string{fmt.Sprintf("agents/%s/", agentID)}, extraPrefixes...) {
+		objects, err := m.r2.ListObjectInfos(ctx, m.bucket, prefix)
+		if err != nil {
+			return 0, fmt.Errorf("measure storage %s: %w", prefix, err)
+		}
+		for _, o := range objects {
+			total += o.Size
+		}
+	}
+
+	u, err := m.Load(ctx, agentID)
+	if err != nil {
+		return 0, err
+	}
+	u.StorageBytes = total
+	if err := m.Save(ctx, u); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.measuredAt[agentID] = m.clock.Now()
+	m.mu.Unlock()
+	return total, nil
+}
+
+// CheckUpload measures the agent's storage (cached) and returns an error if
+// adding size bytes would exceed MaxStorageBytes. Call before large uploads.
+func (m *Manager) CheckUpload(ctx context.Context, agentID string, size int64, extraPrefixes ...string) error {
+	if m.limits.MaxStorageBytes <= 0 {
+		return nil
+	}
+	if _, err := m.MeasureStorage(ctx, agentID, extraPrefixes...); err != nil {
+		return err
+	}
+	return m.Check(ctx, agentID, Usage{StorageBytes: size})
+}
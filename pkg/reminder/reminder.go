@@ -0,0 +1,303 @@
+// Package reminder stores scheduled chat reminders in R2 so they survive
+// restarts. The bot polls Due and delivers each reminder once.
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/clock"
+	"github.com/bigneek/picoflare/pkg/storage"
+)
+
+const keyPrefix = "memory/reminders/"
+
+// Reminder is a message to send to a chat at a given time.
+type Reminder struct {
+	ID        string    `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	FireAt    time.Time `json:"fire_at"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps pending reminders in memory, backed by one R2 object per
+// reminder under memory/reminders/.
+type Store struct {
+	r2     storage.ObjectStore
+	bucket string
+
+	clock clock.Clock // see SetClock
+
+	mu    sync.Mutex
+	items map[string]Reminder
+}
+
+// NewStore creates a reminder store. Call Load on boot to restore pending reminders.
+func NewStore(r2 storage.ObjectStore, bucket string) *Store {
+	return &Store{r2: r2, bucket: bucket, clock: clock.Real, items: make(map[string]Reminder)}
+}
+
+// SetClock replaces the clock that reminder times are parsed against and
+// checked with. Call before use.
+func (s *Store) SetClock(c clock.Clock) {
+	s.clock = clock.OrReal(c)
+}
+
+// Now is the store's current time: the reference for parsing "in 2h" and the
+// time to pass to Due.
+func (s *Store) Now() time.Time {
+	return s.clock.Now()
+}
+
+func key(id string) string {
+	return keyPrefix + id + ".json"
+}
+
+// Load reads all pending reminders from R2.
+func (s *Store) Load(ctx context.Context) error {
+	if s.r2 == nil {
+		return nil
+	}
+	keys, err := s.r2.ListObjects(ctx, s.bucket, keyPrefix, 1000)
+	if err != nil {
+		return fmt.Errorf("list reminders: %w", err)
+	}
+	loaded := make(map[string]Reminder, len(keys))
+	for _, k := range keys {
+		data, err := s.r2.DownloadObject(ctx, s.bucket, k)
+		if err != nil {
+			log.Printf("reminder: read %s: %v", k, err)
+			continue
+		}
+		var r Reminder
+		if err := json.Unmarshal(data, &r); err != nil || r.ID == "" {
+			log.Printf("reminder: skipping corrupt %s", k)
+			continue
+		}
+		loaded[r.ID] = r
+	}
+
+	s.mu.Lock()
+	for id, r := range loaded {
+		s.items[id] = r
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Add schedules a reminder and persists it.
+func (s *Store) Add(ctx context.Context, chatID int64, fireAt time.Time, text string) (Reminder, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Reminder{}, fmt.Errorf("reminder text is required")
+	}
+	r := Reminder{
+		ID:        fmt.Sprintf("rem-%d", time.Now().UnixNano()),
+		ChatID:    chatID,
+		FireAt:    fireAt,
+		Text:      text,
+		CreatedAt: s.clock.Now(),
+	}
+	if s.r2 != nil {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return Reminder{}, err
+		}
+		if err := s.r2.UploadObject(ctx, s.bucket, key(r.ID), data); err != nil {
+			return Reminder{}, fmt.Errorf("save reminder: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.items[r.ID] = r
+	s.mu.Unlock()
+	return r, nil
+}
+
+// Remove deletes a reminder (after delivery or on cancel).
+func (s *Store) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	_, ok := s.items[id]
+	delete(s.items, id)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("reminder %q not found", id)
+	}
+	if s.r2 != nil {
+		return s.r2.DeleteObject(ctx, s.bucket, key(id))
+	}
+	return nil
+}
+
+// Due returns reminders whose time has come, oldest first.
+func (s *Store) Due(now time.Time) []Reminder {
+	s.mu.Lock()
+	var due []Reminder
+	for _, r := range s.items {
+		if !r.FireAt.After(now) {
+			due = append(due, r)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].FireAt.Before(due[j].FireAt) })
+	return due
+}
+
+// List returns a chat's pending reminders, soonest first.
+func (s *Store) List(chatID int64) []Reminder {
+	s.mu.Lock()
+	var out []Reminder
+	for _, r := range s.items {
+		if r.ChatID == chatID {
+			out = append(out, r)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].FireAt.Before(out[j].FireAt) })
+	return out
+}
+
+// --- Natural time parsing ---
+
+var (
+	relativeRe = regexp.MustCompile(`^in\s+(\d+|an?)\s*(minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w)$`)
+	clockRe    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	weekdays   = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	}
+)
+
+// defaultHour is used when a day is given without a time ("tomorrow").
+const defaultHour = 9
+
+// ParseTime parses a natural time relative to now, in now's location.
+// Supported: "in 10 minutes", "in 2h", "tomorrow at 9am", "today 17:30",
+// "at 9pm" / "21:00" (next occurrence), "friday at 10am", "noon",
+// "2026-01-02 15:04", RFC 3339, and Go durations like "90m".
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	in := strings.ToLower(strings.TrimSpace(s))
+	in = strings.Join(strings.Fields(in), " ")
+	if in == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+
+	if m := relativeRe.FindStringSubmatch(in); m != nil {
+		n := 1
+		if m[1] != "a" && m[1] != "an" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		var unit time.Duration
+		switch m[2][0] {
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	}
+	if d, err := time.ParseDuration(in); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(in), now.Location()); err == nil {
+			if layout == "2006-01-02" {
+				t = t.Add(defaultHour * time.Hour)
+			}
+			return t, nil
+		}
+	}
+
+	// "<day> [at] <clock>" or "[at] <clock>"
+	day, rest := "", in
+	if first, after, _ := strings.Cut(in, " "); isDay(first) {
+		day, rest = first, after
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "at "))
+	if rest == "at" {
+		rest = ""
+	}
+
+	hour, minute := defaultHour, 0
+	if rest != "" {
+		var err error
+		if hour, minute, err = parseClock(rest); err != nil {
+			return time.Time{}, fmt.Errorf("can't parse time %q (try \"in 30 minutes\", \"tomorrow at 9am\", or \"2006-01-02 15:04\")", s)
+		}
+	} else if day == "" {
+		return time.Time{}, fmt.Errorf("can't parse time %q", s)
+	}
+
+	base := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	switch {
+	case day == "today":
+		return base, nil
+	case day == "tomorrow":
+		return base.AddDate(0, 0, 1), nil
+	case day != "":
+		ahead := (int(weekdays[day]) - int(now.Weekday()) + 7) % 7
+		t := base.AddDate(0, 0, ahead)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 7)
+		}
+		return t, nil
+	}
+	// Bare clock time: next occurrence
+	if !base.After(now) {
+		base = base.AddDate(0, 0, 1)
+	}
+	return base, nil
+}
+
+func isDay(w string) bool {
+	_, ok := weekdays[w]
+	return ok || w == "today" || w == "tomorrow"
+}
+
+// parseClock parses "9am", "9:30 pm", "21:00", "noon", or "midnight".
+func parseClock(s string) (hour, minute int, err error) {
+	switch s {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+	m := clockRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("bad clock time %q", s)
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" && (hour < 1 || hour > 12) {
+		return 0, 0, fmt.Errorf("bad clock time %q", s)
+	}
+	switch m[3] {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("bad clock time %q", s)
+	}
+	return hour, minute, nil
+}
@@ -0,0 +1,91 @@
+// Package retry decides when a failed call to a remote API (R2, the
+// Cloudflare REST API) is worth repeating and how long to wait first:
+// exponential backoff with jitter, on 429, 5xx, and network errors.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultAttempts is how many times a call is tried in total (the first try
+// plus two retries) unless a client is configured otherwise.
+const DefaultAttempts = 3
+
+const (
+	baseDelay = 250 * time.Millisecond
+	maxDelay  = 8 * time.Second
+)
+
+// Backoff is the wait before retry n (0 for the first retry): baseDelay
+// doubled n times, capped at maxDelay, with the upper half randomized so
+// clients that failed together don't retry in lockstep.
+func Backoff(n int) time.Duration {
+	d := maxDelay
+	if n < 16 && baseDelay<<n < maxDelay {
+		d = baseDelay << n
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// Status reports whether a response with this HTTP status may succeed if
+// sent again: 429 and 5xx. Other 4xx responses won't change on retry.
+func Status(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Network reports whether err is a transport failure (connection refused or
+// reset, timeout, truncated response) rather than a response from the server.
+// Cancellation of the caller's context is not retryable.
+func Network(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// *url.Error is itself a net.Error; judge what it wraps
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// After parses a Retry-After header (seconds or an HTTP date), returning
+// fallback when it is missing or invalid.
+func After(header string, fallback time.Duration) time.Duration {
+	if header == "" {
+		return fallback
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
+
+// Sleep waits for d, returning early with ctx's error if it is canceled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package slack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	fencedRe     = regexp.MustCompile("(?s)```(\\w*)\\n?(.*?)```")
+	inlineCodeRe = regexp.MustCompile("`([^`\n]+)`")
+	headerRe     = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	boldRe       = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicRe     = regexp.MustCompile(`(^|[^*\w])\*([^*\n]+?)\*([^*\w]|$)`)
+	strikeRe     = regexp.MustCompile(`~~(.+?)~~`)
+	linkRe       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	bulletRe     = regexp.MustCompile(`(?m)^([ \t]*)[\-\*\+]\s+`)
+	ruleRe       = regexp.MustCompile(`(?m)^[\-\*]{3,}\s*$`)
+)
+
+// markdownToMrkdwn converts standard LLM markdown to Slack's mrkdwn:
+// *bold*, _italic_, ~strike~, <url|text> links, and ``` code blocks.
+// Slack has no headings or tables, so headings become bold lines and tables
+// are shown as preformatted text.
+func markdownToMrkdwn(md string) string {
+	var blocks, inlines []string
+
+	md = fencedRe.ReplaceAllStringFunc(md, func(match string) string {
+		parts := fencedRe.FindStringSubmatch(match)
+		blocks = append(blocks, parts[2])
+		return fmt.Sprintf("\x00CB%d\x00", len(blocks)-1)
+	})
+	md = wrapTables(md, func(table string) string {
+		blocks = append(blocks, table)
+		return fmt.Sprintf("\x00CB%d\x00", len(blocks)-1)
+	})
+	md = inlineCodeRe.ReplaceAllStringFunc(md, func(match string) string {
+		inlines = append(inlines, inlineCodeRe.FindStringSubmatch(match)[1])
+		return fmt.Sprintf("\x00IC%d\x00", len(inlines)-1)
+	})
+
+	// Slack treats &, <, and > as control characters in message text
+	md = escapeMrkdwn(md)
+
+	md = boldRe.ReplaceAllString(md, "\x01$1$2\x01")
+	md = headerRe.ReplaceAllStringFunc(md, func(line string) string {
+		title := headerRe.FindStringSubmatch(line)[1]
+		return "\x01" + strings.ReplaceAll(title, "\x01", "") + "\x01"
+	})
+	md = bulletRe.ReplaceAllString(md, "$1• ")
+	md = italicRe.ReplaceAllString(md, "${1}_${2}_${3}")
+	md = strings.ReplaceAll(md, "\x01", "*") // bold markers, placed after italics so they aren't re-read
+	md = strikeRe.ReplaceAllString(md, "~$1~")
+	md = linkRe.ReplaceAllString(md, "<$2|$1>")
+	md = ruleRe.ReplaceAllString(md, "")
+
+	for i, code := range inlines {
+		md = strings.Replace(md, fmt.Sprintf("\x00IC%d\x00", i), "`"+escapeMrkdwn(code)+"`", 1)
+	}
+	for i, code := range blocks {
+		md = strings.Replace(md, fmt.Sprintf("\x00CB%d\x00", i), "```\n"+strings.TrimRight(escapeMrkdwn(code), "\n ")+"\n```", 1)
+	}
+
+	md = regexp.MustCompile(`\n{3,}`).ReplaceAllString(md, "\n\n")
+	return strings.TrimSpace(md)
+}
+
+func escapeMrkdwn(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
+
+// wrapTables replaces runs of "|"-delimited lines (markdown tables) with
+// render's result, dropping the |---| separator row.
+func wrapTables(md string, render func(table string) string) string {
+	lines := strings.Split(md, "\n")
+	var out, table []string
+	flush := func() {
+		if len(table) >= 2 {
+			out = append(out, render(strings.Join(table, "\n")))
+		} else {
+			out = append(out, table...)
+		}
+		table = nil
+	}
+	for _, line := range lines {
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, "|") && strings.HasSuffix(t, "|") && len(t) > 1 {
+			if strings.Trim(t, "|-: ") == "" {
+				continue // separator row
+			}
+			table = append(table, t)
+			continue
+		}
+		flush()
+		out = append(out, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// splitMessage splits mrkdwn into chunks of at most maxLen bytes on line
+// boundaries. A code block split across chunks is closed and reopened so
+// each chunk renders on its own.
+func splitMessage(text string, maxLen int) []string {
+	if len(text) <= maxLen {
+		return []string{text}
+	}
+	const fence = "```"
+	var chunks []string
+	var cur strings.Builder
+	inCode := false
+	empty := func() bool { s := cur.String(); return s == "" || s == fence+"\n" }
+	flush := func() {
+		if empty() {
+			return
+		}
+		s := strings.TrimRight(cur.String(), "\n")
+		if inCode {
+			s += "\n" + fence
+		}
+		chunks = append(chunks, s)
+		cur.Reset()
+		if inCode {
+			cur.WriteString(fence + "\n")
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > maxLen-len(fence)*2-2 {
+			cut := maxLen - len(fence)*2 - 2
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			flush()
+			cur.WriteString(line[:cut])
+			flush()
+			line = line[cut:]
+		}
+		if cur.Len()+len(line)+1+len(fence)+1 > maxLen {
+			flush()
+		}
+		cur.WriteString(line + "\n")
+		if strings.Count(line, fence)%2 == 1 {
+			inCode = !inCode
+		}
+	}
+	inCode = false
+	flush()
+	return chunks
+}
@@ -0,0 +1,322 @@
+// Package slack runs the agent as a Slack app over the Events API: Slack POSTs
+// messages to an HTTP endpoint and replies go out through chat.postMessage.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bigneek/picoflare/pkg/agent"
+)
+
+const (
+	slackAPI = "https://slack.com/api/"
+
+	// maxSkew rejects signed requests older than this (replay protection).
+	maxSkew = 5 * time.Minute
+	// dedupeTTL is how long event IDs are remembered, since Slack retries
+	// deliveries it thinks were missed.
+	dedupeTTL = 10 * time.Minute
+	// maxMessage keeps replies under Slack's recommended text length.
+	maxMessage = 3500
+	// messageTimeout bounds handling one message, posting the reply
+	// included, so a stuck run can't pin its goroutine forever.
+	messageTimeout = 6 * time.Minute
+)
+
+// Config configures the Slack adapter.
+type Config struct {
+	BotToken      string // xoxb-… token with chat:write, app_mentions:read, im:history
+	SigningSecret string // app signing secret, verifies requests come from Slack
+	Addr          string // listen address, default ":3000"
+	Path          string // Events API request URL path, default "/slack/events"
+}
+
+// Adapter feeds Slack messages to an agent.Agent and posts the replies.
+type Adapter struct {
+	cfg       Config
+	agent     *agent.Agent
+	http      *http.Client
+	botUserID string
+	ctx       context.Context // Run's context, for work that outlives a request
+
+	mu   sync.Mutex
+	seen map[string]time.Time // event_id -> received
+}
+
+// New creates an adapter. Both the bot token and signing secret are required.
+func New(cfg Config, ag *agent.Agent) (*Adapter, error) {
+	if cfg.BotToken == "" {
+		return nil, fmt.Errorf("SLACK_BOT_TOKEN is required")
+	}
+	if cfg.SigningSecret == "" {
+		return nil, fmt.Errorf("SLACK_SIGNING_SECRET is required")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":3000"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/slack/events"
+	}
+	return &Adapter{
+		cfg:   cfg,
+		agent: ag,
+		http:  &http.Client{Timeout: 30 * time.Second},
+		seen:  make(map[string]time.Time),
+	}, nil
+}
+
+// Run verifies the token, then serves the Events API endpoint until ctx is done.
+func (a *Adapter) Run(ctx context.Context) error {
+	var auth struct {
+		UserID string `json:"user_id"`
+		Team   string `json:"team"`
+	}
+	if err := a.call(ctx, "auth.test", struct{}{}, &auth); err != nil {
+		return fmt.Errorf("slack auth: %w", err)
+	}
+	a.botUserID = auth.UserID
+	a.ctx = ctx
+	log.Printf("Slack: connected to %s as %s", auth.Team, auth.UserID)
+
+	mux := http.NewServeMux()
+	mux.Handle(a.cfg.Path, a)
+	srv := &http.Server{Addr: a.cfg.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Slack: listening on %s%s", a.cfg.Addr, a.cfg.Path)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type envelope struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	EventID   string `json:"event_id"`
+	Event     event  `json:"event"`
+}
+
+type event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+}
+
+// ServeHTTP handles Events API requests. Events are acknowledged at once and
+// processed in the background, since Slack retries anything not answered
+// within 3 seconds.
+func (a *Adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := verifySignature(a.cfg.SigningSecret, r.Header, body, time.Now()); err != nil {
+		log.Printf("Slack: rejected request: %v", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch env.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(env.Challenge))
+		return
+	case "event_callback":
+		w.WriteHeader(http.StatusOK)
+		if a.firstDelivery(env.EventID) {
+			go a.handleEvent(env.Event)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleEvent answers @mentions in channels and direct messages.
+func (a *Adapter) handleEvent(ev event) {
+	if ev.Subtype != "" || ev.BotID != "" || ev.User == "" || ev.User == a.botUserID {
+		return // edits, joins, and bot messages (including our own)
+	}
+	threadTS := ev.ThreadTS
+	switch {
+	case ev.Type == "app_mention":
+		if threadTS == "" {
+			threadTS = ev.TS // answer mentions in a thread to keep channels quiet
+		}
+	case ev.Type == "message" && ev.ChannelType == "im":
+	default:
+		return // channel messages arrive as app_mention when addressed to us
+	}
+
+	text := strings.TrimSpace(strings.ReplaceAll(ev.Text, "<@"+a.botUserID+">", ""))
+	if text == "" {
+		return
+	}
+	log.Printf("[slack %s] %s", ev.User, text)
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, messageTimeout)
+	defer cancel()
+	userCtx := fmt.Sprintf("[Slack user: <@%s>] %s", ev.User, text)
+	reply := a.agent.ProcessMessage(ctx, chatID(ev.Channel), userCtx)
+	if reply == "" {
+		reply = "(no response)"
+	}
+	for _, chunk := range splitMessage(markdownToMrkdwn(reply), maxMessage) {
+		if err := a.postMessage(ctx, ev.Channel, threadTS, chunk); err != nil {
+			log.Printf("Slack: post to %s failed: %v", ev.Channel, err)
+			return
+		}
+	}
+}
+
+// firstDelivery records eventID and reports whether it is new.
+func (a *Adapter) firstDelivery(eventID string) bool {
+	if eventID == "" {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for id, t := range a.seen {
+		if now.Sub(t) > dedupeTTL {
+			delete(a.seen, id)
+		}
+	}
+	if _, ok := a.seen[eventID]; ok {
+		return false
+	}
+	a.seen[eventID] = now
+	return true
+}
+
+// chatID maps a Slack channel to the int64 session key the agent uses, so
+// each channel or DM gets its own conversation and memory.
+func chatID(channel string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("slack:" + channel))
+	return int64(h.Sum64() &^ (1 << 63))
+}
+
+// verifySignature checks Slack's v0 request signature:
+// HMAC-SHA256 of "v0:<timestamp>:<body>" keyed by the signing secret.
+func verifySignature(secret string, h http.Header, body []byte, now time.Time) error {
+	tsHeader := h.Get("X-Slack-Request-Timestamp")
+	sig := h.Get("X-Slack-Signature")
+	if tsHeader == "" || sig == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp %q", tsHeader)
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > maxSkew || d < -maxSkew {
+		return fmt.Errorf("timestamp too old or in the future")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", tsHeader)
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func (a *Adapter) postMessage(ctx context.Context, channel, threadTS, text string) error {
+	msg := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+		"mrkdwn":  true,
+	}
+	if threadTS != "" {
+		msg["thread_ts"] = threadTS
+	}
+	return a.call(ctx, "chat.postMessage", msg, nil)
+}
+
+// call invokes a Slack Web API method with a JSON body and decodes the reply
+// into out (if non-nil). Slack reports failures as {"ok": false, "error": ...}.
+func (a *Adapter) call(ctx context.Context, method string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackAPI+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+a.cfg.BotToken)
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return fmt.Errorf("%s: HTTP %d: %s", method, resp.StatusCode, truncate(string(respBody), 200))
+	}
+	if !status.OK {
+		return fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tmpPrefix marks in-progress writes, which listings skip.
+const tmpPrefix = ".picoflare-tmp-"
+
+// FileStore is a ConditionalStore over a local directory, for running without
+// Cloudflare (STORAGE_BACKEND=file). Object bucket/key lives at
+// <root>/<bucket>/<key>. Writes go through a temp file and rename, so readers
+// never see partial objects. Conditional writes are atomic within one
+// process only.
+type FileStore struct {
+	root string
+	mu   sync.Mutex // serializes conditional writes
+}
+
+// NewFileStore returns a store rooted at dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("storage root directory is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage root: %w", err)
+	}
+	return &FileStore{root: abs}, nil
+}
+
+// path maps bucket/key to a file, rejecting names that would escape the root.
+func (s *FileStore) path(bucket, key string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket %q", bucket)
+	}
+	clean := path.Clean("/" + key)
+	if key == "" || strings.HasSuffix(key, "/") || clean == "/" || strings.Contains(key, `\`) || strings.HasPrefix(path.Base(clean), tmpPrefix) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.root, bucket, filepath.FromSlash(clean)), nil
+}
+
+func (s *FileStore) UploadObject(ctx context.Context, bucket, key string, data []byte) error {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data)
+}
+
+func (s *FileStore) DownloadObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return data, err
+}
+
+func (s *FileStore) DownloadObjectETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	data, err := s.DownloadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentETag(data), nil
+}
+
+func (s *FileStore) UploadObjectIf(ctx context.Context, bucket, key string, data []byte, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.DownloadObject(ctx, bucket, key)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	if (etag == "" && exists) || (etag != "" && (!exists || contentETag(current) != etag)) {
+		return ErrPreconditionFailed
+	}
+	return s.UploadObject(ctx, bucket, key, data)
+}
+
+func (s *FileStore) ListObjects(ctx context.Context, bucket, prefix string, maxKeys int) ([]string, error) {
+	infos, err := s.ListObjectInfos(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, info := range infos {
+		if maxKeys > 0 && len(keys) == maxKeys {
+			break
+		}
+		keys = append(keys, info.Key)
+	}
+	return keys, nil
+}
+
+// ListObjectInfos lists objects under prefix in key order, like S3.
+func (s *FileStore) ListObjectInfos(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	bucketDir, err := s.path(bucket, "x")
+	if err != nil {
+		return nil, err
+	}
+	bucketDir = filepath.Dir(bucketDir)
+
+	// Walk only the directory the prefix points into
+	start := bucketDir
+	if dir := path.Dir(path.Clean("/" + prefix + "x")); dir != "/" {
+		start = filepath.Join(bucketDir, filepath.FromSlash(dir))
+	}
+
+	var infos []ObjectInfo
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(bucketDir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed while listing
+		}
+		infos = append(infos, ObjectInfo{Key: key, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *FileStore) DeleteObject(ctx context.Context, bucket, key string) error {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return false, nil
+	}
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir(), nil
+}
+
+// writeFileAtomic writes data to a temp file beside p and renames it into place.
+func writeFileAtomic(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// contentETag derives an ETag from content, as S3 does for simple uploads.
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemStore is an in-memory ConditionalStore, for tests and for running
+// without R2 (STORAGE_BACKEND=memory); nothing survives a restart. Buckets
+// spring into existence on first write. The zero value is not usable; call
+// NewMemStore.
+type MemStore struct {
+	mu      sync.Mutex
+	objects map[string]memObject // bucket + "/" + key
+	version int
+}
+
+type memObject struct {
+	data []byte
+	etag string
+}
+
+// NewMemStore returns an empty store.
+func NewMemStore() *MemStore {
+	return &MemStore{objects: make(map[string]memObject)}
+}
+
+func memKey(bucket, key string) string { return bucket + "/" + key }
+
+// put stores a copy of data under a fresh ETag. The caller holds s.mu.
+func (s *MemStore) put(bucket, key string, data []byte) {
+	s.version++
+	s.objects[memKey(bucket, key)] = memObject{
+		data: append([]byte(nil), data...),
+		etag: strconv.Quote(strconv.Itoa(s.version)),
+	}
+}
+
+func (s *MemStore) UploadObject(ctx context.Context, bucket, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(bucket, key, data)
+	return nil
+}
+
+func (s *MemStore) DownloadObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	data, _, err := s.DownloadObjectETag(ctx, bucket, key)
+	return data, err
+}
+
+func (s *MemStore) DownloadObjectETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[memKey(bucket, key)]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return append([]byte(nil), obj.data...), obj.etag, nil
+}
+
+func (s *MemStore) UploadObjectIf(ctx context.Context, bucket, key string, data []byte, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[memKey(bucket, key)]
+	if (etag == "" && ok) || (etag != "" && (!ok || obj.etag != etag)) {
+		return ErrPreconditionFailed
+	}
+	s.put(bucket, key, data)
+	return nil
+}
+
+func (s *MemStore) ListObjects(ctx context.Context, bucket, prefix string, maxKeys int) ([]string, error) {
+	infos, _ := s.ListObjectInfos(ctx, bucket, prefix)
+	var keys []string
+	for _, info := range infos {
+		if maxKeys > 0 && len(keys) == maxKeys {
+			break
+		}
+		keys = append(keys, info.Key)
+	}
+	return keys, nil
+}
+
+// ListObjectInfos lists objects under prefix in key order, like S3.
+func (s *MemStore) ListObjectInfos(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var infos []ObjectInfo
+	for k, obj := range s.objects {
+		key := strings.TrimPrefix(k, bucket+"/")
+		if key == k || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		infos = append(infos, ObjectInfo{Key: key, Size: int64(len(obj.data))})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *MemStore) DeleteObject(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, memKey(bucket, key))
+	return nil
+}
+
+func (s *MemStore) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[memKey(bucket, key)]
+	return ok, nil
+}
@@ -1,29 +1,59 @@
 // Package storage provides R2 (S3-compatible) object storage via AWS SDK v2.
-// Endpoint: https://<ACCOUNT_ID>.r2.cloudflarestorage.com
+// Endpoint: https://<ACCOUNT_ID>.r2.cloudflarestorage.com, or any
+// S3-compatible server (MinIO, localstack) when overridden.
 package storage
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/bigneek/picoflare/pkg/retry"
 )
 
 // R2Client is an S3-compatible client for Cloudflare R2.
 type R2Client struct {
-	client *s3.Client
+	client   *s3.Client
+	uploader *manager.Uploader // multipart uploads for UploadReader
 }
 
 // NewR2Client creates an R2 client with the given account ID and R2 API credentials.
-// Uses endpoint https://<accountID>.r2.cloudflarestorage.com and region "auto".
-func NewR2Client(accountID, accessKeyID, secretAccessKey string) (*R2Client, error) {
-	if accountID == "" || accessKeyID == "" || secretAccessKey == "" {
-		return nil, fmt.Errorf("accountID, accessKeyID, and secretAccessKey are required")
+// Uses endpoint https://<accountID>.r2.cloudflarestorage.com and region "auto",
+// unless endpoint is set (e.g. http://localhost:9000 for MinIO), in which case
+// accountID may be empty. Requests are tried up to retry.DefaultAttempts times.
+func NewR2Client(accountID, accessKeyID, secretAccessKey, endpoint string) (*R2Client, error) {
+	return NewR2ClientWithRetries(accountID, accessKeyID, secretAccessKey, endpoint, retry.DefaultAttempts)
+}
+
+// NewR2ClientWithRetries is NewR2Client trying each request up to attempts
+// times (1 disables retrying). The SDK's standard retryer decides what is
+// retryable (throttling, 5xx, connection errors, not other 4xx); the waits
+// between tries come from retry.Backoff. A body from UploadReader that can't
+// be rewound is sent once.
+func NewR2ClientWithRetries(accountID, accessKeyID, secretAccessKey, endpoint string, attempts int) (*R2Client, error) {
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("accessKeyID and secretAccessKey are required")
+	}
+	if endpoint == "" {
+		if accountID == "" {
+			return nil, fmt.Errorf("accountID is required without an endpoint override")
+		}
+		endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
 	}
-	endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
 
 	cfg := aws.Config{
 		Region: "auto",
@@ -32,6 +62,14 @@ func NewR2Client(accountID, accessKeyID, secretAccessKey string) (*R2Client, err
 			secretAccessKey,
 			"",
 		),
+		Retryer: func() aws.Retryer {
+			return awsretry.NewStandard(func(o *awsretry.StandardOptions) {
+				o.MaxAttempts = max(attempts, 1)
+				o.Backoff = awsretry.BackoffDelayerFunc(func(attempt int, _ error) (time.Duration, error) {
+					return retry.Backoff(attempt - 1), nil // attempt counts from 1
+				})
+			})
+		},
 	}
 
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
@@ -39,57 +77,260 @@ func NewR2Client(accountID, accessKeyID, secretAccessKey string) (*R2Client, err
 		o.UsePathStyle = true
 	})
 
-	return &R2Client{client: client}, nil
+	return &R2Client{client: client, uploader: manager.NewUploader(client)}, nil
+}
+
+// Errors returned by Verify.
+var (
+	ErrR2Credentials  = errors.New("R2 credentials invalid (check R2_ACCESS_KEY_ID / R2_SECRET_ACCESS_KEY)")
+	ErrBucketNotFound = errors.New("R2 bucket not found")
+)
+
+// Verify makes one cheap request (HeadBucket) to confirm the credentials work
+// and the bucket exists. Errors wrap ErrR2Credentials or ErrBucketNotFound
+// when the cause is known.
+func (c *R2Client) Verify(ctx context.Context, bucket string) error {
+	_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return nil
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrR2Credentials
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+		}
+	}
+	return fmt.Errorf("verify R2 bucket %s: %w", bucket, err)
 }
 
 // UploadObject uploads data to the given bucket and key.
 func (c *R2Client) UploadObject(ctx context.Context, bucket, key string, data []byte) error {
-	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
-	})
+	return c.UploadObjectWith(ctx, bucket, key, data, UploadOptions{})
+}
+
+// UploadObjectWith is UploadObject with a content type and metadata.
+func (c *R2Client) UploadObjectWith(ctx context.Context, bucket, key string, data []byte, opts UploadOptions) error {
+	return c.UploadReaderWith(ctx, bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+}
+
+// UploadReader uploads r to the given bucket and key without reading it all
+// into memory. A seekable r of known size goes up in one PutObject; anything
+// else is sent as a multipart upload, buffering one part at a time.
+func (c *R2Client) UploadReader(ctx context.Context, bucket, key string, r io.Reader, size int64) error {
+	return c.UploadReaderWith(ctx, bucket, key, r, size, UploadOptions{})
+}
+
+// UploadReaderWith is UploadReader with a content type and metadata.
+func (c *R2Client) UploadReaderWith(ctx context.Context, bucket, key string, r io.Reader, size int64, opts UploadOptions) error {
+	in := &s3.PutObjectInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		Body:     r,
+		Metadata: opts.Metadata,
+	}
+	if opts.ContentType != "" {
+		in.ContentType = aws.String(opts.ContentType)
+	}
+	if _, ok := r.(io.ReadSeeker); ok && size >= 0 {
+		in.ContentLength = aws.Int64(size)
+		_, err := c.client.PutObject(ctx, in)
+		return err
+	}
+	_, err := c.uploader.Upload(ctx, in)
 	return err
 }
 
 // DownloadObject downloads the object at the given bucket and key.
 func (c *R2Client) DownloadObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	body, err := c.DownloadReader(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadReader opens the object at the given bucket and key for reading.
+// The caller must close it. A missing object returns an error wrapping
+// ErrObjectNotFound.
+func (c *R2Client) DownloadReader(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		if statusCode(err) == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
 		return nil, err
 	}
+	return out.Body, nil
+}
+
+// MaxPresignTTL is the longest lifetime R2 allows for a presigned URL.
+const MaxPresignTTL = 7 * 24 * time.Hour
+
+// PresignGetURL returns a URL that downloads the object without credentials
+// until ttl passes (capped at MaxPresignTTL). The download is saved under the
+// key's base name.
+func (c *R2Client) PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", fmt.Errorf("presign TTL must be positive")
+	}
+	ttl = min(ttl, MaxPresignTTL)
+	req, err := s3.NewPresignClient(c.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(key)})),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// Errors returned by the conditional read/write helpers.
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrPreconditionFailed means the object changed (or appeared) since it
+	// was read, so a conditional write was refused.
+	ErrPreconditionFailed = errors.New("object changed since it was read")
+)
+
+// DownloadObjectETag downloads an object along with its ETag, for a later
+// UploadObjectIf. A missing object returns an error wrapping ErrObjectNotFound.
+func (c *R2Client) DownloadObjectETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if statusCode(err) == http.StatusNotFound {
+			return nil, "", fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, "", err
+	}
 	defer out.Body.Close()
 
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(out.Body); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return buf.Bytes(), nil
+	return buf.Bytes(), aws.ToString(out.ETag), nil
 }
 
-// ListObjects lists objects under the given prefix. Returns keys (full paths).
-func (c *R2Client) ListObjects(ctx context.Context, bucket, prefix string, maxKeys int) ([]string, error) {
-	if maxKeys <= 0 {
-		maxKeys = 1000
+// UploadObjectIf writes data only if the object is unchanged: its ETag still
+// matches etag, or, with an empty etag, it doesn't exist yet. Otherwise it
+// returns ErrPreconditionFailed and the caller should re-read and retry.
+func (c *R2Client) UploadObjectIf(ctx context.Context, bucket, key string, data []byte, etag string) error {
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
 	}
-	out, err := c.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket:  aws.String(bucket),
-		Prefix:  aws.String(prefix),
-		MaxKeys: aws.Int32(int32(maxKeys)),
-	})
-	if err != nil {
-		return nil, err
+	if etag == "" {
+		in.IfNoneMatch = aws.String("*")
+	} else {
+		in.IfMatch = aws.String(etag)
+	}
+	_, err := c.client.PutObject(ctx, in)
+	switch statusCode(err) {
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return ErrPreconditionFailed
 	}
+	return err
+}
+
+// statusCode returns the HTTP status of a failed S3 call, or 0.
+func statusCode(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
+
+// listPageSize is the most keys S3 returns per ListObjectsV2 page.
+const listPageSize = 1000
+
+// ListObjects lists up to maxKeys objects under the given prefix, following
+// continuation tokens across pages; maxKeys <= 0 lists them all. Returns keys
+// (full paths).
+func (c *R2Client) ListObjects(ctx context.Context, bucket, prefix string, maxKeys int) ([]string, error) {
 	var keys []string
-	for _, o := range out.Contents {
-		if o.Key != nil {
-			keys = append(keys, *o.Key)
+	var token *string
+	for {
+		page := listPageSize
+		if maxKeys > 0 {
+			page = min(page, maxKeys-len(keys))
+		}
+		out, err := c.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucket),
+			Prefix:            aws.String(prefix),
+			MaxKeys:           aws.Int32(int32(page)),
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range out.Contents {
+			if o.Key != nil {
+				keys = append(keys, *o.Key)
+			}
+		}
+		if maxKeys > 0 && len(keys) >= maxKeys {
+			return keys[:maxKeys], nil
+		}
+		if !aws.ToBool(out.IsTruncated) || out.NextContinuationToken == nil {
+			return keys, nil
 		}
+		token = out.NextContinuationToken
+	}
+}
+
+// ObjectInfo is a listed object's key and size.
+type ObjectInfo struct {
+	Key  string
+	Size int64
+}
+
+// ListObjectInfos lists every object under the given prefix with its size,
+// following continuation tokens across pages.
+func (c *R2Client) ListObjectInfos(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	var token *string
+	for {
+		out, err := c.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range out.Contents {
+			if o.Key == nil {
+				continue
+			}
+			info := ObjectInfo{Key: *o.Key}
+			if o.Size != nil {
+				info.Size = *o.Size
+			}
+			infos = append(infos, info)
+		}
+		if out.IsTruncated == nil || !*out.IsTruncated || out.NextContinuationToken == nil {
+			return infos, nil
+		}
+		token = out.NextContinuationToken
 	}
-	return keys, nil
 }
 
 // DeleteObject deletes the object at the given bucket and key.
@@ -101,6 +342,41 @@ func (c *R2Client) DeleteObject(ctx context.Context, bucket, key string) error {
 	return err
 }
 
+// maxDeleteBatch is the most keys S3 DeleteObjects accepts per request.
+const maxDeleteBatch = 1000
+
+// DeleteObjects deletes keys, up to maxDeleteBatch per request. It reports
+// the first key the server failed to delete.
+func (c *R2Client) DeleteObjects(ctx context.Context, bucket string, keys []string) error {
+	for len(keys) > 0 {
+		n := min(len(keys), maxDeleteBatch)
+		ids := make([]types.ObjectIdentifier, n)
+		for i, k := range keys[:n] {
+			ids[i] = types.ObjectIdentifier{Key: aws.String(k)}
+		}
+		out, err := c.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// DeletePrefix deletes everything under prefix, up to maxDeleteBatch keys
+// per request, and returns how many objects were deleted. An empty prefix is
+// refused.
+func (c *R2Client) DeletePrefix(ctx context.Context, bucket, prefix string) (int, error) {
+	return DeletePrefix(ctx, c, bucket, prefix)
+}
+
 // ObjectExists returns true if the object exists.
 func (c *R2Client) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
 	_, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestR2CallStopsWhenContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // hang like an unresponsive endpoint
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	r2, err := NewR2ClientWithRetries("", "key", "secret", srv.URL, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = r2.DownloadObject(ctx, "bucket", "key")
+	if err == nil {
+		t.Fatal("DownloadObject succeeded against a hung server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("DownloadObject returned after %v, want it to stop at the 200ms deadline", elapsed)
+	}
+}
+
+// fakeS3 serves ListObjectsV2 over a fixed set of keys, in pages of at most
+// listPageSize, and records the page size of each request.
+type fakeS3 struct {
+	keys []string // sorted
+
+	mu    sync.Mutex
+	pages []int
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Contents              []listEntry
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+}
+
+type listEntry struct {
+	Key  string
+	Size int64
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		http.Error(w, "only ListObjectsV2", http.StatusNotImplemented)
+		return
+	}
+	size := listPageSize
+	if n, err := strconv.Atoi(q.Get("max-keys")); err == nil {
+		size = min(n, listPageSize)
+	}
+	f.mu.Lock()
+	f.pages = append(f.pages, size)
+	f.mu.Unlock()
+
+	var matching []string
+	for _, k := range f.keys {
+		if strings.HasPrefix(k, q.Get("prefix")) {
+			matching = append(matching, k)
+		}
+	}
+	start, _ := strconv.Atoi(q.Get("continuation-token"))
+	end := min(start+size, len(matching))
+	res := listResult{KeyCount: end - start, IsTruncated: end < len(matching)}
+	for _, k := range matching[start:end] {
+		res.Contents = append(res.Contents, listEntry{Key: k, Size: 1})
+	}
+	if res.IsTruncated {
+		res.NextContinuationToken = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+func TestR2ListObjectsFollowsPages(t *testing.T) {
+	fake := &fakeS3{}
+	for i := 0; i < 2500; i++ {
+		fake.keys = append(fake.keys, fmt.Sprintf("logs/%04d.txt", i))
+	}
+	fake.keys = append(fake.keys, "other/1.txt")
+	sort.Strings(fake.keys)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	r2, err := NewR2ClientWithRetries("", "key", "secret", srv.URL, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	keys, err := r2.ListObjects(ctx, "bucket", "logs/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2500 || keys[0] != "logs/0000.txt" || keys[2499] != "logs/2499.txt" {
+		t.Fatalf("listed %d keys (%v ... %v), want all 2500", len(keys), keys[:1], keys[len(keys)-1:])
+	}
+	if len(fake.pages) != 3 {
+		t.Fatalf("made %d list requests, want 3 pages", len(fake.pages))
+	}
+
+	fake.pages = nil
+	keys, err = r2.ListObjects(ctx, "bucket", "logs/", 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1200 {
+		t.Fatalf("maxKeys 1200 listed %d keys", len(keys))
+	}
+	if want := []int{1000, 200}; fmt.Sprint(fake.pages) != fmt.Sprint(want) {
+		t.Fatalf("page sizes %v, want %v", fake.pages, want)
+	}
+
+	infos, err := r2.ListObjectInfos(ctx, "bucket", "logs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2500 {
+		t.Fatalf("ListObjectInfos listed %d objects, want 2500", len(infos))
+	}
+}
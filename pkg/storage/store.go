@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ObjectStore is the object storage the agent depends on. R2Client
+// implements it against R2, MemStore keeps objects in memory, and FileStore
+// keeps them in a local directory.
+type ObjectStore interface {
+	UploadObject(ctx context.Context, bucket, key string, data []byte) error
+	DownloadObject(ctx context.Context, bucket, key string) ([]byte, error)
+	ListObjects(ctx context.Context, bucket, prefix string, maxKeys int) ([]string, error) // maxKeys <= 0 = all
+	ListObjectInfos(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+	ObjectExists(ctx context.Context, bucket, key string) (bool, error)
+}
+
+// ConditionalStore is an ObjectStore with ETag-based compare-and-set writes,
+// for read-modify-write updates that must not lose concurrent changes.
+type ConditionalStore interface {
+	ObjectStore
+	DownloadObjectETag(ctx context.Context, bucket, key string) ([]byte, string, error)
+	UploadObjectIf(ctx context.Context, bucket, key string, data []byte, etag string) error
+}
+
+// BatchDeleter is an ObjectStore that can delete many objects per request.
+type BatchDeleter interface {
+	ObjectStore
+	DeleteObjects(ctx context.Context, bucket string, keys []string) error
+}
+
+// UploadOptions are optional object attributes for an upload. Stores
+// without them (MemStore, FileStore) ignore them.
+type UploadOptions struct {
+	ContentType string            // e.g. "image/jpeg"; empty = the store's default
+	Metadata    map[string]string // custom metadata (x-amz-meta-*); ASCII values
+}
+
+// StreamStore is an ObjectStore that can move objects without holding them
+// in memory, for large files. size is the length of r, or -1 if unknown.
+type StreamStore interface {
+	ObjectStore
+	UploadReader(ctx context.Context, bucket, key string, r io.Reader, size int64) error
+	UploadReaderWith(ctx context.Context, bucket, key string, r io.Reader, size int64, opts UploadOptions) error
+	DownloadReader(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Presigner is an ObjectStore that can hand out time-limited download URLs.
+type Presigner interface {
+	ObjectStore
+	PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error)
+}
+
+// Upload writes r to key, streaming it if store is a StreamStore and reading
+// it into memory otherwise. It returns the number of bytes written.
+func Upload(ctx context.Context, store ObjectStore, bucket, key string, r io.Reader, size int64, opts UploadOptions) (int64, error) {
+	if ss, ok := store.(StreamStore); ok {
+		cr := &countingReader{r: r}
+		if err := ss.UploadReaderWith(ctx, bucket, key, cr, size, opts); err != nil {
+			return cr.n, err
+		}
+		return cr.n, nil
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), store.UploadObject(ctx, bucket, key, buf.Bytes())
+}
+
+// DeletePrefix deletes every object whose key starts with prefix, in batches
+// when store is a BatchDeleter, and returns how many were deleted. An empty
+// prefix is refused: it would wipe the bucket.
+func DeletePrefix(ctx context.Context, store ObjectStore, bucket, prefix string) (int, error) {
+	if strings.Trim(prefix, "/") == "" {
+		return 0, fmt.Errorf("refusing to delete everything in bucket %s: prefix is empty", bucket)
+	}
+	infos, err := store.ListObjectInfos(ctx, bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]string, len(infos))
+	for i, info := range infos {
+		keys[i] = info.Key
+	}
+	return DeleteKeys(ctx, store, bucket, keys)
+}
+
+// DeleteKeys deletes keys from bucket, in batches when store is a
+// BatchDeleter, and returns how many were deleted.
+func DeleteKeys(ctx context.Context, store ObjectStore, bucket string, keys []string) (int, error) {
+	if bd, ok := store.(BatchDeleter); ok {
+		if err := bd.DeleteObjects(ctx, bucket, keys); err != nil {
+			return 0, err
+		}
+		return len(keys), nil
+	}
+	for i, k := range keys {
+		if err := store.DeleteObject(ctx, bucket, k); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Backends selectable with STORAGE_BACKEND.
+const (
+	BackendR2     = "r2" // default
+	BackendMemory = "memory"
+	BackendFile   = "file" // under STORAGE_ROOT
+)
+
+// DefaultFileRoot is where the file backend keeps objects when STORAGE_ROOT
+// is unset.
+const DefaultFileRoot = "data"
+
+var (
+	_ ConditionalStore = (*R2Client)(nil)
+	_ ConditionalStore = (*MemStore)(nil)
+	_ ConditionalStore = (*FileStore)(nil)
+	_ StreamStore      = (*R2Client)(nil)
+	_ Presigner        = (*R2Client)(nil)
+	_ BatchDeleter     = (*R2Client)(nil)
+)
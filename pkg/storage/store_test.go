@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// manyObjects is more than one page of an S3 listing (listPageSize).
+const manyObjects = 1200
+
+func TestListObjectsPastOnePage(t *testing.T) {
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]ObjectStore{"mem": NewMemStore(), "file": fs} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < manyObjects; i++ {
+				if err := store.UploadObject(ctx, "b", fmt.Sprintf("logs/%04d.txt", i), []byte("x")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.UploadObject(ctx, "b", "other/1.txt", []byte("x")); err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := store.ListObjects(ctx, "b", "logs/", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != manyObjects {
+				t.Fatalf("listed %d keys, want %d", len(keys), manyObjects)
+			}
+			seen := make(map[string]bool, len(keys))
+			for _, k := range keys {
+				seen[k] = true
+			}
+			for i := 0; i < manyObjects; i++ {
+				if k := fmt.Sprintf("logs/%04d.txt", i); !seen[k] {
+					t.Fatalf("%s missing from the listing", k)
+				}
+			}
+			if keys, _ := store.ListObjects(ctx, "b", "logs/", 1100); len(keys) != 1100 {
+				t.Fatalf("maxKeys 1100 listed %d keys", len(keys))
+			}
+		})
+	}
+}
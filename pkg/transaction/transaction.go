@@ -10,7 +10,7 @@ import (
 
 // Txn tracks R2 writes and can revert them on Rollback.
 type Txn struct {
-	r2     *storage.R2Client
+	r2     storage.ObjectStore
 	bucket string
 	prefix string // e.g. agents/{agentID}/
 
@@ -21,7 +21,7 @@ type Txn struct {
 }
 
 // New creates a transaction scoped to the given prefix.
-func New(r2 *storage.R2Client, bucket, prefix string) *Txn {
+func New(r2 storage.ObjectStore, bucket, prefix string) *Txn {
 	return &Txn{
 		r2:     r2,
 		bucket: bucket,